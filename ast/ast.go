@@ -11,6 +11,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Pos // 最初の文字の位置
+	End() token.Pos // 最後の文字の次の位置
 }
 
 // Statement have some expression
@@ -34,6 +36,7 @@ type Literal interface {
 // Program is represent the entire program
 type Program struct {
 	Statements []Statement
+	Comments   []*CommentGroup // lexer.ScanComments の時のすべてのコメント
 }
 
 // TokenLiteral ...
@@ -59,6 +62,9 @@ func (p *Program) String() string {
 type ExpressionStatement struct {
 	Token      token.Token // first token
 	Expression Expression
+	Semicolon  token.Token // ';' (無い時もある)
+
+	Comments
 }
 
 func (es *ExpressionStatement) statementNode() {}
@@ -193,8 +199,9 @@ func (te *TernaryExpression) String() string {
 
 // CastExpression ...
 type CastExpression struct {
-	Token token.Token // string or int or float or matrix or vector
-	Right Expression
+	Token  token.Token // string or int or float or matrix or vector
+	Right  Expression
+	Lparen token.Token // '(' token
 }
 
 func (ce *CastExpression) expressionNode() {}
@@ -218,8 +225,9 @@ func (ce *CastExpression) String() string {
 
 // TypeDeclaration ...
 type TypeDeclaration struct {
-	Token   token.Token // string or int or float or matrix or vector
-	IsArray bool
+	Token    token.Token // string or int or float or matrix or vector
+	IsArray  bool
+	Rbracket token.Token // IsArray の時の ']' token
 }
 
 func (td *TypeDeclaration) expressionNode() {}
@@ -240,11 +248,36 @@ func (td *TypeDeclaration) String() string {
 	return td.Token.Literal
 }
 
+// CallSyntax is the syntax of a call
+type CallSyntax int
+
+// Syntaxes of CallExpression
+const (
+	FunctionCall  CallSyntax = iota // add(1, 2)
+	CommandCall                     // add 1 2; or add (1) 2;
+	BackQuoteCall                   // `add 1 2`
+)
+
+// String ...
+func (cs CallSyntax) String() string {
+	switch cs {
+	case FunctionCall:
+		return "function"
+	case CommandCall:
+		return "command"
+	case BackQuoteCall:
+		return "back-quote"
+	}
+	return "unknown"
+}
+
 // CallExpression ...
 type CallExpression struct {
 	Token     token.Token // '(' token or '`' token or function
 	Function  *Identifier // Identifier
 	Arguments []Expression
+	Close     token.Token // ')' or '`' token (command style では無い)
+	Syntax    CallSyntax
 }
 
 func (ce *CallExpression) expressionNode() {}
@@ -258,8 +291,9 @@ func (ce *CallExpression) TokenLiteral() string {
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
+	// flag と値はそれぞれ一つの引数として書く
 	var args []string
-	for _, a := range ce.Arguments {
+	for _, a := range FlattenArguments(ce.Arguments) {
 		args = append(args, a.String())
 	}
 
@@ -271,6 +305,54 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// FlagArgument is a flag and its values in a command style call.
+// ex) -type "double3" 1 2 3
+//
+// The parser takes the number of values from parser.SetFlagArity. Without
+// the signature of the flag, Values are the arguments up to the next flag,
+// and the last flag has no values because the arguments after it may be
+// objects. The objects after the values stay in the arguments of the call.
+type FlagArgument struct {
+	Token  token.Token // token.Flag
+	Flag   string      // "-type"
+	Values []Expression
+}
+
+func (fa *FlagArgument) expressionNode() {}
+
+// TokenLiteral ...
+func (fa *FlagArgument) TokenLiteral() string {
+	return fa.Token.Literal
+}
+
+// String ...
+func (fa *FlagArgument) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fa.Flag)
+	for _, v := range fa.Values {
+		out.WriteString(" " + v.String())
+	}
+
+	return out.String()
+}
+
+// FlattenArguments returns args with each FlagArgument expanded back into
+// the flag identifier and its values.
+func FlattenArguments(args []Expression) []Expression {
+	var flat []Expression
+	for _, a := range args {
+		fa, ok := a.(*FlagArgument)
+		if !ok {
+			flat = append(flat, a)
+			continue
+		}
+		flat = append(flat, &Identifier{Token: fa.Token, Value: fa.Flag})
+		flat = append(flat, fa.Values...)
+	}
+	return flat
+}
+
 // ForExpression ...
 type ForExpression struct {
 	Token       token.Token // for
@@ -362,6 +444,7 @@ type DoWhileExpression struct {
 	Token       token.Token // do
 	Condition   Expression
 	Consequence *BlockStatement
+	Rparen      token.Token // ')' token
 }
 
 func (dwe *DoWhileExpression) expressionNode() {}
@@ -449,6 +532,9 @@ func (ie *IfExpression) String() string {
 type BlockStatement struct {
 	Token      token.Token // '{' token
 	Statements []Statement
+	Rbrace     token.Token // '}' token (括弧の無いブロックでは無い)
+
+	Comments
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -477,6 +563,7 @@ type SwitchExpression struct {
 	Condition      Expression
 	Cases          []Literal
 	CaseStatements []*CaseStatement
+	Rbrace         token.Token // '}' token
 }
 
 func (se *SwitchExpression) expressionNode() {}
@@ -540,6 +627,8 @@ type ProcStatement struct {
 	ParamTypes []*TypeDeclaration
 	Parameters []Expression
 	Body       *BlockStatement
+
+	Comments
 }
 
 func (fl *ProcStatement) statementNode() {}
@@ -576,6 +665,7 @@ func (fl *ProcStatement) String() string {
 type CaseStatement struct {
 	Token      token.Token // ':' token
 	Statements []Statement
+	Keyword    token.Token // case or default
 }
 
 func (cs *CaseStatement) statementNode() {}
@@ -599,9 +689,12 @@ func (cs *CaseStatement) String() string {
 
 // VariableStatement ...
 type VariableStatement struct {
-	Names   []Expression
-	Assigns []token.Token
-	Values  []Expression
+	Names     []Expression
+	Assigns   []token.Token
+	Values    []Expression
+	Semicolon token.Token // ';'
+
+	Comments
 }
 
 func (vs *VariableStatement) statementNode() {}
@@ -633,10 +726,13 @@ func (vs *VariableStatement) String() string {
 
 // VectorStatement ...
 type VectorStatement struct {
-	Token   token.Token // token.VectorDec
-	Names   []Expression
-	Assigns []token.Token
-	Values  []Expression
+	Token     token.Token // token.VectorDec
+	Names     []Expression
+	Assigns   []token.Token
+	Values    []Expression
+	Semicolon token.Token // ';'
+
+	Comments
 }
 
 func (vs *VectorStatement) statementNode() {}
@@ -671,10 +767,13 @@ func (vs *VectorStatement) String() string {
 
 // MatrixStatement ...
 type MatrixStatement struct {
-	Token   token.Token // token.MatrixDec
-	Names   []Expression
-	Assigns []token.Token
-	Values  []Expression
+	Token     token.Token // token.MatrixDec
+	Names     []Expression
+	Assigns   []token.Token
+	Values    []Expression
+	Semicolon token.Token // ';'
+
+	Comments
 }
 
 func (ms *MatrixStatement) statementNode() {}
@@ -709,10 +808,13 @@ func (ms *MatrixStatement) String() string {
 
 // IntegerStatement ...
 type IntegerStatement struct {
-	Token   token.Token // token.IntDec
-	Names   []Expression
-	Assigns []token.Token
-	Values  []Expression
+	Token     token.Token // token.IntDec
+	Names     []Expression
+	Assigns   []token.Token
+	Values    []Expression
+	Semicolon token.Token // ';'
+
+	Comments
 }
 
 func (is *IntegerStatement) statementNode() {}
@@ -747,10 +849,13 @@ func (is *IntegerStatement) String() string {
 
 // FloatStatement ...
 type FloatStatement struct {
-	Token   token.Token // token.FloatDec
-	Names   []Expression
-	Assigns []token.Token
-	Values  []Expression
+	Token     token.Token // token.FloatDec
+	Names     []Expression
+	Assigns   []token.Token
+	Values    []Expression
+	Semicolon token.Token // ';'
+
+	Comments
 }
 
 func (fs *FloatStatement) statementNode() {}
@@ -785,10 +890,13 @@ func (fs *FloatStatement) String() string {
 
 // StringStatement ...
 type StringStatement struct {
-	Token   token.Token // token.StringDec
-	Names   []Expression
-	Assigns []token.Token // token.Assign or token.?Assign
-	Values  []Expression
+	Token     token.Token // token.StringDec
+	Names     []Expression
+	Assigns   []token.Token // token.Assign or token.?Assign
+	Values    []Expression
+	Semicolon token.Token // ';'
+
+	Comments
 }
 
 func (ss *StringStatement) statementNode() {}
@@ -825,6 +933,7 @@ func (ss *StringStatement) String() string {
 type ArrayLiteral struct {
 	Token    token.Token // '{' token
 	Elements []Expression
+	Rbrace   token.Token // '}' token
 }
 
 func (al *ArrayLiteral) expressionNode() {}
@@ -853,9 +962,10 @@ func (al *ArrayLiteral) String() string {
 
 // IndexExpression ...
 type IndexExpression struct {
-	Token token.Token // token.Lbracket
-	Left  Expression
-	Index Expression
+	Token    token.Token // token.Lbracket
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token // token.Rbracket
 }
 
 func (ie *IndexExpression) expressionNode() {}
@@ -900,7 +1010,10 @@ func (i *Identifier) String() string {
 
 // BreakStatement ...
 type BreakStatement struct {
-	Token token.Token // token.Break
+	Token     token.Token // token.Break
+	Semicolon token.Token // ';'
+
+	Comments
 }
 
 func (bs *BreakStatement) statementNode() {}
@@ -917,7 +1030,10 @@ func (bs *BreakStatement) String() string {
 
 // ContinueStatement ...
 type ContinueStatement struct {
-	Token token.Token // token.Break
+	Token     token.Token // token.Break
+	Semicolon token.Token // ';'
+
+	Comments
 }
 
 func (bs *ContinueStatement) statementNode() {}
@@ -936,6 +1052,9 @@ func (bs *ContinueStatement) String() string {
 type ReturnStatement struct {
 	Token       token.Token // token.Return
 	ReturnValue Expression
+	Semicolon   token.Token // ';'
+
+	Comments
 }
 
 func (rs *ReturnStatement) statementNode() {}
@@ -1001,8 +1120,8 @@ func (fl *FloatLiteral) String() string {
 
 // StringLiteral ...
 type StringLiteral struct {
-	Token token.Token // token.String
-	Value string
+	Token token.Token // token.String. Literal は引用符とエスケープを含むソースのまま
+	Value string      // 引用符を外してエスケープを戻した値
 }
 
 func (sl *StringLiteral) expressionNode() {}
@@ -1039,8 +1158,9 @@ func (b *BooleanLiteral) String() string {
 
 // TensorLiteral ...
 type TensorLiteral struct {
-	Token  token.Token // token.Ltensor
-	Values [][]Expression
+	Token   token.Token // token.Ltensor
+	Values  [][]Expression
+	Rtensor token.Token // token.Rtensor
 }
 
 func (vl *TensorLiteral) expressionNode() {}
@@ -15,6 +15,7 @@ func TestString(t *testing.T) {
 					Token: token.Token{Type: token.Ident, Literal: "$myVar"},
 					Value: "$myVar",
 				}},
+				Assigns: []token.Token{{Type: token.Assign, Literal: "="}},
 				Values: []Expression{&Identifier{
 					Token: token.Token{Type: token.Ident, Literal: "$anotherVar"},
 					Value: "$anotherVar",
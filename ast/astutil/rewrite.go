@@ -0,0 +1,396 @@
+// Package astutil rewrites MEL syntax trees in the style of
+// golang.org/x/tools/go/ast/astutil.
+package astutil
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/nrtkbb/go-MEL/ast"
+)
+
+// An ApplyFunc is invoked by Apply for each node n, even if n is nil,
+// before and/or after the node's children, using a Cursor describing
+// the current node and providing operations on it.
+//
+// The return value of ApplyFunc controls the syntax tree traversal.
+// See Apply for details.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses a syntax tree recursively, starting with root,
+// and calling pre and post for each node as described below.
+// Apply returns the syntax tree, possibly modified.
+//
+// If pre is not nil, it is called for each node before the node's
+// children are traversed (pre-order). If pre returns false, no
+// children are traversed, and post is not called for that node.
+//
+// If post is not nil, and a prior call of pre didn't return false,
+// post is called for each node after its children are traversed
+// (post-order). If post returns false, traversal is terminated and
+// Apply returns immediately.
+//
+// Only fields that refer to AST nodes are considered children;
+// i.e., tokens and comments are not traversed. Children are traversed
+// in the order of ast.Walk.
+//
+// Nodes replaced by the cursor are not traversed again, but the children
+// of a node replaced in pre are traversed.
+func Apply(root ast.Node, pre, post ApplyFunc) (result ast.Node) {
+	parent := &struct{ ast.Node }{root}
+	defer func() {
+		if r := recover(); r != nil && r != abort {
+			panic(r)
+		}
+		result = parent.Node
+	}()
+	a := &application{pre: pre, post: post}
+	a.apply(parent, "Node", nil, root)
+	return
+}
+
+var abort = new(int) // singleton, to signal termination of Apply
+
+// A Cursor describes a node encountered during Apply.
+// Information about the node and its parent is available
+// from the Node, Parent, Name, and Index methods.
+//
+// If p is a variable of type and value of the current parent node
+// c.Parent(), and f is the field identifier with name c.Name(),
+// the following invariants hold:
+//
+//	p.f            == c.Node()  if c.Index() <  0
+//	p.f[c.Index()] == c.Node()  if c.Index() >= 0
+//
+// The methods Replace, Delete, InsertBefore, and InsertAfter
+// can be used to change the AST without disrupting Apply.
+type Cursor struct {
+	parent ast.Node
+	name   string
+	iter   *iterator // valid if non-nil
+	node   ast.Node
+}
+
+// Node returns the current Node.
+func (c *Cursor) Node() ast.Node { return c.node }
+
+// Parent returns the parent of the current Node.
+func (c *Cursor) Parent() ast.Node { return c.parent }
+
+// Name returns the name of the parent Node field that contains the current Node.
+// If the parent is a *ast.Program and the current Node is a statement,
+// Name returns "Statements".
+func (c *Cursor) Name() string { return c.name }
+
+// Index reports the index >= 0 of the current Node in the slice of Nodes that
+// contains it, or a value < 0 if the current Node is not part of a slice.
+// The index of the current node changes if InsertBefore is called while
+// processing the current node.
+func (c *Cursor) Index() int {
+	if c.iter != nil {
+		return c.iter.index
+	}
+	return -1
+}
+
+// field returns the current node's parent field value.
+func (c *Cursor) field() reflect.Value {
+	v := reflect.Indirect(reflect.ValueOf(c.parent)).FieldByName(c.name)
+	if c.iter != nil && c.iter.row >= 0 {
+		// TensorLiteral.Values の行
+		v = v.Index(c.iter.row)
+	}
+	return v
+}
+
+// list は Delete と Insert ができる slice の中の位置を返す
+func (c *Cursor) list(op string) int {
+	if c.iter == nil || c.iter.fixed {
+		panic(fmt.Sprintf("%s node not contained in slice", op))
+	}
+	return c.iter.index
+}
+
+// value は n を field の要素の型にする
+func (c *Cursor) value(n ast.Node, t reflect.Type) reflect.Value {
+	if n == nil {
+		return reflect.Zero(t)
+	}
+	v := reflect.ValueOf(n)
+	if !v.Type().AssignableTo(t) {
+		panic(fmt.Sprintf("can not use %T as %s in %T.%s", n, t, c.parent, c.name))
+	}
+	return v
+}
+
+// Replace replaces the current Node with n.
+// The replacement node is not walked by Apply.
+func (c *Cursor) Replace(n ast.Node) {
+	v := c.field()
+	if i := c.Index(); i >= 0 {
+		v = v.Index(i)
+	}
+	v.Set(c.value(n, v.Type()))
+	c.node = n
+}
+
+// Delete deletes the current Node from its containing slice.
+// If the current Node is not part of a slice, or the slice is one of two
+// slices that go together like the Names and Values of a declaration,
+// Delete panics.
+func (c *Cursor) Delete() {
+	i := c.list("Delete")
+	v := c.field()
+	l := v.Len()
+	reflect.Copy(v.Slice(i, l), v.Slice(i+1, l))
+	v.Index(l - 1).Set(reflect.Zero(v.Type().Elem()))
+	v.SetLen(l - 1)
+	c.iter.step--
+}
+
+// InsertAfter inserts n after the current Node in its containing slice.
+// If the current Node is not part of a slice, InsertAfter panics.
+// Apply does not walk n.
+func (c *Cursor) InsertAfter(n ast.Node) {
+	i := c.list("InsertAfter")
+	v := c.field()
+	e := c.value(n, v.Type().Elem())
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	l := v.Len()
+	reflect.Copy(v.Slice(i+2, l), v.Slice(i+1, l))
+	v.Index(i + 1).Set(e)
+	c.iter.step++
+}
+
+// InsertBefore inserts n before the current Node in its containing slice.
+// If the current Node is not part of a slice, InsertBefore panics.
+// Apply will not walk n.
+func (c *Cursor) InsertBefore(n ast.Node) {
+	i := c.list("InsertBefore")
+	v := c.field()
+	e := c.value(n, v.Type().Elem())
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	l := v.Len()
+	reflect.Copy(v.Slice(i+1, l), v.Slice(i, l))
+	v.Index(i).Set(e)
+	c.iter.index++
+}
+
+// application carries all the shared data so we can pass it around cheaply.
+type application struct {
+	pre, post ApplyFunc
+	cursor    Cursor
+}
+
+// An iterator controls iteration over a slice of nodes.
+type iterator struct {
+	index, step int
+	row         int  // TensorLiteral.Values の行. 無い時は -1
+	fixed       bool // 他の slice と並んでいるので長さを変えられない
+}
+
+func (a *application) apply(parent ast.Node, name string, iter *iterator, n ast.Node) {
+	// convert typed nil into untyped nil
+	if v := reflect.ValueOf(n); v.Kind() == reflect.Ptr && v.IsNil() {
+		n = nil
+	}
+
+	// avoid heap-allocating a new cursor for each apply call; reuse a.cursor instead
+	saved := a.cursor
+	a.cursor.parent = parent
+	a.cursor.name = name
+	a.cursor.iter = iter
+	a.cursor.node = n
+
+	if a.pre != nil && !a.pre(&a.cursor) {
+		a.cursor = saved
+		return
+	}
+
+	// walk children
+	// (the order of the cases matches the order of the corresponding node types in ast.Walk)
+	switch n := a.cursor.node.(type) {
+	case nil:
+		// nothing to do
+
+	// Program
+	case *ast.Program:
+		a.applyList(n, "Statements")
+
+	// Statements
+	case *ast.ExpressionStatement:
+		a.apply(n, "Expression", nil, n.Expression)
+
+	case *ast.BlockStatement:
+		a.applyList(n, "Statements")
+
+	case *ast.GlobalStatement:
+		a.apply(n, "Statement", nil, n.Statement)
+
+	case *ast.ProcStatement:
+		a.apply(n, "ReturnType", nil, n.ReturnType)
+		for i := 0; i < len(n.Parameters); i++ {
+			if i < len(n.ParamTypes) {
+				a.apply(n, "ParamTypes", &iterator{index: i, row: -1, fixed: true}, n.ParamTypes[i])
+			}
+			a.apply(n, "Parameters", &iterator{index: i, row: -1, fixed: true}, n.Parameters[i])
+		}
+		a.apply(n, "Body", nil, n.Body)
+
+	case *ast.CaseStatement:
+		a.applyList(n, "Statements")
+
+	case *ast.VariableStatement:
+		a.applyDeclaration(n, "Names", "Values")
+
+	case *ast.IntegerStatement:
+		a.applyDeclaration(n, "Names", "Values")
+
+	case *ast.FloatStatement:
+		a.applyDeclaration(n, "Names", "Values")
+
+	case *ast.StringStatement:
+		a.applyDeclaration(n, "Names", "Values")
+
+	case *ast.VectorStatement:
+		a.applyDeclaration(n, "Names", "Values")
+
+	case *ast.MatrixStatement:
+		a.applyDeclaration(n, "Names", "Values")
+
+	case *ast.ReturnStatement:
+		a.apply(n, "ReturnValue", nil, n.ReturnValue)
+
+	case *ast.BreakStatement, *ast.ContinueStatement, *ast.BadStatement:
+		// nothing to do
+
+	// Expressions
+	case *ast.InfixExpression:
+		a.apply(n, "Left", nil, n.Left)
+		a.apply(n, "Right", nil, n.Right)
+
+	case *ast.PrefixExpression:
+		a.apply(n, "Right", nil, n.Right)
+
+	case *ast.PostfixExpression:
+		a.apply(n, "Left", nil, n.Left)
+
+	case *ast.TernaryExpression:
+		a.apply(n, "Conditional", nil, n.Conditional)
+		a.apply(n, "TrueExp", nil, n.TrueExp)
+		a.apply(n, "FalseExp", nil, n.FalseExp)
+
+	case *ast.CastExpression:
+		a.apply(n, "Right", nil, n.Right)
+
+	case *ast.CallExpression:
+		a.apply(n, "Function", nil, n.Function)
+		a.applyList(n, "Arguments")
+
+	case *ast.FlagArgument:
+		a.applyList(n, "Values")
+
+	case *ast.IndexExpression:
+		a.apply(n, "Left", nil, n.Left)
+		a.apply(n, "Index", nil, n.Index)
+
+	case *ast.ArrayLiteral:
+		a.applyList(n, "Elements")
+
+	case *ast.TensorLiteral:
+		for row := range n.Values {
+			for i := 0; i < len(n.Values[row]); i++ {
+				a.apply(n, "Values", &iterator{index: i, row: row, fixed: true}, n.Values[row][i])
+			}
+		}
+
+	case *ast.IfExpression:
+		a.apply(n, "Condition", nil, n.Condition)
+		a.apply(n, "Consequence", nil, n.Consequence)
+		a.apply(n, "Alternative", nil, n.Alternative)
+
+	case *ast.WhileExpression:
+		a.apply(n, "Condition", nil, n.Condition)
+		a.apply(n, "Consequence", nil, n.Consequence)
+
+	case *ast.DoWhileExpression:
+		a.apply(n, "Consequence", nil, n.Consequence)
+		a.apply(n, "Condition", nil, n.Condition)
+
+	case *ast.ForExpression:
+		a.applyDeclaration(n, "InitNames", "InitValues")
+		a.apply(n, "Condition", nil, n.Condition)
+		a.applyList(n, "ChangeOfs")
+		a.apply(n, "Consequence", nil, n.Consequence)
+
+	case *ast.ForInExpression:
+		a.apply(n, "Element", nil, n.Element)
+		a.apply(n, "ArrayElement", nil, n.ArrayElement)
+		a.apply(n, "Consequence", nil, n.Consequence)
+
+	case *ast.SwitchExpression:
+		a.apply(n, "Condition", nil, n.Condition)
+		for i := 0; i < len(n.CaseStatements); i++ {
+			if i < len(n.Cases) {
+				a.apply(n, "Cases", &iterator{index: i, row: -1, fixed: true}, n.Cases[i])
+			}
+			a.apply(n, "CaseStatements", &iterator{index: i, row: -1, fixed: true}, n.CaseStatements[i])
+		}
+
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral,
+		*ast.BooleanLiteral, *ast.TypeDeclaration, *ast.BadExpression:
+		// nothing to do
+
+	default:
+		panic(fmt.Sprintf("Apply: unexpected node type %T", n))
+	}
+
+	if a.post != nil && !a.post(&a.cursor) {
+		panic(abort)
+	}
+
+	a.cursor = saved
+}
+
+func (a *application) applyList(parent ast.Node, name string) {
+	iter := &iterator{row: -1}
+	for {
+		// must reload parent.name each time, since cursor modifications might change it
+		v := reflect.Indirect(reflect.ValueOf(parent)).FieldByName(name)
+		if iter.index >= v.Len() {
+			break
+		}
+
+		// element x may be nil in a bad AST - be cautious
+		var x ast.Node
+		if e := v.Index(iter.index); e.IsValid() && !(e.Kind() == reflect.Interface && e.IsNil()) {
+			x = e.Interface().(ast.Node)
+		}
+
+		iter.step = 1
+		a.apply(parent, name, iter, x)
+		iter.index += iter.step
+	}
+}
+
+// applyDeclaration は名前と値を交互に訪れる. 名前と値は並んでいるので Delete できない
+func (a *application) applyDeclaration(parent ast.Node, names, values string) {
+	v := reflect.Indirect(reflect.ValueOf(parent))
+	n := v.FieldByName(names).Len()
+	if l := v.FieldByName(values).Len(); l > n {
+		n = l
+	}
+	for i := 0; i < n; i++ {
+		for _, name := range []string{names, values} {
+			list := v.FieldByName(name)
+			if i >= list.Len() {
+				continue
+			}
+			var x ast.Node
+			if e := list.Index(i); !e.IsNil() {
+				x = e.Interface().(ast.Node)
+			}
+			a.apply(parent, name, &iterator{index: i, row: -1, fixed: true}, x)
+		}
+	}
+}
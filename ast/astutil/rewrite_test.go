@@ -0,0 +1,236 @@
+package astutil
+
+import (
+	"testing"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("input %q: %s", input, p.Errors())
+	}
+	return program
+}
+
+func ident(name string) *ast.Identifier {
+	return &ast.Identifier{Token: token.Token{Type: token.Ident, Literal: name}, Value: name}
+}
+
+// isCall は文が name の呼び出しか調べる
+func isCall(n ast.Node, name string) bool {
+	es, ok := n.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	call, ok := es.Expression.(*ast.CallExpression)
+	return ok && call.Function != nil && call.Function.Value == name
+}
+
+func TestApply(t *testing.T) {
+	debug := parse(t, `print "debug";`).Statements[0]
+
+	tests := []struct {
+		input    string
+		pre      ApplyFunc
+		expected string
+	}{
+		{
+			// Replace
+			`int $old = 1; $x = $old + f($old); for ($i = 0; $i < 2; $i++) { $old++; }`,
+			func(c *Cursor) bool {
+				if id, ok := c.Node().(*ast.Identifier); ok && id.Value == "$old" {
+					c.Replace(ident("$new"))
+				}
+				return true
+			},
+			`int $new = 1;$x = ($new + f($new));for ($i = 0; ($i < 2); ($i++)) { ($new++) }`,
+		},
+		{
+			// Delete
+			`print "a"; if ($a) { print "b"; ls; print "c"; } switch ($a) { case 1: print "d"; break; }`,
+			func(c *Cursor) bool {
+				if isCall(c.Node(), "print") {
+					c.Delete()
+				}
+				return true
+			},
+			`if $a { ls }switch $a {case 1:break; }`,
+		},
+		{
+			// InsertBefore
+			`proc f() { ls; return; } proc g() { return; }`,
+			func(c *Cursor) bool {
+				if _, ok := c.Node().(*ast.ReturnStatement); ok {
+					c.InsertBefore(debug)
+				}
+				return true
+			},
+			`proc (){ lsprint("debug")return ; }proc (){ print("debug")return ; }`,
+		},
+		{
+			// InsertAfter した文は訪れない
+			`ls; pwd;`,
+			func(c *Cursor) bool {
+				if _, ok := c.Node().(*ast.ExpressionStatement); ok {
+					c.InsertAfter(debug)
+				}
+				return true
+			},
+			`lsprint("debug")pwdprint("debug")`,
+		},
+		{
+			// 並んだ slice の中の値も置き換えられる
+			`vector $v = <<1, 2, 3>>; int $a, $b = 2; proc f(int $p) {}`,
+			func(c *Cursor) bool {
+				if lit, ok := c.Node().(*ast.IntegerLiteral); ok && lit.Value == 2 {
+					c.Replace(&ast.IntegerLiteral{Token: token.Token{Type: token.Int, Literal: "5"}, Value: 5})
+				}
+				if id, ok := c.Node().(*ast.Identifier); ok && id.Value == "$p" {
+					c.Replace(ident("$q"))
+				}
+				return true
+			},
+			`vector $v = <<1, 5, 3>>;int $a, $b = 5;proc ($q){  }`,
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		result := Apply(program, tt.pre, nil)
+		if result != program {
+			t.Errorf("input %q: Apply returned another node %T", tt.input, result)
+		}
+		if got := program.String(); got != tt.expected {
+			t.Errorf("input %q wrong.\nwant=%s\ngot=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestApplyCursor(t *testing.T) {
+	program := parse(t, `ls; $a = f(1, 2);`)
+
+	type visit struct {
+		parent string
+		name   string
+		index  int
+	}
+	var got []visit
+	Apply(program, func(c *Cursor) bool {
+		switch c.Node().(type) {
+		case *ast.IntegerLiteral, *ast.VariableStatement:
+			got = append(got, visit{c.Parent().String(), c.Name(), c.Index()})
+		}
+		return true
+	}, nil)
+
+	expected := []visit{
+		{"ls$a = f(1, 2);", "Statements", 1},
+		{"f(1, 2)", "Arguments", 0},
+		{"f(1, 2)", "Arguments", 1},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("wrong visits. want=%v, got=%v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("visit %d wrong. want=%v, got=%v", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestApplyPreReplace(t *testing.T) {
+	// pre で置き換えた node の子も訪れる
+	program := parse(t, `$a = -$b;`)
+	var names []string
+	Apply(program, func(c *Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.PrefixExpression:
+			c.Replace(&ast.InfixExpression{Left: ident("$zero"), Operator: "-", Right: n.Right})
+		case *ast.Identifier:
+			names = append(names, n.Value)
+		}
+		return true
+	}, nil)
+
+	if got := program.String(); got != "$a = ($zero - $b);" {
+		t.Errorf("wrong program. got=%s", got)
+	}
+	if len(names) != 3 || names[1] != "$zero" || names[2] != "$b" {
+		t.Errorf("wrong names. got=%v", names)
+	}
+}
+
+func TestApplyRoot(t *testing.T) {
+	program := parse(t, `ls;`)
+	other := parse(t, `pwd;`)
+	result := Apply(program, func(c *Cursor) bool {
+		if _, ok := c.Node().(*ast.Program); ok {
+			c.Replace(other)
+			return false
+		}
+		return true
+	}, nil)
+	if result != other {
+		t.Errorf("Apply did not return the new root. got=%v", result)
+	}
+}
+
+func TestApplyAbort(t *testing.T) {
+	program := parse(t, `a; b; c;`)
+	var visited []string
+	Apply(program, nil, func(c *Cursor) bool {
+		if es, ok := c.Node().(*ast.ExpressionStatement); ok {
+			visited = append(visited, es.String())
+			return es.String() != "b"
+		}
+		return true
+	})
+	if len(visited) != 2 {
+		t.Errorf("Apply did not stop. visited=%v", visited)
+	}
+}
+
+func TestApplyPanics(t *testing.T) {
+	tests := []struct {
+		input string
+		pre   ApplyFunc
+	}{
+		{
+			// 名前と値は並んでいるので消せない
+			`int $a = 1;`,
+			func(c *Cursor) bool {
+				if _, ok := c.Node().(*ast.IntegerLiteral); ok {
+					c.Delete()
+				}
+				return true
+			},
+		},
+		{
+			`$a = 1;`,
+			func(c *Cursor) bool {
+				if _, ok := c.Node().(*ast.VariableStatement); ok {
+					// 文の場所に式は置けない
+					c.InsertBefore(ident("$x"))
+				}
+				return true
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("input %q: expected a panic", tt.input)
+				}
+			}()
+			Apply(parse(t, tt.input), tt.pre, nil)
+		}()
+	}
+}
@@ -0,0 +1,53 @@
+package ast
+
+import (
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// BadStatement is a placeholder for a statement with syntax errors
+type BadStatement struct {
+	From token.Token // 最初の token
+	To   token.Token // 読み飛ばした最後の token
+}
+
+func (bs *BadStatement) statementNode() {}
+
+// TokenLiteral ...
+func (bs *BadStatement) TokenLiteral() string {
+	return bs.From.Literal
+}
+
+// String ...
+func (bs *BadStatement) String() string {
+	return "<bad statement>"
+}
+
+// Pos ...
+func (bs *BadStatement) Pos() token.Pos { return bs.From.Pos }
+
+// End ...
+func (bs *BadStatement) End() token.Pos { return lastEnd(bs.From.End(), bs.To.End()) }
+
+// BadExpression is a placeholder for an expression with syntax errors
+type BadExpression struct {
+	From token.Token // 最初の token
+	To   token.Token // 最後に読んだ token
+}
+
+func (be *BadExpression) expressionNode() {}
+
+// TokenLiteral ...
+func (be *BadExpression) TokenLiteral() string {
+	return be.From.Literal
+}
+
+// String ...
+func (be *BadExpression) String() string {
+	return "<bad expression>"
+}
+
+// Pos ...
+func (be *BadExpression) Pos() token.Pos { return be.From.Pos }
+
+// End ...
+func (be *BadExpression) End() token.Pos { return lastEnd(be.From.End(), be.To.End()) }
@@ -0,0 +1,58 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Comment is a "//" or "/* */" comment
+type Comment struct {
+	Token token.Token // token.Comment
+}
+
+// Text return the comment without "//", "/*" and "*/"
+func (c *Comment) Text() string {
+	text := c.Token.Literal
+	if strings.HasPrefix(text, "//") {
+		return strings.TrimSpace(text[2:])
+	}
+	text = strings.TrimPrefix(text, "/*")
+	text = strings.TrimSuffix(text, "*/")
+	return strings.TrimSpace(text)
+}
+
+// CommentGroup is a sequence of comments with no tokens and no empty lines between
+type CommentGroup struct {
+	List []*Comment
+}
+
+// Text return the text of the comments joined with newline
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+	var lines []string
+	for _, c := range g.List {
+		lines = append(lines, c.Text())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Comments は文に付くコメント
+type Comments struct {
+	Leading  *CommentGroup // 文の直前の行にあるコメント
+	Trailing *CommentGroup // 文の後ろの同じ行にあるコメント
+}
+
+// Attached return the comments of the statement
+func (c *Comments) Attached() *Comments {
+	return c
+}
+
+// CommentedStatement is a statement that can have comments.
+// The statement in a GlobalStatement has its comments.
+type CommentedStatement interface {
+	Statement
+	Attached() *Comments
+}
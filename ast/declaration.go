@@ -0,0 +1,41 @@
+package ast
+
+import (
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Declaration returns the names and values of a variable declaration such as
+// int $a = 1, $b[];. It returns nil for other statements.
+func Declaration(stmt Statement) (names, values []Expression) {
+	switch stmt := stmt.(type) {
+	case *IntegerStatement:
+		return stmt.Names, stmt.Values
+	case *FloatStatement:
+		return stmt.Names, stmt.Values
+	case *StringStatement:
+		return stmt.Names, stmt.Values
+	case *VectorStatement:
+		return stmt.Names, stmt.Values
+	case *MatrixStatement:
+		return stmt.Names, stmt.Values
+	}
+	return nil, nil
+}
+
+// DeclaredVariable returns the variable of a declared name such as $a, $a[]
+// or $m[4][4]. It returns nil if name is not a variable.
+func DeclaredVariable(name Expression) *Identifier {
+	for {
+		switch n := name.(type) {
+		case *Identifier:
+			if n.Token.Type != token.Ident {
+				return nil
+			}
+			return n
+		case *IndexExpression:
+			name = n.Left
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// JSONVersion is the version of the JSON encoding of the AST.
+// It changes when a kind or a field is renamed or removed, or when the
+// meaning of a field changes.
+const JSONVersion = 2
+
+// kinds は JSON の "kind" から node の型を引く
+var kinds = map[string]reflect.Type{}
+
+func init() {
+	for _, n := range []interface{}{
+		&Program{}, &Comment{}, &CommentGroup{},
+		&BadStatement{}, &BadExpression{},
+		&ExpressionStatement{}, &BlockStatement{}, &GlobalStatement{}, &ProcStatement{},
+		&CaseStatement{}, &VariableStatement{}, &VectorStatement{}, &MatrixStatement{},
+		&IntegerStatement{}, &FloatStatement{}, &StringStatement{},
+		&BreakStatement{}, &ContinueStatement{}, &ReturnStatement{},
+		&InfixExpression{}, &PrefixExpression{}, &PostfixExpression{}, &TernaryExpression{},
+		&CastExpression{}, &TypeDeclaration{}, &CallExpression{}, &FlagArgument{},
+		&ForExpression{}, &ForInExpression{}, &DoWhileExpression{}, &WhileExpression{},
+		&IfExpression{}, &SwitchExpression{}, &IndexExpression{}, &Identifier{},
+		&ArrayLiteral{}, &IntegerLiteral{}, &FloatLiteral{}, &StringLiteral{},
+		&BooleanLiteral{}, &TensorLiteral{},
+	} {
+		t := reflect.TypeOf(n).Elem()
+		kinds[t.Name()] = t
+	}
+}
+
+var (
+	tokenType  = reflect.TypeOf(token.Token{})
+	syntaxType = reflect.TypeOf(CallSyntax(0))
+)
+
+var syntaxNames = map[CallSyntax]string{
+	FunctionCall:  "function",
+	CommandCall:   "command",
+	BackQuoteCall: "backquote",
+}
+
+// jsonFile is the top level object of the JSON encoding.
+type jsonFile struct {
+	Version int             `json:"version"`
+	Program json.RawMessage `json:"program"`
+}
+
+// EncodeJSON encodes the program as JSON.
+//
+// Every node is an object with its kind, the Go type name of the node, and
+// its fields named in lower camel case. Tokens are objects of type, literal,
+// line, column, offset and pos. Missing nodes and tokens are null.
+// The schema is documented in ast/json.md.
+func EncodeJSON(program *Program) ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, `{"version":%d,"program":`, JSONVersion)
+	if err := encodeValue(&out, reflect.ValueOf(program)); err != nil {
+		return nil, err
+	}
+	out.WriteString("}")
+	return out.Bytes(), nil
+}
+
+// DecodeJSON rebuilds the program from JSON made by EncodeJSON.
+func DecodeJSON(data []byte) (*Program, error) {
+	var file jsonFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Version != JSONVersion {
+		return nil, fmt.Errorf("unsupported AST JSON version %d", file.Version)
+	}
+
+	d := json.NewDecoder(bytes.NewReader(file.Program))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	program, err := decodeValue(v, reflect.TypeOf(&Program{}), "program")
+	if err != nil {
+		return nil, err
+	}
+	if program.IsNil() {
+		return nil, fmt.Errorf("program: null")
+	}
+	return program.Interface().(*Program), nil
+}
+
+// fieldName は Go の field の名前を JSON の名前にする. ex) InitNames -> initNames
+func fieldName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+func encodeValue(out *bytes.Buffer, v reflect.Value) error {
+	switch v.Type() {
+	case tokenType:
+		encodeToken(out, v.Interface().(token.Token))
+		return nil
+	case syntaxType:
+		name, ok := syntaxNames[CallSyntax(v.Int())]
+		if !ok {
+			return fmt.Errorf("unknown call syntax %d", v.Int())
+		}
+		out.WriteString(strconv.Quote(name))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		return encodeValue(out, v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		return encodeNode(out, v.Elem())
+	case reflect.Slice:
+		out.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			if err := encodeValue(out, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		out.WriteString("]")
+	case reflect.String:
+		s, _ := json.Marshal(v.String())
+		out.Write(s)
+	case reflect.Bool:
+		out.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int64:
+		out.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Float64:
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			// JSON で書けない値は文字列にする
+			out.WriteString(strconv.Quote(strconv.FormatFloat(f, 'g', -1, 64)))
+			return nil
+		}
+		out.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	default:
+		return fmt.Errorf("can not encode %s", v.Type())
+	}
+	return nil
+}
+
+// encodeNode は node を kind と field の object にする
+func encodeNode(out *bytes.Buffer, v reflect.Value) error {
+	t := v.Type()
+	if kinds[t.Name()] != t {
+		return fmt.Errorf("unknown node type %s", t)
+	}
+	fmt.Fprintf(out, `{"kind":%q`, t.Name())
+	if err := encodeFields(out, v); err != nil {
+		return err
+	}
+	out.WriteString("}")
+	return nil
+}
+
+func encodeFields(out *bytes.Buffer, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			// Comments の Leading と Trailing は node の field にする
+			if err := encodeFields(out, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		fmt.Fprintf(out, ",%q:", fieldName(f.Name))
+		if err := encodeValue(out, v.Field(i)); err != nil {
+			return fmt.Errorf("%s.%s: %s", t.Name(), f.Name, err)
+		}
+	}
+	return nil
+}
+
+func encodeToken(out *bytes.Buffer, tok token.Token) {
+	if tok == (token.Token{}) {
+		out.WriteString("null")
+		return
+	}
+	typ, _ := json.Marshal(string(tok.Type))
+	lit, _ := json.Marshal(tok.Literal)
+	fmt.Fprintf(out, `{"type":%s,"literal":%s,"line":%d,"column":%d,"offset":%d,"pos":%d}`,
+		typ, lit, tok.Row, tok.Column, tok.Offset, tok.Pos)
+}
+
+// decodeValue は JSON の値 v から t の値を作る. path はエラーの場所
+func decodeValue(v interface{}, t reflect.Type, path string) (reflect.Value, error) {
+	switch t {
+	case tokenType:
+		tok, err := decodeToken(v, path)
+		return reflect.ValueOf(tok), err
+	case syntaxType:
+		s, ok := v.(string)
+		for syntax, name := range syntaxNames {
+			if ok && s == name {
+				return reflect.ValueOf(syntax), nil
+			}
+		}
+		return reflect.Value{}, fmt.Errorf("%s: unknown call syntax %v", path, v)
+	}
+
+	switch t.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v == nil {
+			return reflect.Zero(t), nil
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s: want an object, got %T", path, v)
+		}
+		kind, _ := obj["kind"].(string)
+		nt, ok := kinds[kind]
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s: unknown kind %q", path, obj["kind"])
+		}
+		node := reflect.New(nt)
+		if !node.Type().AssignableTo(t) {
+			return reflect.Value{}, fmt.Errorf("%s: %s is not %s", path, kind, t)
+		}
+		if err := decodeFields(obj, node.Elem(), path); err != nil {
+			return reflect.Value{}, err
+		}
+		return node.Convert(t), nil
+	case reflect.Slice:
+		if v == nil {
+			return reflect.Zero(t), nil
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s: want an array, got %T", path, v)
+		}
+		s := reflect.MakeSlice(t, len(list), len(list))
+		for i, e := range list {
+			ev, err := decodeValue(e, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			s.Index(i).Set(ev)
+		}
+		return s, nil
+	case reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s: want a string, got %T", path, v)
+		}
+		return reflect.ValueOf(s).Convert(t), nil
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s: want a bool, got %T", path, v)
+		}
+		return reflect.ValueOf(b), nil
+	case reflect.Int, reflect.Int64:
+		n, ok := v.(json.Number)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s: want a number, got %T", path, v)
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s: %s", path, err)
+		}
+		return reflect.ValueOf(i).Convert(t), nil
+	case reflect.Float64:
+		var s string
+		switch n := v.(type) {
+		case json.Number:
+			s = string(n)
+		case string:
+			s = n // +Inf, -Inf, NaN
+		default:
+			return reflect.Value{}, fmt.Errorf("%s: want a number, got %T", path, v)
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s: %s", path, err)
+		}
+		return reflect.ValueOf(f), nil
+	}
+	return reflect.Value{}, fmt.Errorf("%s: can not decode %s", path, t)
+}
+
+func decodeFields(obj map[string]interface{}, v reflect.Value, path string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			if err := decodeFields(obj, v.Field(i), path); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		name := fieldName(f.Name)
+		fv, err := decodeValue(obj[name], f.Type, path+"."+name)
+		if err != nil {
+			return err
+		}
+		v.Field(i).Set(fv)
+	}
+	return nil
+}
+
+func decodeToken(v interface{}, path string) (token.Token, error) {
+	if v == nil {
+		return token.Token{}, nil
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return token.Token{}, fmt.Errorf("%s: want a token, got %T", path, v)
+	}
+	typ, ok1 := obj["type"].(string)
+	lit, ok2 := obj["literal"].(string)
+	if !ok1 || !ok2 {
+		return token.Token{}, fmt.Errorf("%s: token needs type and literal", path)
+	}
+	var nums [4]int64
+	for i, name := range []string{"line", "column", "offset", "pos"} {
+		n, ok := obj[name].(json.Number)
+		if !ok {
+			return token.Token{}, fmt.Errorf("%s: token needs %s", path, name)
+		}
+		i64, err := n.Int64()
+		if err != nil {
+			return token.Token{}, fmt.Errorf("%s.%s: %s", path, name, err)
+		}
+		nums[i] = i64
+	}
+	return token.Token{
+		Type:    token.Type(typ),
+		Literal: lit,
+		Row:     int(nums[0]),
+		Column:  int(nums[1]),
+		Offset:  int(nums[2]),
+		Pos:     token.Pos(nums[3]),
+	}, nil
+}
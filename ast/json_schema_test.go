@@ -0,0 +1,70 @@
+package ast
+
+import (
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// schemaLine は json.md の表の node の行を作る
+func schemaLine(t reflect.Type) string {
+	var fields []string
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous {
+				add(f.Type)
+				continue
+			}
+			if f.PkgPath == "" {
+				fields = append(fields, "`"+fieldName(f.Name)+"` "+schemaType(f.Type))
+			}
+		}
+	}
+	add(t)
+	return "| `" + t.Name() + "` | " + strings.Join(fields, ", ") + " |"
+}
+
+// schemaType は json.md に書く field の型
+func schemaType(t reflect.Type) string {
+	switch t {
+	case tokenType:
+		return "token"
+	case syntaxType:
+		return "syntax"
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		return schemaType(t.Elem()) + "[]"
+	case reflect.Ptr:
+		return t.Elem().Name()
+	case reflect.Interface:
+		return t.Name()
+	case reflect.Int64, reflect.Int:
+		return "int"
+	case reflect.Float64:
+		return "float"
+	}
+	return t.Kind().String()
+}
+
+func TestJSONSchemaDocument(t *testing.T) {
+	doc, err := ioutil.ReadFile("json.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if line := schemaLine(kinds[name]); !strings.Contains(string(doc), line+"\n") {
+			t.Errorf("json.md does not document %s. want the line\n%s", name, line)
+		}
+	}
+}
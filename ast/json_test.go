@@ -0,0 +1,108 @@
+package ast_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []string{
+		`int $a = 1; float $f[] = {1.5, 2.}; string $s = "a\n" + $a;`,
+		"// doc\nglobal proc string[] names(string $a, int $b[]) {\n\tglobal vector $v;\n\treturn {$a}; // ret\n}",
+		`vector $v = <<1, 2, 3>>; matrix $m[2][2] = <<1, 2; 3, 4>>; $x = $v.x;`,
+		`if ($a && !$b) { $c++; } else if ($d) {} else { --$e; }`,
+		`for ($i = 0, $j = 1; $i < 10; $i++, $j += 2) { continue; } for ($e in $list) break;`,
+		`while (true) {} do { $i--; } while ($i > 0 ? on : off);`,
+		`switch ($x) { case 1: case "a": print 1; break; default: ; }`,
+		"polyCube -w 1 -n \"box\" -ch off; $s = `ls -sl`; setAttr(\"a.tx\", (float)$x); $a[size($a)] = `pwd`;",
+		`int $a = ; proc (`,
+		`$f = 1e999;`,
+	}
+
+	for _, input := range tests {
+		p := parser.New(lexer.NewWithMode(input, lexer.ScanComments))
+		program := p.ParseProgram()
+
+		data, err := ast.EncodeJSON(program)
+		if err != nil {
+			t.Errorf("input %q: EncodeJSON error: %s", input, err)
+			continue
+		}
+		if !json.Valid(data) {
+			t.Errorf("input %q: invalid JSON %s", input, data)
+			continue
+		}
+
+		decoded, err := ast.DecodeJSON(data)
+		if err != nil {
+			t.Errorf("input %q: DecodeJSON error: %s", input, err)
+			continue
+		}
+		if decoded.String() != program.String() {
+			t.Errorf("input %q: decoded program wrong.\nwant=%s\ngot=%s", input, program, decoded)
+		}
+
+		again, err := ast.EncodeJSON(decoded)
+		if err != nil {
+			t.Errorf("input %q: EncodeJSON error: %s", input, err)
+			continue
+		}
+		if !bytes.Equal(data, again) {
+			t.Errorf("input %q: JSON changed.\nfirst=%s\nagain=%s", input, data, again)
+		}
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	program := parser.New(lexer.New(`ls -sl;`)).ParseProgram()
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"version":2,"program":{"kind":"Program","statements":[` +
+		`{"kind":"ExpressionStatement",` +
+		`"token":{"type":"ProcIdent","literal":"ls","line":1,"column":1,"offset":0,"pos":1},` +
+		`"expression":{"kind":"CallExpression",` +
+		`"token":{"type":"ProcIdent","literal":"ls","line":1,"column":1,"offset":0,"pos":1},` +
+		`"function":{"kind":"Identifier","token":{"type":"ProcIdent","literal":"ls","line":1,"column":1,"offset":0,"pos":1},"value":"ls"},` +
+		`"arguments":[{"kind":"FlagArgument","token":{"type":"Flag","literal":"-sl","line":1,"column":4,"offset":3,"pos":4},"flag":"-sl","values":[]}],` +
+		`"close":null,"syntax":"command"},` +
+		`"semicolon":{"type":";","literal":";","line":1,"column":7,"offset":6,"pos":7},` +
+		`"leading":null,"trailing":null}],` +
+		`"comments":[]}}`
+	if string(data) != expected {
+		t.Errorf("EncodeJSON wrong.\nwant=%s\ngot=%s", expected, data)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"version":1,"program":{"kind":"Program"}}`, "unsupported AST JSON version 1"},
+		{`{"version":2,"program":null}`, "program: null"},
+		{`{"version":2,"program":{"kind":"Program","statements":[{"kind":"Foo"}]}}`, `program.statements[0]: unknown kind "Foo"`},
+		{`{"version":2,"program":{"kind":"Program","statements":[{"kind":"Identifier"}]}}`, "program.statements[0]: Identifier is not ast.Statement"},
+		{`{"version":2,"program":{"kind":"Program","statements":[{"kind":"BreakStatement","token":{"type":"break"}}]}}`, "program.statements[0].token: token needs type and literal"},
+		{`{"version":2,"program":{"kind":"Program","statements":"a"}}`, "program.statements: want an array, got string"},
+	}
+
+	for _, tt := range tests {
+		_, err := ast.DecodeJSON([]byte(tt.input))
+		if err == nil {
+			t.Errorf("input %s: expected an error", tt.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("input %s: wrong error. want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package ast
+
+import (
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Pos と End は node の範囲を返す.
+// 閉じ括弧などの token が無い時は最後の子の End を使う.
+// 子になる型 (BlockStatement, CaseStatement, Identifier) は nil でも NoPos を返す.
+
+func posOf(n Node, def token.Pos) token.Pos {
+	if n == nil || !n.Pos().IsValid() {
+		return def
+	}
+	return n.Pos()
+}
+
+func endOf(n Node) token.Pos {
+	if n == nil {
+		return token.NoPos
+	}
+	return n.End()
+}
+
+func lastEnd(pos ...token.Pos) token.Pos {
+	end := token.NoPos
+	for _, p := range pos {
+		if p > end {
+			end = p
+		}
+	}
+	return end
+}
+
+func lastExpressionEnd(exps []Expression) token.Pos {
+	for i := len(exps) - 1; i >= 0; i-- {
+		if exps[i] != nil {
+			return exps[i].End()
+		}
+	}
+	return token.NoPos
+}
+
+func lastStatementEnd(stmts []Statement) token.Pos {
+	for i := len(stmts) - 1; i >= 0; i-- {
+		if stmts[i] != nil {
+			return stmts[i].End()
+		}
+	}
+	return token.NoPos
+}
+
+func declarationEnd(names, values []Expression, semicolon token.Token) token.Pos {
+	return lastEnd(lastExpressionEnd(names), lastExpressionEnd(values), semicolon.End())
+}
+
+// Pos ...
+func (p *Program) Pos() token.Pos {
+	if len(p.Statements) > 0 {
+		return posOf(p.Statements[0], token.NoPos)
+	}
+	return token.NoPos
+}
+
+// End ...
+func (p *Program) End() token.Pos {
+	return lastStatementEnd(p.Statements)
+}
+
+// Pos ...
+func (es *ExpressionStatement) Pos() token.Pos { return es.Token.Pos }
+
+// End ...
+func (es *ExpressionStatement) End() token.Pos {
+	return lastEnd(es.Token.End(), endOf(es.Expression), es.Semicolon.End())
+}
+
+// Pos ...
+func (ie *InfixExpression) Pos() token.Pos { return posOf(ie.Left, ie.Token.Pos) }
+
+// End ...
+func (ie *InfixExpression) End() token.Pos { return lastEnd(ie.Token.End(), endOf(ie.Right)) }
+
+// Pos ...
+func (pe *PrefixExpression) Pos() token.Pos { return pe.Token.Pos }
+
+// End ...
+func (pe *PrefixExpression) End() token.Pos { return lastEnd(pe.Token.End(), endOf(pe.Right)) }
+
+// Pos ...
+func (pe *PostfixExpression) Pos() token.Pos { return posOf(pe.Left, pe.Token.Pos) }
+
+// End ...
+func (pe *PostfixExpression) End() token.Pos { return pe.Token.End() }
+
+// Pos ...
+func (te *TernaryExpression) Pos() token.Pos { return posOf(te.Conditional, te.Token1.Pos) }
+
+// End ...
+func (te *TernaryExpression) End() token.Pos {
+	return lastEnd(te.Token1.End(), endOf(te.TrueExp), te.Token2.End(), endOf(te.FalseExp))
+}
+
+// Pos ...
+func (ce *CastExpression) Pos() token.Pos {
+	if ce.Lparen.Pos.IsValid() {
+		return ce.Lparen.Pos
+	}
+	return ce.Token.Pos
+}
+
+// End ...
+func (ce *CastExpression) End() token.Pos { return lastEnd(ce.Token.End(), endOf(ce.Right)) }
+
+// Pos ...
+func (td *TypeDeclaration) Pos() token.Pos { return td.Token.Pos }
+
+// End ...
+func (td *TypeDeclaration) End() token.Pos { return lastEnd(td.Token.End(), td.Rbracket.End()) }
+
+// Pos ...
+func (ce *CallExpression) Pos() token.Pos {
+	if ce.Token.Type == token.BackQuotes || !ce.Function.Pos().IsValid() {
+		return ce.Token.Pos
+	}
+	return ce.Function.Pos()
+}
+
+// End ...
+func (ce *CallExpression) End() token.Pos {
+	return lastEnd(ce.Token.End(), endOf(ce.Function), lastExpressionEnd(ce.Arguments), ce.Close.End())
+}
+
+// Pos ...
+func (fa *FlagArgument) Pos() token.Pos { return fa.Token.Pos }
+
+// End ...
+func (fa *FlagArgument) End() token.Pos {
+	return lastEnd(fa.Token.End(), lastExpressionEnd(fa.Values))
+}
+
+// Pos ...
+func (fe *ForExpression) Pos() token.Pos { return fe.Token.Pos }
+
+// End ...
+func (fe *ForExpression) End() token.Pos { return lastEnd(fe.Token.End(), endOf(fe.Consequence)) }
+
+// Pos ...
+func (fe *ForInExpression) Pos() token.Pos { return fe.Token.Pos }
+
+// End ...
+func (fe *ForInExpression) End() token.Pos { return lastEnd(fe.Token.End(), endOf(fe.Consequence)) }
+
+// Pos ...
+func (dw *DoWhileExpression) Pos() token.Pos { return dw.Token.Pos }
+
+// End ...
+func (dw *DoWhileExpression) End() token.Pos {
+	return lastEnd(dw.Token.End(), endOf(dw.Consequence), endOf(dw.Condition), dw.Rparen.End())
+}
+
+// Pos ...
+func (we *WhileExpression) Pos() token.Pos { return we.Token.Pos }
+
+// End ...
+func (we *WhileExpression) End() token.Pos { return lastEnd(we.Token.End(), endOf(we.Consequence)) }
+
+// Pos ...
+func (ie *IfExpression) Pos() token.Pos { return ie.Token.Pos }
+
+// End ...
+func (ie *IfExpression) End() token.Pos {
+	return lastEnd(ie.Token.End(), endOf(ie.Consequence), endOf(ie.Alternative))
+}
+
+// Pos ...
+func (bs *BlockStatement) Pos() token.Pos {
+	if bs == nil {
+		return token.NoPos
+	}
+	return bs.Token.Pos
+}
+
+// End ...
+func (bs *BlockStatement) End() token.Pos {
+	if bs == nil {
+		return token.NoPos
+	}
+	if bs.Rbrace.Pos.IsValid() {
+		return bs.Rbrace.End()
+	}
+	if end := lastStatementEnd(bs.Statements); end.IsValid() {
+		return end
+	}
+	return bs.Token.Pos
+}
+
+// Pos ...
+func (se *SwitchExpression) Pos() token.Pos { return se.Token.Pos }
+
+// End ...
+func (se *SwitchExpression) End() token.Pos {
+	end := lastEnd(se.Token.End(), endOf(se.Condition), se.Rbrace.End())
+	for _, cs := range se.CaseStatements {
+		end = lastEnd(end, endOf(cs))
+	}
+	return end
+}
+
+// Pos ...
+func (gs *GlobalStatement) Pos() token.Pos { return gs.Token.Pos }
+
+// End ...
+func (gs *GlobalStatement) End() token.Pos { return lastEnd(gs.Token.End(), endOf(gs.Statement)) }
+
+// Pos ...
+func (fl *ProcStatement) Pos() token.Pos { return fl.Token.Pos }
+
+// End ...
+func (fl *ProcStatement) End() token.Pos {
+	return lastEnd(fl.Token.End(), fl.Name.End(), endOf(fl.Body))
+}
+
+// Pos ...
+func (cs *CaseStatement) Pos() token.Pos {
+	if cs == nil {
+		return token.NoPos
+	}
+	// case の label は SwitchExpression.Cases にあるが, 範囲には case から入れる
+	if cs.Keyword.Pos.IsValid() {
+		return cs.Keyword.Pos
+	}
+	return cs.Token.Pos
+}
+
+// End ...
+func (cs *CaseStatement) End() token.Pos {
+	if cs == nil {
+		return token.NoPos
+	}
+	return lastEnd(cs.Token.End(), lastStatementEnd(cs.Statements))
+}
+
+// Pos ...
+func (vs *VariableStatement) Pos() token.Pos {
+	if len(vs.Names) > 0 {
+		return posOf(vs.Names[0], token.NoPos)
+	}
+	return token.NoPos
+}
+
+// End ...
+func (vs *VariableStatement) End() token.Pos {
+	return declarationEnd(vs.Names, vs.Values, vs.Semicolon)
+}
+
+// Pos ...
+func (vs *VectorStatement) Pos() token.Pos { return vs.Token.Pos }
+
+// End ...
+func (vs *VectorStatement) End() token.Pos {
+	return lastEnd(vs.Token.End(), declarationEnd(vs.Names, vs.Values, vs.Semicolon))
+}
+
+// Pos ...
+func (ms *MatrixStatement) Pos() token.Pos { return ms.Token.Pos }
+
+// End ...
+func (ms *MatrixStatement) End() token.Pos {
+	return lastEnd(ms.Token.End(), declarationEnd(ms.Names, ms.Values, ms.Semicolon))
+}
+
+// Pos ...
+func (is *IntegerStatement) Pos() token.Pos { return is.Token.Pos }
+
+// End ...
+func (is *IntegerStatement) End() token.Pos {
+	return lastEnd(is.Token.End(), declarationEnd(is.Names, is.Values, is.Semicolon))
+}
+
+// Pos ...
+func (fs *FloatStatement) Pos() token.Pos { return fs.Token.Pos }
+
+// End ...
+func (fs *FloatStatement) End() token.Pos {
+	return lastEnd(fs.Token.End(), declarationEnd(fs.Names, fs.Values, fs.Semicolon))
+}
+
+// Pos ...
+func (ss *StringStatement) Pos() token.Pos { return ss.Token.Pos }
+
+// End ...
+func (ss *StringStatement) End() token.Pos {
+	return lastEnd(ss.Token.End(), declarationEnd(ss.Names, ss.Values, ss.Semicolon))
+}
+
+// Pos ...
+func (al *ArrayLiteral) Pos() token.Pos { return al.Token.Pos }
+
+// End ...
+func (al *ArrayLiteral) End() token.Pos {
+	return lastEnd(al.Token.End(), lastExpressionEnd(al.Elements), al.Rbrace.End())
+}
+
+// Pos ...
+func (ie *IndexExpression) Pos() token.Pos { return posOf(ie.Left, ie.Token.Pos) }
+
+// End ...
+func (ie *IndexExpression) End() token.Pos {
+	return lastEnd(ie.Token.End(), endOf(ie.Index), ie.Rbracket.End())
+}
+
+// Pos ...
+func (i *Identifier) Pos() token.Pos {
+	if i == nil {
+		return token.NoPos
+	}
+	return i.Token.Pos
+}
+
+// End ...
+func (i *Identifier) End() token.Pos {
+	if i == nil {
+		return token.NoPos
+	}
+	return i.Token.End()
+}
+
+// Pos ...
+func (bs *BreakStatement) Pos() token.Pos { return bs.Token.Pos }
+
+// End ...
+func (bs *BreakStatement) End() token.Pos { return lastEnd(bs.Token.End(), bs.Semicolon.End()) }
+
+// Pos ...
+func (bs *ContinueStatement) Pos() token.Pos { return bs.Token.Pos }
+
+// End ...
+func (bs *ContinueStatement) End() token.Pos { return lastEnd(bs.Token.End(), bs.Semicolon.End()) }
+
+// Pos ...
+func (rs *ReturnStatement) Pos() token.Pos { return rs.Token.Pos }
+
+// End ...
+func (rs *ReturnStatement) End() token.Pos {
+	return lastEnd(rs.Token.End(), endOf(rs.ReturnValue), rs.Semicolon.End())
+}
+
+// Pos ...
+func (il *IntegerLiteral) Pos() token.Pos { return il.Token.Pos }
+
+// End ...
+func (il *IntegerLiteral) End() token.Pos { return il.Token.End() }
+
+// Pos ...
+func (fl *FloatLiteral) Pos() token.Pos { return fl.Token.Pos }
+
+// End ...
+func (fl *FloatLiteral) End() token.Pos { return fl.Token.End() }
+
+// Pos ...
+func (sl *StringLiteral) Pos() token.Pos { return sl.Token.Pos }
+
+// End ...
+func (sl *StringLiteral) End() token.Pos { return sl.Token.End() }
+
+// Pos ...
+func (bl *BooleanLiteral) Pos() token.Pos { return bl.Token.Pos }
+
+// End ...
+func (bl *BooleanLiteral) End() token.Pos { return bl.Token.End() }
+
+// Pos ...
+func (tl *TensorLiteral) Pos() token.Pos { return tl.Token.Pos }
+
+// End ...
+func (tl *TensorLiteral) End() token.Pos {
+	end := lastEnd(tl.Token.End(), tl.Rtensor.End())
+	for _, row := range tl.Values {
+		end = lastEnd(end, lastExpressionEnd(row))
+	}
+	return end
+}
+
+// Pos ...
+func (c *Comment) Pos() token.Pos { return c.Token.Pos }
+
+// End ...
+func (c *Comment) End() token.Pos { return c.Token.End() }
+
+// Pos ...
+func (g *CommentGroup) Pos() token.Pos { return g.List[0].Pos() }
+
+// End ...
+func (g *CommentGroup) End() token.Pos { return g.List[len(g.List)-1].End() }
@@ -0,0 +1,251 @@
+package ast
+
+import (
+	"fmt"
+)
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the non-nil children of node, followed by a call of
+// w.Visit(nil).
+//
+// Children are visited in source order. The names and values of a
+// declaration and of the initializer of a for loop are visited in turn,
+// and so are the labels and the statements of the cases of a switch.
+// Comments are not visited.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	// Program
+	case *Program:
+		walkStatements(v, n.Statements)
+
+	// Statements
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	case *GlobalStatement:
+		if n.Statement != nil {
+			Walk(v, n.Statement)
+		}
+
+	case *ProcStatement:
+		if n.ReturnType != nil {
+			Walk(v, n.ReturnType)
+		}
+		for i, param := range n.Parameters {
+			if i < len(n.ParamTypes) && n.ParamTypes[i] != nil {
+				Walk(v, n.ParamTypes[i])
+			}
+			walkExpression(v, param)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *CaseStatement:
+		walkStatements(v, n.Statements)
+
+	case *VariableStatement:
+		walkDeclaration(v, n.Names, n.Values)
+
+	case *IntegerStatement:
+		walkDeclaration(v, n.Names, n.Values)
+
+	case *FloatStatement:
+		walkDeclaration(v, n.Names, n.Values)
+
+	case *StringStatement:
+		walkDeclaration(v, n.Names, n.Values)
+
+	case *VectorStatement:
+		walkDeclaration(v, n.Names, n.Values)
+
+	case *MatrixStatement:
+		walkDeclaration(v, n.Names, n.Values)
+
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+
+	case *BreakStatement, *ContinueStatement, *BadStatement:
+		// nothing to do
+
+	// Expressions
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+
+	case *PostfixExpression:
+		walkExpression(v, n.Left)
+
+	case *TernaryExpression:
+		walkExpression(v, n.Conditional)
+		walkExpression(v, n.TrueExp)
+		walkExpression(v, n.FalseExp)
+
+	case *CastExpression:
+		walkExpression(v, n.Right)
+
+	case *CallExpression:
+		if n.Function != nil {
+			Walk(v, n.Function)
+		}
+		walkExpressions(v, n.Arguments)
+
+	case *FlagArgument:
+		walkExpressions(v, n.Values)
+
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+
+	case *TensorLiteral:
+		for _, row := range n.Values {
+			walkExpressions(v, row)
+		}
+
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+
+	case *WhileExpression:
+		walkExpression(v, n.Condition)
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+
+	case *DoWhileExpression:
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+		walkExpression(v, n.Condition)
+
+	case *ForExpression:
+		walkDeclaration(v, n.InitNames, n.InitValues)
+		walkExpression(v, n.Condition)
+		walkStatements(v, n.ChangeOfs)
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+
+	case *ForInExpression:
+		if n.Element != nil {
+			Walk(v, n.Element)
+		}
+		walkExpression(v, n.ArrayElement)
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+
+	case *SwitchExpression:
+		walkExpression(v, n.Condition)
+		for i, cs := range n.CaseStatements {
+			if i < len(n.Cases) {
+				walkExpression(v, n.Cases[i])
+			}
+			if cs != nil {
+				Walk(v, cs)
+			}
+		}
+
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral,
+		*BooleanLiteral, *TypeDeclaration, *BadExpression:
+		// nothing to do
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// walkExpression は nil でない式を訪れる. ex) Literal の nil は default の case
+func walkExpression(v Visitor, exp Node) {
+	if exp != nil && !isNil(exp) {
+		Walk(v, exp)
+	}
+}
+
+func walkExpressions(v Visitor, list []Expression) {
+	for _, exp := range list {
+		walkExpression(v, exp)
+	}
+}
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, stmt := range list {
+		if stmt != nil {
+			Walk(v, stmt)
+		}
+	}
+}
+
+// walkDeclaration は名前と値を交互に訪れる. 値の無い名前もある
+func walkDeclaration(v Visitor, names, values []Expression) {
+	for i, name := range names {
+		walkExpression(v, name)
+		if i < len(values) {
+			walkExpression(v, values[i])
+		}
+	}
+	for i := len(names); i < len(values); i++ {
+		walkExpression(v, values[i])
+	}
+}
+
+// isNil は interface に入った nil の pointer を見つける
+func isNil(node Node) bool {
+	switch n := node.(type) {
+	case *Identifier:
+		return n == nil
+	case *BlockStatement:
+		return n == nil
+	case *TypeDeclaration:
+		return n == nil
+	case *CaseStatement:
+		return n == nil
+	}
+	return false
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,140 @@
+package ast_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+)
+
+// describe は node を "Kind:source" の形にする
+func describe(n ast.Node) string {
+	kind := strings.TrimPrefix(reflect.TypeOf(n).String(), "*ast.")
+	switch n := n.(type) {
+	case *ast.Identifier:
+		return kind + ":" + n.Value
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.TypeDeclaration, *ast.BooleanLiteral:
+		return kind + ":" + n.String()
+	}
+	return kind
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			`int $a = 1, $b;`,
+			[]string{"Program", "IntegerStatement", "Identifier:$a", "IntegerLiteral:1", "Identifier:$b"},
+		},
+		{
+			`proc string f(int $a[], string $b) { return $b; }`,
+			[]string{"Program", "ProcStatement", "TypeDeclaration:string",
+				"TypeDeclaration:int", "IndexExpression", "Identifier:$a",
+				"TypeDeclaration:string", "Identifier:$b",
+				"BlockStatement", "ReturnStatement", "Identifier:$b"},
+		},
+		{
+			`for ($i = 0; $i < 2; $i++) ls -type "a";`,
+			[]string{"Program", "ExpressionStatement", "ForExpression",
+				"Identifier:$i", "IntegerLiteral:0",
+				"InfixExpression", "Identifier:$i", "IntegerLiteral:2",
+				"ExpressionStatement", "PostfixExpression", "Identifier:$i",
+				"BlockStatement", "ExpressionStatement", "CallExpression", "Identifier:ls",
+				"FlagArgument", "StringLiteral:\"a\""},
+		},
+		{
+			`switch ($x) { case 1: break; default: $y = <<1, 2; 3, 4>>; }`,
+			[]string{"Program", "ExpressionStatement", "SwitchExpression", "Identifier:$x",
+				"IntegerLiteral:1", "CaseStatement", "BreakStatement",
+				"CaseStatement", "VariableStatement", "Identifier:$y", "TensorLiteral",
+				"IntegerLiteral:1", "IntegerLiteral:2", "IntegerLiteral:3", "IntegerLiteral:4"},
+		},
+		{
+			`for ($e in $list) {} do { } while (true);`,
+			[]string{"Program", "ExpressionStatement", "ForInExpression", "Identifier:$e", "Identifier:$list", "BlockStatement",
+				"ExpressionStatement", "DoWhileExpression", "BlockStatement", "BooleanLiteral:true"},
+		},
+		{
+			`global string $s[] = {"a"}; $v = $c ? (float)$s[0] : -$v.x;`,
+			[]string{"Program", "GlobalStatement", "StringStatement", "IndexExpression", "Identifier:$s",
+				"ArrayLiteral", "StringLiteral:\"a\"",
+				"VariableStatement", "Identifier:$v", "TernaryExpression", "Identifier:$c",
+				"CastExpression", "IndexExpression", "Identifier:$s", "IntegerLiteral:0",
+				"InfixExpression", "PrefixExpression", "Identifier:$v", "Identifier:x"},
+		},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		var got []string
+		depth := 0
+		ast.Inspect(program, func(n ast.Node) bool {
+			if n == nil {
+				depth--
+				return false
+			}
+			depth++
+			got = append(got, describe(n))
+			return true
+		})
+		if depth != 0 {
+			t.Errorf("input %q: Inspect called f(nil) %d times less than nodes", tt.input, depth)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("input %q wrong.\nwant=%v\ngot=%v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestInspectSkip(t *testing.T) {
+	program := parser.New(lexer.New(`proc f() { ls; } $a = 1;`)).ParseProgram()
+
+	var got []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		got = append(got, describe(n))
+		_, isProc := n.(*ast.ProcStatement)
+		return !isProc
+	})
+	expected := []string{"Program", "ProcStatement", "VariableStatement", "Identifier:$a", "IntegerLiteral:1"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong.\nwant=%v\ngot=%v", expected, got)
+	}
+}
+
+type counter map[string]int
+
+func (c counter) Visit(n ast.Node) ast.Visitor {
+	if n != nil {
+		c[fmt.Sprintf("%T", n)]++
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	program := parser.New(lexer.New(`if ($a) { f(1); } else if ($b) { g 2; } else { h; }`)).ParseProgram()
+
+	c := counter{}
+	ast.Walk(c, program)
+	expected := counter{
+		"*ast.Program":             1,
+		"*ast.ExpressionStatement": 5,
+		"*ast.IfExpression":        2,
+		"*ast.BlockStatement":      4,
+		"*ast.Identifier":          5,
+		"*ast.CallExpression":      2,
+		"*ast.IntegerLiteral":      2,
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("wrong.\nwant=%v\ngot=%v", expected, c)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runCalls は calls サブコマンドを実行して終了コードを返す.
+// 到達できない proc があれば 1, ファイルを読めなければ 2 を返す.
+func runCalls(args []string) int {
+	fs := flag.NewFlagSet("calls", flag.ExitOnError)
+	format := fs.String("format", "dot", "output format: dot or json")
+	path := fs.String("path", os.Getenv("MAYA_SCRIPT_PATH"), "script path to search for sourced files")
+	entry := fs.String("entry", "", "comma separated global procs called from shelves and menus")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-MEL calls [-format dot|json] [-path dirs] [-entry procs] path ...")
+		fmt.Fprintln(os.Stderr, "Print the call graph of MEL files. With -entry, report the procs that are never called.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 || (*format != "dot" && *format != "json") {
+		fs.Usage()
+		return 2
+	}
+
+	ws, err := loadWorkspace(*path, fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	// source しているファイルの proc も呼び出し先になる
+	ws.LoadSources()
+
+	g := ws.CallGraph()
+	if *format == "json" {
+		out, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Print(g.DOT())
+	}
+
+	for _, cycle := range g.Cycles() {
+		fmt.Fprintf(os.Stderr, "recursion: %s\n", strings.Join(cycle, " -> "))
+	}
+	if *entry == "" {
+		return 0
+	}
+
+	code := 0
+	for _, n := range g.Unreachable(strings.Split(*entry, ",")...) {
+		fmt.Fprintf(os.Stderr, "%s: line:%d proc %s is unreachable\n", n.File, n.Line, n.Name)
+		code = 1
+	}
+	return code
+}
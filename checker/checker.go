@@ -0,0 +1,684 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/object"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// unknown はコマンドの戻り値など静的に型が決まらない式の型
+const unknown object.Type = ""
+
+// Error is a type error found by the checker.
+type Error struct {
+	Token   token.Token
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line:%d.%d %s", e.Token.Row, e.Token.Column, e.Message)
+}
+
+// scope は変数名と型の対応を持つ
+type scope struct {
+	vars  map[string]object.Type
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{vars: map[string]object.Type{}, outer: outer}
+}
+
+func (s *scope) lookup(name string) (object.Type, bool) {
+	for ; s != nil; s = s.outer {
+		if typ, ok := s.vars[name]; ok {
+			return typ, true
+		}
+	}
+	return unknown, false
+}
+
+// Checker infers the types of expressions and reports mismatches.
+type Checker struct {
+	errors []*Error
+
+	procs   map[string]*ast.ProcStatement
+	globals *scope
+	scope   *scope
+	proc    *ast.ProcStatement // 検査中の proc
+}
+
+// New make Checker instance.
+func New() *Checker {
+	globals := newScope(nil)
+	return &Checker{
+		errors:  []*Error{},
+		procs:   map[string]*ast.ProcStatement{},
+		globals: globals,
+		scope:   newScope(globals),
+	}
+}
+
+// Errors return type errors in the order they were found.
+func (c *Checker) Errors() []*Error {
+	return c.errors
+}
+
+// Check checks program and returns the errors.
+func Check(program *ast.Program) []*Error {
+	c := New()
+	c.Check(program)
+	return c.Errors()
+}
+
+// Check checks every statement of program.
+func (c *Checker) Check(program *ast.Program) {
+	// proc はファイル内のどこで定義されていても呼び出せる
+	for _, stmt := range program.Statements {
+		if gs, ok := stmt.(*ast.GlobalStatement); ok {
+			stmt = gs.Statement
+		}
+		if ps, ok := stmt.(*ast.ProcStatement); ok && ps != nil {
+			c.procs[ps.Name.Literal] = ps
+		}
+	}
+
+	for _, stmt := range program.Statements {
+		c.checkStatement(stmt)
+	}
+}
+
+func (c *Checker) errorf(tok token.Token, format string, a ...interface{}) {
+	c.errors = append(c.errors, &Error{Token: tok, Message: fmt.Sprintf(format, a...)})
+}
+
+func (c *Checker) checkStatement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		// "foo;" は引数なしで proc foo を呼び出す
+		if ident, ok := stmt.Expression.(*ast.Identifier); ok && ident.Token.Type == token.ProcIdent {
+			c.checkCall(ident, nil)
+			return
+		}
+		c.typeOf(stmt.Expression)
+	case *ast.BlockStatement:
+		c.checkBlock(stmt, newScope(c.scope))
+	case *ast.GlobalStatement:
+		c.checkGlobalStatement(stmt)
+	case *ast.ProcStatement:
+		c.checkProc(stmt)
+	case *ast.VariableStatement:
+		c.checkAssignments(stmt.Names, stmt.Assigns, stmt.Values)
+	case *ast.IntegerStatement:
+		c.checkDeclaration(object.IntType, stmt.Names, stmt.Assigns, stmt.Values, c.scope)
+	case *ast.FloatStatement:
+		c.checkDeclaration(object.FloatType, stmt.Names, stmt.Assigns, stmt.Values, c.scope)
+	case *ast.StringStatement:
+		c.checkDeclaration(object.StringType, stmt.Names, stmt.Assigns, stmt.Values, c.scope)
+	case *ast.VectorStatement:
+		c.checkDeclaration(object.VectorType, stmt.Names, stmt.Assigns, stmt.Values, c.scope)
+	case *ast.MatrixStatement:
+		c.checkDeclaration(object.MatrixType, stmt.Names, stmt.Assigns, stmt.Values, c.scope)
+	case *ast.ReturnStatement:
+		c.checkReturn(stmt)
+	}
+}
+
+func (c *Checker) checkBlock(block *ast.BlockStatement, s *scope) {
+	if block == nil {
+		return
+	}
+
+	outer := c.scope
+	c.scope = s
+	defer func() { c.scope = outer }()
+
+	for _, stmt := range block.Statements {
+		c.checkStatement(stmt)
+	}
+}
+
+func (c *Checker) checkGlobalStatement(gs *ast.GlobalStatement) {
+	switch stmt := gs.Statement.(type) {
+	case *ast.ProcStatement:
+		c.checkProc(stmt)
+	case *ast.IntegerStatement:
+		c.checkGlobalDeclaration(object.IntType, stmt.Names, stmt.Assigns, stmt.Values)
+	case *ast.FloatStatement:
+		c.checkGlobalDeclaration(object.FloatType, stmt.Names, stmt.Assigns, stmt.Values)
+	case *ast.StringStatement:
+		c.checkGlobalDeclaration(object.StringType, stmt.Names, stmt.Assigns, stmt.Values)
+	case *ast.VectorStatement:
+		c.checkGlobalDeclaration(object.VectorType, stmt.Names, stmt.Assigns, stmt.Values)
+	case *ast.MatrixStatement:
+		c.checkGlobalDeclaration(object.MatrixType, stmt.Names, stmt.Assigns, stmt.Values)
+	}
+}
+
+// checkGlobalDeclaration は global 変数を宣言したスコープと global スコープの両方に登録する
+func (c *Checker) checkGlobalDeclaration(
+	typ object.Type,
+	names []ast.Expression,
+	assigns []token.Token,
+	values []ast.Expression,
+) {
+	c.checkDeclaration(typ, names, assigns, values, c.scope)
+	for _, name := range names {
+		if ident, declType := declarationTarget(typ, name); ident != nil {
+			c.globals.vars[ident.Value] = declType
+		}
+	}
+}
+
+// checkDeclaration は int $a = 1, $b[], $c[3]; のような宣言を検査する
+func (c *Checker) checkDeclaration(
+	typ object.Type,
+	names []ast.Expression,
+	assigns []token.Token,
+	values []ast.Expression,
+	s *scope,
+) {
+	for i, name := range names {
+		ident, declType := declarationTarget(typ, name)
+		if ident == nil {
+			continue
+		}
+
+		// 初期値の中では宣言前の変数を参照する
+		if i < len(values) && values[i] != nil {
+			valType := c.typeOf(values[i])
+			if assigns[i].Type != token.Assign {
+				valType = c.binaryType(assignOperator(assigns[i]), declType, valType, assigns[i])
+			}
+			if !assignable(valType, declType) {
+				c.errorf(ident.Token, "can not assign %s to %s %s", valType, declType, ident.Value)
+			}
+		}
+
+		s.vars[ident.Value] = declType
+	}
+}
+
+// declarationTarget は宣言される名前から変数名と型を取り出す
+func declarationTarget(typ object.Type, name ast.Expression) (*ast.Identifier, object.Type) {
+	dims := 0
+	for {
+		switch n := name.(type) {
+		case *ast.Identifier:
+			if dims == 0 || typ == object.MatrixType {
+				return n, typ
+			}
+			return n, typ.ArrayOf()
+		case *ast.IndexExpression:
+			dims++
+			name = n.Left
+		default:
+			return nil, unknown
+		}
+	}
+}
+
+// checkAssignments は $a = 1, $b[0] += 2; のような代入を検査する
+func (c *Checker) checkAssignments(names []ast.Expression, assigns []token.Token, values []ast.Expression) {
+	for i, name := range names {
+		if i >= len(values) || values[i] == nil {
+			c.typeOf(name)
+			continue
+		}
+
+		valType := c.typeOf(values[i])
+		if assigns[i].Type != token.Assign {
+			valType = c.binaryType(assignOperator(assigns[i]), c.typeOf(name), valType, assigns[i])
+		}
+		c.assign(name, valType, assigns[i])
+	}
+}
+
+func assignOperator(tok token.Token) string {
+	// "+=" -> "+"
+	return tok.Literal[:len(tok.Literal)-1]
+}
+
+// assign は target に valType の値を代入できるか検査する
+func (c *Checker) assign(target ast.Expression, valType object.Type, tok token.Token) {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		typ, ok := c.scope.lookup(target.Value)
+		if !ok {
+			// 宣言されていない変数は値の型で暗黙に宣言される
+			c.scope.vars[target.Value] = valType
+			return
+		}
+		if !assignable(valType, typ) {
+			c.errorf(target.Token, "can not assign %s to %s %s", valType, typ, target.Value)
+		}
+	case *ast.IndexExpression:
+		typ := c.typeOf(target)
+		if !assignable(valType, typ) {
+			c.errorf(target.Token, "can not assign %s to %s element %s", valType, typ, target.Left.String())
+		}
+	}
+}
+
+func (c *Checker) checkProc(ps *ast.ProcStatement) {
+	if ps == nil {
+		return
+	}
+
+	// proc の中からは global 変数と引数だけが見える
+	s := newScope(c.globals)
+	for i, param := range ps.Parameters {
+		if ident, typ := paramType(ps, i); ident != nil {
+			s.vars[ident.Value] = typ
+		} else {
+			c.errorf(ps.Token, "invalid parameter %s", param.String())
+		}
+	}
+
+	outer := c.proc
+	c.proc = ps
+	defer func() { c.proc = outer }()
+
+	c.checkBlock(ps.Body, s)
+}
+
+// paramType は i 番目の引数の名前と型を返す. string $a[] は string[] になる
+func paramType(ps *ast.ProcStatement, i int) (*ast.Identifier, object.Type) {
+	if i >= len(ps.ParamTypes) || ps.ParamTypes[i] == nil {
+		return nil, unknown
+	}
+	typ := typeOfDeclaration(ps.ParamTypes[i])
+
+	switch param := ps.Parameters[i].(type) {
+	case *ast.Identifier:
+		return param, typ
+	case *ast.IndexExpression:
+		ident, ok := param.Left.(*ast.Identifier)
+		if !ok || param.Index != nil {
+			return nil, unknown
+		}
+		if !typ.IsArray() {
+			typ = typ.ArrayOf()
+		}
+		return ident, typ
+	}
+	return nil, unknown
+}
+
+func typeOfDeclaration(td *ast.TypeDeclaration) object.Type {
+	typ := object.Type(td.Token.Literal)
+	if td.IsArray {
+		return typ.ArrayOf()
+	}
+	return typ
+}
+
+func (c *Checker) checkReturn(rs *ast.ReturnStatement) {
+	var valType object.Type
+	if rs.ReturnValue != nil {
+		valType = c.typeOf(rs.ReturnValue)
+	}
+	if c.proc == nil {
+		return
+	}
+
+	name := c.proc.Name.Literal
+	if c.proc.ReturnType == nil {
+		if rs.ReturnValue != nil {
+			c.errorf(rs.Token, "proc %s has no return type but returns %s", name, typeName(valType))
+		}
+		return
+	}
+
+	retType := typeOfDeclaration(c.proc.ReturnType)
+	if rs.ReturnValue == nil {
+		c.errorf(rs.Token, "proc %s must return %s", name, retType)
+		return
+	}
+	if !assignable(valType, retType) {
+		c.errorf(rs.Token, "return type mismatch in proc %s. expected=%s, got=%s", name, retType, valType)
+	}
+}
+
+// typeOf は式の型を推論する. 推論できないときは unknown を返す
+func (c *Checker) typeOf(exp ast.Expression) object.Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral, *ast.BooleanLiteral:
+		return object.IntType
+	case *ast.FloatLiteral:
+		return object.FloatType
+	case *ast.StringLiteral:
+		return object.StringType
+	case *ast.TensorLiteral:
+		for _, row := range exp.Values {
+			for _, v := range row {
+				c.typeOf(v)
+			}
+		}
+		if len(exp.Values) == 1 && len(exp.Values[0]) == 3 {
+			return object.VectorType
+		}
+		return object.MatrixType
+	case *ast.ArrayLiteral:
+		return c.typeOfArrayLiteral(exp)
+	case *ast.Identifier:
+		if exp.Token.Type != token.Ident {
+			// コマンド形式の引数 (select pCube1 -r;) は文字列として扱う
+			return object.StringType
+		}
+		typ, _ := c.scope.lookup(exp.Value)
+		return typ
+	case *ast.PrefixExpression:
+		return c.typeOfPrefix(exp)
+	case *ast.PostfixExpression:
+		return c.typeOf(exp.Left)
+	case *ast.InfixExpression:
+		return c.typeOfInfix(exp)
+	case *ast.TernaryExpression:
+		c.typeOf(exp.Conditional)
+		t, f := c.typeOf(exp.TrueExp), c.typeOf(exp.FalseExp)
+		if t == unknown || f == unknown || t.IsArray() || f.IsArray() {
+			if t == f {
+				return t
+			}
+			return unknown
+		}
+		return object.WiderType(t, f)
+	case *ast.CastExpression:
+		typ := object.Type(exp.Token.Literal)
+		if from := c.typeOf(exp.Right); !convertible(from, typ) {
+			c.errorf(exp.Token, "can not convert %s to %s", from, typ)
+		}
+		return typ
+	case *ast.IndexExpression:
+		return c.typeOfIndex(exp)
+	case *ast.CallExpression:
+		if exp.Function == nil {
+			return unknown
+		}
+		return c.checkCall(exp.Function, ast.FlattenArguments(exp.Arguments))
+
+	// 式として使われる制御構文
+	case *ast.IfExpression:
+		c.typeOf(exp.Condition)
+		c.checkBlock(exp.Consequence, newScope(c.scope))
+		c.checkBlock(exp.Alternative, newScope(c.scope))
+	case *ast.WhileExpression:
+		c.typeOf(exp.Condition)
+		c.checkBlock(exp.Consequence, newScope(c.scope))
+	case *ast.DoWhileExpression:
+		c.checkBlock(exp.Consequence, newScope(c.scope))
+		c.typeOf(exp.Condition)
+	case *ast.ForExpression:
+		c.checkAssignments(exp.InitNames, exp.InitAssigns, exp.InitValues)
+		c.typeOf(exp.Condition)
+		for _, changeOf := range exp.ChangeOfs {
+			c.checkStatement(changeOf)
+		}
+		c.checkBlock(exp.Consequence, newScope(c.scope))
+	case *ast.ForInExpression:
+		c.checkForIn(exp)
+	case *ast.SwitchExpression:
+		c.typeOf(exp.Condition)
+		s := newScope(c.scope)
+		for _, cs := range exp.CaseStatements {
+			c.checkBlock(&ast.BlockStatement{Token: cs.Token, Statements: cs.Statements}, s)
+		}
+	}
+	return unknown
+}
+
+func (c *Checker) typeOfArrayLiteral(al *ast.ArrayLiteral) object.Type {
+	elemType := unknown
+	for i, el := range al.Elements {
+		typ := c.typeOf(el)
+		if typ.IsArray() {
+			c.errorf(al.Token, "array can not have %s", typ)
+			return unknown
+		}
+		if i == 0 {
+			elemType = typ
+			continue
+		}
+		if elemType == unknown || typ == unknown {
+			elemType = unknown
+			continue
+		}
+		elemType = object.WiderType(elemType, typ)
+	}
+	if elemType == unknown {
+		return unknown
+	}
+	return elemType.ArrayOf()
+}
+
+func (c *Checker) typeOfPrefix(pe *ast.PrefixExpression) object.Type {
+	typ := c.typeOf(pe.Right)
+	switch pe.Operator {
+	case "!":
+		return object.IntType
+	case "-", "++", "--":
+		if typ == object.StringType || typ.IsArray() {
+			c.errorf(pe.Token, "unknown operator: %s%s", pe.Operator, typ)
+			return unknown
+		}
+	}
+	return typ
+}
+
+func (c *Checker) typeOfInfix(ie *ast.InfixExpression) object.Type {
+	if ie.Operator == "." {
+		// $v.x
+		if typ := c.typeOf(ie.Left); typ != unknown && typ != object.VectorType {
+			c.errorf(ie.Token, "%s has no components", typ)
+		}
+		return object.FloatType
+	}
+
+	left := c.typeOf(ie.Left)
+	right := c.typeOf(ie.Right)
+	return c.binaryType(ie.Operator, left, right, ie.Token)
+}
+
+// binaryType は object.BinaryOp と同じ規則で left op right の型を返す
+func (c *Checker) binaryType(op string, left, right object.Type, tok token.Token) object.Type {
+	switch op {
+	case "&&", "||":
+		return object.IntType
+	}
+	if left == unknown || right == unknown {
+		switch op {
+		case "==", "!=", "<", ">", "<=", ">=":
+			return object.IntType
+		}
+		return unknown
+	}
+
+	if !left.IsArray() && !right.IsArray() {
+		result, err := object.BinaryOp(op, sample(left), sample(right))
+		if err == nil {
+			return result.Type()
+		}
+	}
+	c.errorf(tok, "unknown operator: %s %s %s", left, op, right)
+	return unknown
+}
+
+// sample は型の検査に使う値を返す. 0 で割らないように 1 を使う
+func sample(typ object.Type) object.Object {
+	switch typ {
+	case object.IntType:
+		return &object.Integer{Value: 1}
+	case object.FloatType:
+		return &object.Float{Value: 1}
+	case object.VectorType:
+		return &object.Vector{X: 1, Y: 1, Z: 1}
+	case object.MatrixType:
+		return &object.Matrix{Rows: 1, Cols: 1, Values: []float64{1}}
+	}
+	return object.Zero(typ)
+}
+
+func (c *Checker) typeOfIndex(ie *ast.IndexExpression) object.Type {
+	if ie.Index != nil {
+		if typ := c.typeOf(ie.Index); typ != unknown && typ != object.IntType && typ != object.FloatType {
+			c.errorf(ie.Token, "index must be an int. got=%s", typ)
+		}
+	}
+
+	// $m[0][1]
+	if inner, ok := ie.Left.(*ast.IndexExpression); ok {
+		if c.typeOf(inner.Left) == object.MatrixType {
+			if inner.Index != nil {
+				c.typeOf(inner.Index)
+			}
+			return object.FloatType
+		}
+	}
+
+	typ := c.typeOf(ie.Left)
+	if typ == unknown {
+		return unknown
+	}
+	if !typ.IsArray() {
+		c.errorf(ie.Token, "%s is not an array. got=%s", ie.Left.String(), typ)
+		return unknown
+	}
+	return typ.ElementType()
+}
+
+func (c *Checker) checkForIn(fie *ast.ForInExpression) {
+	typ := c.typeOf(fie.ArrayElement)
+	elemType := unknown
+	if typ != unknown {
+		if !typ.IsArray() {
+			c.errorf(fie.Token, "for-in needs an array. got=%s", typ)
+		} else {
+			elemType = typ.ElementType()
+		}
+	}
+	if fie.Element != nil {
+		c.assign(fie.Element, elemType, fie.Token)
+	}
+	c.checkBlock(fie.Consequence, newScope(c.scope))
+}
+
+// checkCall は引数の数と型を検査して戻り値の型を返す
+func (c *Checker) checkCall(fn *ast.Identifier, args []ast.Expression) object.Type {
+	var argTypes []object.Type
+	for _, a := range args {
+		argTypes = append(argTypes, c.typeOf(a))
+	}
+
+	name := fn.Value
+	if object.IsTypeName(name) {
+		typ := object.Type(name)
+		if len(args) != 1 {
+			c.errorf(fn.Token, "%s", object.WrongArgumentCount(name))
+		} else if !convertible(argTypes[0], typ) {
+			c.errorf(fn.Token, "can not convert %s to %s", argTypes[0], typ)
+		}
+		return typ
+	}
+
+	ps, ok := c.procs[name]
+	if !ok {
+		// Maya のコマンドや他のファイルの proc は検査しない
+		return builtinTypes[name]
+	}
+
+	if len(args) != len(ps.Parameters) {
+		c.errorf(fn.Token, "%s", object.WrongArgumentCount(name))
+	} else {
+		for i, argType := range argTypes {
+			_, paramType := paramType(ps, i)
+			if !assignable(argType, paramType) {
+				c.errorf(fn.Token, "argument %d of %s must be %s. got=%s", i+1, name, paramType, argType)
+			}
+		}
+	}
+
+	if ps.ReturnType == nil {
+		return object.VoidType
+	}
+	return typeOfDeclaration(ps.ReturnType)
+}
+
+// assignable は from の値を暗黙の変換で to の変数に代入できるかを返す.
+// 実行時には変換できても string と数値の間の代入は間違いとして扱う
+func assignable(from, to object.Type) bool {
+	if from == to || from == unknown || to == unknown {
+		return true
+	}
+	if from.IsArray() || to.IsArray() {
+		if !from.IsArray() || !to.IsArray() {
+			return false
+		}
+		return assignable(from.ElementType(), to.ElementType())
+	}
+
+	switch to {
+	case object.IntType, object.FloatType:
+		return from == object.IntType || from == object.FloatType || from == object.VectorType
+	case object.VectorType:
+		return from == object.IntType || from == object.FloatType || from == object.MatrixType
+	case object.MatrixType:
+		return from == object.VectorType
+	}
+	return false
+}
+
+// convertible は (int) $s のような明示的な変換ができるかを返す
+func convertible(from, to object.Type) bool {
+	if from == unknown || to == unknown {
+		return true
+	}
+	if from.IsArray() || from == object.VoidType {
+		return false
+	}
+	_, err := object.Convert(sample(from), to)
+	return err == nil
+}
+
+func typeName(typ object.Type) string {
+	if typ == unknown {
+		return "a value"
+	}
+	return string(typ)
+}
+
+// builtinTypes は戻り値の型が決まっている組み込み proc
+var builtinTypes = map[string]object.Type{
+	"size":                object.IntType,
+	"exists":              object.IntType,
+	"ceil":                object.FloatType,
+	"floor":               object.FloatType,
+	"trunc":               object.FloatType,
+	"sqrt":                object.FloatType,
+	"sin":                 object.FloatType,
+	"cos":                 object.FloatType,
+	"tan":                 object.FloatType,
+	"exp":                 object.FloatType,
+	"log":                 object.FloatType,
+	"pow":                 object.FloatType,
+	"mag":                 object.FloatType,
+	"unit":                object.VectorType,
+	"dot":                 object.FloatType,
+	"cross":               object.VectorType,
+	"toupper":             object.StringType,
+	"tolower":             object.StringType,
+	"strip":               object.StringType,
+	"substring":           object.StringType,
+	"startsWith":          object.IntType,
+	"endsWith":            object.IntType,
+	"match":               object.StringType,
+	"gmatch":              object.IntType,
+	"substitute":          object.StringType,
+	"substituteAllString": object.StringType,
+	"tokenize":            object.IntType,
+	"stringArrayToString": object.StringType,
+	"stringToStringArray": object.StringArrayType,
+	"stringArrayContains": object.IntType,
+}
@@ -0,0 +1,141 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+)
+
+func TestCheckNoErrors(t *testing.T) {
+	inputs := []string{
+		`int $i = 1; float $f = $i; $f = 2.5 * $i;`,
+		`string $s = "a" + 1; $s += 2.5;`,
+		`vector $v = <<1, 2, 3>>; float $x = $v.x; $v = $v * 2;`,
+		`matrix $m[2][2] = <<1, 2; 3, 4>>; float $f = $m[0][1];`,
+		`int $a[] = {1, 2, 3}; int $e = $a[0]; float $b[] = $a;`,
+		`string $a[]; for ($s in $a) { string $t = $s; }`,
+		`proc int add(int $a, int $b) { return $a + $b; } int $r = add(1, 2);`,
+		`proc string[] names() { string $r[]; return $r; } string $n[] = names();`,
+		`proc hello() { print "hello"; return; } hello;`,
+		`global proc float half(float $x) { return $x / 2; } float $h = half(3);`,
+		`string $s = toupper("a"); int $n = size({1, 2});`,
+		`string $r[] = ls("-sl"); int $i = (int) "12";`,
+		`select -r pCube1; setAttr ".tx" 1;`,
+		`int $x = $y[0];`,
+	}
+
+	for _, input := range inputs {
+		errs := testCheck(t, input)
+		for _, err := range errs {
+			t.Errorf("input %q has error: %s", input, err)
+		}
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			`string $x = 1.5;`,
+			[]string{"line:1.8 can not assign float to string $x"},
+		},
+		{
+			`int $i = "a";`,
+			[]string{"line:1.5 can not assign string to int $i"},
+		},
+		{
+			`int $i; $i = "a";`,
+			[]string{"line:1.9 can not assign string to int $i"},
+		},
+		{
+			`int $a[] = {"a", "b"};`,
+			[]string{"line:1.5 can not assign string[] to int[] $a"},
+		},
+		{
+			`int $a = {1, 2};`,
+			[]string{"line:1.5 can not assign int[] to int $a"},
+		},
+		{
+			"proc int foo() {\n  return \"a\";\n}",
+			[]string{"line:2.3 return type mismatch in proc foo. expected=int, got=string"},
+		},
+		{
+			"proc int foo() {\n  return;\n}",
+			[]string{"line:2.3 proc foo must return int"},
+		},
+		{
+			"proc foo() {\n  return 1;\n}",
+			[]string{"line:2.3 proc foo has no return type but returns int"},
+		},
+		{
+			`proc foo(int $a, string $b) {} foo(1);`,
+			[]string{"line:1.32 Wrong number of arguments on call to foo."},
+		},
+		{
+			`proc foo(int $a, string $b[]) {} string $s; foo($s, $s);`,
+			[]string{
+				"line:1.45 argument 1 of foo must be int. got=string",
+				"line:1.45 argument 2 of foo must be string[]. got=string",
+			},
+		},
+		{
+			`proc foo(int $a) {} foo "a";`,
+			[]string{"line:1.21 argument 1 of foo must be int. got=string"},
+		},
+		{
+			`int $i = 1; int $j = $i[0];`,
+			[]string{"line:1.24 $i is not an array. got=int"},
+		},
+		{
+			`int $a[]; $a["x"] = 1;`,
+			[]string{"line:1.13 index must be an int. got=string"},
+		},
+		{
+			`float $f = 1; for ($e in $f) {}`,
+			[]string{"line:1.15 for-in needs an array. got=float"},
+		},
+		{
+			`string $s = "a" - "b";`,
+			[]string{
+				"line:1.17 unknown operator: string - string",
+			},
+		},
+		{
+			`proc foo() {} int $r = foo();`,
+			[]string{"line:1.19 can not assign void to int $r"},
+		},
+		{
+			`int $i = 1; float $f = $i.x;`,
+			[]string{"line:1.26 int has no components"},
+		},
+	}
+
+	for _, tt := range tests {
+		errs := testCheck(t, tt.input)
+		if len(errs) != len(tt.expected) {
+			t.Errorf("input %q: wrong number of errors. want=%d, got=%d", tt.input, len(tt.expected), len(errs))
+			for _, err := range errs {
+				t.Errorf("  %s", err)
+			}
+			continue
+		}
+		for i, err := range errs {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("input %q: wrong error. want=%q, got=%q", tt.input, tt.expected[i], err.Error())
+			}
+		}
+	}
+}
+
+func testCheck(t *testing.T, input string) []*Error {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("input %q has parser errors: %v", input, p.Errors())
+	}
+	return Check(program)
+}
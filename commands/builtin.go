@@ -0,0 +1,167 @@
+package commands
+
+// builtin は Maya の標準コマンドのうちよく使うもの
+const builtin = `{
+  "commands": {
+    "polyCube": {
+      "query": true,
+      "edit": true,
+      "flags": [
+        {"long": "width", "short": "w", "args": ["float"], "query": true, "edit": true},
+        {"long": "height", "short": "h", "args": ["float"], "query": true, "edit": true},
+        {"long": "depth", "short": "d", "args": ["float"], "query": true, "edit": true},
+        {"long": "subdivisionsX", "short": "sx", "args": ["int"], "query": true, "edit": true},
+        {"long": "subdivisionsY", "short": "sy", "args": ["int"], "query": true, "edit": true},
+        {"long": "subdivisionsZ", "short": "sz", "args": ["int"], "query": true, "edit": true},
+        {"long": "subdivisionsWidth", "short": "sw", "args": ["int"], "query": true, "edit": true},
+        {"long": "subdivisionsHeight", "short": "sh", "args": ["int"], "query": true, "edit": true},
+        {"long": "subdivisionsDepth", "short": "sd", "args": ["int"], "query": true, "edit": true},
+        {"long": "axis", "short": "ax", "args": ["float", "float", "float"], "query": true, "edit": true},
+        {"long": "createUVs", "short": "cuv", "args": ["int"], "query": true, "edit": true},
+        {"long": "caching", "short": "cch", "args": ["bool"], "query": true, "edit": true},
+        {"long": "nodeState", "short": "nds", "args": ["int"], "query": true, "edit": true},
+        {"long": "constructionHistory", "short": "ch", "args": ["bool"], "query": true},
+        {"long": "name", "short": "n", "args": ["string"]},
+        {"long": "object", "short": "o", "args": ["bool"]}
+      ]
+    },
+    "polySphere": {
+      "query": true,
+      "edit": true,
+      "flags": [
+        {"long": "radius", "short": "r", "args": ["float"], "query": true, "edit": true},
+        {"long": "subdivisionsX", "short": "sx", "args": ["int"], "query": true, "edit": true},
+        {"long": "subdivisionsY", "short": "sy", "args": ["int"], "query": true, "edit": true},
+        {"long": "subdivisionsAxis", "short": "sa", "args": ["int"], "query": true, "edit": true},
+        {"long": "subdivisionsHeight", "short": "sh", "args": ["int"], "query": true, "edit": true},
+        {"long": "axis", "short": "ax", "args": ["float", "float", "float"], "query": true, "edit": true},
+        {"long": "createUVs", "short": "cuv", "args": ["int"], "query": true, "edit": true},
+        {"long": "caching", "short": "cch", "args": ["bool"], "query": true, "edit": true},
+        {"long": "nodeState", "short": "nds", "args": ["int"], "query": true, "edit": true},
+        {"long": "constructionHistory", "short": "ch", "args": ["bool"], "query": true},
+        {"long": "name", "short": "n", "args": ["string"]},
+        {"long": "object", "short": "o", "args": ["bool"]}
+      ]
+    },
+    "select": {
+      "flags": [
+        {"long": "add", "short": "add"},
+        {"long": "addFirst", "short": "af"},
+        {"long": "all", "short": "all"},
+        {"long": "allDagObjects", "short": "ado"},
+        {"long": "allDependencyNodes", "short": "adn"},
+        {"long": "clear", "short": "cl"},
+        {"long": "containerCentric", "short": "cc"},
+        {"long": "deselect", "short": "d"},
+        {"long": "hierarchy", "short": "hi"},
+        {"long": "noExpand", "short": "ne"},
+        {"long": "replace", "short": "r"},
+        {"long": "symmetry", "short": "sym"},
+        {"long": "symmetrySide", "short": "sys", "args": ["int"]},
+        {"long": "toggle", "short": "tgl"},
+        {"long": "visible", "short": "vis"}
+      ]
+    },
+    "setAttr": {
+      "flags": [
+        {"long": "alteredValue", "short": "av"},
+        {"long": "caching", "short": "ca", "args": ["bool"]},
+        {"long": "capacityHint", "short": "ch", "args": ["int"]},
+        {"long": "channelBox", "short": "cb", "args": ["bool"]},
+        {"long": "clamp", "short": "c"},
+        {"long": "keyable", "short": "k", "args": ["bool"]},
+        {"long": "lock", "short": "l", "args": ["bool"]},
+        {"long": "size", "short": "s", "args": ["int"]},
+        {"long": "type", "short": "typ", "args": ["string"]}
+      ]
+    },
+    "getAttr": {
+      "flags": [
+        {"long": "asString", "short": "as"},
+        {"long": "caching", "short": "ca"},
+        {"long": "channelBox", "short": "cb"},
+        {"long": "expandEnvironmentVariables", "short": "x"},
+        {"long": "keyable", "short": "k"},
+        {"long": "lock", "short": "l"},
+        {"long": "multiIndices", "short": "mi"},
+        {"long": "settable", "short": "se"},
+        {"long": "silent", "short": "sl"},
+        {"long": "size", "short": "s"},
+        {"long": "time", "short": "t", "args": ["float"]},
+        {"long": "type", "short": "typ"}
+      ]
+    },
+    "connectAttr": {
+      "flags": [
+        {"long": "force", "short": "f"},
+        {"long": "lock", "short": "l", "args": ["bool"]},
+        {"long": "nextAvailable", "short": "na"},
+        {"long": "referenceDest", "short": "rd", "args": ["string"]}
+      ]
+    },
+    "disconnectAttr": {
+      "flags": [
+        {"long": "nextAvailable", "short": "na"}
+      ]
+    },
+    "createNode": {
+      "flags": [
+        {"long": "name", "short": "n", "args": ["string"]},
+        {"long": "parent", "short": "p", "args": ["string"]},
+        {"long": "shared", "short": "s"},
+        {"long": "skipSelect", "short": "ss"}
+      ]
+    },
+    "delete": {
+      "flags": [
+        {"long": "all", "short": "all"},
+        {"long": "attribute", "short": "at", "args": ["string"], "multiUse": true},
+        {"long": "channels", "short": "c"},
+        {"long": "constraints", "short": "cn"},
+        {"long": "constructionHistory", "short": "ch"},
+        {"long": "controlPoints", "short": "cp"},
+        {"long": "expressions", "short": "e"},
+        {"long": "hierarchy", "short": "hi", "args": ["string"]},
+        {"long": "inputConnectionsAndNodes", "short": "icn"},
+        {"long": "motionPaths", "short": "mp"},
+        {"long": "shape", "short": "s"},
+        {"long": "staticChannels", "short": "sc"},
+        {"long": "timeAnimationCurves", "short": "tac", "args": ["bool"]},
+        {"long": "unitlessAnimationCurves", "short": "uac", "args": ["bool"]}
+      ]
+    },
+    "rename": {
+      "flags": [
+        {"long": "ignoreShape", "short": "is"},
+        {"long": "uuid", "short": "uid"}
+      ]
+    },
+    "objExists": {
+      "flags": []
+    },
+    "parent": {
+      "flags": [
+        {"long": "absolute", "short": "a"},
+        {"long": "addObject", "short": "add"},
+        {"long": "noConnections", "short": "nc"},
+        {"long": "noInvScale", "short": "nis"},
+        {"long": "relative", "short": "r"},
+        {"long": "removeObject", "short": "rm"},
+        {"long": "shape", "short": "s"},
+        {"long": "world", "short": "w"}
+      ]
+    },
+    "group": {
+      "flags": [
+        {"long": "absolute", "short": "a"},
+        {"long": "empty", "short": "em"},
+        {"long": "name", "short": "n", "args": ["string"]},
+        {"long": "parent", "short": "p", "args": ["string"]},
+        {"long": "relative", "short": "r"},
+        {"long": "useAsGroup", "short": "uag", "args": ["string"]},
+        {"long": "world", "short": "w"}
+      ]
+    }
+  }
+}
+`
@@ -0,0 +1,208 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Error is a wrong use of a command found by the checker.
+type Error struct {
+	Token   token.Token
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line:%d.%d %s", e.Token.Row, e.Token.Column, e.Message)
+}
+
+// Checker validates the flags of every call of the commands in the database.
+// Calls of commands that are not in the database are not checked.
+type Checker struct {
+	errors []*Error
+	db     *DB
+}
+
+// NewChecker make Checker instance.
+func NewChecker(db *DB) *Checker {
+	return &Checker{errors: []*Error{}, db: db}
+}
+
+// Errors return errors in the order they were found.
+func (c *Checker) Errors() []*Error {
+	return c.errors
+}
+
+// Check checks program with db and returns the errors.
+// Parse program with db.FlagArity set to the parser, so that the values of
+// the flags are split from the objects by the signatures.
+func Check(db *DB, program *ast.Program) []*Error {
+	c := NewChecker(db)
+	c.Check(program)
+	return c.Errors()
+}
+
+// Check checks every CallExpression in program.
+func (c *Checker) Check(program *ast.Program) {
+	for _, stmt := range program.Statements {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpression); ok {
+				c.checkCall(call)
+			}
+			return true
+		})
+	}
+}
+
+func (c *Checker) errorf(tok token.Token, format string, a ...interface{}) {
+	c.errors = append(c.errors, &Error{Token: tok, Message: fmt.Sprintf(format, a...)})
+}
+
+func (c *Checker) checkCall(call *ast.CallExpression) {
+	if call.Function == nil {
+		return
+	}
+	cmd := c.db.Lookup(call.Function.Value)
+	if cmd == nil {
+		return
+	}
+
+	// -query と -edit はどこに書いてもコマンド全体に効く
+	query, edit := false, false
+	for _, arg := range call.Arguments {
+		flag, ok := arg.(*ast.FlagArgument)
+		if !ok || cmd.Flag(flag.Flag) != nil {
+			continue
+		}
+		switch flag.Flag {
+		case "-q", "-query":
+			if !cmd.Query {
+				c.errorf(flag.Token, "command %s does not support query", cmd.Name)
+			}
+			query = true
+		case "-e", "-edit":
+			if !cmd.Edit {
+				c.errorf(flag.Token, "command %s does not support edit", cmd.Name)
+			}
+			edit = true
+		}
+	}
+
+	used := map[*Flag]bool{}
+	for _, arg := range call.Arguments {
+		flag, ok := arg.(*ast.FlagArgument)
+		if !ok {
+			continue
+		}
+		f := cmd.Flag(flag.Flag)
+		if f == nil {
+			switch flag.Flag {
+			case "-q", "-query", "-e", "-edit":
+			default:
+				c.errorf(flag.Token, "unknown flag %s for command %s", flag.Flag, cmd.Name)
+			}
+			continue
+		}
+
+		if used[f] && !f.MultiUse {
+			c.errorf(flag.Token, "flag %s of %s can not be used more than once", flag.Flag, cmd.Name)
+		}
+		used[f] = true
+
+		if query {
+			// query の時 flag は引数を取らない
+			if !f.Query {
+				c.errorf(flag.Token, "flag %s of %s can not be queried", flag.Flag, cmd.Name)
+			}
+			continue
+		}
+		if edit && !f.Edit {
+			c.errorf(flag.Token, "flag %s of %s can not be edited", flag.Flag, cmd.Name)
+		}
+
+		// flag の引数より後ろの値は object
+		n := len(flag.Values)
+		if n < len(f.Args) {
+			c.errorf(flag.Token, "flag %s of %s needs %d %s, got %d",
+				flag.Flag, cmd.Name, len(f.Args), plural(len(f.Args), "argument"), n)
+		} else {
+			n = len(f.Args)
+		}
+		for j, v := range flag.Values[:n] {
+			if typ := typeOf(v); !accepts(f.Args[j], typ) {
+				c.errorf(argToken(v, flag.Token), "flag %s of %s expects %s, got %s",
+					flag.Flag, cmd.Name, f.Args[j], typ)
+			}
+		}
+	}
+}
+
+func plural(n int, s string) string {
+	if n == 1 {
+		return s
+	}
+	return s + "s"
+}
+
+// typeOf は引数の式の型. 静的に決まらない時は Any
+func typeOf(exp ast.Expression) ArgType {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.FloatLiteral:
+		return Float
+	case *ast.StringLiteral:
+		return String
+	case *ast.BooleanLiteral:
+		return Bool
+	case *ast.Identifier:
+		// コマンドの引数の裸の単語は文字列
+		if exp.Token.Type == token.ProcIdent {
+			return String
+		}
+	case *ast.PrefixExpression:
+		if exp.Operator == "-" {
+			switch typ := typeOf(exp.Right); typ {
+			case Int, Float:
+				return typ
+			}
+		}
+	}
+	return Any
+}
+
+// accepts は want 型の引数に got 型の値を渡せるか調べる
+func accepts(want, got ArgType) bool {
+	if want == Any || got == Any || want == got {
+		return true
+	}
+	switch want {
+	case Bool:
+		return got == Int
+	case Int:
+		return got == Bool
+	case Float:
+		return got == Int
+	case String:
+		// コマンドの引数では数も文字列になる
+		return got == Int || got == Float
+	}
+	return false
+}
+
+func argToken(exp ast.Expression, def token.Token) token.Token {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return exp.Token
+	case *ast.FloatLiteral:
+		return exp.Token
+	case *ast.StringLiteral:
+		return exp.Token
+	case *ast.BooleanLiteral:
+		return exp.Token
+	case *ast.Identifier:
+		return exp.Token
+	}
+	return def
+}
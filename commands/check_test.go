@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+)
+
+func TestCheckNoErrors(t *testing.T) {
+	inputs := []string{
+		`polyCube -w 2 -h 1.5 -d 3 -sx 2 -n "box";`,
+		`polyCube -width 2 -axis 0 1 0 -ch on;`,
+		`polyCube -w $w -n $name;`,
+		`polyCube -q -w pCube1;`,
+		`polyCube -e -w 2 polyCube1;`,
+		`float $w = ` + "`polyCube -q -w polyCube1`" + `;`,
+		`string $r[] = polyCube("-w", 2);`,
+		`select -r pCube1 pCube2;`,
+		`setAttr -type "double3" ".t" 1 2 3;`,
+		`setAttr -type "string" $n $v;`,
+		`delete -at "tx" -at "ty" pCube1;`,
+		`createNode transform -n group1 -p world;`,
+		`proc foo() { polyCube -sx 1; }`,
+		`myPluginCommand -anything 1;`,
+	}
+
+	db := Default()
+	for _, input := range inputs {
+		for _, err := range testCheck(t, db, input) {
+			t.Errorf("input %q has error: %s", input, err)
+		}
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			`polyCube -wdth 2;`,
+			[]string{"line:1.10 unknown flag -wdth for command polyCube"},
+		},
+		{
+			`polyCube -w "abc";`,
+			[]string{`line:1.13 flag -w of polyCube expects float, got string`},
+		},
+		{
+			`polyCube -sx 1.5;`,
+			[]string{"line:1.14 flag -sx of polyCube expects int, got float"},
+		},
+		{
+			`polyCube -w -h 2;`,
+			[]string{"line:1.10 flag -w of polyCube needs 1 argument, got 0"},
+		},
+		{
+			`polyCube -ax 0 1;`,
+			[]string{"line:1.10 flag -ax of polyCube needs 3 arguments, got 2"},
+		},
+		{
+			`polyCube -w 1 -width 2;`,
+			[]string{"line:1.15 flag -width of polyCube can not be used more than once"},
+		},
+		{
+			`polyCube -q -n pCube1;`,
+			[]string{"line:1.13 flag -n of polyCube can not be queried"},
+		},
+		{
+			`polyCube -e -ch off polyCube1;`,
+			[]string{"line:1.13 flag -ch of polyCube can not be edited"},
+		},
+		{
+			`select -q;`,
+			[]string{
+				"line:1.8 command select does not support query",
+			},
+		},
+		{
+			"if (1) {\n\t$c = `polyCube -wdth 1`;\n}",
+			[]string{"line:2.17 unknown flag -wdth for command polyCube"},
+		},
+	}
+
+	db := Default()
+	for _, tt := range tests {
+		errs := testCheck(t, db, tt.input)
+		if len(errs) != len(tt.expected) {
+			t.Errorf("input %q: wrong number of errors. want=%d, got=%d", tt.input, len(tt.expected), len(errs))
+			for _, err := range errs {
+				t.Errorf("  %s", err)
+			}
+			continue
+		}
+		for i, err := range errs {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("input %q: wrong error. want=%q, got=%q", tt.input, tt.expected[i], err.Error())
+			}
+		}
+	}
+}
+
+func testCheck(t *testing.T, db *DB, input string) []*Error {
+	l := lexer.New(input)
+	p := parser.New(l)
+	p.SetFlagArity(db.FlagArity)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("input %q has parser errors: %v", input, p.Errors())
+	}
+	return Check(db, program)
+}
@@ -0,0 +1,215 @@
+// Package commands has a database of Maya command signatures and a checker
+// that validates the flags of command calls against it.
+//
+// A database is written in JSON. Each command lists its flags with the short
+// and the long name, the types of the flag arguments, and whether the flag
+// can be used in query and edit mode.
+//
+//	{
+//	  "commands": {
+//	    "polyCube": {
+//	      "query": true,
+//	      "edit": true,
+//	      "flags": [
+//	        {"long": "width", "short": "w", "args": ["float"], "query": true, "edit": true}
+//	      ]
+//	    }
+//	  }
+//	}
+//
+// Default returns the commands built in this package. Load the signatures of
+// in-house plugins and Merge them into it.
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ArgType is the type of a flag argument
+type ArgType string
+
+// Types of flag arguments
+const (
+	Bool   ArgType = "bool"   // true, false, on, off, 1, 0
+	Int    ArgType = "int"    // 1
+	Float  ArgType = "float"  // 1.5, 1
+	String ArgType = "string" // "pCube1", pCube1
+	Any    ArgType = "any"    // 型を調べない
+)
+
+func (t ArgType) valid() bool {
+	switch t {
+	case Bool, Int, Float, String, Any:
+		return true
+	}
+	return false
+}
+
+// Flag is a flag of a command
+type Flag struct {
+	Long     string    `json:"long"`
+	Short    string    `json:"short"`
+	Args     []ArgType `json:"args,omitempty"`
+	Query    bool      `json:"query,omitempty"`    // -query と一緒に使える
+	Edit     bool      `json:"edit,omitempty"`     // -edit と一緒に使える
+	MultiUse bool      `json:"multiUse,omitempty"` // 何度も使える
+}
+
+// Command is the signature of a command
+type Command struct {
+	Name  string  `json:"-"`
+	Query bool    `json:"query,omitempty"` // -query をサポートする
+	Edit  bool    `json:"edit,omitempty"`  // -edit をサポートする
+	Flags []*Flag `json:"flags"`
+
+	flags map[string]*Flag // "-" の無い short と long の名前から引く
+}
+
+// Flag return the flag with the short or long name, or nil. name may start with "-".
+func (c *Command) Flag(name string) *Flag {
+	return c.flags[strings.TrimPrefix(name, "-")]
+}
+
+// DB is a database of command signatures
+type DB struct {
+	commands map[string]*Command
+}
+
+// file は JSON ファイルの形
+type file struct {
+	Commands map[string]*Command `json:"commands"`
+}
+
+// NewDB make empty DB instance.
+func NewDB() *DB {
+	return &DB{commands: map[string]*Command{}}
+}
+
+// Load reads a database in JSON
+func Load(r io.Reader) (*DB, error) {
+	var f file
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("commands: %s", err)
+	}
+
+	db := NewDB()
+	for name, cmd := range f.Commands {
+		if cmd == nil {
+			return nil, fmt.Errorf("commands: command %s has no signature", name)
+		}
+		cmd.Name = name
+		if err := cmd.index(); err != nil {
+			return nil, err
+		}
+		db.commands[name] = cmd
+	}
+	return db, nil
+}
+
+// LoadFile reads a database from the JSON file
+func LoadFile(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return db, nil
+}
+
+// index は flag を名前から引けるようにして, 定義の間違いを調べる
+func (c *Command) index() error {
+	c.flags = map[string]*Flag{}
+	for _, f := range c.Flags {
+		if f == nil || f.Long == "" && f.Short == "" {
+			return fmt.Errorf("commands: command %s has a flag without name", c.Name)
+		}
+		for _, typ := range f.Args {
+			if !typ.valid() {
+				return fmt.Errorf("commands: flag -%s of %s has unknown argument type %q", name(f), c.Name, typ)
+			}
+		}
+		for _, n := range []string{f.Long, f.Short} {
+			if n == "" {
+				continue
+			}
+			if old, ok := c.flags[n]; ok && old != f {
+				return fmt.Errorf("commands: command %s has duplicate flag -%s", c.Name, n)
+			}
+			c.flags[n] = f
+		}
+	}
+	return nil
+}
+
+// name は flag のエラーに使う名前
+func name(f *Flag) string {
+	if f.Short != "" {
+		return f.Short
+	}
+	return f.Long
+}
+
+// Lookup return the command with the name, or nil
+func (db *DB) Lookup(name string) *Command {
+	return db.commands[name]
+}
+
+// FlagArity return the number of values that the flag of the command takes.
+// Flags take no values in query mode. ok is false if the command or the flag
+// is not in the database. It is a parser.FlagArity.
+func (db *DB) FlagArity(command, flag string, query bool) (n int, ok bool) {
+	cmd := db.Lookup(command)
+	if cmd == nil {
+		return 0, false
+	}
+	f := cmd.Flag(flag)
+	if f == nil {
+		switch flag {
+		case "-q", "-query", "-e", "-edit":
+			return 0, true
+		}
+		return 0, false
+	}
+	if query {
+		return 0, true
+	}
+	return len(f.Args), true
+}
+
+// Names return the names of the commands in sorted order
+func (db *DB) Names() []string {
+	var names []string
+	for name := range db.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Merge adds the commands of other. A command in other replaces the command with the same name.
+func (db *DB) Merge(other *DB) {
+	for name, cmd := range other.commands {
+		db.commands[name] = cmd
+	}
+}
+
+// Default return a new database of the built-in Maya commands
+func Default() *DB {
+	db, err := Load(strings.NewReader(builtin))
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	db := Default()
+	cmd := db.Lookup("polyCube")
+	if cmd == nil {
+		t.Fatalf("polyCube not found")
+	}
+	if !cmd.Query || !cmd.Edit {
+		t.Errorf("polyCube should support query and edit")
+	}
+	for _, name := range []string{"w", "-w", "width", "-width"} {
+		f := cmd.Flag(name)
+		if f == nil || f.Long != "width" || !reflect.DeepEqual(f.Args, []ArgType{Float}) {
+			t.Errorf("Flag(%q) wrong: %+v", name, f)
+		}
+	}
+	if cmd.Flag("wdth") != nil {
+		t.Errorf("Flag(wdth) should be nil")
+	}
+	if db.Lookup("myPluginCommand") != nil {
+		t.Errorf("myPluginCommand should not be found")
+	}
+}
+
+func TestFlagArity(t *testing.T) {
+	tests := []struct {
+		command string
+		flag    string
+		query   bool
+		n       int
+		ok      bool
+	}{
+		{"polyCube", "-ax", false, 3, true},
+		{"polyCube", "-axis", true, 0, true},
+		{"polyCube", "-q", false, 0, true},
+		{"select", "-r", false, 0, true},
+		{"setAttr", "-type", false, 1, true},
+		{"polyCube", "-wdth", false, 0, false},
+		{"myPluginCommand", "-w", false, 0, false},
+	}
+
+	db := Default()
+	for _, tt := range tests {
+		n, ok := db.FlagArity(tt.command, tt.flag, tt.query)
+		if n != tt.n || ok != tt.ok {
+			t.Errorf("FlagArity(%q, %q, %t) wrong. want=%d %t, got=%d %t", tt.command, tt.flag, tt.query, tt.n, tt.ok, n, ok)
+		}
+	}
+}
+
+func TestLoadAndMerge(t *testing.T) {
+	input := `{
+  "commands": {
+    "myExport": {
+      "flags": [
+        {"long": "path", "short": "p", "args": ["string"]},
+        {"long": "frameRange", "short": "fr", "args": ["int", "int"], "multiUse": true}
+      ]
+    },
+    "polyCube": {"flags": []}
+  }
+}`
+	plugin, err := Load(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+
+	db := Default()
+	db.Merge(plugin)
+	if cmd := db.Lookup("myExport"); cmd == nil || cmd.Flag("fr") == nil || !cmd.Flag("frameRange").MultiUse {
+		t.Errorf("myExport wrong: %+v", cmd)
+	}
+	if cmd := db.Lookup("polyCube"); cmd == nil || cmd.Flag("w") != nil {
+		t.Errorf("polyCube should be replaced: %+v", cmd)
+	}
+	if cmd := db.Lookup("select"); cmd == nil {
+		t.Errorf("select should be kept")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"commands": {"a": {"flags": [{"long": "x", "args": ["vector"]}]}}}`,
+			`commands: flag -x of a has unknown argument type "vector"`},
+		{`{"commands": {"a": {"flags": [{"long": "x", "short": "x"}, {"long": "y", "short": "x"}]}}}`,
+			"commands: command a has duplicate flag -x"},
+		{`{"commands": {"a": {"flags": [{"args": ["int"]}]}}}`,
+			"commands: command a has a flag without name"},
+		{`{"commands": {"a": {"flag": []}}}`,
+			`commands: json: unknown field "flag"`},
+	}
+
+	for _, tt := range tests {
+		_, err := Load(strings.NewReader(tt.input))
+		if err == nil {
+			t.Errorf("input %s should return error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("input %s wrong error. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
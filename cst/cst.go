@@ -0,0 +1,278 @@
+// Package cst is a lossless concrete syntax tree of MEL.
+//
+// Every token keeps the whitespace and comments around it as trivia, so
+// printing the tree gives back the source byte for byte. Each Node stands
+// for a node of the ast package, and the *ast.Program is the AST of the
+// root, so tools can find a node with the ast package, edit its tokens and
+// print the file with every other byte untouched. The AST is not updated by
+// the edits; call Reparse to get the AST of the edited text.
+package cst
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Element is a *Node or a *Token.
+type Element interface {
+	// String return the source text including the trivia
+	String() string
+	writeTo(b *strings.Builder)
+}
+
+// Token is a token with the trivia around it.
+// Trailing has the whitespace and comments up to the end of the line,
+// and the newline and the following lines are Leading of the next token.
+type Token struct {
+	Token    token.Token
+	Leading  []token.Token // token.Whitespace or token.Comment
+	Trailing []token.Token // token.Whitespace or token.Comment
+}
+
+func (t *Token) String() string {
+	var b strings.Builder
+	t.writeTo(&b)
+	return b.String()
+}
+
+func (t *Token) writeTo(b *strings.Builder) {
+	for _, tr := range t.Leading {
+		b.WriteString(tr.Literal)
+	}
+	b.WriteString(t.Token.Literal)
+	for _, tr := range t.Trailing {
+		b.WriteString(tr.Literal)
+	}
+}
+
+// Node is a node of the tree. Children are the child nodes and the tokens
+// between them in source order.
+type Node struct {
+	AST      ast.Node
+	Children []Element
+}
+
+func (n *Node) String() string {
+	var b strings.Builder
+	n.writeTo(&b)
+	return b.String()
+}
+
+func (n *Node) writeTo(b *strings.Builder) {
+	for _, c := range n.Children {
+		c.writeTo(b)
+	}
+}
+
+// Text return the source text without the leading trivia of the first token
+// and the trailing trivia of the last token.
+func (n *Node) Text() string {
+	tokens := n.Tokens()
+	if len(tokens) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, t := range tokens {
+		if i != 0 {
+			for _, tr := range t.Leading {
+				b.WriteString(tr.Literal)
+			}
+		}
+		b.WriteString(t.Token.Literal)
+		if i != len(tokens)-1 {
+			for _, tr := range t.Trailing {
+				b.WriteString(tr.Literal)
+			}
+		}
+	}
+	return b.String()
+}
+
+// Tokens return the tokens of the node in source order.
+func (n *Node) Tokens() []*Token {
+	var tokens []*Token
+	var collect func(n *Node)
+	collect = func(n *Node) {
+		for _, c := range n.Children {
+			switch c := c.(type) {
+			case *Token:
+				tokens = append(tokens, c)
+			case *Node:
+				collect(c)
+			}
+		}
+	}
+	collect(n)
+	return tokens
+}
+
+// File is the tree of a source file.
+type File struct {
+	Root *Node  // Root.AST is the *ast.Program
+	EOF  *Token // Leading has the trivia at the end of the file
+
+	nodes map[ast.Node]*Node
+}
+
+// Parse parses src and return the tree. It returns the tree with the syntax
+// errors too, and the tree still prints src as it is.
+func Parse(src string) (*File, error) {
+	file := token.NewFileSet().AddFile("", len(src))
+	p := parser.New(lexer.NewFile(file, src, lexer.ScanComments))
+	program := p.ParseProgram()
+
+	tokens, eof := tokenize(file, src)
+	b := &builder{tokens: tokens, nodes: map[ast.Node]*Node{}}
+	root := b.node(program, nil, eof.Token.Pos)
+	return &File{Root: root, EOF: eof, nodes: b.nodes}, p.Errors().Err()
+}
+
+// Program return the AST of the file. It is a snapshot of the last Parse or
+// Reparse, and editing the tokens does not change it.
+func (f *File) Program() *ast.Program {
+	return f.Root.AST.(*ast.Program)
+}
+
+// Reparse parses the current text of f again and replaces the tree and the
+// AST of f with the new ones. The old Nodes and AST nodes are no longer in f.
+func (f *File) Reparse() error {
+	nf, err := Parse(f.String())
+	*f = *nf
+	return err
+}
+
+// Node return the Node of the AST node n, or nil.
+func (f *File) Node(n ast.Node) *Node {
+	return f.nodes[n]
+}
+
+func (f *File) String() string {
+	var b strings.Builder
+	f.Root.writeTo(&b)
+	f.EOF.writeTo(&b)
+	return b.String()
+}
+
+// tokenize は src を trivia の付いた token に分ける. 最後の token は EOF
+func tokenize(file *token.File, src string) ([]*Token, *Token) {
+	l := lexer.NewFile(file, src, lexer.ScanComments|lexer.ScanWhitespace)
+
+	var tokens []*Token
+	var leading []token.Token
+	var last *Token // 同じ行にある前の token
+	for {
+		tok := l.NextToken()
+		switch tok.Type {
+		case token.Whitespace:
+			if last == nil {
+				leading = append(leading, tok)
+				continue
+			}
+			// 改行の前までは前の token の Trailing になる
+			i := strings.IndexAny(tok.Literal, "\r\n")
+			if i < 0 {
+				last.Trailing = append(last.Trailing, tok)
+				continue
+			}
+			if i > 0 {
+				last.Trailing = append(last.Trailing, split(tok, 0, i))
+			}
+			leading = append(leading, split(tok, i, len(tok.Literal)))
+			last = nil
+		case token.Comment:
+			if last == nil {
+				leading = append(leading, tok)
+				continue
+			}
+			last.Trailing = append(last.Trailing, tok)
+			if strings.ContainsAny(tok.Literal, "\r\n") {
+				// 複数行のコメントの後は次の行になる
+				last = nil
+			}
+		case token.EOF:
+			if tok.Offset < len(src) {
+				// NUL で止まった残りも落とさない
+				rest := tok
+				rest.Type = token.Illegal
+				rest.Literal = src[tok.Offset:]
+				leading = append(leading, rest)
+			}
+			tok.Literal = ""
+			return tokens, &Token{Token: tok, Leading: leading}
+		default:
+			last = &Token{Token: tok, Leading: leading}
+			leading = nil
+			tokens = append(tokens, last)
+		}
+	}
+}
+
+// split は 1 行の token の Literal の [i, j) を token にする
+func split(tok token.Token, i, j int) token.Token {
+	tok.Column += len([]rune(tok.Literal[:i]))
+	tok.Offset += i
+	tok.Pos += token.Pos(i)
+	tok.Literal = tok.Literal[i:j]
+	return tok
+}
+
+type builder struct {
+	tokens []*Token
+	i      int // 次に木に入れる token
+	nodes  map[ast.Node]*Node
+}
+
+// node は n の Node を作る. end より前の token が n に入る.
+// inner は n の子ではないが n の範囲にある node で, n の子と同じように入れる.
+// ex) case の label は SwitchExpression の子だが CaseStatement の中にある.
+// 範囲が入れ子になっていない子は Node にせず, その token は n に入る
+func (b *builder) node(n ast.Node, inner []ast.Node, end token.Pos) *Node {
+	node := &Node{AST: n}
+	b.nodes[n] = node
+	list := append(children(n), inner...)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Pos() < list[j].Pos() })
+	for i := 0; i < len(list); i++ {
+		child := list[i]
+		pos, cend := child.Pos(), child.End()
+		if !pos.IsValid() || pos >= cend || cend > end || b.i < len(b.tokens) && pos < b.tokens[b.i].Token.Pos {
+			continue
+		}
+		var in []ast.Node
+		for i+1 < len(list) && list[i+1].Pos() < cend && list[i+1].End() <= cend {
+			i++
+			in = append(in, list[i])
+		}
+		b.tokensBefore(node, pos)
+		node.Children = append(node.Children, b.node(child, in, cend))
+	}
+	b.tokensBefore(node, end)
+	return node
+}
+
+// tokensBefore は pos より前から始まる token を node に入れる
+func (b *builder) tokensBefore(node *Node, pos token.Pos) {
+	for b.i < len(b.tokens) && b.tokens[b.i].Token.Pos < pos {
+		node.Children = append(node.Children, b.tokens[b.i])
+		b.i++
+	}
+}
+
+// children は n の直接の子を返す
+func children(n ast.Node) []ast.Node {
+	var list []ast.Node
+	ast.Inspect(n, func(c ast.Node) bool {
+		if c == n {
+			return true
+		}
+		if c != nil {
+			list = append(list, c)
+		}
+		return false
+	})
+	return list
+}
@@ -0,0 +1,247 @@
+package cst
+
+import (
+	"go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	melast "github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// corpus は parser_test.go の中のすべての文字列リテラルを返す
+func corpus(t *testing.T) []string {
+	f, err := goparser.ParseFile(gotoken.NewFileSet(), "../parser/parser_test.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inputs []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == gotoken.STRING {
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inputs = append(inputs, s)
+		}
+		return true
+	})
+	return inputs
+}
+
+func TestRoundTrip(t *testing.T) {
+	inputs := append(corpus(t),
+		"",
+		"  \n// only comments\r\n/* and */  ",
+		"proc f() {\r\n\tls; // crlf\r\n}\r\n",
+		"print \"\xff\";\xfe\n",
+		"ls;\x00 after nul",
+		"ls; /* not terminated\n",
+		"print \"not terminated\n",
+	)
+	if len(inputs) < 300 {
+		t.Fatalf("too few inputs in the corpus: %d", len(inputs))
+	}
+
+	for _, input := range inputs {
+		f, _ := Parse(input)
+		if got := f.String(); got != input {
+			t.Errorf("input %q: wrong text. got=%q", input, got)
+			continue
+		}
+
+		// 位置のある node はすべて Node になる. Program の Node はファイル全体になる
+		melast.Inspect(f.Program(), func(n melast.Node) bool {
+			if n == f.Root.AST {
+				return true
+			}
+			if n == nil || !n.Pos().IsValid() || n.Pos() >= n.End() {
+				return false
+			}
+			if node := f.Node(n); node == nil {
+				t.Errorf("input %q: %T %q has no Node", input, n, n)
+			} else if node.Text() != input[n.Pos()-1:n.End()-1] {
+				t.Errorf("input %q: wrong text of %T. want=%q, got=%q", input, n, input[n.Pos()-1:n.End()-1], node.Text())
+			}
+			return true
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, input := range []string{"proc f() { print 1;", "if (1) { print 1;\nprint 2;", "int $a = ;"} {
+		f, err := Parse(input)
+		if err == nil {
+			t.Errorf("input %q: no error", input)
+		}
+		if got := f.String(); got != input {
+			t.Errorf("input %q: wrong text. got=%q", input, got)
+		}
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	input := "// doc\nproc f() { // open\n\tls;  /* a\n b */ pwd;\n}\n\n// end\n"
+	f, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		literal  string
+		leading  string
+		trailing string
+	}{
+		{"proc", "// doc\n", " "},
+		{"{", "", " // open"},
+		{"ls", "\n\t", ""},
+		{";", "", "  /* a\n b */"},
+		{"pwd", " ", ""},
+		{"}", "\n", ""},
+	}
+
+	tokens := f.Root.Tokens()
+	for _, tt := range tests {
+		var found *Token
+		for _, tok := range tokens {
+			if tok.Token.Literal == tt.literal {
+				found = tok
+				break
+			}
+		}
+		if found == nil {
+			t.Fatalf("token %q not found", tt.literal)
+		}
+		if got := join(found.Leading); got != tt.leading {
+			t.Errorf("token %q: wrong leading. want=%q, got=%q", tt.literal, tt.leading, got)
+		}
+		if got := join(found.Trailing); got != tt.trailing {
+			t.Errorf("token %q: wrong trailing. want=%q, got=%q", tt.literal, tt.trailing, got)
+		}
+	}
+
+	if got := join(f.EOF.Leading); got != "\n\n// end\n" {
+		t.Errorf("wrong trivia at the end. got=%q", got)
+	}
+	// 分けた空白の位置
+	ws := tokens[len(tokens)-1].Leading[0]
+	if ws.Literal != "\n" || ws.Row != 4 || ws.Column != 11 || input[ws.Offset:ws.Offset+1] != "\n" {
+		t.Errorf("wrong position of the split whitespace. got=%+v", ws)
+	}
+}
+
+func join(trivia []token.Token) string {
+	var b strings.Builder
+	for _, tr := range trivia {
+		b.WriteString(tr.Literal)
+	}
+	return b.String()
+}
+
+func TestCaseStatement(t *testing.T) {
+	input := "switch ($a) {\n\tcase 1: // one\n\t\tls;\n\tdefault:\n\t\tpwd;\n}\n"
+	f, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// case の label は CaseStatement の Node に入る
+	se := f.Program().Statements[0].(*melast.ExpressionStatement).Expression.(*melast.SwitchExpression)
+	expected := []string{"case 1: // one\n\t\tls;", "default:\n\t\tpwd;"}
+	for i, cs := range se.CaseStatements {
+		node := f.Node(cs)
+		if node == nil {
+			t.Fatalf("CaseStatements[%d] has no Node", i)
+		}
+		if got := node.Text(); got != expected[i] {
+			t.Errorf("CaseStatements[%d]: wrong text. want=%q, got=%q", i, expected[i], got)
+		}
+	}
+	if label := f.Node(se.Cases[0]); label == nil || !containsNode(f.Node(se.CaseStatements[0]), label) {
+		t.Errorf("the case label is not in the Node of the CaseStatement")
+	}
+}
+
+func containsNode(n, target *Node) bool {
+	for _, c := range n.Children {
+		if c, ok := c.(*Node); ok && (c == target || containsNode(c, target)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEdit(t *testing.T) {
+	input := "global proc  old() {\n\tls; // keep\n}\n\nproc other() { old; }\n"
+	f, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// proc の名前だけを書き換えて, ほかの byte はそのまま残す
+	gs := f.Program().Statements[0].(*melast.GlobalStatement)
+	ps := gs.Statement.(*melast.ProcStatement)
+	for _, tok := range f.Node(ps).Tokens() {
+		if tok.Token.Pos == ps.Name.Pos {
+			tok.Token.Literal = "renamed"
+		}
+	}
+
+	expected := "global proc  renamed() {\n\tls; // keep\n}\n\nproc other() { old; }\n"
+	if got := f.String(); got != expected {
+		t.Errorf("wrong text.\nwant=%q\ngot=%q", expected, got)
+	}
+	if got := f.Node(gs).Text(); got != "global proc  renamed() {\n\tls; // keep\n}" {
+		t.Errorf("wrong text of the proc. got=%q", got)
+	}
+}
+
+func TestReparse(t *testing.T) {
+	input := "proc old() { ls; }\nold;\n"
+	f, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := f.Program().Statements[0].(*melast.ProcStatement)
+	for _, tok := range f.Node(ps).Tokens() {
+		if tok.Token.Pos == ps.Name.Pos {
+			tok.Token.Literal = "renamed"
+		}
+	}
+
+	// Program は Parse した時の AST のまま
+	if got := f.Program().Statements[0].(*melast.ProcStatement).Name.Literal; got != "old" {
+		t.Errorf("Program changed before Reparse. got=%q", got)
+	}
+
+	if err := f.Reparse(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "proc renamed() { ls; }\nold;\n"
+	if got := f.String(); got != expected {
+		t.Errorf("wrong text.\nwant=%q\ngot=%q", expected, got)
+	}
+	ps = f.Program().Statements[0].(*melast.ProcStatement)
+	if ps.Name.Literal != "renamed" {
+		t.Errorf("wrong proc name after Reparse. got=%q", ps.Name.Literal)
+	}
+	if node := f.Node(ps); node == nil || node.Text() != "proc renamed() { ls; }" {
+		t.Errorf("wrong Node of the proc after Reparse. got=%v", node)
+	}
+
+	// 壊した text は Reparse がエラーを返すが text はそのまま
+	for _, tok := range f.Node(ps).Tokens() {
+		if tok.Token.Literal == "}" {
+			tok.Token.Literal = ""
+		}
+	}
+	if err := f.Reparse(); err == nil {
+		t.Errorf("Reparse of %q returned no error", f.String())
+	}
+	if got := f.String(); got != "proc renamed() { ls; \nold;\n" {
+		t.Errorf("wrong text after Reparse with an error. got=%q", got)
+	}
+}
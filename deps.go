@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/workspace"
+)
+
+// runDeps は deps サブコマンドを実行して終了コードを返す.
+// 見つからないファイルか循環があれば 1, ファイルを読めなければ 2 を返す.
+func runDeps(args []string) int {
+	fs := flag.NewFlagSet("deps", flag.ExitOnError)
+	format := fs.String("format", "dot", "output format: dot or json")
+	path := fs.String("path", os.Getenv("MAYA_SCRIPT_PATH"), "script path to search for sourced files")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-MEL deps [-format dot|json] [-path dirs] path ...")
+		fmt.Fprintln(os.Stderr, "Print the source graph of MEL files.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 || (*format != "dot" && *format != "json") {
+		fs.Usage()
+		return 2
+	}
+
+	ws, err := loadWorkspace(*path, fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ws.LoadSources()
+	g := ws.Graph()
+	if *format == "json" {
+		out, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Print(g.DOT())
+	}
+
+	for _, dep := range g.Missing {
+		fmt.Fprintf(os.Stderr, "%s: line:%d.%d source %s is not found\n", dep.From, dep.Line, dep.Column, dep.Name)
+	}
+	for _, cycle := range g.Cycles {
+		fmt.Fprintf(os.Stderr, "source cycle: %s\n", strings.Join(cycle, " -> "))
+	}
+	if len(g.Missing) != 0 || len(g.Cycles) != 0 {
+		return 1
+	}
+	return 0
+}
+
+// loadWorkspace は paths のファイルとディレクトリを読み込んだワークスペースを返す.
+// scriptPath は source するファイルを探すディレクトリのリスト
+func loadWorkspace(scriptPath string, paths []string) (*workspace.Workspace, error) {
+	ws := workspace.New()
+	if scriptPath != "" {
+		ws.ScriptPath = filepath.SplitList(scriptPath)
+	}
+	for _, p := range paths {
+		stat, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if stat.IsDir() {
+			err = ws.AddDir(p)
+		} else {
+			err = ws.AddFile(p)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ws, nil
+}
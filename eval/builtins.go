@@ -0,0 +1,496 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/object"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// builtins は Maya 無しで実行できる MEL の組み込み proc
+var builtins = map[string]*object.Builtin{}
+
+func init() {
+	register := func(name string, fn object.BuiltinFunction) {
+		builtins[name] = &object.Builtin{Name: name, Fn: fn}
+	}
+
+	register("print", builtinPrint)
+	register("size", builtinSize)
+	register("clear", builtinClear)
+	register("error", builtinMelError)
+	register("warning", builtinMelWarning)
+	register("exists", builtinExists)
+
+	// math
+	register("abs", mathFunc(math.Abs))
+	register("ceil", mathFunc(math.Ceil))
+	register("floor", mathFunc(math.Floor))
+	register("trunc", mathFunc(math.Trunc))
+	register("sqrt", mathFunc(math.Sqrt))
+	register("sin", mathFunc(math.Sin))
+	register("cos", mathFunc(math.Cos))
+	register("tan", mathFunc(math.Tan))
+	register("exp", mathFunc(math.Exp))
+	register("log", mathFunc(math.Log))
+	register("pow", builtinPow)
+	register("min", builtinMin)
+	register("max", builtinMax)
+
+	// vector
+	register("mag", builtinMag)
+	register("unit", builtinUnit)
+	register("dot", builtinDot)
+	register("cross", builtinCross)
+
+	// string
+	register("toupper", stringFunc(strings.ToUpper))
+	register("tolower", stringFunc(strings.ToLower))
+	register("strip", stringFunc(strings.TrimSpace))
+	register("substring", builtinSubstring)
+	register("startsWith", builtinStartsWith)
+	register("endsWith", builtinEndsWith)
+	register("match", builtinMatch)
+	register("gmatch", builtinGmatch)
+	register("substitute", builtinSubstitute)
+	register("substituteAllString", builtinSubstituteAllString)
+	register("tokenize", builtinTokenize)
+
+	// string array
+	register("stringArrayToString", builtinStringArrayToString)
+	register("stringToStringArray", builtinStringToStringArray)
+	register("stringArrayContains", builtinStringArrayContains)
+	register("sort", builtinSort)
+}
+
+func newBuiltinError(msg string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(msg, a...)}
+}
+
+func checkArgs(name string, args []object.Object, n int) *object.Error {
+	if len(args) != n {
+		return newBuiltinError("%s", object.WrongArgumentCount(name))
+	}
+	return nil
+}
+
+func stringArg(obj object.Object) string {
+	s, ok := convert(obj, object.StringType, token.Token{}).(*object.String)
+	if !ok {
+		return ""
+	}
+	return s.Value
+}
+
+func arrayArg(name string, obj object.Object) (*object.Array, *object.Error) {
+	arr, ok := obj.(*object.Array)
+	if !ok {
+		return nil, newBuiltinError("%s needs an array. got=%s", name, obj.Type())
+	}
+	return arr, nil
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.IntType || obj.Type() == object.FloatType
+}
+
+func toFloat(obj object.Object) float64 {
+	f, err := object.Convert(obj, object.FloatType)
+	if err != nil {
+		return 0
+	}
+	return f.(*object.Float).Value
+}
+
+func floatArg(name string, obj object.Object) (float64, *object.Error) {
+	if !isNumber(obj) {
+		return 0, newBuiltinError("%s needs a number. got=%s", name, obj.Type())
+	}
+	return toFloat(obj), nil
+}
+
+func builtinPrint(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("print", args, 1); err != nil {
+		return err
+	}
+
+	out := env.Out()
+	if arr, ok := args[0].(*object.Array); ok {
+		for _, e := range arr.Elements {
+			io.WriteString(out, e.Inspect()+"\n")
+		}
+		return VOID
+	}
+	io.WriteString(out, args[0].Inspect())
+	return VOID
+}
+
+func builtinSize(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("size", args, 1); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.String:
+		return &object.Integer{Value: int64(len([]rune(arg.Value)))}
+	}
+	return newBuiltinError("size needs an array or a string. got=%s", args[0].Type())
+}
+
+func builtinClear(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("clear", args, 1); err != nil {
+		return err
+	}
+
+	arr, err := arrayArg("clear", args[0])
+	if err != nil {
+		return err
+	}
+	arr.Elements = nil
+	return &object.Integer{Value: 1}
+}
+
+// builtinMelError は error "msg"; を実行時エラーとして扱う
+func builtinMelError(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("error", args, 1); err != nil {
+		return err
+	}
+	return newBuiltinError("%s", stringArg(args[0]))
+}
+
+func builtinMelWarning(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("warning", args, 1); err != nil {
+		return err
+	}
+	io.WriteString(env.Out(), "// Warning: "+stringArg(args[0])+"\n")
+	return VOID
+}
+
+func builtinExists(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("exists", args, 1); err != nil {
+		return err
+	}
+	name := stringArg(args[0])
+	_, isProc := env.GetProc(name)
+	_, isBuiltin := builtins[name]
+	return object.Bool(isProc || isBuiltin)
+}
+
+func mathFunc(fn func(float64) float64) object.BuiltinFunction {
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArgs("math function", args, 1); err != nil {
+			return err
+		}
+		f, err := floatArg("math function", args[0])
+		if err != nil {
+			return err
+		}
+		return &object.Float{Value: fn(f)}
+	}
+}
+
+func builtinPow(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("pow", args, 2); err != nil {
+		return err
+	}
+	x, err := floatArg("pow", args[0])
+	if err != nil {
+		return err
+	}
+	y, err := floatArg("pow", args[1])
+	if err != nil {
+		return err
+	}
+	return &object.Float{Value: math.Pow(x, y)}
+}
+
+func builtinMin(env *object.Environment, args ...object.Object) object.Object {
+	return minMax("min", args, func(a, b float64) bool { return a < b })
+}
+
+func builtinMax(env *object.Environment, args ...object.Object) object.Object {
+	return minMax("max", args, func(a, b float64) bool { return a > b })
+}
+
+func minMax(name string, args []object.Object, better func(a, b float64) bool) object.Object {
+	if err := checkArgs(name, args, 2); err != nil {
+		return err
+	}
+	a, err := floatArg(name, args[0])
+	if err != nil {
+		return err
+	}
+	b, err := floatArg(name, args[1])
+	if err != nil {
+		return err
+	}
+
+	result := args[1]
+	if better(a, b) {
+		result = args[0]
+	}
+	if args[0].Type() == object.IntType && args[1].Type() == object.IntType {
+		return result
+	}
+	return convert(result, object.FloatType, token.Token{})
+}
+
+func vectorArg(name string, obj object.Object) (*object.Vector, *object.Error) {
+	v, ok := obj.(*object.Vector)
+	if !ok {
+		return nil, newBuiltinError("%s needs a vector. got=%s", name, obj.Type())
+	}
+	return v, nil
+}
+
+func builtinMag(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("mag", args, 1); err != nil {
+		return err
+	}
+	v, err := vectorArg("mag", args[0])
+	if err != nil {
+		return err
+	}
+	return &object.Float{Value: v.Magnitude()}
+}
+
+func builtinUnit(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("unit", args, 1); err != nil {
+		return err
+	}
+	v, err := vectorArg("unit", args[0])
+	if err != nil {
+		return err
+	}
+	m := v.Magnitude()
+	if m == 0 {
+		return v
+	}
+	return &object.Vector{X: v.X / m, Y: v.Y / m, Z: v.Z / m}
+}
+
+func builtinDot(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("dot", args, 2); err != nil {
+		return err
+	}
+	return binaryOp("*", args[0], args[1], token.Token{})
+}
+
+func builtinCross(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("cross", args, 2); err != nil {
+		return err
+	}
+	return binaryOp("^", args[0], args[1], token.Token{})
+}
+
+func stringFunc(fn func(string) string) object.BuiltinFunction {
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArgs("string function", args, 1); err != nil {
+			return err
+		}
+		return &object.String{Value: fn(stringArg(args[0]))}
+	}
+}
+
+// builtinSubstring は 1 はじまりで end を含む部分文字列を返す
+func builtinSubstring(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("substring", args, 3); err != nil {
+		return err
+	}
+	s := []rune(stringArg(args[0]))
+	start, err := floatArg("substring", args[1])
+	if err != nil {
+		return err
+	}
+	end, err := floatArg("substring", args[2])
+	if err != nil {
+		return err
+	}
+
+	from, to := int(start), int(end)
+	if from < 1 {
+		from = 1
+	}
+	if to > len(s) {
+		to = len(s)
+	}
+	if from > to {
+		return &object.String{}
+	}
+	return &object.String{Value: string(s[from-1 : to])}
+}
+
+func builtinStartsWith(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("startsWith", args, 2); err != nil {
+		return err
+	}
+	return object.Bool(strings.HasPrefix(stringArg(args[0]), stringArg(args[1])))
+}
+
+func builtinEndsWith(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("endsWith", args, 2); err != nil {
+		return err
+	}
+	return object.Bool(strings.HasSuffix(stringArg(args[0]), stringArg(args[1])))
+}
+
+func builtinMatch(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("match", args, 2); err != nil {
+		return err
+	}
+	re, err := regexp.Compile(stringArg(args[0]))
+	if err != nil {
+		return newBuiltinError("invalid regular expression: %s", err)
+	}
+	return &object.String{Value: re.FindString(stringArg(args[1]))}
+}
+
+// builtinGmatch は glob 形式のパターン (*, ?, [...]) で文字列を照合する
+func builtinGmatch(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("gmatch", args, 2); err != nil {
+		return err
+	}
+	pattern := regexp.QuoteMeta(stringArg(args[1]))
+	pattern = strings.Replace(pattern, `\*`, ".*", -1)
+	pattern = strings.Replace(pattern, `\?`, ".", -1)
+	pattern = strings.Replace(pattern, `\[`, "[", -1)
+	pattern = strings.Replace(pattern, `\]`, "]", -1)
+	re, err := regexp.Compile("^" + pattern + "$")
+	if err != nil {
+		return newBuiltinError("invalid pattern: %s", err)
+	}
+	return object.Bool(re.MatchString(stringArg(args[0])))
+}
+
+// builtinSubstitute は最初に一致した部分だけを置き換える
+func builtinSubstitute(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("substitute", args, 3); err != nil {
+		return err
+	}
+	re, err := regexp.Compile(stringArg(args[0]))
+	if err != nil {
+		return newBuiltinError("invalid regular expression: %s", err)
+	}
+	s := stringArg(args[1])
+	loc := re.FindStringIndex(s)
+	if loc == nil {
+		return &object.String{Value: s}
+	}
+	return &object.String{Value: s[:loc[0]] + stringArg(args[2]) + s[loc[1]:]}
+}
+
+func builtinSubstituteAllString(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("substituteAllString", args, 3); err != nil {
+		return err
+	}
+	return &object.String{
+		Value: strings.Replace(stringArg(args[0]), stringArg(args[1]), stringArg(args[2]), -1),
+	}
+}
+
+func splitByChars(s, chars string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune(chars, r)
+	})
+}
+
+// builtinTokenize は tokenize $s " " $buffer; のように最後の引数の配列に結果を書き込む
+func builtinTokenize(env *object.Environment, args ...object.Object) object.Object {
+	var chars string
+	var buffer object.Object
+	switch len(args) {
+	case 2:
+		chars, buffer = " \t\n", args[1]
+	case 3:
+		chars, buffer = stringArg(args[1]), args[2]
+	default:
+		return newBuiltinError("%s", object.WrongArgumentCount("tokenize"))
+	}
+
+	arr, err := arrayArg("tokenize", buffer)
+	if err != nil {
+		return err
+	}
+	if arr.ElementType != object.StringType {
+		return newBuiltinError("tokenize needs a string[]. got=%s", arr.Type())
+	}
+
+	arr.Elements = nil
+	for _, f := range splitByChars(stringArg(args[0]), chars) {
+		arr.Elements = append(arr.Elements, &object.String{Value: f})
+	}
+	return &object.Integer{Value: int64(len(arr.Elements))}
+}
+
+func builtinStringArrayToString(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("stringArrayToString", args, 2); err != nil {
+		return err
+	}
+	arr, err := arrayArg("stringArrayToString", args[0])
+	if err != nil {
+		return err
+	}
+	var ss []string
+	for _, e := range arr.Elements {
+		ss = append(ss, stringArg(e))
+	}
+	return &object.String{Value: strings.Join(ss, stringArg(args[1]))}
+}
+
+func builtinStringToStringArray(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("stringToStringArray", args, 2); err != nil {
+		return err
+	}
+	arr := &object.Array{ElementType: object.StringType}
+	for _, f := range splitByChars(stringArg(args[0]), stringArg(args[1])) {
+		arr.Elements = append(arr.Elements, &object.String{Value: f})
+	}
+	return arr
+}
+
+func builtinStringArrayContains(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("stringArrayContains", args, 2); err != nil {
+		return err
+	}
+	arr, err := arrayArg("stringArrayContains", args[1])
+	if err != nil {
+		return err
+	}
+	s := stringArg(args[0])
+	for _, e := range arr.Elements {
+		if stringArg(e) == s {
+			return object.Bool(true)
+		}
+	}
+	return object.Bool(false)
+}
+
+func builtinSort(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgs("sort", args, 1); err != nil {
+		return err
+	}
+	arr, err := arrayArg("sort", args[0])
+	if err != nil {
+		return err
+	}
+
+	sorted := &object.Array{
+		ElementType: arr.ElementType,
+		Elements:    append([]object.Object(nil), arr.Elements...),
+	}
+	sort.SliceStable(sorted.Elements, func(i, j int) bool {
+		a, b := sorted.Elements[i], sorted.Elements[j]
+		if isNumber(a) && isNumber(b) {
+			return toFloat(a) < toFloat(b)
+		}
+		return a.Inspect() < b.Inspect()
+	})
+	return sorted
+}
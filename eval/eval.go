@@ -0,0 +1,1086 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/object"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// singleton objects
+var (
+	VOID     = &object.Void{}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+)
+
+// Eval は node を env の中で評価して結果を返す
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+
+	// Statements
+	case *ast.Program:
+		return evalProgram(node, env)
+	case *ast.ExpressionStatement:
+		return evalExpressionStatement(node, env)
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, object.NewEnclosedEnvironment(env))
+	case *ast.GlobalStatement:
+		return evalGlobalStatement(node, env)
+	case *ast.ProcStatement:
+		env.SetProc(node.Name.Literal, &object.Proc{Statement: node})
+		return VOID
+	case *ast.VariableStatement:
+		return evalAssignments(node.Names, node.Assigns, node.Values, env)
+	case *ast.IntegerStatement:
+		return evalDeclaration(object.IntType, node.Names, node.Assigns, node.Values, env, false)
+	case *ast.FloatStatement:
+		return evalDeclaration(object.FloatType, node.Names, node.Assigns, node.Values, env, false)
+	case *ast.StringStatement:
+		return evalDeclaration(object.StringType, node.Names, node.Assigns, node.Values, env, false)
+	case *ast.VectorStatement:
+		return evalDeclaration(object.VectorType, node.Names, node.Assigns, node.Values, env, false)
+	case *ast.MatrixStatement:
+		return evalDeclaration(object.MatrixType, node.Names, node.Assigns, node.Values, env, false)
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			return &object.ReturnValue{}
+		}
+		val := Eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+
+	// Control flow
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+	case *ast.WhileExpression:
+		return evalWhileExpression(node, env)
+	case *ast.DoWhileExpression:
+		return evalDoWhileExpression(node, env)
+	case *ast.ForExpression:
+		return evalForExpression(node, env)
+	case *ast.ForInExpression:
+		return evalForInExpression(node, env)
+	case *ast.SwitchExpression:
+		return evalSwitchExpression(node, env)
+
+	// Expressions
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.BooleanLiteral:
+		return object.Bool(node.Value)
+	case *ast.TensorLiteral:
+		return evalTensorLiteral(node, env)
+	case *ast.ArrayLiteral:
+		return evalArrayLiteral(node, env)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.PrefixExpression:
+		return evalPrefixExpression(node, env)
+	case *ast.PostfixExpression:
+		return evalCrement(node.Left, node.Operator, node.Token, false, env)
+	case *ast.InfixExpression:
+		return evalInfixExpression(node, env)
+	case *ast.TernaryExpression:
+		cond := Eval(node.Conditional, env)
+		if isError(cond) {
+			return cond
+		}
+		if object.Truthy(cond) {
+			return Eval(node.TrueExp, env)
+		}
+		return Eval(node.FalseExp, env)
+	case *ast.CastExpression:
+		val := Eval(node.Right, env)
+		if isError(val) {
+			return val
+		}
+		return convert(val, object.Type(node.Token.Literal), node.Token)
+	case *ast.IndexExpression:
+		return evalIndexExpression(node, env)
+	case *ast.CallExpression:
+		return evalCallExpression(node, env)
+	}
+
+	if node == nil {
+		return VOID
+	}
+	return newError(tokenOf(node), "cannot evaluate %T", node)
+}
+
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	// proc はファイル内のどこで定義されていても呼び出せる
+	for _, stmt := range program.Statements {
+		hoistProc(stmt, env)
+	}
+
+	var result object.Object = VOID
+	for _, stmt := range program.Statements {
+		result = Eval(stmt, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			if result.Value == nil {
+				return VOID
+			}
+			return result.Value
+		case *object.Error:
+			return result
+		case *object.Break, *object.Continue:
+			return VOID
+		}
+	}
+
+	return result
+}
+
+func hoistProc(stmt ast.Statement, env *object.Environment) {
+	switch stmt := stmt.(type) {
+	case *ast.ProcStatement:
+		env.SetProc(stmt.Name.Literal, &object.Proc{Statement: stmt})
+	case *ast.GlobalStatement:
+		if proc, ok := stmt.Statement.(*ast.ProcStatement); ok {
+			env.SetProc(proc.Name.Literal, &object.Proc{Statement: proc, Global: true})
+		}
+	}
+}
+
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object = VOID
+
+	for _, stmt := range block.Statements {
+		result = Eval(stmt, env)
+
+		switch result.(type) {
+		case *object.ReturnValue, *object.Error, *object.Break, *object.Continue:
+			return result
+		}
+	}
+
+	return result
+}
+
+func evalExpressionStatement(stmt *ast.ExpressionStatement, env *object.Environment) object.Object {
+	if stmt.Expression == nil {
+		return VOID
+	}
+
+	// "foo;" は引数なしで proc foo を呼び出す
+	if ident, ok := stmt.Expression.(*ast.Identifier); ok && ident.Token.Type == token.ProcIdent {
+		return callByName(ident.Value, nil, ident.Token, env)
+	}
+
+	return Eval(stmt.Expression, env)
+}
+
+func evalGlobalStatement(gs *ast.GlobalStatement, env *object.Environment) object.Object {
+	switch stmt := gs.Statement.(type) {
+	case *ast.ProcStatement:
+		env.SetProc(stmt.Name.Literal, &object.Proc{Statement: stmt, Global: true})
+		return VOID
+	case *ast.IntegerStatement:
+		return evalDeclaration(object.IntType, stmt.Names, stmt.Assigns, stmt.Values, env, true)
+	case *ast.FloatStatement:
+		return evalDeclaration(object.FloatType, stmt.Names, stmt.Assigns, stmt.Values, env, true)
+	case *ast.StringStatement:
+		return evalDeclaration(object.StringType, stmt.Names, stmt.Assigns, stmt.Values, env, true)
+	case *ast.VectorStatement:
+		return evalDeclaration(object.VectorType, stmt.Names, stmt.Assigns, stmt.Values, env, true)
+	case *ast.MatrixStatement:
+		return evalDeclaration(object.MatrixType, stmt.Names, stmt.Assigns, stmt.Values, env, true)
+	}
+	return newError(gs.Token, "global can not be used with %s", gs.Statement.TokenLiteral())
+}
+
+// evalDeclaration は int $a = 1, $b[], $c[3]; のような宣言を評価する
+func evalDeclaration(
+	typ object.Type,
+	names []ast.Expression,
+	assigns []token.Token,
+	values []ast.Expression,
+	env *object.Environment,
+	global bool,
+) object.Object {
+	var result object.Object = VOID
+
+	for i, name := range names {
+		ident, declType, dims, errObj := declarationTarget(typ, name, env)
+		if errObj != nil {
+			return errObj
+		}
+
+		zero, errObj := zeroValue(declType, dims, ident.Token)
+		if errObj != nil {
+			return errObj
+		}
+
+		var v *object.Variable
+		if global {
+			v = env.DeclareGlobal(ident.Value, declType, zero)
+		} else {
+			if old, ok := env.Get(ident.Value); ok && env.IsDeclaredHere(ident.Value) &&
+				old.Type != declType {
+				return newError(ident.Token,
+					"variable %s redeclared with type %s (was %s)", ident.Value, declType, old.Type)
+			}
+			v = env.Declare(ident.Value, declType, zero)
+		}
+
+		if v.Type != declType {
+			return newError(ident.Token,
+				"variable %s redeclared with type %s (was %s)", ident.Value, declType, v.Type)
+		}
+
+		if i >= len(values) || values[i] == nil {
+			result = v.Value
+			continue
+		}
+
+		val := Eval(values[i], env)
+		if isError(val) {
+			return val
+		}
+		if assigns[i].Type != token.Assign {
+			val = binaryOp(assignOperator(assigns[i]), v.Value, val, assigns[i])
+			if isError(val) {
+				return val
+			}
+		}
+		val = assignValue(v, val, assigns[i])
+		if isError(val) {
+			return val
+		}
+		result = val
+	}
+
+	return result
+}
+
+// declarationTarget は宣言される名前から変数名と型と配列サイズを取り出す
+func declarationTarget(typ object.Type, name ast.Expression, env *object.Environment) (
+	*ast.Identifier, object.Type, []int, *object.Error) {
+
+	var dims []int
+	for {
+		switch n := name.(type) {
+		case *ast.Identifier:
+			for i, j := 0, len(dims)-1; i < j; i, j = i+1, j-1 {
+				dims[i], dims[j] = dims[j], dims[i]
+			}
+			if typ == object.MatrixType {
+				if len(dims) != 0 && len(dims) != 2 {
+					return nil, "", nil, newError(n.Token, "matrix %s needs two sizes", n.Value)
+				}
+				return n, typ, dims, nil
+			}
+			if len(dims) > 1 {
+				return nil, "", nil, newError(n.Token, "multi dimensional array %s is not supported", n.Value)
+			}
+			if len(dims) == 1 {
+				return n, typ.ArrayOf(), dims, nil
+			}
+			return n, typ, nil, nil
+		case *ast.IndexExpression:
+			size := -1 // -1 は $a[] のようにサイズ指定が無いもの
+			if n.Index != nil {
+				idx := Eval(n.Index, env)
+				if isError(idx) {
+					return nil, "", nil, idx.(*object.Error)
+				}
+				i, ok := idx.(*object.Integer)
+				if !ok || i.Value < 0 {
+					return nil, "", nil, newError(n.Token, "array size must be a non negative int")
+				}
+				size = int(i.Value)
+			}
+			dims = append(dims, size)
+			name = n.Left
+		default:
+			return nil, "", nil, newError(tokenOf(name), "%s is not a variable name", name.String())
+		}
+	}
+}
+
+// zeroValue は宣言された変数の初期値を返す. int $a[3]; は 3 要素の配列になる
+func zeroValue(typ object.Type, dims []int, tok token.Token) (object.Object, *object.Error) {
+	if typ == object.MatrixType && len(dims) == 2 {
+		if dims[0] < 0 || dims[1] < 0 {
+			return nil, newError(tok, "matrix needs explicit sizes")
+		}
+		return object.NewMatrix(dims[0], dims[1]), nil
+	}
+
+	zero := object.Zero(typ)
+	if arr, ok := zero.(*object.Array); ok && len(dims) == 1 && dims[0] > 0 {
+		arr.Resize(dims[0])
+	}
+	return zero, nil
+}
+
+// evalAssignments は $a = 1, $b[0] += 2; のような代入を評価する
+func evalAssignments(
+	names []ast.Expression,
+	assigns []token.Token,
+	values []ast.Expression,
+	env *object.Environment,
+) object.Object {
+	var result object.Object = VOID
+
+	for i, name := range names {
+		if i >= len(values) || values[i] == nil {
+			// 値の無い名前は評価だけ行う
+			result = Eval(name, env)
+			if isError(result) {
+				return result
+			}
+			continue
+		}
+
+		val := Eval(values[i], env)
+		if isError(val) {
+			return val
+		}
+
+		if assigns[i].Type != token.Assign {
+			old := Eval(name, env)
+			if isError(old) {
+				return old
+			}
+			val = binaryOp(assignOperator(assigns[i]), old, val, assigns[i])
+			if isError(val) {
+				return val
+			}
+		}
+
+		result = assign(name, val, assigns[i], env)
+		if isError(result) {
+			return result
+		}
+	}
+
+	return result
+}
+
+func assignOperator(tok token.Token) string {
+	// "+=" -> "+"
+	return tok.Literal[:len(tok.Literal)-1]
+}
+
+// assign は target に val を代入して代入後の値を返す
+func assign(target ast.Expression, val object.Object, tok token.Token, env *object.Environment) object.Object {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		if target.Token.Type != token.Ident {
+			return newError(target.Token, "can not assign to %s", target.Value)
+		}
+		v, ok := env.Get(target.Value)
+		if !ok {
+			// 宣言されていない変数は値の型で暗黙に宣言される
+			v = env.Declare(target.Value, val.Type(), nil)
+		}
+		return assignValue(v, val, tok)
+	case *ast.IndexExpression:
+		return assignIndex(target, val, tok, env)
+	}
+	return newError(tokenOf(target), "can not assign to %s", target.String())
+}
+
+func assignValue(v *object.Variable, val object.Object, tok token.Token) object.Object {
+	assigned, err := v.Assign(val)
+	if err != nil {
+		return newError(tok, "%s", err)
+	}
+	return assigned
+}
+
+func assignIndex(target *ast.IndexExpression, val object.Object, tok token.Token, env *object.Environment) object.Object {
+	if target.Index == nil {
+		return newError(target.Token, "missing index")
+	}
+
+	// $m[0][1] = 1;
+	if inner, ok := target.Left.(*ast.IndexExpression); ok {
+		ident, ok := inner.Left.(*ast.Identifier)
+		if !ok {
+			return newError(target.Token, "can not assign to %s", target.String())
+		}
+		v, ok := env.Get(ident.Value)
+		if !ok {
+			return newError(ident.Token, "undefined variable %s", ident.Value)
+		}
+		m, ok := v.Value.(*object.Matrix)
+		if !ok {
+			return newError(target.Token, "%s is not a matrix", ident.Value)
+		}
+		row, errObj := evalIndex(inner.Index, env)
+		if errObj != nil {
+			return errObj
+		}
+		col, errObj := evalIndex(target.Index, env)
+		if errObj != nil {
+			return errObj
+		}
+		f, err := m.Set(row, col, val)
+		if err != nil {
+			return newError(target.Token, "%s", err)
+		}
+		return f
+	}
+
+	ident, ok := target.Left.(*ast.Identifier)
+	if !ok || ident.Token.Type != token.Ident {
+		return newError(target.Token, "can not assign to %s", target.String())
+	}
+
+	idx, errObj := evalIndex(target.Index, env)
+	if errObj != nil {
+		return errObj
+	}
+
+	v, ok := env.Get(ident.Value)
+	if !ok {
+		// $a[0] = 1; は int[] として暗黙に宣言される
+		if val.Type().IsArray() {
+			return newError(tok, "can not assign %s to an element", val.Type())
+		}
+		v = env.Declare(ident.Value, val.Type().ArrayOf(), object.Zero(val.Type().ArrayOf()))
+	}
+
+	arr, ok := v.Value.(*object.Array)
+	if !ok {
+		return newError(target.Token, "%s is not an array", ident.Value)
+	}
+
+	// 範囲外への代入は配列を伸ばす
+	elem, err := arr.Set(idx, val)
+	if err != nil {
+		return newError(tok, "%s", err)
+	}
+	return elem
+}
+
+func evalIndex(index ast.Expression, env *object.Environment) (int, *object.Error) {
+	idx := Eval(index, env)
+	if isError(idx) {
+		return 0, idx.(*object.Error)
+	}
+	i, err := object.Convert(idx, object.IntType)
+	if err != nil {
+		return 0, newError(tokenOf(index), "index must be an int. got=%s", idx.Type())
+	}
+	if i.(*object.Integer).Value < 0 {
+		return 0, newError(tokenOf(index), "negative index %d", i.(*object.Integer).Value)
+	}
+	return int(i.(*object.Integer).Value), nil
+}
+
+func evalIdentifier(ident *ast.Identifier, env *object.Environment) object.Object {
+	if ident.Token.Type != token.Ident {
+		// コマンド形式の引数 (select pCube1 -r;) は文字列として扱う
+		return &object.String{Value: ident.Value}
+	}
+
+	v, ok := env.Get(ident.Value)
+	if !ok || v.Value == nil {
+		return newError(ident.Token, "undefined variable %s", ident.Value)
+	}
+	return v.Value
+}
+
+func evalTensorLiteral(tl *ast.TensorLiteral, env *object.Environment) object.Object {
+	var rows [][]float64
+	for _, row := range tl.Values {
+		var values []float64
+		for _, exp := range row {
+			val := Eval(exp, env)
+			if isError(val) {
+				return val
+			}
+			f := convert(val, object.FloatType, tokenOf(exp))
+			if isError(f) {
+				return f
+			}
+			values = append(values, f.(*object.Float).Value)
+		}
+		rows = append(rows, values)
+	}
+
+	if len(rows) == 1 && len(rows[0]) == 3 {
+		return &object.Vector{X: rows[0][0], Y: rows[0][1], Z: rows[0][2]}
+	}
+
+	m := &object.Matrix{Rows: len(rows)}
+	for _, row := range rows {
+		if m.Cols == 0 {
+			m.Cols = len(row)
+		}
+		if len(row) != m.Cols {
+			return newError(tl.Token, "matrix rows must have the same size")
+		}
+		m.Values = append(m.Values, row...)
+	}
+	return m
+}
+
+func evalArrayLiteral(al *ast.ArrayLiteral, env *object.Environment) object.Object {
+	var elements []object.Object
+	for _, exp := range al.Elements {
+		val := Eval(exp, env)
+		if isError(val) {
+			return val
+		}
+		elements = append(elements, val)
+	}
+
+	arr, err := object.NewArray(elements)
+	if err != nil {
+		return newError(al.Token, "%s", err)
+	}
+	return arr
+}
+
+func evalPrefixExpression(pe *ast.PrefixExpression, env *object.Environment) object.Object {
+	switch pe.Operator {
+	case "++", "--":
+		return evalCrement(pe.Right, pe.Operator, pe.Token, true, env)
+	}
+
+	right := Eval(pe.Right, env)
+	if isError(right) {
+		return right
+	}
+
+	switch pe.Operator {
+	case "!":
+		return object.Bool(!object.Truthy(right))
+	case "-":
+		val, err := object.Negate(right)
+		if err != nil {
+			return newError(pe.Token, "%s", err)
+		}
+		return val
+	}
+	return newError(pe.Token, "unknown operator: %s%s", pe.Operator, right.Type())
+}
+
+// evalCrement は ++$i, $i++, --$i, $i-- を評価する
+func evalCrement(target ast.Expression, operator string, tok token.Token, prefix bool, env *object.Environment) object.Object {
+	old := Eval(target, env)
+	if isError(old) {
+		return old
+	}
+
+	op := "+"
+	if operator == "--" {
+		op = "-"
+	}
+	val := binaryOp(op, old, &object.Integer{Value: 1}, tok)
+	if isError(val) {
+		return val
+	}
+
+	val = assign(target, val, tok, env)
+	if isError(val) || prefix {
+		return val
+	}
+	return old
+}
+
+func evalInfixExpression(ie *ast.InfixExpression, env *object.Environment) object.Object {
+	switch ie.Operator {
+	case "&&", "||":
+		left := Eval(ie.Left, env)
+		if isError(left) {
+			return left
+		}
+		if ie.Operator == "&&" && !object.Truthy(left) {
+			return object.Bool(false)
+		}
+		if ie.Operator == "||" && object.Truthy(left) {
+			return object.Bool(true)
+		}
+		right := Eval(ie.Right, env)
+		if isError(right) {
+			return right
+		}
+		return object.Bool(object.Truthy(right))
+	case ".":
+		return evalComponent(ie, env)
+	}
+
+	left := Eval(ie.Left, env)
+	if isError(left) {
+		return left
+	}
+	right := Eval(ie.Right, env)
+	if isError(right) {
+		return right
+	}
+	return binaryOp(ie.Operator, left, right, ie.Token)
+}
+
+// evalComponent は $v.x のような vector の要素アクセスを評価する
+func evalComponent(ie *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(ie.Left, env)
+	if isError(left) {
+		return left
+	}
+	v, ok := left.(*object.Vector)
+	if !ok {
+		return newError(ie.Token, "%s has no components", left.Type())
+	}
+
+	ident, ok := ie.Right.(*ast.Identifier)
+	if !ok {
+		return newError(ie.Token, "invalid vector component %s", ie.Right.String())
+	}
+	f, err := v.Component(ident.Value)
+	if err != nil {
+		return newError(ident.Token, "%s", err)
+	}
+	return f
+}
+
+func evalIndexExpression(ie *ast.IndexExpression, env *object.Environment) object.Object {
+	if ie.Index == nil {
+		return newError(ie.Token, "missing index")
+	}
+
+	// $m[0][1]
+	if inner, ok := ie.Left.(*ast.IndexExpression); ok && inner.Index != nil {
+		base := Eval(inner.Left, env)
+		if isError(base) {
+			return base
+		}
+		if m, ok := base.(*object.Matrix); ok {
+			row, errObj := evalIndex(inner.Index, env)
+			if errObj != nil {
+				return errObj
+			}
+			col, errObj := evalIndex(ie.Index, env)
+			if errObj != nil {
+				return errObj
+			}
+			f, err := m.At(row, col)
+			if err != nil {
+				return newError(ie.Token, "%s", err)
+			}
+			return &object.Float{Value: f}
+		}
+	}
+
+	left := Eval(ie.Left, env)
+	if isError(left) {
+		return left
+	}
+	arr, ok := left.(*object.Array)
+	if !ok {
+		return newError(ie.Token, "%s is not an array", left.Type())
+	}
+
+	idx, errObj := evalIndex(ie.Index, env)
+	if errObj != nil {
+		return errObj
+	}
+	// 範囲外の読み出しはゼロ値になる
+	elem, err := arr.Get(idx)
+	if err != nil {
+		return newError(ie.Token, "%s", err)
+	}
+	return elem
+}
+
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	cond := Eval(ie.Condition, env)
+	if isError(cond) {
+		return cond
+	}
+
+	if object.Truthy(cond) {
+		return Eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return Eval(ie.Alternative, env)
+	}
+	return VOID
+}
+
+func evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
+	for {
+		cond := Eval(we.Condition, env)
+		if isError(cond) {
+			return cond
+		}
+		if !object.Truthy(cond) {
+			return VOID
+		}
+
+		result := Eval(we.Consequence, env)
+		if stop, ret := loopControl(result); stop {
+			return ret
+		}
+	}
+}
+
+func evalDoWhileExpression(dwe *ast.DoWhileExpression, env *object.Environment) object.Object {
+	for {
+		result := Eval(dwe.Consequence, env)
+		if stop, ret := loopControl(result); stop {
+			return ret
+		}
+
+		cond := Eval(dwe.Condition, env)
+		if isError(cond) {
+			return cond
+		}
+		if !object.Truthy(cond) {
+			return VOID
+		}
+	}
+}
+
+func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	init := evalAssignments(fe.InitNames, fe.InitAssigns, fe.InitValues, env)
+	if isError(init) {
+		return init
+	}
+
+	for {
+		if fe.Condition != nil {
+			cond := Eval(fe.Condition, env)
+			if isError(cond) {
+				return cond
+			}
+			if !object.Truthy(cond) {
+				return VOID
+			}
+		}
+
+		result := Eval(fe.Consequence, env)
+		if stop, ret := loopControl(result); stop {
+			return ret
+		}
+
+		for _, changeOf := range fe.ChangeOfs {
+			result := Eval(changeOf, env)
+			if isError(result) {
+				return result
+			}
+		}
+	}
+}
+
+func evalForInExpression(fie *ast.ForInExpression, env *object.Environment) object.Object {
+	val := Eval(fie.ArrayElement, env)
+	if isError(val) {
+		return val
+	}
+	arr, ok := val.(*object.Array)
+	if !ok {
+		return newError(fie.Token, "for-in needs an array. got=%s", val.Type())
+	}
+
+	// ループ中に配列が変更されても影響を受けないようにコピーする
+	elements := append([]object.Object(nil), arr.Elements...)
+	for _, elem := range elements {
+		result := assign(fie.Element, elem, fie.Token, env)
+		if isError(result) {
+			return result
+		}
+
+		result = Eval(fie.Consequence, env)
+		if stop, ret := loopControl(result); stop {
+			return ret
+		}
+	}
+	return VOID
+}
+
+// loopControl はループ本体の結果からループを抜けるかどうかを判定する
+func loopControl(result object.Object) (bool, object.Object) {
+	switch result.(type) {
+	case *object.Break:
+		return true, VOID
+	case *object.ReturnValue, *object.Error:
+		return true, result
+	}
+	return false, nil
+}
+
+func evalSwitchExpression(se *ast.SwitchExpression, env *object.Environment) object.Object {
+	cond := Eval(se.Condition, env)
+	if isError(cond) {
+		return cond
+	}
+
+	start := -1
+	for i, cas := range se.Cases {
+		if cas == nil {
+			continue
+		}
+		val := Eval(cas, env)
+		if isError(val) {
+			return val
+		}
+		if object.Equal(cond, val) {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		for i, cas := range se.Cases {
+			if cas == nil {
+				start = i
+				break
+			}
+		}
+	}
+	if start == -1 {
+		return VOID
+	}
+
+	// break されるまで次の case に流れる
+	scope := object.NewEnclosedEnvironment(env)
+	for _, cs := range se.CaseStatements[start:] {
+		for _, stmt := range cs.Statements {
+			result := Eval(stmt, scope)
+			switch result.(type) {
+			case *object.Break:
+				return VOID
+			case *object.ReturnValue, *object.Error, *object.Continue:
+				return result
+			}
+		}
+	}
+	return VOID
+}
+
+func evalCallExpression(ce *ast.CallExpression, env *object.Environment) object.Object {
+	if ce.Function == nil {
+		return newError(ce.Token, "missing proc name")
+	}
+
+	var args []object.Object
+	for _, a := range ast.FlattenArguments(ce.Arguments) {
+		val := Eval(a, env)
+		if isError(val) {
+			return val
+		}
+		args = append(args, val)
+	}
+
+	return callByName(ce.Function.Value, args, ce.Function.Token, env)
+}
+
+func callByName(name string, args []object.Object, tok token.Token, env *object.Environment) object.Object {
+	if object.IsTypeName(name) {
+		if len(args) != 1 {
+			return newError(tok, "%s", object.WrongArgumentCount(name))
+		}
+		return convert(args[0], object.Type(name), tok)
+	}
+
+	if proc, ok := env.GetProc(name); ok {
+		return applyProc(proc, args, tok, env)
+	}
+	if builtin, ok := builtins[name]; ok {
+		return applyProc(builtin, args, tok, env)
+	}
+	return newError(tok, "Cannot find procedure \"%s\".", name)
+}
+
+func applyProc(proc object.Object, args []object.Object, tok token.Token, env *object.Environment) object.Object {
+	switch proc := proc.(type) {
+	case *object.Proc:
+		return callProc(proc.Statement, args, tok, env)
+	case *object.Builtin:
+		result := proc.Fn(env, args...)
+		if err, ok := result.(*object.Error); ok && err.Token.Type == "" {
+			err.Token = tok
+		}
+		return result
+	}
+	return newError(tok, "%s is not a proc", proc.Inspect())
+}
+
+func callProc(ps *ast.ProcStatement, args []object.Object, tok token.Token, env *object.Environment) object.Object {
+	if len(args) != len(ps.Parameters) {
+		return newError(tok, "%s", object.WrongArgumentCount(ps.Name.Literal))
+	}
+
+	procEnv := object.NewProcEnvironment(env)
+	for i, param := range ps.Parameters {
+		ident, isArrayParam := paramName(param)
+		if ident == nil {
+			return newError(ps.Token, "invalid parameter %s", param.String())
+		}
+
+		typ := object.Type(ps.ParamTypes[i].Token.Literal)
+		if isArrayParam || ps.ParamTypes[i].IsArray {
+			typ = typ.ArrayOf()
+		}
+
+		// 配列は参照渡し
+		arg := args[i]
+		if arg.Type() != typ {
+			arg = convert(arg, typ, tok)
+			if isError(arg) {
+				return arg
+			}
+		}
+		procEnv.Declare(ident.Value, typ, arg)
+	}
+
+	result := evalBlockStatement(ps.Body, procEnv)
+	if isError(result) {
+		return result
+	}
+
+	rv, ok := result.(*object.ReturnValue)
+	if ps.ReturnType == nil {
+		if ok && rv.Value != nil {
+			return newError(ps.Name, "proc %s has no return type but returned %s",
+				ps.Name.Literal, rv.Value.Type())
+		}
+		return VOID
+	}
+
+	typ := object.Type(ps.ReturnType.Token.Literal)
+	if ps.ReturnType.IsArray {
+		typ = typ.ArrayOf()
+	}
+	if !ok || rv.Value == nil {
+		return object.Zero(typ)
+	}
+	return convert(rv.Value, typ, tok)
+}
+
+func paramName(param ast.Expression) (*ast.Identifier, bool) {
+	switch param := param.(type) {
+	case *ast.Identifier:
+		return param, false
+	case *ast.IndexExpression:
+		ident, ok := param.Left.(*ast.Identifier)
+		if !ok || param.Index != nil {
+			return nil, false
+		}
+		return ident, true
+	}
+	return nil, false
+}
+
+func newError(tok token.Token, format string, a ...interface{}) *object.Error {
+	return &object.Error{Token: tok, Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ErrorType
+	}
+	return false
+}
+
+// tokenOf はエラー位置として使う node の先頭の token を返す
+func tokenOf(node ast.Node) token.Token {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return node.Token
+	case *ast.InfixExpression:
+		return node.Token
+	case *ast.PrefixExpression:
+		return node.Token
+	case *ast.PostfixExpression:
+		return node.Token
+	case *ast.TernaryExpression:
+		return node.Token1
+	case *ast.CastExpression:
+		return node.Token
+	case *ast.TypeDeclaration:
+		return node.Token
+	case *ast.CallExpression:
+		return node.Token
+	case *ast.ForExpression:
+		return node.Token
+	case *ast.ForInExpression:
+		return node.Token
+	case *ast.DoWhileExpression:
+		return node.Token
+	case *ast.WhileExpression:
+		return node.Token
+	case *ast.IfExpression:
+		return node.Token
+	case *ast.BlockStatement:
+		return node.Token
+	case *ast.SwitchExpression:
+		return node.Token
+	case *ast.GlobalStatement:
+		return node.Token
+	case *ast.ProcStatement:
+		return node.Token
+	case *ast.CaseStatement:
+		return node.Token
+	case *ast.VariableStatement:
+		if len(node.Names) != 0 {
+			return tokenOf(node.Names[0])
+		}
+	case *ast.VectorStatement:
+		return node.Token
+	case *ast.MatrixStatement:
+		return node.Token
+	case *ast.IntegerStatement:
+		return node.Token
+	case *ast.FloatStatement:
+		return node.Token
+	case *ast.StringStatement:
+		return node.Token
+	case *ast.ArrayLiteral:
+		return node.Token
+	case *ast.IndexExpression:
+		return node.Token
+	case *ast.Identifier:
+		return node.Token
+	case *ast.BreakStatement:
+		return node.Token
+	case *ast.ContinueStatement:
+		return node.Token
+	case *ast.ReturnStatement:
+		return node.Token
+	case *ast.IntegerLiteral:
+		return node.Token
+	case *ast.FloatLiteral:
+		return node.Token
+	case *ast.StringLiteral:
+		return node.Token
+	case *ast.BooleanLiteral:
+		return node.Token
+	case *ast.TensorLiteral:
+		return node.Token
+	}
+	return token.Token{}
+}
+
+func convert(val object.Object, typ object.Type, tok token.Token) object.Object {
+	converted, err := object.Convert(val, typ)
+	if err != nil {
+		return newError(tok, "%s", err)
+	}
+	return converted
+}
+
+func binaryOp(op string, left, right object.Object, tok token.Token) object.Object {
+	result, err := object.BinaryOp(op, left, right)
+	if err != nil {
+		return newError(tok, "%s", err)
+	}
+	return result
+}
@@ -0,0 +1,362 @@
+package eval
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/object"
+	"github.com/nrtkbb/go-MEL/parser"
+)
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5;", 5},
+		{"-5;", -5},
+		{"5 + 5 * 2;", 15},
+		{"(5 + 5) * 2;", 20},
+		{"7 / 2;", 3},
+		{"7 % 4;", 3},
+		{"0xff;", 255},
+		{"1 < 2;", 1},
+		{"1 > 2;", 0},
+		{"1 == 1 && 2 != 2;", 0},
+		{"1 == 1 || 2 != 2;", 1},
+		{"!0;", 1},
+		{"true;", 1},
+		{"off;", 0},
+		{"1 > 0 ? 10 : 20;", 10},
+		{"(int) 2.9;", 2},
+		{"int $i = int(\"12abc\"); $i;", 12},
+		{"int $i = 1; $i++; $i;", 2},
+		{"int $i = 1; $i += 4; $i;", 5},
+		{"int $i = 1; int $j = $i++; $j;", 1},
+		{"int $i = 1; int $j = ++$i; $j;", 2},
+		{"int $i = 2147483647; $i++; $i;", -2147483648},
+		{"\"1\" == 1;", 1},
+		{"\"2.5\" > 2;", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5;", 1.5},
+		{"1 + 0.5;", 1.5},
+		{"1.0 / 4;", 0.25},
+		{"5.5 % 2;", 1.5},
+		{"float $f = 1; $f;", 1},
+		{"vector $v = <<1, 2, 3>>; $v.y;", 2},
+		{"<<1, 2, 3>> * <<4, 5, 6>>;", 32},
+		{"float $f = <<3, 4, 0>>; $f;", 5},
+		{"matrix $m[2][2] = <<1, 2; 3, 4>>; $m[1][0];", 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalStringExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"abc";`, "abc"},
+		{`"a\tb\n";`, "a\tb\n"},
+		{`"a" + "b";`, "ab"},
+		{`"a" + 1;`, "a1"},
+		{`1.5 + "a";`, "1.5a"},
+		{`string $s = 1.0 / 3; $s;`, "0.333333"},
+		{`string $s = <<1, 2.5, 3>>; $s;`, "1 2.5 3"},
+		{`toupper("abc");`, "ABC"},
+		{`substring("abcdef", 2, 4);`, "bcd"},
+		{`string $a[] = {"x", "y"}; stringArrayToString($a, "-");`, "x-y"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testStringObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalVectorExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Vector
+	}{
+		{"<<1, 2, 3>>;", object.Vector{X: 1, Y: 2, Z: 3}},
+		{"<<1, 2, 3>> + <<1, 1, 1>>;", object.Vector{X: 2, Y: 3, Z: 4}},
+		{"<<1, 0, 0>> ^ <<0, 1, 0>>;", object.Vector{X: 0, Y: 0, Z: 1}},
+		{"<<1, 2, 3>> * 2;", object.Vector{X: 2, Y: 4, Z: 6}},
+		{"-<<1, 2, 3>>;", object.Vector{X: -1, Y: -2, Z: -3}},
+		{"vector $v = 1; $v;", object.Vector{X: 1, Y: 1, Z: 1}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		v, ok := evaluated.(*object.Vector)
+		if !ok {
+			t.Errorf("object is not Vector. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if *v != tt.expected {
+			t.Errorf("object has wrong value. got=%+v, want=%+v", *v, tt.expected)
+		}
+	}
+}
+
+func TestEvalArray(t *testing.T) {
+	tests := []struct {
+		input    string
+		typ      object.Type
+		expected string
+	}{
+		{`$a = {1, 2, 3}; $a;`, object.IntArrayType, "1 2 3"},
+		{`$a = {1, 2.5}; $a;`, object.FloatArrayType, "1 2.5"},
+		{`float $f[] = {1, 2}; $f;`, object.FloatArrayType, "1 2"},
+		{`int $a[]; $a[3] = 7; $a;`, object.IntArrayType, "0 0 0 7"},
+		{`int $a[3]; $a;`, object.IntArrayType, "0 0 0"},
+		{`$a[1] = "x"; $a;`, object.StringArrayType, " x"},
+		{`string $a[] = {"a"}; string $b[] = $a; $b[0] = "b"; $a;`, object.StringArrayType, "a"},
+		{`string $b[]; tokenize "a|b|c" "|" $b; $b;`, object.StringArrayType, "a b c"},
+		{`sort({3, 1, 2});`, object.IntArrayType, "1 2 3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		arr, ok := evaluated.(*object.Array)
+		if !ok {
+			t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if arr.Type() != tt.typ {
+			t.Errorf("array type wrong. got=%s, want=%s", arr.Type(), tt.typ)
+		}
+		if arr.Inspect() != tt.expected {
+			t.Errorf("array has wrong value. got=%q, want=%q", arr.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestEvalIndexExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"int $a[] = {1, 2, 3}; $a[0];", 1},
+		{"int $a[] = {1, 2, 3}; $a[1 + 1];", 3},
+		{"int $a[] = {1, 2, 3}; $a[10];", 0},
+		{"int $a[] = {1, 2, 3}; size($a);", 3},
+		{"int $a[] = {1, 2, 3}; clear($a); size($a);", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalControlFlow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"int $x = 0; if (1) $x = 1; else $x = 2; $x;", 1},
+		{"int $x = 0; if (0) $x = 1; else if (1) $x = 2; else $x = 3; $x;", 2},
+		{"int $i = 0; while ($i < 10) $i++; $i;", 10},
+		{"int $i = 0; while (true) { $i++; if ($i == 3) break; } $i;", 3},
+		{"int $i = 10; do { $i++; } while ($i < 5); $i;", 11},
+		{"int $s = 0; for ($i = 0; $i < 5; $i++) $s += $i; $s;", 10},
+		{"int $s = 0; for ($i = 0; $i < 5; $i++) { if ($i % 2) continue; $s += $i; } $s;", 6},
+		{"int $s = 0; int $a[] = {1, 2, 3}; for ($e in $a) $s += $e; $s;", 6},
+		{"int $s = 0; int $a[] = {1, 2, 3}; for ($e in $a) { if ($e == 2) break; $s += $e; } $s;", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalSwitchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`string $r; switch (1) { case 1: $r += "a"; break; case 2: $r += "b"; } $r;`, "a"},
+		{`string $r; switch (1) { case 1: $r += "a"; case 2: $r += "b"; break; default: $r += "c"; } $r;`, "ab"},
+		{`string $r; switch (3) { case 1: $r += "a"; break; default: $r += "c"; } $r;`, "c"},
+		{`string $r; switch ("x") { case "x": $r = "X"; break; } $r;`, "X"},
+		{`string $r = "none"; switch (5) { case 1: $r = "a"; } $r;`, "none"},
+		{`string $r; switch ("2") { case 1: $r = "a"; break; case 2: $r = "b"; } $r;`, "b"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testStringObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalProc(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`proc int add(int $a, int $b) { return $a + $b; } add(1, 2);`, 3},
+		{`int $r = add(1, 2); proc int add(int $a, int $b) { return $a + $b; } $r;`, 3},
+		{`global proc int fib(int $n) {
+			if ($n < 2) return $n;
+			return fib($n - 1) + fib($n - 2);
+		}
+		fib(10);`, 55},
+		{`proc int half(float $f) { return $f / 2; } half(5);`, 2},
+		{`proc fill(int $a[]) { $a[2] = 1; } int $x[]; fill($x); size($x);`, 3},
+		{`proc int noReturn() { } noReturn();`, 0},
+		{`proc int count(string $s) { string $b[]; return tokenize($s, $b); } count "a b c";`, 3},
+		{`global int $g = 1; proc int getG() { global int $g; return $g; } $g = 5; getG();`, 5},
+		{`int $x = 1; proc int shadow() { int $x = 2; return $x; } shadow() + $x;`, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	input := `
+print "a";
+print ("b" + 1 + "\n");
+string $s[] = {"x", "y"};
+print $s;
+warning "w";
+`
+	var out bytes.Buffer
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	result := Eval(program, object.NewEnvironment(&out))
+	if err, ok := result.(*object.Error); ok {
+		t.Fatalf("unexpected error: %s", err.Inspect())
+	}
+
+	expected := "ab1\nx\ny\n// Warning: w\n"
+	if out.String() != expected {
+		t.Errorf("output wrong. got=%q, want=%q", out.String(), expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+		row, column     int
+	}{
+		{"1 / 0;", "divide by zero", 1, 3},
+		{"\n  $x + 1;", "undefined variable $x", 2, 3},
+		{"foo(1);", "Cannot find procedure \"foo\".", 1, 1},
+		{"proc int f(int $a) { return $a; }\nf(1, 2);", "Wrong number of arguments on call to f.", 2, 1},
+		{"int $a = 1; $a[0];", "int is not an array", 1, 15},
+		{"\"a\" - 1;", "unknown operator: string - int", 1, 5},
+		{"int $a; string $a;", "variable $a redeclared with type string (was int)", 1, 16},
+		{"matrix $m[1][1]; $m[1][0] = 1;", "matrix index [1][0] out of range 1x1", 1, 23},
+		{"error \"boom\";", "boom", 1, 1},
+		{"int $i = 0; while (1) { $i++; if ($i > 2) $i + \"a\" - 1; }", "unknown operator: string - int", 1, 52},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+
+		if errObj.Token.Row != tt.row || errObj.Token.Column != tt.column {
+			t.Errorf("wrong error position for %q. expected=%d.%d, got=%d.%d",
+				tt.input, tt.row, tt.column, errObj.Token.Row, errObj.Token.Column)
+		}
+	}
+}
+
+func testEval(t *testing.T, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	var out bytes.Buffer
+	return Eval(program, object.NewEnvironment(&out))
+}
+
+func checkParserErrors(t *testing.T, p *parser.Parser) {
+	errors := p.Errors()
+	if len(errors) == 0 {
+		return
+	}
+
+	t.Errorf("parser has %d errors", len(errors))
+	for _, msg := range errors {
+		t.Errorf("parser error: %q", msg)
+	}
+	t.FailNow()
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%f, want=%f", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+		return false
+	}
+	return true
+}
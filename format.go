@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/nrtkbb/go-MEL/format"
+)
+
+// runFmt は fmt サブコマンドを実行して終了コードを返す
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write result to the source file instead of stdout")
+	list := fs.Bool("l", false, "list files whose formatting differs")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-MEL fmt [-l] [-w] [path ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "fmt: can not use -w with standard input")
+			return 2
+		}
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := formatSource("<standard input>", src, *write, *list); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	code := 0
+	formatPath := func(path string) error {
+		src, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = formatSource(path, src, *write, *list)
+		}
+		if err != nil {
+			// 一つのファイルが失敗しても残りのファイルは続けて整形する
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+		return nil
+	}
+	for _, path := range fs.Args() {
+		stat, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+			continue
+		}
+		if stat.IsDir() {
+			if err := readDir(path, formatPath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = 1
+			}
+			continue
+		}
+		formatPath(path)
+	}
+	return code
+}
+
+func formatSource(name string, src []byte, write, list bool) error {
+	out, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+
+	changed := !bytes.Equal(src, out)
+	if list && changed {
+		fmt.Println(name)
+	}
+	if write {
+		if !changed {
+			return nil
+		}
+		// gofmt と同じくファイルの mode はそのまま残す
+		fi, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(name, out, fi.Mode().Perm())
+	}
+	if !list {
+		os.Stdout.Write(out)
+	}
+	return nil
+}
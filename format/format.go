@@ -0,0 +1,154 @@
+// Package format implements canonical formatting of MEL source.
+package format
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Source formats src in the canonical MEL style.
+//
+// Statements are printed one per line, blocks are indented with tabs and
+// opening braces stay on the line of the statement. Every comment is kept.
+// It returns an error if src has syntax errors.
+func Source(src []byte) ([]byte, error) {
+	input := string(src)
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, p.Errors()
+	}
+
+	items, err := scan(input)
+	if err != nil {
+		return nil, err
+	}
+
+	pr := &printer{items: items}
+	out := pr.print()
+
+	// 整形しても同じ AST になることを確かめる
+	fp := parser.New(lexer.New(out))
+	formatted := fp.ParseProgram()
+	if len(fp.Errors()) != 0 || formatted.String() != program.String() || !sameTokens(input, out) {
+		return nil, errors.New("format: formatted source does not parse to the same program")
+	}
+
+	return []byte(out), nil
+}
+
+// sameTokens は a と b が空白の他は同じ token とコメントかどうかを返す.
+// Program.String() は括弧やコメントを落とすので token でも比べる
+func sameTokens(a, b string) bool {
+	la, lb := lexer.NewWithMode(a, lexer.ScanComments), lexer.NewWithMode(b, lexer.ScanComments)
+	for {
+		ta, tb := la.NextToken(), lb.NextToken()
+		if ta.Type != tb.Type {
+			return false
+		}
+		if ta.Type == token.Comment {
+			// "//" コメントの行末の空白は消す
+			if strings.TrimRight(ta.Literal, " \t\r") != strings.TrimRight(tb.Literal, " \t\r") {
+				return false
+			}
+		} else if ta.Literal != tb.Literal {
+			return false
+		}
+		if ta.Type == token.EOF {
+			return true
+		}
+	}
+}
+
+// comment は token の前にあるコメント
+type comment struct {
+	text     string
+	newlines int  // コメントの前の改行の数
+	line     bool // "//" コメント
+}
+
+// item は token と, その前にある空白とコメント
+type item struct {
+	tok      token.Token
+	comments []comment
+	newlines int  // コメントの後, token の前の改行の数
+	space    bool // token の直前に空白があったか
+}
+
+// scan は input を token に分けて, token の間にある空白とコメントを集める
+func scan(input string) ([]item, error) {
+	var items []item
+	end := 0
+	l := lexer.New(input)
+	for {
+		tok := l.NextToken()
+
+		start := tok.Offset
+		if start < end || start+len(tok.Literal) > len(input) || input[start:start+len(tok.Literal)] != tok.Literal {
+			return nil, fmt.Errorf("format: line:%d.%d token %q does not match the source", tok.Row, tok.Column, tok.Literal)
+		}
+
+		it, err := scanGap([]rune(input[end:start]))
+		if err != nil {
+			return nil, fmt.Errorf("format: line:%d.%d %s", tok.Row, tok.Column, err)
+		}
+		it.tok = tok
+		items = append(items, it)
+
+		if tok.Type == token.EOF {
+			return items, nil
+		}
+		end = start + len(tok.Literal)
+	}
+}
+
+// scanGap は token の間の文字列から改行とコメントを読み取る
+func scanGap(gap []rune) (item, error) {
+	var it item
+	for i := 0; i < len(gap); i++ {
+		switch r := gap[i]; {
+		case r == '\n' || r == '\r' && (i+1 == len(gap) || gap[i+1] != '\n'):
+			it.newlines++
+			it.space = true
+		case r == ' ' || r == '\t' || r == '\r':
+			it.space = true
+		case r == '/' && i+1 < len(gap) && gap[i+1] == '/':
+			j := i
+			for j < len(gap) && gap[j] != '\n' && gap[j] != '\r' {
+				j++
+			}
+			it.comments = append(it.comments, comment{
+				text:     strings.TrimRight(string(gap[i:j]), " \t"),
+				newlines: it.newlines,
+				line:     true,
+			})
+			it.newlines = 0
+			it.space = false
+			i = j - 1
+		case r == '/' && i+1 < len(gap) && gap[i+1] == '*':
+			j := i + 2
+			for j < len(gap) && !(gap[j-1] == '*' && gap[j] == '/' && j-1 > i+1) {
+				j++
+			}
+			if j == len(gap) {
+				return it, errors.New("comment not terminated")
+			}
+			it.comments = append(it.comments, comment{
+				text:     string(gap[i : j+1]),
+				newlines: it.newlines,
+			})
+			it.newlines = 0
+			it.space = false
+			i = j
+		default:
+			return it, fmt.Errorf("unexpected %q", r)
+		}
+	}
+	return it, nil
+}
@@ -0,0 +1,228 @@
+package format
+
+import (
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`int $a=1;int $b=$a+2*3;`,
+			"int $a = 1;\nint $b = $a + 2 * 3;\n",
+		},
+		{
+			"proc int add(int $a,int $b){return $a+$b;}",
+			"proc int add(int $a, int $b) {\n\treturn $a + $b;\n}\n",
+		},
+		{
+			"global proc string[] names( ) {\nstring $r[ ];\nreturn $r ;\n}",
+			"global proc string[] names() {\n\tstring $r[];\n\treturn $r;\n}\n",
+		},
+		{
+			"if ($a) { print \"a\"; }\nelse if ($b) {\nprint \"b\";\n}\nelse\n{ print \"c\"; }",
+			"if ($a) {\n\tprint \"a\";\n} else if ($b) {\n\tprint \"b\";\n} else {\n\tprint \"c\";\n}\n",
+		},
+		{
+			"for($i=0;$i<10;$i++){$s+=$i;}",
+			"for ($i = 0; $i < 10; $i++) {\n\t$s += $i;\n}\n",
+		},
+		{
+			"for ($e in $a) print $e;",
+			"for ($e in $a) print $e;\n",
+		},
+		{
+			"do { $i--; } while ($i > 0);",
+			"do {\n\t$i--;\n} while ($i > 0);\n",
+		},
+		{
+			"switch ($x) { case 1: print \"a\"; break; default: print \"b\"; }",
+			"switch ($x) {\n\tcase 1:\n\t\tprint \"a\";\n\t\tbreak;\n\tdefault:\n\t\tprint \"b\";\n}\n",
+		},
+		{
+			`int $a[]={1,2,3};vector $v=<<1,2,3>>;matrix $m[2][2]=<<1,2;3,4>>;`,
+			"int $a[] = {1, 2, 3};\nvector $v = <<1, 2, 3>>;\nmatrix $m[2][2] = <<1, 2; 3, 4>>;\n",
+		},
+		{
+			`float $f=-$v.x;int $i=!$b;$i=-1;$i=(int)$f;$s=$c?"a":"b";`,
+			"float $f = -$v.x;\nint $i = !$b;\n$i = -1;\n$i = (int) $f;\n$s = $c ? \"a\" : \"b\";\n",
+		},
+		{
+			// コマンドの引数は元の書き方を残す
+			`setAttr ".t" 1 -2 3; move -r 1 2 3; setAttr("a", 1); print ("a");`,
+			"setAttr \".t\" 1 -2 3;\nmove -r 1 2 3;\nsetAttr(\"a\", 1);\nprint (\"a\");\n",
+		},
+		{
+			"string $s[] = `ls -sl`;",
+			"string $s[] = `ls -sl`;\n",
+		},
+		{
+			"window -title \"x\"\n  -widthHeight 100 200\n      myWindow;",
+			"window -title \"x\"\n\t-widthHeight 100 200\n\tmyWindow;\n",
+		},
+		{
+			"string $a[] = {\n\"x\",\n\"y\"\n};",
+			"string $a[] = {\n\t\"x\",\n\t\"y\"\n};\n",
+		},
+		{
+			"int $a;\n\n\n\nint $b;\n{\n\nint $c;\n\n}",
+			"int $a;\n\nint $b;\n{\n\tint $c;\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("Source(%q) wrong.\nwant=\n%s\ngot=\n%s", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"// header\n\n/* doc */\nproc foo() { // open\n  // inside\n  int $a; // trailing\n  /* before */ print $a;\n  // last\n}\n// eof",
+			"// header\n\n/* doc */\nproc foo() { // open\n\t// inside\n\tint $a; // trailing\n\t/* before */ print $a;\n\t// last\n}\n// eof\n",
+		},
+		{
+			"setAttr \".t\" // x\n 1 2 3;",
+			"setAttr \".t\" // x\n\t1 2 3;\n",
+		},
+		{
+			"int $a = /* one */ 1;",
+			"int $a = /* one */ 1;\n",
+		},
+		{
+			"switch ($x) {\n// first\ncase 1:\n// body\nbreak;\n}",
+			"switch ($x) {\n\t// first\n\tcase 1:\n\t\t// body\n\t\tbreak;\n}\n",
+		},
+		{
+			"/*\n * block\n */\nint $a;   ",
+			"/*\n * block\n */\nint $a;\n",
+		},
+		{
+			// block の } と else の間のコメント
+			"if ($a) {\nprint 1;\n} // c\nelse {\nprint 2;\n}",
+			"if ($a) {\n\tprint 1;\n} // c\nelse {\n\tprint 2;\n}\n",
+		},
+		{
+			// header と block の { の間のコメント
+			"global proc f() // c\n{\nprint 1;\n}\nif ($a) // d\n{\nprint 2;\n}\nwhile ($b)\n/* e */\n{\nprint 3;\n}",
+			"global proc f() // c\n{\n\tprint 1;\n}\nif ($a) // d\n{\n\tprint 2;\n}\nwhile ($b)\n/* e */\n{\n\tprint 3;\n}\n",
+		},
+		{
+			"proc f() {\nif ($a) {\nprint 1;\n}\n/* c */\nelse print 2;\ndo { $i--; } // d\nwhile ($i);\n}",
+			"proc f() {\n\tif ($a) {\n\t\tprint 1;\n\t}\n\t/* c */\n\telse print 2;\n\tdo {\n\t\t$i--;\n\t} // d\n\twhile ($i);\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("Source(%q) wrong.\nwant=\n%s\ngot=\n%s", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestSourceBlankLines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"int $a;\n\nint $b;\n\n\nint $c;",
+			"int $a;\n\nint $b;\n\nint $c;\n",
+		},
+		{
+			"proc f() {\n\n  ls;\n\n\n  pwd;\n\n}\n\n\nproc g() {\n}",
+			"proc f() {\n\tls;\n\n\tpwd;\n}\n\nproc g() {\n}\n",
+		},
+		{
+			"ls; // a\n\n// b\n\npwd; /* c */\n  \t\nls;",
+			"ls; // a\n\n// b\n\npwd; /* c */\n\nls;\n",
+		},
+		{
+			"if ($a) {\n  ls;\n} // c\nelse {\n  pwd;\n}\n\nls;",
+			"if ($a) {\n\tls;\n} // c\nelse {\n\tpwd;\n}\n\nls;\n",
+		},
+		{
+			"switch ($x) {\ncase 1:\nls;\n\nbreak;\n\ncase 2:\npwd;\n}",
+			"switch ($x) {\n\tcase 1:\n\t\tls;\n\n\t\tbreak;\n\n\tcase 2:\n\t\tpwd;\n}\n",
+		},
+		{
+			"ls;\r\n\r\n\r\npwd;\r\n",
+			"ls;\n\npwd;\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("Source(%q) wrong.\nwant=%q\ngot=%q", tt.input, tt.expected, out)
+			continue
+		}
+		// 整形した結果はもう一度整形しても変わらない
+		if again, err := Source(out); err != nil || string(again) != string(out) {
+			t.Errorf("Source(%q) is not idempotent. got=%q, err=%v", out, again, err)
+		}
+	}
+}
+
+func TestSourceIdempotent(t *testing.T) {
+	inputs := []string{
+		"proc   foo (int $a[ ], string $b)\n{\n  if($a[0]>1)\n    print $b;\n  else {\n  $b=\"x\"; // c\n  }\n}",
+		"string $cmd = \"a\"\n    + \"b\" /* x */\n    + \"c\";\n\n\n// end",
+		"switch ($x) { case \"a\": { print 1; } break; }",
+		"$a = `getAttr ($n + \".tx\")` + - 1;",
+		"if (!`exists foo`) { source \"foo.mel\"; }",
+		"print(- -1); $a = $b - -$c;",
+		"while (true) {\n\tbreak;\n}\n",
+		"if ($a) {\n} // c\nelse {\n\tls;\n}\n\n\nls;",
+	}
+
+	for _, input := range inputs {
+		first, err := Source([]byte(input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", input, err)
+			continue
+		}
+		second, err := Source(first)
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", first, err)
+			continue
+		}
+		if string(first) != string(second) {
+			t.Errorf("Source is not idempotent.\nfirst=\n%s\nsecond=\n%s", first, second)
+		}
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	inputs := []string{
+		`int $a = ;`,
+		"int $a; /* not terminated",
+	}
+
+	for _, input := range inputs {
+		if _, err := Source([]byte(input)); err == nil {
+			t.Errorf("Source(%q) should return error", input)
+		}
+	}
+}
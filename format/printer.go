@@ -0,0 +1,500 @@
+package format
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// 開いている括弧の種類
+type frameKind int
+
+const (
+	parenFrame      frameKind = iota // ( )
+	headerFrame                      // if ( ) や proc foo( ) のように後に block が続くもの
+	bracketFrame                     // [ ]
+	arrayFrame                       // { } の配列
+	tensorFrame                      // << >>
+	backQuotesFrame                  // ` `
+	blockFrame                       // { } の block
+	switchFrame                      // switch の { }
+	doFrame                          // do { } while
+)
+
+type frame struct {
+	kind   frameKind
+	indent int // block の外側のインデント
+}
+
+func (f frame) isBlock() bool {
+	return f.kind == blockFrame || f.kind == switchFrame || f.kind == doFrame
+}
+
+type printer struct {
+	items []item
+	out   bytes.Buffer
+
+	stack  []frame
+	indent int // 現在の block の中のインデント
+
+	lineEmpty   bool // 現在の行にまだ何も書いていない
+	commentOnly bool // 現在の行にはコメントしか書いていない
+	stmtStart   bool // 次の token は文の先頭
+
+	prev       token.Token
+	prevUnary  bool      // prev の後ろに空白を入れない. 単項演算子など
+	prevClose  frameKind // prev が閉じ括弧のときの種類. それ以外は -1
+	closed     frame     // 書いている token が閉じた括弧
+	isClose    bool
+	nextHeader bool      // 次の ( は if や while の条件
+	nextBlock  frameKind // 次の block の種類
+	inCase     bool      // case と : の間
+	inProc     bool      // proc と ( の間
+}
+
+func (p *printer) print() string {
+	p.lineEmpty = true
+	p.stmtStart = true
+	p.nextBlock = blockFrame
+
+	for i, it := range p.items {
+		p.printComments(it)
+		if it.tok.Type == token.EOF {
+			break
+		}
+		p.printToken(it, p.nextItem(i))
+	}
+
+	out := strings.TrimRight(p.out.String(), "\n")
+	if out == "" {
+		return ""
+	}
+	return out + "\n"
+}
+
+func (p *printer) nextItem(i int) item {
+	if i+1 < len(p.items) {
+		return p.items[i+1]
+	}
+	return item{tok: token.Token{Type: token.EOF}}
+}
+
+func (p *printer) newline() {
+	p.out.WriteString("\n")
+	p.lineEmpty = true
+	p.commentOnly = false
+}
+
+// blankLine は文の間の空行を一つだけ残す
+func (p *printer) blankLine() {
+	if p.out.Len() == 0 || bytes.HasSuffix(p.out.Bytes(), []byte("\n\n")) {
+		return
+	}
+	if p.prev.Type == token.Lbrace && len(p.stack) != 0 && p.top().isBlock() {
+		return
+	}
+	p.out.WriteString("\n")
+}
+
+func (p *printer) writeIndent(n int) {
+	p.out.WriteString(strings.Repeat("\t", n))
+	p.lineEmpty = false
+}
+
+func (p *printer) top() frame {
+	return p.stack[len(p.stack)-1]
+}
+
+// openFrames は現在の block の中で開いている括弧の数を返す
+func (p *printer) openFrames() int {
+	n := 0
+	for i := len(p.stack) - 1; i >= 0 && !p.stack[i].isBlock(); i-- {
+		n++
+	}
+	return n
+}
+
+// lineIndent は文の途中で改行したときのインデントを返す
+func (p *printer) lineIndent(closing bool) int {
+	n := p.openFrames()
+	if closing {
+		return p.indent + n
+	}
+	if n == 0 {
+		n = 1
+	}
+	return p.indent + n
+}
+
+func (p *printer) inSwitch() bool {
+	return len(p.stack) != 0 && p.top().kind == switchFrame
+}
+
+func (p *printer) printComments(it item) {
+	for i, c := range it.comments {
+		if !p.lineEmpty && c.newlines == 0 {
+			// 行末やトークンの間のコメント. int $a; // comment
+			p.out.WriteString(" " + c.text)
+			if c.line {
+				p.newline()
+			}
+			continue
+		}
+
+		if !p.lineEmpty {
+			p.newline()
+		}
+		if c.newlines >= 2 {
+			p.blankLine()
+		}
+		switch {
+		case p.stmtStart && p.inSwitch() && (it.tok.Type == token.Case || it.tok.Type == token.Default):
+			p.writeIndent(p.indent - 1)
+		case p.stmtStart || p.joinsPrev(it.tok) || p.isBlockOpen(it.tok):
+			p.writeIndent(p.indent)
+		default:
+			p.writeIndent(p.lineIndent(false))
+		}
+		p.out.WriteString(c.text)
+
+		newlinesAfter := it.newlines
+		if i+1 < len(it.comments) {
+			newlinesAfter = it.comments[i+1].newlines
+		}
+		if c.line || newlinesAfter != 0 {
+			p.newline()
+		} else {
+			p.commentOnly = true
+		}
+	}
+}
+
+func (p *printer) printToken(it item, next item) {
+	tok := it.tok
+
+	// 閉じ括弧はインデントを決める前にスタックから取り除く
+	closed, isClose := p.close(tok)
+	p.closed, p.isClose = closed, isClose
+
+	switch {
+	case isClose && closed.isBlock():
+		if !p.lineEmpty {
+			p.newline()
+		}
+		p.writeIndent(p.indent)
+	case p.lineEmpty:
+		if p.stmtStart && it.newlines >= 2 {
+			p.blankLine()
+		}
+		p.writeIndent(p.startIndent(tok, isClose))
+	case p.stmtStart && p.commentOnly:
+		// /* comment */ int $a;
+		p.out.WriteString(" ")
+	case p.stmtStart:
+		p.newline()
+		if it.newlines >= 2 {
+			p.blankLine()
+		}
+		p.writeIndent(p.startIndent(tok, isClose))
+	case it.newlines != 0 && !p.isBlockOpen(tok) && !p.joinsPrev(tok):
+		// 文の途中の改行は残す
+		p.newline()
+		p.writeIndent(p.lineIndent(isClose))
+	case p.space(it):
+		p.out.WriteString(" ")
+	}
+
+	p.out.WriteString(tok.Literal)
+	p.commentOnly = false
+	wasStmtStart := p.stmtStart
+	p.stmtStart = false
+
+	p.update(it, next, wasStmtStart)
+}
+
+func (p *printer) startIndent(tok token.Token, closing bool) int {
+	if p.joinsPrev(tok) || p.isBlockOpen(tok) {
+		// } // comment
+		// else {
+		// や
+		// if ($a) // comment
+		// {
+		return p.indent
+	}
+	if !p.stmtStart {
+		return p.lineIndent(closing)
+	}
+	if p.inSwitch() && (tok.Type == token.Case || tok.Type == token.Default) {
+		return p.indent - 1
+	}
+	return p.indent
+}
+
+// close は tok が閉じ括弧ならスタックから取り除いてその括弧を返す
+func (p *printer) close(tok token.Token) (frame, bool) {
+	if len(p.stack) == 0 {
+		return frame{}, false
+	}
+	top := p.top()
+
+	var ok bool
+	switch tok.Type {
+	case token.Rparen:
+		ok = top.kind == parenFrame || top.kind == headerFrame
+	case token.Rbracket:
+		ok = top.kind == bracketFrame
+	case token.Rtensor:
+		ok = top.kind == tensorFrame
+	case token.Rbrace:
+		ok = top.kind == arrayFrame || top.isBlock()
+	case token.BackQuotes:
+		ok = top.kind == backQuotesFrame
+	}
+	if !ok {
+		return frame{}, false
+	}
+
+	p.stack = p.stack[:len(p.stack)-1]
+	if top.isBlock() {
+		p.indent = top.indent
+	}
+	return top, true
+}
+
+func (p *printer) push(kind frameKind) {
+	p.stack = append(p.stack, frame{kind: kind, indent: p.indent})
+	switch kind {
+	case blockFrame, doFrame:
+		p.indent++
+	case switchFrame:
+		// case は switch の一段内側, case の中の文はさらに一段内側
+		p.indent += 2
+	}
+}
+
+// isBlockOpen は tok が block の { かどうかを返す
+func (p *printer) isBlockOpen(tok token.Token) bool {
+	if tok.Type != token.Lbrace {
+		return false
+	}
+	if p.stmtStart {
+		return true
+	}
+	switch p.prev.Type {
+	case token.Rparen:
+		return p.prevClose == headerFrame
+	case token.Else, token.Do:
+		return true
+	}
+	return false
+}
+
+// joinsBlock は block の } の後に同じ行に続ける else, while, ; かどうかを返す
+func joinsBlock(closed frameKind, next token.Type) bool {
+	switch next {
+	case token.Else:
+		return closed == blockFrame
+	case token.While:
+		return closed == doFrame
+	case token.Semicolon:
+		return frame{kind: closed}.isBlock()
+	}
+	return false
+}
+
+// joinsPrev は tok が直前の block の } に続く else, while, ; かどうかを返す
+func (p *printer) joinsPrev(tok token.Token) bool {
+	return p.prev.Type == token.Rbrace && joinsBlock(p.prevClose, tok.Type)
+}
+
+// update は tok を書いた後の状態を更新する
+func (p *printer) update(it item, next item, wasStmtStart bool) {
+	tok, closed, isClose := it.tok, p.closed, p.isClose
+	unary := p.isUnary(tok)
+	if tok.Type == token.Minus && it.space && !next.space {
+		// コマンドの引数の "1 -2" は "1 - 2" と意味が違うので元のまま残す
+		unary = true
+	}
+
+	switch tok.Type {
+	case token.If, token.While, token.For, token.Switch:
+		p.nextHeader = true
+		if tok.Type == token.Switch {
+			p.nextBlock = switchFrame
+		}
+	case token.Do:
+		p.nextBlock = doFrame
+	case token.Proc:
+		p.inProc = true
+	case token.Case, token.Default:
+		p.inCase = wasStmtStart
+	case token.Lparen:
+		if p.nextHeader || p.inProc {
+			p.push(headerFrame)
+		} else {
+			p.push(parenFrame)
+		}
+		p.nextHeader = false
+		p.inProc = false
+	case token.Lbracket:
+		p.push(bracketFrame)
+	case token.Ltensor:
+		p.push(tensorFrame)
+	case token.BackQuotes:
+		if !isClose {
+			p.push(backQuotesFrame)
+		}
+	case token.Lbrace:
+		if p.isBlockOpen(tok) || wasStmtStart {
+			p.push(p.nextBlock)
+			p.nextBlock = blockFrame
+			p.stmtStart = true
+		} else {
+			p.push(arrayFrame)
+		}
+	case token.Rbrace:
+		if isClose && closed.isBlock() && !joinsBlock(closed.kind, next.tok.Type) {
+			p.stmtStart = true
+		}
+	case token.Semicolon:
+		if len(p.stack) == 0 || p.top().isBlock() {
+			p.stmtStart = true
+			p.nextHeader = false
+			p.nextBlock = blockFrame
+		}
+	case token.Coron:
+		if p.inCase && (len(p.stack) == 0 || p.top().isBlock()) {
+			p.inCase = false
+			p.stmtStart = true
+		}
+	}
+
+	p.prevClose = closed.kind
+	if !isClose {
+		p.prevClose = -1
+	}
+	p.prev = tok
+	p.prevUnary = unary
+}
+
+// operandEnd は tok の後ろに二項演算子が来られるかどうかを返す
+func (p *printer) operandEnd() bool {
+	switch p.prev.Type {
+	case token.Ident, token.ProcIdent, token.Int, token.Int16, token.Float, token.String,
+		token.Flag, token.True, token.False, token.On, token.Off,
+		token.Rparen, token.Rbracket, token.Rtensor:
+		return true
+	case token.Rbrace:
+		return p.prevClose == arrayFrame
+	case token.BackQuotes:
+		return p.prevClose == backQuotesFrame
+	case token.Increment, token.Decrement:
+		return !p.prevUnary
+	}
+	return false
+}
+
+// isUnary は tok が前置の単項演算子かどうかを返す
+func (p *printer) isUnary(tok token.Token) bool {
+	switch tok.Type {
+	case token.Bang:
+		return true
+	case token.Minus, token.Plus, token.Increment, token.Decrement:
+		return !p.operandEnd()
+	}
+	return false
+}
+
+func isDec(typ token.Type) bool {
+	switch typ {
+	case token.IntDec, token.FloatDec, token.StringDec, token.VectorDec, token.MatrixDec:
+		return true
+	}
+	return false
+}
+
+// space は prev と it.tok の間に空白を入れるかどうかを返す
+func (p *printer) space(it item) bool {
+	tok := it.tok
+	sp := !p.prevUnary && p.wantSpace(it)
+	if !sp && !joinable(p.prev, tok) {
+		// 空白を消すと別の token になってしまう. ex) - -1
+		return true
+	}
+	return sp
+}
+
+func (p *printer) wantSpace(it item) bool {
+	tok, prev := it.tok, p.prev
+
+	// 閉じ括弧と区切り
+	switch tok.Type {
+	case token.Semicolon:
+		return false
+	case token.Comma, token.Rparen, token.Rbracket, token.Rtensor:
+		return false
+	case token.Rbrace:
+		return false
+	case token.BackQuotes:
+		if p.isClose {
+			return false
+		}
+	case token.Dot:
+		return false
+	case token.Coron:
+		if p.inCase {
+			return false
+		}
+	}
+
+	// 開き括弧の後
+	switch prev.Type {
+	case token.Lparen, token.Lbracket, token.Ltensor, token.Dot:
+		return false
+	case token.Lbrace:
+		return !p.arrayOpen()
+	case token.BackQuotes:
+		if p.prevClose == -1 {
+			return false
+		}
+	case token.Comma:
+		return true
+	case token.Semicolon:
+		return tok.Type != token.Semicolon && tok.Type != token.Rparen
+	}
+
+	switch tok.Type {
+	case token.Lparen:
+		switch {
+		case prev.Type == token.ProcIdent && len(p.stack) != 0 && p.top().kind == headerFrame:
+			return false
+		case prev.Type == token.ProcIdent || isDec(prev.Type):
+			// foo(1) と foo (1) はどちらも書けるので元のまま残す
+			return it.space
+		}
+		return true
+	case token.Lbracket:
+		return !(p.operandEnd() || isDec(prev.Type))
+	case token.Increment, token.Decrement:
+		if p.operandEnd() {
+			// $i++
+			return false
+		}
+	}
+	return true
+}
+
+// arrayOpen は直前の { が配列の { かどうかを返す
+func (p *printer) arrayOpen() bool {
+	return len(p.stack) != 0 && p.top().kind == arrayFrame
+}
+
+// joinable は a と b を空白無しで並べても同じ token に分かれるかどうかを返す
+func joinable(a, b token.Token) bool {
+	l := lexer.New(a.Literal + b.Literal)
+	first, second := l.NextToken(), l.NextToken()
+	return first.Type == a.Type && first.Literal == a.Literal &&
+		second.Type == b.Type && second.Literal == b.Literal
+}
@@ -1,5 +1,7 @@
 module github.com/nrtkbb/go-MEL
 
+go 1.27.1
+
 require (
 	golang.org/x/lint v0.0.0-20181011164241-5906bd5c48cd // indirect
 	golang.org/x/tools v0.0.0-20181016205153-5ef16f43e633 // indirect
@@ -0,0 +1,86 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Error is a lexical error found by the Lexer.
+type Error struct {
+	Token   token.Token // エラーのある token
+	Pos     token.Pos   // エラーの位置. token の途中のこともある
+	Row     int         // エラーの位置の行 1行はじまり
+	Column  int         // エラーの位置の列 1列はじまり
+	Offset  int         // エラーの位置の byte offset
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line:%d.%d %s", e.Row, e.Column, e.Message)
+}
+
+// Errors return the lexical errors of the tokens read so far.
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+// check は読んだ token の字句のエラーを記録する
+func (l *Lexer) check(tok token.Token) {
+	switch tok.Type {
+	case token.EOF:
+		if l.err != nil {
+			// 読めなかったところで終わりにする
+			l.errorf(tok, 0, "%s", l.err)
+			l.err = nil
+		}
+	case token.Illegal:
+		if tok.Literal == "&" {
+			l.errorf(tok, 0, "illegal character '&', did you mean '&&'?")
+			return
+		}
+		r, size := utf8.DecodeRuneInString(tok.Literal)
+		if r == utf8.RuneError && size == 1 {
+			l.errorf(tok, 0, "illegal UTF-8 encoding %#x", tok.Literal[0])
+			return
+		}
+		l.errorf(tok, 0, "illegal character %#U", r)
+	case token.String:
+		if _, err := Unquote(tok.Literal); err == ErrUnterminatedString {
+			l.errorf(tok, 0, "string literal not terminated")
+		}
+	case token.Comment:
+		if strings.HasPrefix(tok.Literal, "/*") && (len(tok.Literal) < 4 || !strings.HasSuffix(tok.Literal, "*/")) {
+			l.errorf(tok, 0, "comment not terminated")
+		}
+	case token.Int16:
+		if len(tok.Literal) == len("0x") {
+			l.errorf(tok, 0, "hexadecimal literal has no digits")
+		}
+	case token.Float:
+		if i := strings.IndexAny(tok.Literal, "eE"); i >= 0 && strings.TrimLeft(tok.Literal[i+1:], "+-") == "" {
+			l.errorf(tok, i, "exponent has no digits")
+		}
+	default:
+		for i, r := range tok.Literal {
+			if r >= utf8.RuneSelf {
+				l.errorf(tok, i, "identifier contains non-ASCII character %#U", r)
+				return
+			}
+		}
+	}
+}
+
+// errorf は tok の i byte 目の位置のエラーを記録する. token は 1 行なので列は rune で数える
+func (l *Lexer) errorf(tok token.Token, i int, format string, a ...interface{}) {
+	l.errors = append(l.errors, &Error{
+		Token:   tok,
+		Pos:     tok.Pos + token.Pos(i),
+		Row:     tok.Row,
+		Column:  tok.Column + utf8.RuneCountInString(tok.Literal[:i]),
+		Offset:  tok.Offset + i,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
@@ -1,54 +1,165 @@
 package lexer
 
 import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/nrtkbb/go-MEL/token"
 )
 
 // Lexer は字句解析を行うための構造体
 type Lexer struct {
-	input        []rune // 字句解析対象のすべてのrune配列
-	position     int    // 字句解析中のinputのインデックス
-	readPosition int    // 字句解析中の一つ先のinputのインデックス
-	rune         rune   // positionの位置にあるrune
-	row          int    // 行数 1行はじまり
-	column       int    // 列数 1列はじまり
+	r        reader      // 字句解析対象
+	err      error       // r の読み込みエラー
+	rune     rune        // 今の rune
+	size     int         // 今の rune の byte 数. 終わりでは 0
+	raw      byte        // 今の rune が UTF-8 でない時の byte
+	next     rune        // 一つ先の rune
+	nextSize int         // 一つ先の rune の byte 数. 終わりでは 0
+	nextRaw  byte        // 一つ先の rune が UTF-8 でない時の byte
+	offset   int         // 今の rune の byte offset
+	row      int         // 行数 1行はじまり
+	column   int         // 列数 1列はじまり
+	lit      []byte      // mark してから読んだ文字列
+	marking  bool        // lit に記録しているか
+	file     *token.File // 行の先頭の位置を記録する
+	mode     Mode
+	errors   []*Error
+}
+
+// reader は UTF-8 でない byte を読み直せる rune の reader.
+// strings.Reader と bufio.Reader が満たす
+type reader interface {
+	io.RuneScanner
+	io.ByteReader
 }
 
+// Mode は Lexer の動作を切り替えるフラグ
+type Mode uint
+
+const (
+	// ScanComments はコメントを読み飛ばさずに token.Comment として返す
+	ScanComments Mode = 1 << iota
+	// ScanWhitespace は空白と改行を読み飛ばさずに token.Whitespace として返す
+	ScanWhitespace
+)
+
 // New はMELの文字列を受け取りLexerを生成して返す
 func New(input string) *Lexer {
+	return NewWithMode(input, 0)
+}
+
+// NewWithMode は mode を指定してLexerを生成して返す
+func NewWithMode(input string, mode Mode) *Lexer {
+	file := token.NewFileSet().AddFile("", len(input))
+	return NewFile(file, input, mode)
+}
+
+// NewFile は FileSet に追加した file の文字列を受け取りLexerを生成して返す.
+// Token の Pos は file の中の位置になる.
+func NewFile(file *token.File, input string, mode Mode) *Lexer {
+	if file.Size() != len(input) {
+		panic("lexer: file size does not match input length")
+	}
+	return newLexer(file, strings.NewReader(input), mode)
+}
+
+// NewReader は r から少しずつ読むLexerを生成して返す.
+// 読んだ入力は今の token の分しか持たないので大きなファイルでもメモリは増えない.
+// Token の Row, Column と Offset は New と同じだが, File は行の表を持たない.
+func NewReader(r io.Reader) *Lexer {
+	return NewReaderWithMode(r, 0)
+}
+
+// NewReaderWithMode は mode を指定して r から読むLexerを生成して返す
+func NewReaderWithMode(r io.Reader, mode Mode) *Lexer {
+	file := token.NewFileSet().AddStream("")
+	return newLexer(file, bufio.NewReader(r), mode)
+}
+
+func newLexer(file *token.File, r reader, mode Mode) *Lexer {
 	l := &Lexer{
-		input: []rune(input),
-		row:   1, // 1行はじまり
+		r:    r,
+		row:  1, // 1行はじまり
+		file: file,
+		mode: mode,
 	}
+	l.next, l.nextSize, l.nextRaw = l.read()
 	l.readRune()
 	return l
 }
 
+// File return the file of the Lexer
+func (l *Lexer) File() *token.File {
+	return l.file
+}
+
+// read は r から rune を一つ読む. 終わりか読めない時は size が 0 になる.
+// UTF-8 でない byte は utf8.RuneError になり, 元の byte を raw に返す
+func (l *Lexer) read() (r rune, size int, raw byte) {
+	r, size, err := l.r.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			l.err = err
+		}
+		return 0, 0, 0
+	}
+	if r == utf8.RuneError && size == 1 {
+		l.r.UnreadRune()
+		raw, _ = l.r.ReadByte()
+	}
+	return r, size, raw
+}
+
 func (l *Lexer) readRune() {
-	if l.readPosition >= len(l.input) {
+	if l.marking && l.size != 0 {
+		if l.rune == utf8.RuneError && l.size == 1 {
+			// 元の byte のまま残す
+			l.lit = append(l.lit, l.raw)
+		} else {
+			l.lit = utf8.AppendRune(l.lit, l.rune)
+		}
+	}
+	l.offset += l.size
+	if l.nextSize == 0 {
 		l.rune = 0
+		l.size = 0
+		l.raw = 0
 	} else {
 		if '\n' == l.rune {
 			l.row++
 			l.column = 0
+			l.file.AddLine(l.offset)
 		}
-		if '\r' == l.rune && '\n' != l.input[l.readPosition] {
+		if '\r' == l.rune && '\n' != l.next {
 			// '\r\n' の文章の '\r' の時はまだ改行しない
 			l.row++
 			l.column = 0
+			l.file.AddLine(l.offset)
 		}
-		l.rune = rune(l.input[l.readPosition])
+		l.rune, l.size, l.raw = l.next, l.nextSize, l.nextRaw
+		l.next, l.nextSize, l.nextRaw = l.read()
 	}
-	l.position = l.readPosition
-	l.readPosition++
 	l.column++
 }
 
 func (l *Lexer) peekRune() rune {
-	if l.readPosition >= len(l.input) {
-		return 0
-	}
-	return l.input[l.readPosition]
+	return l.next
+}
+
+// mark は今の rune から token の文字列を記録しはじめる
+func (l *Lexer) mark() {
+	l.lit = l.lit[:0]
+	l.marking = true
+}
+
+// text は mark から今の rune の前までの文字列を返す
+func (l *Lexer) text() string {
+	l.marking = false
+	return string(l.lit)
 }
 
 func newToken(tokenType token.Type, r rune, row, column int) token.Token {
@@ -62,11 +173,35 @@ func newToken(tokenType token.Type, r rune, row, column int) token.Token {
 
 // NextToken は実行される度に一つずつTokenを生成して返す
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	for {
+		if l.mode&ScanWhitespace == 0 {
+			l.skipWhitespace()
+		}
+
+		offset := l.offset
+		tok := l.scanToken()
+		tok.Offset = offset
+		tok.Pos = l.file.Pos(offset)
+		l.check(tok)
+
+		if tok.Type != token.Comment || l.mode&ScanComments != 0 {
+			return tok
+		}
+	}
+}
 
-	l.skipWhitespace()
+func (l *Lexer) scanToken() token.Token {
+	var tok token.Token
 
 	switch l.rune {
+	case ' ', '\t', '\n', '\r':
+		tok.Type = token.Whitespace
+		tok.Row = l.row
+		tok.Column = l.column
+		l.mark()
+		l.skipWhitespace()
+		tok.Literal = l.text()
+		return tok
 	case '&':
 		tok = l.peekRuneCheck('&', token.And, token.Illegal)
 	case '=':
@@ -133,12 +268,16 @@ func (l *Lexer) NextToken() token.Token {
 		if '=' == l.peekRune() {
 			l.readNAssign(&tok, token.SAssign)
 			return tok
-		} else if '/' == l.peekRune() {
-			l.readLineComment()
-			return l.NextToken()
-		} else if '*' == l.peekRune() {
-			l.readComment()
-			return l.NextToken()
+		} else if '/' == l.peekRune() || '*' == l.peekRune() {
+			tok.Type = token.Comment
+			tok.Row = l.row
+			tok.Column = l.column
+			if '/' == l.peekRune() {
+				tok.Literal = l.readLineComment()
+			} else {
+				tok.Literal = l.readComment()
+			}
+			return tok
 		}
 		tok = newToken(token.Slash, l.rune, l.row, l.column)
 	case '$':
@@ -155,12 +294,12 @@ func (l *Lexer) NextToken() token.Token {
 		return tok
 	case '|':
 		if l.peekRune() == '|' {
-			l.readRune()
-			l.readRune()
 			tok.Type = token.Or
 			tok.Row = l.row
 			tok.Column = l.column
 			tok.Literal = "||"
+			l.readRune()
+			l.readRune()
 			return tok
 		}
 		tok.Row = l.row
@@ -198,7 +337,7 @@ func (l *Lexer) NextToken() token.Token {
 			return tok
 		}
 
-		if isLetterFirst(l.rune) {
+		if isLetterFirst(l.rune) || isNonASCIILetter(l.rune) {
 			tok.Row = l.row
 			tok.Column = l.column
 			tok.Literal = l.readLetterIdentifier()
@@ -206,6 +345,9 @@ func (l *Lexer) NextToken() token.Token {
 			return tok
 		}
 		tok = newToken(token.Illegal, l.rune, l.row, l.column)
+		if l.rune == utf8.RuneError && l.size == 1 {
+			tok.Literal = string([]byte{l.raw})
+		}
 	}
 
 	l.readRune()
@@ -222,28 +364,27 @@ func (l *Lexer) readNAssign(tok *token.Token, typ token.Type) {
 }
 
 func (l *Lexer) readLineComment() string {
-	position := l.position
+	l.mark()
 	l.readRune() // '/'
 	l.readRune() // ?
 	for !isNewLine(l.rune) && l.rune != 0 {
 		l.readRune()
 	}
-	return string(l.input[position:l.position])
+	return l.text()
 }
 
 func (l *Lexer) readComment() string {
-	position := l.position
+	l.mark()
 	l.readRune() // '*'
 	l.readRune() // ?
 	for !('*' == l.rune && '/' == l.peekRune()) && l.rune != 0 {
 		l.readRune()
-		if l.position == len(l.input) {
-			break
-		}
 	}
-	l.readRune() // '*'
-	l.readRune() // '/'
-	comment := string(l.input[position:l.position])
+	if l.rune != 0 {
+		l.readRune() // '*'
+		l.readRune() // '/'
+	}
+	comment := l.text()
 	return comment
 }
 
@@ -252,12 +393,12 @@ func isNewLine(r rune) bool {
 }
 
 func (l *Lexer) readFlag() string {
-	position := l.position
+	l.mark()
 	l.readRune() // '-'
 	for isFlag(l.rune) {
 		l.readRune()
 	}
-	return string(l.input[position:l.position])
+	return l.text()
 }
 
 func (l *Lexer) peekRuneCheck(peek rune, trueType, falseType token.Type) token.Token {
@@ -278,35 +419,40 @@ func (l *Lexer) peekRuneCheck(peek rune, trueType, falseType token.Type) token.T
 }
 
 func (l *Lexer) readString() string {
-	position := l.position
+	l.mark()
 	l.readRune() // '"'
 	for '"' != l.rune && 0 != l.rune {
 		if '\\' == l.rune {
 			l.readRune() // '\\'
+			if 0 == l.rune {
+				break
+			}
 		}
 		l.readRune()
 	}
-	l.readRune() // '"'
-	return string(l.input[position:l.position])
+	if '"' == l.rune {
+		l.readRune() // '"'
+	}
+	return l.text()
 }
 
 func (l *Lexer) readLetterIdentifier() string {
-	position := l.position
+	l.mark()
 	l.readRune()
-	for isLetter(l.rune) ||
+	for isLetter(l.rune) || isNonASCIILetter(l.rune) ||
 		':' == l.rune && isLetter(l.peekRune()) { // last Coron is bad
 		l.readRune()
 	}
-	return string(l.input[position:l.position])
+	return l.text()
 }
 
 func (l *Lexer) readIdentifier() string {
-	position := l.position
+	l.mark()
 	l.readRune() // '$'
-	for isIdentifier(l.rune) {
+	for isIdentifier(l.rune) || isNonASCIILetter(l.rune) {
 		l.readRune()
 	}
-	return string(l.input[position:l.position])
+	return l.text()
 }
 
 func isFlag(r rune) bool {
@@ -323,19 +469,25 @@ func isLetter(r rune) bool {
 		'_' == r || '.' == r || '|' == r || '0' <= r && r <= '9'
 }
 
+// isNonASCIILetter は識別子に使えない ASCII 以外の文字か調べる.
+// 識別子の一部として読んでからエラーにする
+func isNonASCIILetter(r rune) bool {
+	return r >= utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
 func isIdentifier(r rune) bool {
 	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' ||
 		'_' == r || '0' <= r && r <= '9'
 }
 
 func (l *Lexer) readHexadecimalNumber() string {
-	position := l.position
+	l.mark()
 	l.readRune() // '0'
 	l.readRune() // 'x'
 	for isHexadecimalDigit(l.rune) {
 		l.readRune()
 	}
-	return string(l.input[position:l.position])
+	return l.text()
 }
 
 func isHexadecimalDigit(r rune) bool {
@@ -345,7 +497,7 @@ func isHexadecimalDigit(r rune) bool {
 func (l *Lexer) readNumber() (token.Type, string) {
 	var typ token.Type
 	typ = token.Int
-	position := l.position
+	l.mark()
 	for isDigit(l.rune) {
 		l.readRune()
 	}
@@ -357,16 +509,19 @@ func (l *Lexer) readNumber() (token.Type, string) {
 		}
 	}
 	if 'e' == l.rune || 'E' == l.rune {
-		if '-' == l.peekRune() || '+' == l.peekRune() {
+		// 1e のように数字の無い指数も Float にして check でエラーにする
+		if peek := l.peekRune(); '-' == peek || '+' == peek || isDigit(peek) || !isLetter(peek) {
 			typ = token.Float
 			l.readRune() // 'e' or 'E'
-			l.readRune() // '-' or '+'
+			if '-' == l.rune || '+' == l.rune {
+				l.readRune()
+			}
 			for isDigit(l.rune) {
 				l.readRune()
 			}
 		}
 	}
-	return typ, string(l.input[position:l.position])
+	return typ, l.text()
 }
 
 func isDigit(r rune) bool {
@@ -1,7 +1,14 @@
 package lexer
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/nrtkbb/go-MEL/token"
 )
@@ -267,6 +274,52 @@ func TestCommentToken(t *testing.T) {
 	}
 }
 
+func TestScanComments(t *testing.T) {
+	input := `// line
+int $a; /* block
+comment */ $a = 1; // last`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+		expectedRow     int
+		expectedColumn  int
+	}{
+		{token.Comment, "// line", 1, 1},
+		{token.IntDec, "int", 2, 1},
+		{token.Ident, "$a", 2, 5},
+		{token.Semicolon, ";", 2, 7},
+		{token.Comment, "/* block\ncomment */", 2, 9},
+		{token.Ident, "$a", 3, 12},
+		{token.Assign, "=", 3, 15},
+		{token.Int, "1", 3, 17},
+		{token.Semicolon, ";", 3, 18},
+		{token.Comment, "// last", 3, 20},
+		{token.EOF, "", 3, 27},
+	}
+
+	l := NewWithMode(input, ScanComments)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q %q",
+				i, tt.expectedType, tok.Type, tok.Literal)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Row != tt.expectedRow || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d.%d, got=%d.%d",
+				i, tt.expectedRow, tt.expectedColumn, tok.Row, tok.Column)
+		}
+	}
+}
+
 func TestNextToken(t *testing.T) {
 	input := `int $five = 5;
 int $ten = 10;
@@ -574,13 +627,325 @@ int $ten = 10;
 		}
 
 		if tok.Column != tt.expectedColumn {
-			t.Fatalf("tests[%d] - column wrong. expected=%q, got=%q",
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d",
 				i, tt.expectedColumn, tok.Column)
 		}
 
 		if tok.Row != tt.expectedRow {
-			t.Fatalf("tests[%d] - row wrong. expected=%q, got=%q",
+			t.Fatalf("tests[%d] - row wrong. expected=%d, got=%d",
 				i, tt.expectedRow, tok.Row)
 		}
 	}
 }
+
+func TestUnterminatedInput(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{`"abc`, token.String, `"abc`},
+		{`"abc\`, token.String, `"abc\`},
+		{`/* abc`, token.EOF, ""},
+		{`a || b`, token.ProcIdent, "a"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q %q",
+				i, tt.expectedType, tok.Type, tok.Literal)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Type == token.EOF {
+			continue
+		}
+		if next := l.NextToken(); tt.expectedType == token.String && next.Type != token.EOF {
+			t.Fatalf("tests[%d] - expected EOF after string. got=%q", i, next.Type)
+		}
+	}
+
+	l := New("a || b")
+	l.NextToken()
+	tok := l.NextToken()
+	if tok.Type != token.Or || tok.Column != 3 {
+		t.Fatalf("|| token wrong. got=%q at column %d", tok.Type, tok.Column)
+	}
+}
+
+func TestTokenOffset(t *testing.T) {
+	input := "print \"あい\";\r\n$a = 1;"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedOffset  int
+		expectedRow     int
+		expectedColumn  int
+	}{
+		{"print", 0, 1, 1},
+		{`"あい"`, 6, 1, 7},
+		{";", 14, 1, 11},
+		{"$a", 17, 2, 1},
+		{"=", 20, 2, 4},
+		{"1", 22, 2, 6},
+		{";", 23, 2, 7},
+		{"", 24, 2, 8},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Offset != tt.expectedOffset {
+			t.Fatalf("tests[%d] - offset wrong. expected=%d, got=%d",
+				i, tt.expectedOffset, tok.Offset)
+		}
+
+		pos := l.File().Position(tok.Pos)
+		if pos.Offset != tok.Offset || pos.Line != tt.expectedRow || tok.Row != tt.expectedRow || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d.%d, got=%d.%d (%s)",
+				i, tt.expectedRow, tt.expectedColumn, tok.Row, tok.Column, pos)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{`"s"`, "s", ""},
+		{`"a\nb\tc\r"`, "a\nb\tc\r", ""},
+		{`"\"\\"`, `"\`, ""},
+		{`"\x41"`, "x41", `unknown escape sequence \x`},
+		{`"\é"`, "é", `unknown escape sequence \é`},
+		{`"s`, "s", "string literal not terminated"},
+		{`"s\"`, `s"`, "string literal not terminated"},
+		{`"`, "", "string literal not terminated"},
+	}
+
+	for _, tt := range tests {
+		got, err := Unquote(tt.input)
+		if got != tt.expected {
+			t.Errorf("Unquote(%q) wrong. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != tt.err {
+			t.Errorf("Unquote(%q) wrong error. expected=%q, got=%q", tt.input, tt.err, errMsg)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		literals []string
+		errors   []string
+	}{
+		{"$a & $b;", []string{"$a", "&", "$b", ";"}, []string{"line:1.4 illegal character '&', did you mean '&&'?"}},
+		{"ls # @", []string{"ls", "#", "@"}, []string{"line:1.4 illegal character U+0023 '#'", "line:1.6 illegal character U+0040 '@'"}},
+		{"0x; 0x1F;", []string{"0x", ";", "0x1F", ";"}, []string{"line:1.1 hexadecimal literal has no digits"}},
+		{"1e; 2.5E+ 3e-2 4e5", []string{"1e", ";", "2.5E+", "3e-2", "4e5"}, []string{"line:1.2 exponent has no digits", "line:1.8 exponent has no digits"}},
+		{"$名前 = 1;", []string{"$名前", "=", "1", ";"}, []string{"line:1.2 identifier contains non-ASCII character U+540D '名'"}},
+		{"ls\n  proc_é;", []string{"ls", "proc_é", ";"}, []string{"line:2.8 identifier contains non-ASCII character U+00E9 'é'"}},
+		{"print \"abc;\n", []string{"print", "\"abc;\n"}, []string{"line:1.7 string literal not terminated"}},
+		{"ls; /* abc\n", []string{"ls", ";"}, []string{"line:1.5 comment not terminated"}},
+		{"/* a */ // b\n\"a\\\"\"", []string{"\"a\\\"\""}, nil},
+		// UTF-8 でない byte は literal にそのまま残す
+		{"ls \xff \"a\xfeb\";", []string{"ls", "\xff", "\"a\xfeb\"", ";"}, []string{"line:1.4 illegal UTF-8 encoding 0xff"}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		var literals []string
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			literals = append(literals, tok.Literal)
+		}
+		if fmt.Sprint(literals) != fmt.Sprint(tt.literals) {
+			t.Errorf("input %q: wrong literals.\nexpected=%q\ngot=%q", tt.input, tt.literals, literals)
+		}
+
+		var errors []string
+		for _, err := range l.Errors() {
+			errors = append(errors, err.Error())
+		}
+		if fmt.Sprint(errors) != fmt.Sprint(tt.errors) {
+			t.Errorf("input %q: wrong errors.\nexpected=%q\ngot=%q", tt.input, tt.errors, errors)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	inputs := []string{
+		"",
+		"ls;\n",
+		"a\r\nb\rc\n\n",
+		"$名前 = \"é\\n\";\xff x",
+		"/* a\r\n b */ 1e5 .5 0x1f -flag |a|b:c // end",
+		"\"abc",
+		"/* open",
+		"proc string[] f(int $a) { return {\"a\", \"b\"}; }\nf 1 -q;",
+	}
+
+	for _, input := range inputs {
+		for _, mode := range []Mode{0, ScanComments} {
+			want := NewWithMode(input, mode)
+			// 1 byte ずつ読ませて rune の途中で切れても同じになるか調べる
+			got := NewReaderWithMode(iotest.OneByteReader(strings.NewReader(input)), mode)
+			for {
+				w, g := want.NextToken(), got.NextToken()
+				if w != g {
+					t.Errorf("input %q: wrong token. want=%+v, got=%+v", input, w, g)
+					break
+				}
+				if w.Type == token.EOF {
+					break
+				}
+			}
+			if fmt.Sprint(want.Errors()) != fmt.Sprint(got.Errors()) {
+				t.Errorf("input %q: wrong errors. want=%v, got=%v", input, want.Errors(), got.Errors())
+			}
+		}
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("ls -l"), iotest.ErrReader(errors.New("disk error")))
+	l := NewReader(r)
+
+	var literals []string
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		literals = append(literals, tok.Literal)
+	}
+	if fmt.Sprint(literals) != "[ls -l]" {
+		t.Errorf("wrong literals. got=%q", literals)
+	}
+	if errs := l.Errors(); len(errs) != 1 || errs[0].Error() != "line:1.6 disk error" {
+		t.Errorf("wrong errors. got=%v", errs)
+	}
+
+	pos := l.File().Position(l.NextToken().Pos)
+	if pos.Offset != 5 || pos.IsValid() {
+		t.Errorf("a stream position should have the offset only. got=%+v", pos)
+	}
+}
+
+// melReader はアニメーションのキーを n 行書いた MEL を少しずつ作る
+type melReader struct {
+	n, i int
+	size int64
+	buf  []byte
+}
+
+func (r *melReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.i == r.n {
+			return 0, io.EOF
+		}
+		r.buf = []byte(fmt.Sprintf("setKeyframe -t %d -v %d.5 \"pCube1.tx\"; /* key %d */\n", r.i, r.i, r.i))
+		r.i++
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	r.size += int64(n)
+	return n, nil
+}
+
+// lexAll は l の token をすべて読み, その間の heap の大きさの最大を返す
+func lexAll(l *Lexer) uint64 {
+	var stats runtime.MemStats
+	var peak uint64
+	for i := 0; ; i++ {
+		tok := l.NextToken()
+		if i%10000 == 0 || tok.Type == token.EOF {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > peak {
+				peak = stats.HeapAlloc
+			}
+		}
+		if tok.Type == token.EOF {
+			return peak
+		}
+	}
+}
+
+// NewReader の heap は入力が大きくなっても増えない. New は入力の分だけ増える
+func BenchmarkNewReader(b *testing.B) {
+	for _, lines := range []int{10000, 100000, 1000000} {
+		b.Run(fmt.Sprintf("lines=%d", lines), func(b *testing.B) {
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				r := &melReader{n: lines}
+				peak = lexAll(NewReader(r))
+				b.SetBytes(r.size)
+			}
+			b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+		})
+	}
+}
+
+func BenchmarkNew(b *testing.B) {
+	for _, lines := range []int{10000, 100000, 1000000} {
+		b.Run(fmt.Sprintf("lines=%d", lines), func(b *testing.B) {
+			src, err := ioutil.ReadAll(&melReader{n: lines})
+			if err != nil {
+				b.Fatal(err)
+			}
+			input := string(src)
+			src = nil
+			b.SetBytes(int64(len(input)))
+			b.ResetTimer()
+
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				peak = lexAll(New(input))
+			}
+			b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+		})
+	}
+}
+
+func TestScanWhitespace(t *testing.T) {
+	input := "proc f() {\r\n\tls -sl; // c\r\n}\n\n/* d */ $a = <<1, 2>>;  "
+
+	l := NewWithMode(input, ScanComments|ScanWhitespace)
+	var out strings.Builder
+	var types []token.Type
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		out.WriteString(tok.Literal)
+		types = append(types, tok.Type)
+	}
+	// すべての token をつなげると元に戻る
+	if out.String() != input {
+		t.Errorf("wrong text.\nwant=%q\ngot=%q", input, out.String())
+	}
+	if types[1] != token.Whitespace || types[len(types)-1] != token.Whitespace {
+		t.Errorf("whitespace should be tokens. got=%v", types)
+	}
+
+	// 改行を含む空白の次の token の位置
+	l = NewWithMode("ls\r\n  pwd", ScanWhitespace)
+	l.NextToken()
+	if ws := l.NextToken(); ws.Type != token.Whitespace || ws.Literal != "\r\n  " || ws.Column != 3 {
+		t.Errorf("wrong whitespace. got=%+v", ws)
+	}
+	if tok := l.NextToken(); tok.Row != 2 || tok.Column != 3 || tok.Offset != 6 {
+		t.Errorf("wrong position after whitespace. got=%+v", tok)
+	}
+}
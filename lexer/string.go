@@ -0,0 +1,62 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnterminatedString は閉じる '"' が無い文字列リテラルのエラー
+var ErrUnterminatedString = errors.New("string literal not terminated")
+
+// Unquote は token.String の Literal から引用符を外してエスケープを戻す.
+// MEL のエスケープは \n, \t, \r, \\ と \" だけ. 知らないエスケープや閉じていない
+// 文字列はエラーを返すが, その場合も読めたところまでの値を返す.
+// ex) 知らないエスケープ "\d" は d になる
+func Unquote(lit string) (string, error) {
+	if len(lit) == 0 || lit[0] != '"' {
+		return lit, fmt.Errorf("string literal must start with '\"': %s", lit)
+	}
+	s := lit[1:]
+	if strings.IndexByte(s, '\\') < 0 {
+		if len(s) == 0 || s[len(s)-1] != '"' {
+			return s, ErrUnterminatedString
+		}
+		return s[:len(s)-1], nil
+	}
+
+	var out strings.Builder
+	var err error
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			// Lexer は閉じる '"' で止まるので最後の byte のはず
+			return out.String(), err
+		case '\\':
+			i++
+			if i == len(s) {
+				return out.String(), ErrUnterminatedString
+			}
+			switch s[i] {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'r':
+				out.WriteByte('\r')
+			case '\\', '"':
+				out.WriteByte(s[i])
+			default:
+				if err == nil {
+					r, _ := utf8.DecodeRuneInString(s[i:])
+					err = fmt.Errorf("unknown escape sequence \\%c", r)
+				}
+				out.WriteByte(s[i])
+			}
+		default:
+			out.WriteByte(s[i])
+		}
+	}
+	return out.String(), ErrUnterminatedString
+}
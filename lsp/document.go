@@ -0,0 +1,296 @@
+package lsp
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+	"github.com/nrtkbb/go-MEL/resolver"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// proc は document の一番外側で定義された proc
+type proc struct {
+	stmt   *ast.ProcStatement
+	global bool
+}
+
+// document is a parsed MEL file with the index of its symbols
+type document struct {
+	uri   string
+	text  string
+	lines []int // 各行の先頭の byte offset
+	file  *token.File
+
+	program *ast.Program
+	errors  parser.ErrorList
+
+	procs   []proc
+	globals []*ast.Identifier    // 一番外側の global 変数の宣言
+	calls   []*ast.Identifier    // proc を呼び出している名前
+	refs    []resolver.Reference // 変数の参照
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+
+	d.file = token.NewFileSet().AddFile(uriToPath(uri), len(text))
+	p := parser.New(lexer.NewFile(d.file, text, lexer.ScanComments))
+	d.program = p.ParseProgram()
+	d.errors = p.Errors()
+
+	for _, stmt := range d.program.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.ProcStatement:
+			d.procs = append(d.procs, proc{stmt: stmt})
+		case *ast.GlobalStatement:
+			if ps, ok := stmt.Statement.(*ast.ProcStatement); ok {
+				d.procs = append(d.procs, proc{stmt: ps, global: true})
+				continue
+			}
+			names, _ := ast.Declaration(stmt.Statement)
+			for _, name := range names {
+				if ident := ast.DeclaredVariable(name); ident != nil {
+					d.globals = append(d.globals, ident)
+				}
+			}
+		}
+		collectCalls(stmt, &d.calls)
+	}
+
+	r := resolver.New()
+	r.Resolve(d.program)
+	d.refs = r.References()
+
+	return d
+}
+
+// position は byte offset を LSP の Position (UTF-16 単位) にする
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+	character := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		character += utf16Len(r)
+	}
+	return Position{Line: line, Character: character}
+}
+
+// offset は LSP の Position を byte offset にする
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	start := d.lines[pos.Line]
+	character := 0
+	for i, r := range d.text[start:] {
+		if character >= pos.Character || r == '\n' {
+			return start + i
+		}
+		character += utf16Len(r)
+	}
+	return len(d.text)
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// tokenRange は token の範囲
+func (d *document) tokenRange(tok token.Token) Range {
+	return Range{
+		Start: d.position(tok.Offset),
+		End:   d.position(tok.Offset + len(tok.Literal)),
+	}
+}
+
+// nodeRange は node の範囲
+func (d *document) nodeRange(node ast.Node) Range {
+	return d.posRange(node.Pos(), node.End())
+}
+
+func (d *document) posRange(start, end token.Pos) Range {
+	return Range{
+		Start: d.position(d.posOffset(start)),
+		End:   d.position(d.posOffset(end)),
+	}
+}
+
+// posOffset は Pos を byte offset にする. 無効な Pos は 0 になる
+func (d *document) posOffset(p token.Pos) int {
+	if int(p) < d.file.Base() || int(p) > d.file.Base()+d.file.Size() {
+		return 0
+	}
+	return d.file.Offset(p)
+}
+
+func (d *document) location(tok token.Token) Location {
+	return Location{URI: d.uri, Range: d.tokenRange(tok)}
+}
+
+// diagnostics は構文エラーを Diagnostic にする
+func (d *document) diagnostics() []Diagnostic {
+	diags := []Diagnostic{}
+	for _, err := range d.errors {
+		start, end := d.posOffset(err.Start), d.posOffset(err.End)
+		if !err.Start.IsValid() {
+			start = err.Token.Offset
+		}
+		if end < start {
+			end = start
+		}
+		diags = append(diags, Diagnostic{
+			Range:    Range{Start: d.position(start), End: d.position(end)},
+			Severity: SeverityError,
+			Code:     string(err.Code),
+			Source:   "go-MEL",
+			Message:  err.Message,
+		})
+	}
+	return diags
+}
+
+// symbols は proc と global 変数を DocumentSymbol にする
+func (d *document) symbols() []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, p := range d.procs {
+		symbols = append(symbols, DocumentSymbol{
+			Name:           p.stmt.Name.Literal,
+			Detail:         signature(p.stmt, p.global),
+			Kind:           SymbolFunction,
+			Range:          d.nodeRange(p.stmt),
+			SelectionRange: d.tokenRange(p.stmt.Name),
+		})
+	}
+	for _, ident := range d.globals {
+		symbols = append(symbols, DocumentSymbol{
+			Name:           ident.Value,
+			Kind:           SymbolVariable,
+			Range:          d.tokenRange(ident.Token),
+			SelectionRange: d.tokenRange(ident.Token),
+		})
+	}
+	// エディタのアウトラインはファイルの順に並べる
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := symbols[i].SelectionRange.Start, symbols[j].SelectionRange.Start
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Character < b.Character
+	})
+	return symbols
+}
+
+// lookupProc は document の中の proc を名前で探す
+func (d *document) lookupProc(name string) *proc {
+	for i := range d.procs {
+		if d.procs[i].stmt.Name.Literal == name {
+			return &d.procs[i]
+		}
+	}
+	return nil
+}
+
+// symbol は offset の位置にある proc の名前か変数
+type symbol struct {
+	tok token.Token
+	ref *resolver.Reference // 変数の時
+}
+
+func (s *symbol) isProc() bool {
+	return s.tok.Type == token.ProcIdent
+}
+
+// symbolAt は offset の位置にある名前を探す
+func (d *document) symbolAt(offset int) *symbol {
+	contains := func(tok token.Token) bool {
+		return tok.Offset <= offset && offset <= tok.Offset+len(tok.Literal)
+	}
+	for i := range d.refs {
+		if contains(d.refs[i].Ident.Token) {
+			return &symbol{tok: d.refs[i].Ident.Token, ref: &d.refs[i]}
+		}
+	}
+	for _, ident := range d.calls {
+		if contains(ident.Token) {
+			return &symbol{tok: ident.Token}
+		}
+	}
+	for _, p := range d.procs {
+		if contains(p.stmt.Name) {
+			return &symbol{tok: p.stmt.Name}
+		}
+	}
+	// 宣言されていない変数は global 変数として探す
+	l := lexer.New(d.text)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.Ident && contains(tok) {
+			return &symbol{tok: tok}
+		}
+	}
+	return nil
+}
+
+// signature は proc の宣言を本体無しで書く
+func signature(ps *ast.ProcStatement, global bool) string {
+	var out bytes.Buffer
+	if global {
+		out.WriteString("global ")
+	}
+	out.WriteString("proc ")
+	if ps.ReturnType != nil {
+		out.WriteString(ps.ReturnType.String() + " ")
+	}
+	out.WriteString(ps.Name.Literal)
+
+	var params []string
+	for i, param := range ps.Parameters {
+		if i < len(ps.ParamTypes) && ps.ParamTypes[i] != nil {
+			params = append(params, ps.ParamTypes[i].String()+" "+param.String())
+			continue
+		}
+		params = append(params, param.String())
+	}
+	out.WriteString("(" + strings.Join(params, ", ") + ")")
+	return out.String()
+}
+
+// hover は proc の宣言とドキュメントコメント
+func hover(ps *ast.ProcStatement, global bool) string {
+	text := "```mel\n" + signature(ps, global) + "\n```"
+	if doc := ps.Leading.Text(); doc != "" {
+		text += "\n\n" + doc
+	}
+	return text
+}
+
+// collectCalls は node の中で呼び出している proc の名前を集める
+func collectCalls(node ast.Node, calls *[]*ast.Identifier) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpression); ok && call.Function != nil && call.Function.Token.Type == token.ProcIdent {
+			*calls = append(*calls, call.Function)
+		}
+		return true
+	})
+}
+
+// isMEL reports whether the path is a .mel file
+func isMEL(path string) bool {
+	return strings.HasSuffix(path, ".mel")
+}
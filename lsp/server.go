@@ -0,0 +1,434 @@
+// Package lsp implements a Language Server Protocol server for MEL.
+//
+// The server publishes syntax errors as diagnostics and provides document
+// symbols, go-to-definition, hover and find-references for procs and
+// variables. Definitions are looked up in the opened documents and in the
+// .mel files of the workspace folders.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Server is a language server that talks JSON-RPC over a stream
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	docs      map[string]*document // エディタで開いているファイル
+	workspace map[string]*document // workspace にある .mel ファイル
+	shutdown  bool
+}
+
+// NewServer make Server instance that reads requests from in and writes responses to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		docs:      map[string]*document{},
+		workspace: map[string]*document{},
+	}
+}
+
+// Serve handles requests until the exit notification or the end of input.
+// It returns an error if the client exits without shutdown.
+func (s *Server) Serve() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.reply(nil, nil, &ResponseError{Code: ParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("lsp: exit without shutdown")
+			}
+			return nil
+		}
+
+		result, err := s.handle(req.Method, req.Params)
+		if req.ID == nil {
+			// notification には返事をしない
+			continue
+		}
+		if err := s.reply(req.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, err error) error {
+	res := response{JSONRPC: "2.0", ID: id}
+	if err != nil {
+		rerr, ok := err.(*ResponseError)
+		if !ok {
+			rerr = &ResponseError{Code: InternalError, Message: err.Error()}
+		}
+		res.Error = rerr
+		return writeMessage(s.out, res)
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	res.Result = raw
+	return writeMessage(s.out, res)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return writeMessage(s.out, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) handle(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		var p InitializeParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.initialize(&p), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.open(p.TextDocument.URI, p.TextDocument.Text)
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		if len(p.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// 全文同期なので最後の変更が新しい全文
+		return nil, s.open(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.close(p.TextDocument.URI)
+	case "textDocument/didSave":
+		return nil, nil
+
+	case "textDocument/documentSymbol":
+		var p DocumentSymbolParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		d := s.document(p.TextDocument.URI)
+		if d == nil {
+			return nil, nil
+		}
+		return d.symbols(), nil
+	case "textDocument/definition":
+		var p TextDocumentPositionParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.definition(&p), nil
+	case "textDocument/hover":
+		var p TextDocumentPositionParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.hover(&p), nil
+	case "textDocument/references":
+		var p ReferenceParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.references(&p), nil
+	}
+	return nil, &ResponseError{Code: MethodNotFound, Message: "method not found: " + method}
+}
+
+func unmarshalParams(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ResponseError{Code: InvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(p *InitializeParams) *InitializeResult {
+	var roots []string
+	for _, folder := range p.WorkspaceFolders {
+		roots = append(roots, uriToPath(folder.URI))
+	}
+	if len(roots) == 0 && p.RootURI != "" {
+		roots = append(roots, uriToPath(p.RootURI))
+	}
+	if len(roots) == 0 && p.RootPath != "" {
+		roots = append(roots, p.RootPath)
+	}
+	for _, root := range roots {
+		s.loadWorkspace(root)
+	}
+
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:       SyncFull,
+			DocumentSymbolProvider: true,
+			DefinitionProvider:     true,
+			HoverProvider:          true,
+			ReferencesProvider:     true,
+		},
+		ServerInfo: &ServerInfo{Name: "go-MEL"},
+	}
+}
+
+// loadWorkspace は root 以下の .mel ファイルを読む. 読めないファイルは飛ばす
+func (s *Server) loadWorkspace(root string) {
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !isMEL(path) {
+			return nil
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		uri := pathToURI(path)
+		s.workspace[uri] = newDocument(uri, string(src))
+		return nil
+	})
+}
+
+func (s *Server) open(uri, text string) error {
+	uri = normalizeURI(uri)
+	d := newDocument(uri, text)
+	s.docs[uri] = d
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: d.diagnostics(),
+	})
+}
+
+func (s *Server) close(uri string) error {
+	uri = normalizeURI(uri)
+	delete(s.docs, uri)
+	// 保存されたかもしれないので読み直す
+	if _, ok := s.workspace[uri]; ok {
+		src, err := ioutil.ReadFile(uriToPath(uri))
+		if err == nil {
+			s.workspace[uri] = newDocument(uri, string(src))
+		} else {
+			delete(s.workspace, uri)
+		}
+	}
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// document は開いているファイルか workspace のファイルを返す
+func (s *Server) document(uri string) *document {
+	uri = normalizeURI(uri)
+	if d, ok := s.docs[uri]; ok {
+		return d
+	}
+	return s.workspace[uri]
+}
+
+// documents はすべてのファイルを URI の順に返す. 開いているファイルが優先される
+func (s *Server) documents() []*document {
+	var docs []*document
+	for _, d := range s.docs {
+		docs = append(docs, d)
+	}
+	for uri, d := range s.workspace {
+		if _, ok := s.docs[uri]; !ok {
+			docs = append(docs, d)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].uri < docs[j].uri })
+	return docs
+}
+
+func (s *Server) symbolAt(p *TextDocumentPositionParams) (*document, *symbol) {
+	d := s.document(p.TextDocument.URI)
+	if d == nil {
+		return nil, nil
+	}
+	return d, d.symbolAt(d.offset(p.Position))
+}
+
+// procDefinition は d から呼び出した proc の定義を探す.
+// 同じファイルの proc が優先され, 無ければ他のファイルの global proc を探す.
+func (s *Server) procDefinition(d *document, name string) (*document, *proc) {
+	if p := d.lookupProc(name); p != nil {
+		return d, p
+	}
+	for _, other := range s.documents() {
+		if other == d {
+			continue
+		}
+		if p := other.lookupProc(name); p != nil && p.global {
+			return other, p
+		}
+	}
+	return nil, nil
+}
+
+// globalDefinitions は global 変数の一番外側の宣言をすべてのファイルから探す
+func (s *Server) globalDefinitions(name string) []Location {
+	locations := []Location{}
+	for _, d := range s.documents() {
+		for _, ident := range d.globals {
+			if ident.Value == name {
+				locations = append(locations, d.location(ident.Token))
+			}
+		}
+	}
+	return locations
+}
+
+func (s *Server) definition(p *TextDocumentPositionParams) []Location {
+	d, sym := s.symbolAt(p)
+	if sym == nil {
+		return nil
+	}
+	if sym.isProc() {
+		pd, proc := s.procDefinition(d, sym.tok.Literal)
+		if proc == nil {
+			return nil
+		}
+		return []Location{pd.location(proc.stmt.Name)}
+	}
+	if sym.ref != nil && !sym.ref.Global {
+		return []Location{d.location(sym.ref.Decl)}
+	}
+	return s.globalDefinitions(sym.tok.Literal)
+}
+
+func (s *Server) hover(p *TextDocumentPositionParams) *Hover {
+	d, sym := s.symbolAt(p)
+	if sym == nil || !sym.isProc() {
+		return nil
+	}
+	_, proc := s.procDefinition(d, sym.tok.Literal)
+	if proc == nil {
+		return nil
+	}
+	r := d.tokenRange(sym.tok)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: hover(proc.stmt, proc.global)},
+		Range:    &r,
+	}
+}
+
+func (s *Server) references(p *ReferenceParams) []Location {
+	d, sym := s.symbolAt(&p.TextDocumentPositionParams)
+	if sym == nil {
+		return nil
+	}
+	decl := p.Context.IncludeDeclaration
+	locations := []Location{}
+
+	if sym.isProc() {
+		pd, proc := s.procDefinition(d, sym.tok.Literal)
+		if proc == nil {
+			return locations
+		}
+		if decl {
+			locations = append(locations, pd.location(proc.stmt.Name))
+		}
+		for _, other := range s.documents() {
+			for _, call := range other.calls {
+				if call.Value != sym.tok.Literal {
+					continue
+				}
+				// 同じ名前でも別の proc を呼んでいることがある
+				if _, p := s.procDefinition(other, call.Value); p == proc {
+					locations = append(locations, other.location(call.Token))
+				}
+			}
+		}
+		return locations
+	}
+
+	if sym.ref != nil && !sym.ref.Global {
+		for _, ref := range d.refs {
+			if ref.Global || ref.Decl.Offset != sym.ref.Decl.Offset || ref.Ident.Value != sym.tok.Literal {
+				continue
+			}
+			if !decl && ref.Ident.Token.Offset == ref.Decl.Offset {
+				continue
+			}
+			locations = append(locations, d.location(ref.Ident.Token))
+		}
+		return locations
+	}
+
+	for _, other := range s.documents() {
+		for _, ref := range other.refs {
+			if !ref.Global || ref.Ident.Value != sym.tok.Literal {
+				continue
+			}
+			if !decl && ref.Decl.Type != "" && ref.Ident.Token.Offset == ref.Decl.Offset {
+				continue
+			}
+			locations = append(locations, other.location(ref.Ident.Token))
+		}
+	}
+	return locations
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	// file:///C:/scripts のドライブ名
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// normalizeURI はエディタと workspace で URI の書き方が違っても同じファイルにする
+func normalizeURI(uri string) string {
+	if !strings.HasPrefix(uri, "file:") {
+		return uri
+	}
+	return pathToURI(uriToPath(uri))
+}
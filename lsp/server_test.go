@@ -0,0 +1,341 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// message はサーバーから届いた response か notification
+type message struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *ResponseError  `json:"error"`
+}
+
+// client は JSON-RPC でサーバーと話すテスト用のクライアント
+type client struct {
+	t        *testing.T
+	w        io.WriteCloser
+	messages chan *message
+	notified []*message
+	id       int
+	done     chan error
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, messages: make(chan *message, 16), done: make(chan error, 1)}
+
+	go func() {
+		err := NewServer(inR, outW).Serve()
+		outW.Close()
+		c.done <- err
+	}()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			body, err := readMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var m message
+			if err := json.Unmarshal(body, &m); err != nil {
+				t.Errorf("invalid message %s: %s", body, err)
+				continue
+			}
+			c.messages <- &m
+		}
+	}()
+	return c
+}
+
+func (c *client) send(v interface{}) {
+	if err := writeMessage(c.w, v); err != nil {
+		c.t.Fatalf("write failed: %s", err)
+	}
+}
+
+func (c *client) next() *message {
+	select {
+	case m, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("server closed the connection")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timeout")
+	}
+	return nil
+}
+
+// call は request を送って返事を result に読む
+func (c *client) call(method string, params, result interface{}) *ResponseError {
+	c.id++
+	id := json.RawMessage(json.RawMessage(mustMarshal(c.t, c.id)))
+	c.send(request{JSONRPC: "2.0", ID: &id, Method: method, Params: mustMarshal(c.t, params)})
+	for {
+		m := c.next()
+		if m.ID == nil {
+			c.notified = append(c.notified, m)
+			continue
+		}
+		if *m.ID != c.id {
+			c.t.Fatalf("wrong response id. want=%d, got=%d", c.id, *m.ID)
+		}
+		if m.Error != nil {
+			return m.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(m.Result, result); err != nil {
+				c.t.Fatalf("invalid result of %s %s: %s", method, m.Result, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.send(request{JSONRPC: "2.0", Method: method, Params: mustMarshal(c.t, params)})
+}
+
+// diagnostics は publishDiagnostics の notification を待つ
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	var m *message
+	if len(c.notified) > 0 {
+		m, c.notified = c.notified[0], c.notified[1:]
+	} else {
+		m = c.next()
+	}
+	if m.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("wrong notification. want=textDocument/publishDiagnostics, got=%q", m.Method)
+	}
+	var p PublishDiagnosticsParams
+	if err := json.Unmarshal(m.Params, &p); err != nil {
+		c.t.Fatalf("invalid params %s: %s", m.Params, err)
+	}
+	return p
+}
+
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+const testLib = `// Return the names of the selected nodes.
+// Shapes are skipped.
+global proc string[] selectedNames(int $long) {
+	string $names[] = ` + "`ls -sl`" + `;
+	return $names;
+}
+
+global string $gLastName;
+
+proc helper() {}
+`
+
+const testMain = `global string $gLastName;
+
+proc run() {
+	global string $gLastName;
+	string $names[] = selectedNames(1);
+	for ($n in $names) {
+		$gLastName = $n;
+	}
+	helper;
+}
+run();
+`
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	libPath := filepath.Join(dir, "lib.mel")
+	mainPath := filepath.Join(dir, "main.mel")
+	for path, src := range map[string]string{libPath: testLib, mainPath: testMain} {
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	libURI, mainURI := pathToURI(libPath), pathToURI(mainPath)
+	loc := func(uri string, line, start, end int) Location {
+		return Location{URI: uri, Range: Range{Start: Position{line, start}, End: Position{line, end}}}
+	}
+	at := func(uri string, line, character int) TextDocumentPositionParams {
+		return TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{line, character},
+		}
+	}
+
+	c := newClient(t)
+
+	var init InitializeResult
+	if err := c.call("initialize", InitializeParams{RootURI: pathToURI(dir)}, &init); err != nil {
+		t.Fatalf("initialize failed: %s", err)
+	}
+	if !init.Capabilities.DefinitionProvider || init.Capabilities.TextDocumentSync != SyncFull {
+		t.Errorf("wrong capabilities: %+v", init.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+
+	// 開いたファイルの構文エラー
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: mainURI, LanguageID: "mel", Version: 1, Text: testMain + "int $x = ;\n"},
+	})
+	diags := c.diagnostics()
+	if diags.URI != mainURI || len(diags.Diagnostics) != 1 {
+		t.Fatalf("wrong diagnostics: %+v", diags)
+	}
+	if d := diags.Diagnostics[0]; d.Range.Start != (Position{11, 9}) || d.Severity != SeverityError {
+		t.Errorf("wrong diagnostic: %+v", d)
+	}
+
+	// 直したらエラーは消える
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: mainURI, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: testMain}},
+	})
+	if diags := c.diagnostics(); len(diags.Diagnostics) != 0 {
+		t.Errorf("diagnostics should be empty: %+v", diags)
+	}
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: libURI}}, &symbols)
+	var names []string
+	for _, s := range symbols {
+		names = append(names, s.Name)
+	}
+	if want := []string{"selectedNames", "$gLastName", "helper"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("wrong symbols. want=%v, got=%v", want, names)
+	}
+	if len(symbols) == 3 && symbols[0].Detail != "global proc string[] selectedNames(int $long)" {
+		t.Errorf("wrong detail: %q", symbols[0].Detail)
+	}
+
+	definitions := []struct {
+		params   TextDocumentPositionParams
+		expected []Location
+	}{
+		// 別のファイルの global proc
+		{at(mainURI, 4, 22), []Location{loc(libURI, 2, 21, 34)}},
+		// 同じファイルの proc
+		{at(mainURI, 10, 0), []Location{loc(mainURI, 2, 5, 8)}},
+		{at(mainURI, 2, 6), []Location{loc(mainURI, 2, 5, 8)}},
+		// ローカル変数
+		{at(mainURI, 5, 13), []Location{loc(mainURI, 4, 8, 14)}},
+		// global 変数はすべてのファイルの一番外側の宣言
+		{at(mainURI, 6, 4), []Location{loc(libURI, 7, 14, 24), loc(mainURI, 0, 14, 24)}},
+		// helper は lib.mel のローカル proc なので見えない
+		{at(mainURI, 8, 2), nil},
+	}
+	for _, tt := range definitions {
+		var got []Location
+		c.call("textDocument/definition", tt.params, &got)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("definition at %+v wrong.\nwant=%+v\ngot=%+v", tt.params.Position, tt.expected, got)
+		}
+	}
+
+	var h Hover
+	c.call("textDocument/hover", at(mainURI, 4, 22), &h)
+	want := "```mel\nglobal proc string[] selectedNames(int $long)\n```\n\nReturn the names of the selected nodes.\nShapes are skipped."
+	if h.Contents.Value != want {
+		t.Errorf("wrong hover.\nwant=%q\ngot=%q", want, h.Contents.Value)
+	}
+
+	references := []struct {
+		params   ReferenceParams
+		expected []Location
+	}{
+		{
+			ReferenceParams{at(libURI, 2, 25), ReferenceContext{IncludeDeclaration: true}},
+			[]Location{loc(libURI, 2, 21, 34), loc(mainURI, 4, 19, 32)},
+		},
+		{
+			ReferenceParams{at(mainURI, 4, 10), ReferenceContext{IncludeDeclaration: false}},
+			[]Location{loc(mainURI, 5, 12, 18)},
+		},
+		{
+			ReferenceParams{at(mainURI, 6, 4), ReferenceContext{IncludeDeclaration: true}},
+			[]Location{loc(libURI, 7, 14, 24), loc(mainURI, 0, 14, 24), loc(mainURI, 3, 15, 25), loc(mainURI, 6, 2, 12)},
+		},
+		{
+			ReferenceParams{at(mainURI, 6, 4), ReferenceContext{IncludeDeclaration: false}},
+			[]Location{loc(mainURI, 6, 2, 12)},
+		},
+	}
+	for _, tt := range references {
+		var got []Location
+		c.call("textDocument/references", tt.params, &got)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("references at %+v wrong.\nwant=%+v\ngot=%+v", tt.params.Position, tt.expected, got)
+		}
+	}
+
+	if err := c.call("unknown/method", struct{}{}, nil); err == nil || err.Code != MethodNotFound {
+		t.Errorf("unknown method should return MethodNotFound. got=%v", err)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: mainURI}})
+	if diags := c.diagnostics(); diags.URI != mainURI || len(diags.Diagnostics) != 0 {
+		t.Errorf("diagnostics should be cleared: %+v", diags)
+	}
+
+	if err := c.call("shutdown", nil, nil); err != nil {
+		t.Fatalf("shutdown failed: %s", err)
+	}
+	c.notify("exit", nil)
+	c.w.Close()
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve returned error: %s", err)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := newClient(t)
+	c.notify("exit", nil)
+	c.w.Close()
+	if err := <-c.done; err == nil {
+		t.Errorf("Serve should return error")
+	}
+}
+
+func TestDocumentPosition(t *testing.T) {
+	d := newDocument("file:///a.mel", "print \"あ😀\";\n$a = 1;")
+	tests := []struct {
+		offset   int
+		position Position
+	}{
+		{0, Position{0, 0}},
+		{7, Position{0, 7}},
+		{10, Position{0, 8}},
+		{14, Position{0, 10}},
+		{17, Position{1, 0}},
+		{19, Position{1, 2}},
+	}
+	for _, tt := range tests {
+		if got := d.position(tt.offset); got != tt.position {
+			t.Errorf("position(%d) wrong. want=%+v, got=%+v", tt.offset, tt.position, got)
+		}
+		if got := d.offset(tt.position); got != tt.offset {
+			t.Errorf("offset(%+v) wrong. want=%d, got=%d", tt.position, tt.offset, got)
+		}
+	}
+}
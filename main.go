@@ -7,18 +7,41 @@ import (
 	"log"
 	"os"
 	"os/user"
-	"path/filepath"
 	"regexp"
 
 	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/mayaascii"
 	"github.com/nrtkbb/go-MEL/parser"
 	"github.com/nrtkbb/go-MEL/repl"
+	"github.com/nrtkbb/go-MEL/workspace"
 )
 
-var mel = regexp.MustCompile(`.mel$`)
+var mel = regexp.MustCompile(`\.mel$`)
+var ma = regexp.MustCompile(`\.ma$`)
 
 func main() {
 	flag.Parse()
+	if flag.Arg(0) == "fmt" {
+		os.Exit(runFmt(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "parse" {
+		os.Exit(runParse(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "tokens" {
+		os.Exit(runTokens(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "scan" {
+		os.Exit(runScan(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "calls" {
+		os.Exit(runCalls(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "deps" {
+		os.Exit(runDeps(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "serve" {
+		os.Exit(runServe(flag.Args()[1:]))
+	}
 	if len(flag.Args()) == 0 {
 		// REPL mode
 		usr, err := user.Current()
@@ -41,11 +64,18 @@ func main() {
 		}
 
 		if stat.IsDir() {
-			err = readDir(fp)
+			err = readDir(fp, readFile)
 			log.Println(err)
 			continue
 		}
 
+		if ma.MatchString(fp) {
+			if err := readMayaASCII(fp); err != nil {
+				log.Println(err)
+			}
+			continue
+		}
+
 		if !mel.MatchString(fp) {
 			continue
 		}
@@ -54,24 +84,9 @@ func main() {
 	}
 }
 
-func readDir(dir string) error {
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if info.IsDir() {
-			return nil
-		}
-
-		if !mel.MatchString(path) {
-			return nil
-		}
-
-		err = readFile(path)
-		if err != nil {
-			return err
-		}
-
-		return nil
-	})
-	return err
+// readDir は dir 以下にある .mel ファイルを順に fn に渡す
+func readDir(dir string, fn func(path string) error) error {
+	return workspace.WalkFiles(dir, mel, fn)
 }
 
 func readFile(file string) error {
@@ -88,3 +103,25 @@ func readFile(file string) error {
 
 	return nil
 }
+
+// readMayaASCII は .ma ファイルの node を "type name parent" の形で表示する
+func readMayaASCII(file string) error {
+	fmt.Println(file)
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scene, err := mayaascii.Parse(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", file, err)
+	}
+	for _, n := range scene.Nodes {
+		fmt.Printf("%s\t%s\t%s\n", n.Type, n.Name, n.Parent)
+	}
+	for _, err := range scene.Errors {
+		log.Printf("%s: %s", file, err)
+	}
+	return nil
+}
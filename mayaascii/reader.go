@@ -0,0 +1,309 @@
+// Package mayaascii reads Maya ASCII (.ma) scene files.
+//
+// A .ma file is a stream of MEL commands. The Reader splits the stream into
+// commands one at a time and tokenizes each of them with the MEL lexer, so a
+// large scene is never held in memory as a whole. Parse builds a Scene from
+// the commands.
+package mayaascii
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Error is an error in a .ma file
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line:%d.%d %s", e.Line, e.Column, e.Message)
+}
+
+// ArgKind is the kind of a command argument
+type ArgKind int
+
+// Kinds of Arg
+const (
+	WordArg   ArgKind = iota // transform, :time1, yes, ...
+	FlagArg                  // -type, -n, ...
+	StringArg                // "pCube1"
+	NumberArg                // 1, -2.5, 1e-3, ...
+)
+
+// Arg is an argument of a command
+type Arg struct {
+	Kind   ArgKind
+	Text   string // StringArg は引用符を外してエスケープを戻した値
+	Line   int
+	Column int
+}
+
+// Command is a MEL command in a .ma file
+type Command struct {
+	Name     string
+	Args     []Arg
+	Line     int
+	Column   int
+	Comments []string // コマンドの前にあるコメント. 前のコマンドの行の後ろのコメントは含まない
+}
+
+// Reader reads commands from a .ma file
+type Reader struct {
+	r      *bufio.Reader
+	line   int // 次に読む文字の行
+	column int // 次に読む文字の列
+	buf    bytes.Buffer
+}
+
+// NewReader make Reader instance.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), line: 1, column: 1}
+}
+
+// Next returns the next command. It returns io.EOF at the end of the file.
+func (r *Reader) Next() (*Command, error) {
+	for {
+		line, column, err := r.readStatement()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if r.buf.Len() == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
+
+		cmd, cerr := r.command(r.buf.String(), line, column)
+		if cerr != nil {
+			return nil, cerr
+		}
+		if cmd != nil {
+			return cmd, nil
+		}
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		// 空の文 ";" は飛ばす
+	}
+}
+
+// readStatement は文字列とコメントの外にある ';' までを buf に読む.
+// 文の最初の行と列を返す.
+func (r *Reader) readStatement() (int, int, error) {
+	r.buf.Reset()
+	start, startColumn := r.line, r.column
+
+	const (
+		normal = iota
+		inString
+		inLineComment
+		inBlockComment
+	)
+	state := normal
+	var prev rune
+	var open Error // 閉じていない文字列かコメントの位置
+	for {
+		c, _, err := r.r.ReadRune()
+		if err != nil {
+			if err == io.EOF && state == inString {
+				open.Message = "string literal not terminated"
+				return start, startColumn, &open
+			}
+			if err == io.EOF && state == inBlockComment {
+				open.Message = "comment not terminated"
+				return start, startColumn, &open
+			}
+			return start, startColumn, err
+		}
+		r.buf.WriteRune(c)
+		line, column := r.line, r.column
+		if c == '\n' {
+			r.line++
+			r.column = 1
+		} else {
+			r.column++
+		}
+
+		switch state {
+		case normal:
+			switch {
+			case c == ';':
+				return start, startColumn, nil
+			case c == '"':
+				state = inString
+				open = Error{Line: line, Column: column}
+			case c == '/' && prev == '/':
+				state = inLineComment
+			case c == '*' && prev == '/':
+				state = inBlockComment
+				open = Error{Line: line, Column: column - 1}
+				c = 0 // "/*/" を閉じたことにしない
+			}
+		case inString:
+			if c == '\\' && prev == '\\' {
+				c = 0 // "\\" の後の '"' は文字列を閉じる
+			} else if c == '"' && prev != '\\' {
+				state = normal
+			}
+		case inLineComment:
+			if c == '\n' {
+				state = normal
+			}
+		case inBlockComment:
+			if c == '/' && prev == '*' {
+				state = normal
+				c = 0
+			}
+		}
+		prev = c
+	}
+}
+
+// command は一つの文を token に分けて Command にする. 空の文は nil になる.
+// src は line 行の column 列から始まる
+func (r *Reader) command(src string, line, column int) (*Command, error) {
+	var toks []token.Token
+	var comments []string
+	var trailing []string // 前の ';' と同じ行のコメント
+	l := lexer.NewWithMode(src, lexer.ScanComments)
+	for tok := l.NextToken(); tok.Type != token.EOF && tok.Type != token.Semicolon; tok = l.NextToken() {
+		if tok.Row == 1 {
+			tok.Column += column - 1
+		} else if len(toks) == 0 && trailing != nil {
+			// 前のコマンドの後ろのコメントだったので捨てる
+			trailing = nil
+		}
+		tok.Row += line - 1
+		if tok.Type == token.Comment {
+			switch {
+			case len(toks) != 0:
+			case tok.Row == line && column > 1:
+				trailing = append(trailing, tok.Literal)
+			default:
+				comments = append(comments, tok.Literal)
+			}
+			continue
+		}
+		if len(toks) == 0 {
+			// 同じ行でコマンドが続く時はその前のコメント
+			comments = append(trailing, comments...)
+		}
+		toks = append(toks, tok)
+	}
+	if len(toks) == 0 {
+		return nil, nil
+	}
+
+	first := toks[0]
+	if first.Type != token.ProcIdent {
+		return nil, &Error{Line: first.Row, Column: first.Column,
+			Message: fmt.Sprintf("expected a command, got %q", first.Literal)}
+	}
+	cmd := &Command{Name: first.Literal, Line: first.Row, Column: first.Column, Comments: comments}
+
+	for i := 1; i < len(toks); {
+		// 長い文字列は ("..." + "...") と分けて書かれる
+		if arg, n, err := concatenation(toks[i:]); n > 0 {
+			if err != nil {
+				return nil, err
+			}
+			cmd.Args = append(cmd.Args, arg)
+			i += n
+			continue
+		}
+
+		// 空白を挟まずに続く token は一つの引数. ex) -2, :time1, 1.#INF
+		j := i + 1
+		if toks[i].Type != token.String {
+			for j < len(toks) && toks[j].Type != token.String &&
+				toks[j].Offset == toks[j-1].Offset+len(toks[j-1].Literal) {
+				j++
+			}
+		}
+		arg, err := newArg(toks[i:j])
+		if err != nil {
+			return nil, err
+		}
+		cmd.Args = append(cmd.Args, arg)
+		i = j
+	}
+	return cmd, nil
+}
+
+// concatenation は ("a" + "b") を一つの文字列の引数にする.
+// toks が "(" と文字列で始まらない時は 0 を返す.
+func concatenation(toks []token.Token) (Arg, int, error) {
+	if len(toks) < 2 || toks[0].Type != token.Lparen || toks[1].Type != token.String {
+		return Arg{}, 0, nil
+	}
+	arg := Arg{Kind: StringArg, Line: toks[1].Row, Column: toks[1].Column}
+	var text strings.Builder
+	for i := 1; i < len(toks); i += 2 {
+		if toks[i].Type != token.String {
+			break
+		}
+		s, err := lexer.Unquote(toks[i].Literal)
+		if err == lexer.ErrUnterminatedString {
+			return arg, i, &Error{Line: toks[i].Row, Column: toks[i].Column, Message: err.Error()}
+		}
+		text.WriteString(s)
+
+		if i+1 < len(toks) && toks[i+1].Type == token.Rparen {
+			arg.Text = text.String()
+			return arg, i + 2, nil
+		}
+		if i+1 == len(toks) || toks[i+1].Type != token.Plus {
+			break
+		}
+	}
+	return arg, 1, &Error{Line: toks[0].Row, Column: toks[0].Column, Message: "invalid string concatenation"}
+}
+
+func newArg(toks []token.Token) (Arg, error) {
+	first := toks[0]
+	arg := Arg{Line: first.Row, Column: first.Column}
+	if first.Type == token.String {
+		// 知らないエスケープは Maya と同じようにその文字にする
+		text, err := lexer.Unquote(first.Literal)
+		if err == lexer.ErrUnterminatedString {
+			return arg, &Error{Line: first.Row, Column: first.Column, Message: err.Error()}
+		}
+		arg.Kind, arg.Text = StringArg, text
+		return arg, nil
+	}
+
+	var text strings.Builder
+	for _, tok := range toks {
+		text.WriteString(tok.Literal)
+	}
+	arg.Text = text.String()
+	switch {
+	case isNumber(arg.Text):
+		arg.Kind = NumberArg
+	case len(toks) == 1 && first.Type == token.Flag:
+		arg.Kind = FlagArg
+	default:
+		arg.Kind = WordArg
+	}
+	return arg, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		return true
+	}
+	// 範囲外の数も数として扱う
+	if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+		return true
+	}
+	return false
+}
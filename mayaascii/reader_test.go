@@ -0,0 +1,151 @@
+package mayaascii
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	input := "//Maya ASCII 2018 scene\n" +
+		"requires maya \"2018\";\n" +
+		"createNode transform -n \"a;b\"; // trailing ;\n" +
+		"/* block ; */ setAttr \".t\" -type \"double3\" 1 -2 3e-2 ;\n" +
+		"select -ne :time1;;\n" +
+		"setAttr \".s\" -type \"string\" \"x\\\"y\\\\\\d\";\n" +
+		"setAttr \".v\" 1.#INF"
+
+	tests := []struct {
+		name     string
+		line     int
+		args     []Arg
+		comments []string
+	}{
+		{"requires", 2, []Arg{
+			{WordArg, "maya", 2, 10},
+			{StringArg, "2018", 2, 15},
+		}, []string{"//Maya ASCII 2018 scene"}},
+		{"createNode", 3, []Arg{
+			{WordArg, "transform", 3, 12},
+			{FlagArg, "-n", 3, 22},
+			{StringArg, "a;b", 3, 25},
+		}, nil},
+		{"setAttr", 4, []Arg{
+			{StringArg, ".t", 4, 23},
+			{FlagArg, "-type", 4, 28},
+			{StringArg, "double3", 4, 34},
+			{NumberArg, "1", 4, 44},
+			{NumberArg, "-2", 4, 46},
+			{NumberArg, "3e-2", 4, 49},
+		}, []string{"/* block ; */"}},
+		{"select", 5, []Arg{
+			{FlagArg, "-ne", 5, 8},
+			{WordArg, ":time1", 5, 12},
+		}, nil},
+		{"setAttr", 6, []Arg{
+			{StringArg, ".s", 6, 9},
+			{FlagArg, "-type", 6, 14},
+			{StringArg, "string", 6, 20},
+			{StringArg, "x\"y\\d", 6, 29},
+		}, nil},
+		{"setAttr", 7, []Arg{
+			{StringArg, ".v", 7, 9},
+			{WordArg, "1.#INF", 7, 14},
+		}, nil},
+	}
+
+	r := NewReader(strings.NewReader(input))
+	for i, tt := range tests {
+		cmd, err := r.Next()
+		if err != nil {
+			t.Fatalf("tests[%d] Next returned error: %s", i, err)
+		}
+		if cmd.Name != tt.name || cmd.Line != tt.line {
+			t.Errorf("tests[%d] wrong command. want=%s at %d, got=%s at %d", i, tt.name, tt.line, cmd.Name, cmd.Line)
+		}
+		if !reflect.DeepEqual(cmd.Args, tt.args) {
+			t.Errorf("tests[%d] wrong args.\nwant=%+v\ngot=%+v", i, tt.args, cmd.Args)
+		}
+		if !reflect.DeepEqual(cmd.Comments, tt.comments) {
+			t.Errorf("tests[%d] wrong comments. want=%q, got=%q", i, tt.comments, cmd.Comments)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next should return io.EOF. got=%v", err)
+	}
+}
+
+func TestReaderSameLine(t *testing.T) {
+	input := "createNode a; /* b */ setAttr \".t\" 1; // c\nsetAttr \".v\" 0;"
+	tests := []struct {
+		name     string
+		column   int
+		args     []Arg
+		comments []string
+	}{
+		{"createNode", 1, []Arg{{WordArg, "a", 1, 12}}, nil},
+		{"setAttr", 23, []Arg{{StringArg, ".t", 1, 31}, {NumberArg, "1", 1, 36}}, []string{"/* b */"}},
+		{"setAttr", 1, []Arg{{StringArg, ".v", 2, 9}, {NumberArg, "0", 2, 14}}, nil},
+	}
+
+	r := NewReader(strings.NewReader(input))
+	for i, tt := range tests {
+		cmd, err := r.Next()
+		if err != nil {
+			t.Fatalf("tests[%d] Next returned error: %s", i, err)
+		}
+		if cmd.Name != tt.name || cmd.Column != tt.column {
+			t.Errorf("tests[%d] wrong command. want=%s at %d, got=%s at %d", i, tt.name, tt.column, cmd.Name, cmd.Column)
+		}
+		if !reflect.DeepEqual(cmd.Args, tt.args) {
+			t.Errorf("tests[%d] wrong args.\nwant=%+v\ngot=%+v", i, tt.args, cmd.Args)
+		}
+		if !reflect.DeepEqual(cmd.Comments, tt.comments) {
+			t.Errorf("tests[%d] wrong comments. want=%q, got=%q", i, tt.comments, cmd.Comments)
+		}
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"createNode transform;\nsetAttr \".a\" -type \"string\" \"abc;\n", "line:2.29 string literal not terminated"},
+		{"/* open\n", "line:1.1 comment not terminated"},
+		{"createNode transform;\n$a = 1;", "line:2.1 expected a command, got \"$a\""},
+		{"createNode transform; $a = 1;", "line:1.23 expected a command, got \"$a\""},
+	}
+
+	for _, tt := range tests {
+		r := NewReader(strings.NewReader(tt.input))
+		var err error
+		for err == nil {
+			_, err = r.Next()
+		}
+		if err == io.EOF {
+			t.Errorf("input %q should return error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("input %q wrong error. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestReaderConcatenation(t *testing.T) {
+	input := "setAttr \".b\" -type \"string\" (\n\t\"a\\n\"\n\t+ \"b\") ;\nsetAttr \".c\" (\"a\" \"b\");"
+	r := NewReader(strings.NewReader(input))
+	cmd, err := r.Next()
+	if err != nil {
+		t.Fatalf("Next returned error: %s", err)
+	}
+	want := Arg{StringArg, "a\nb", 2, 2}
+	if len(cmd.Args) != 4 || cmd.Args[3] != want {
+		t.Errorf("wrong args. want=%+v as the last, got=%+v", want, cmd.Args)
+	}
+	if _, err := r.Next(); err == nil || err.Error() != "line:4.14 invalid string concatenation" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
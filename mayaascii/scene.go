@@ -0,0 +1,468 @@
+package mayaascii
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Header is the metadata at the top of a .ma file
+type Header struct {
+	Version      string // "//Maya ASCII 2018 scene" の 2018
+	Name         string // "//Name: scene.ma"
+	LastModified string // "//Last modified: ..."
+	Codeset      string // "//Codeset: UTF-8"
+
+	Requires []*Requirement
+	FileInfo []*FileInfo // fileInfo の順番を保つ
+	Units    Units
+}
+
+// Requirement is a "requires" command
+type Requirement struct {
+	Plugin    string // maya, mtoa, ...
+	Version   string
+	NodeTypes []string
+	DataTypes []string
+}
+
+// FileInfo is a "fileInfo" command
+type FileInfo struct {
+	Key   string
+	Value string
+}
+
+// Units is the "currentUnit" command
+type Units struct {
+	Linear string
+	Angle  string
+	Time   string
+}
+
+// Reference is a "file" command that references another scene
+type Reference struct {
+	Path          string
+	Namespace     string
+	ReferenceNode string
+	Type          string
+}
+
+// Node is a node created by "createNode", or an existing node selected by "select"
+type Node struct {
+	Type      string // select で選ばれただけの node では ""
+	Name      string
+	Parent    string
+	Instances []string // parent -add で追加された親
+	Shared    bool     // createNode -s
+	Created   bool     // このファイルの createNode で作られた
+	Locked    bool     // lockNode -l 1
+	UUID      string   // rename -uid
+
+	Attributes      []*Attribute
+	AddedAttributes []*AddedAttribute
+}
+
+// Attribute is a "setAttr" command
+type Attribute struct {
+	Name    string // ".t", ".uvst[0].uvsp[0:3]", ...
+	Type    string // -type の値. 無い時は ""
+	Size    int    // -s
+	Keyable *bool  // -k
+	Locked  *bool  // -l
+	Raw     []Arg  // 値の引数. Value にできた時は nil にしてメモリを空ける
+	Value   interface{}
+	Line    int
+	Column  int
+	Decoded bool // Value が値の引数から型に合わせて作られた
+}
+
+// AddedAttribute is an "addAttr" command
+type AddedAttribute struct {
+	LongName  string
+	ShortName string
+	Type      string // -at または -dt の値
+}
+
+// Connection is a "connectAttr" command
+type Connection struct {
+	Source        string
+	Destination   string
+	NextAvailable bool // -na
+	Locked        bool // -l on
+}
+
+// Scene is the model of a .ma file
+type Scene struct {
+	Header      Header
+	References  []*Reference
+	Nodes       []*Node
+	Connections []*Connection
+	Errors      []*Error // 値の解釈に失敗した setAttr など. 読み込みは続ける
+
+	nodes   map[string]*Node
+	current *Node // setAttr などの対象
+}
+
+// Node return the node with the name, or nil
+func (s *Scene) Node(name string) *Node {
+	return s.nodes[name]
+}
+
+// Parse reads a .ma file into a Scene
+func Parse(r io.Reader) (*Scene, error) {
+	scene := NewScene()
+	reader := NewReader(r)
+	for {
+		cmd, err := reader.Next()
+		if err == io.EOF {
+			return scene, nil
+		}
+		if err != nil {
+			return scene, err
+		}
+		scene.Apply(cmd)
+	}
+}
+
+// NewScene make empty Scene instance.
+func NewScene() *Scene {
+	return &Scene{nodes: map[string]*Node{}}
+}
+
+// Apply adds a command to the scene. Unknown commands are ignored.
+func (s *Scene) Apply(cmd *Command) {
+	for _, c := range cmd.Comments {
+		s.header(c)
+	}
+
+	switch cmd.Name {
+	case "requires":
+		s.requires(cmd)
+	case "fileInfo":
+		if len(cmd.Args) == 2 {
+			s.Header.FileInfo = append(s.Header.FileInfo, &FileInfo{Key: cmd.Args[0].Text, Value: cmd.Args[1].Text})
+		}
+	case "currentUnit":
+		flags, _ := parseFlags(cmd, flagArity{"l": 1, "linear": 1, "a": 1, "angle": 1, "t": 1, "time": 1})
+		s.Header.Units.Linear = first(flags, "l", "linear", s.Header.Units.Linear)
+		s.Header.Units.Angle = first(flags, "a", "angle", s.Header.Units.Angle)
+		s.Header.Units.Time = first(flags, "t", "time", s.Header.Units.Time)
+	case "file":
+		s.file(cmd)
+	case "createNode":
+		s.createNode(cmd)
+	case "select":
+		s.selectNode(cmd)
+	case "rename":
+		flags, args := parseFlags(cmd, flagArity{"uid": 1, "uuid": 1})
+		if s.current != nil {
+			s.current.UUID = first(flags, "uid", "uuid", s.current.UUID)
+		}
+		// rename "old" "new" は node の名前を変える
+		if len(args) == 2 {
+			if n := s.nodes[args[0].Text]; n != nil {
+				delete(s.nodes, n.Name)
+				n.Name = args[1].Text
+				s.nodes[n.Name] = n
+			}
+		}
+	case "lockNode":
+		flags, _ := parseFlags(cmd, flagArity{"l": 1, "lock": 1, "lu": 1, "lockUnpublished": 1})
+		if s.current != nil {
+			s.current.Locked = isTrue(first(flags, "l", "lock", "on"))
+		}
+	case "setAttr":
+		s.setAttr(cmd)
+	case "addAttr":
+		s.addAttr(cmd)
+	case "connectAttr":
+		s.connectAttr(cmd)
+	case "parent":
+		s.parent(cmd)
+	}
+}
+
+// header はファイルの先頭のコメントを読む
+func (s *Scene) header(comment string) {
+	text := strings.TrimSpace(strings.TrimPrefix(comment, "//"))
+	switch {
+	case strings.HasPrefix(text, "Maya ASCII ") && strings.HasSuffix(text, " scene"):
+		s.Header.Version = strings.TrimSuffix(strings.TrimPrefix(text, "Maya ASCII "), " scene")
+	case strings.HasPrefix(text, "Name:"):
+		s.Header.Name = strings.TrimSpace(strings.TrimPrefix(text, "Name:"))
+	case strings.HasPrefix(text, "Last modified:"):
+		s.Header.LastModified = strings.TrimSpace(strings.TrimPrefix(text, "Last modified:"))
+	case strings.HasPrefix(text, "Codeset:"):
+		s.Header.Codeset = strings.TrimSpace(strings.TrimPrefix(text, "Codeset:"))
+	}
+}
+
+func (s *Scene) requires(cmd *Command) {
+	req := &Requirement{}
+	var args []Arg
+	for i := 0; i < len(cmd.Args); i++ {
+		arg := cmd.Args[i]
+		if arg.Kind != FlagArg {
+			args = append(args, arg)
+			continue
+		}
+		if i+1 == len(cmd.Args) {
+			break
+		}
+		i++
+		switch arg.Text {
+		case "-nodeType":
+			req.NodeTypes = append(req.NodeTypes, cmd.Args[i].Text)
+		case "-dataType":
+			req.DataTypes = append(req.DataTypes, cmd.Args[i].Text)
+		}
+	}
+	if len(args) > 0 {
+		req.Plugin = args[0].Text
+	}
+	if len(args) > 1 {
+		req.Version = args[1].Text
+	}
+	s.Header.Requires = append(s.Header.Requires, req)
+}
+
+func (s *Scene) file(cmd *Command) {
+	flags, args := parseFlags(cmd, flagArity{
+		"rdi": 1, "referenceDepthInfo": 1, "ns": 1, "namespace": 1,
+		"rfn": 1, "referenceNode": 1, "typ": 1, "type": 1, "op": 1, "options": 1,
+		"dr": 1, "deferReference": 1, "r": 0, "reference": 0,
+	})
+	if len(args) == 0 {
+		return
+	}
+	s.References = append(s.References, &Reference{
+		Path:          args[len(args)-1].Text,
+		Namespace:     first(flags, "ns", "namespace", ""),
+		ReferenceNode: first(flags, "rfn", "referenceNode", ""),
+		Type:          first(flags, "typ", "type", ""),
+	})
+}
+
+func (s *Scene) createNode(cmd *Command) {
+	flags, args := parseFlags(cmd, flagArity{
+		"n": 1, "name": 1, "p": 1, "parent": 1, "s": 0, "shared": 0, "ss": 0, "skipSelect": 0,
+	})
+	if len(args) == 0 {
+		s.errorf(cmd.Line, cmd.Column, "createNode without node type")
+		return
+	}
+
+	n := &Node{
+		Type:    args[0].Text,
+		Name:    first(flags, "n", "name", ""),
+		Parent:  first(flags, "p", "parent", ""),
+		Created: true,
+	}
+	_, n.Shared = flags["s"]
+	if _, ok := flags["shared"]; ok {
+		n.Shared = true
+	}
+	s.addNode(n)
+	s.current = n
+}
+
+func (s *Scene) addNode(n *Node) {
+	s.Nodes = append(s.Nodes, n)
+	if n.Name != "" {
+		s.nodes[n.Name] = n
+	}
+}
+
+// selectNode は select -ne :time1; のように既存の node を setAttr の対象にする
+func (s *Scene) selectNode(cmd *Command) {
+	_, args := parseFlags(cmd, flagArity{"ne": 0, "noExpand": 0, "r": 0, "replace": 0, "add": 0})
+	if len(args) == 0 {
+		return
+	}
+	name := args[0].Text
+	n := s.nodes[name]
+	if n == nil {
+		n = &Node{Name: name}
+		s.addNode(n)
+	}
+	s.current = n
+}
+
+func (s *Scene) setAttr(cmd *Command) {
+	attr, err := parseAttribute(cmd)
+	if attr == nil {
+		s.Errors = append(s.Errors, err)
+		return
+	}
+	if s.current == nil {
+		s.errorf(cmd.Line, cmd.Column, "setAttr %s without node", attr.Name)
+		return
+	}
+	if err != nil {
+		s.Errors = append(s.Errors, err)
+	}
+	s.current.Attributes = append(s.current.Attributes, attr)
+}
+
+// ParseAttribute reads a "setAttr" command without a Scene. If the value can
+// not be decoded, it returns the attribute with Raw and the error.
+func ParseAttribute(cmd *Command) (*Attribute, error) {
+	attr, err := parseAttribute(cmd)
+	if err != nil {
+		return attr, err
+	}
+	return attr, nil
+}
+
+// parseAttribute は setAttr を Attribute にする. 属性の名前が無い時は nil を返す
+func parseAttribute(cmd *Command) (*Attribute, *Error) {
+	flags, args := parseFlags(cmd, flagArity{
+		"k": 1, "keyable": 1, "l": 1, "lock": 1, "cb": 1, "channelBox": 1,
+		"s": 1, "size": 1, "ch": 1, "capacityHint": 1, "type": 1, "typ": 1,
+		"ca": 1, "caching": 1, "av": 0, "alteredValue": 0, "c": 0, "clamp": 0,
+	})
+	if len(args) == 0 {
+		return nil, &Error{Line: cmd.Line, Column: cmd.Column, Message: "setAttr without attribute"}
+	}
+
+	attr := &Attribute{
+		Name:   args[0].Text,
+		Type:   first(flags, "type", "typ", ""),
+		Raw:    args[1:],
+		Line:   cmd.Line,
+		Column: cmd.Column,
+	}
+	if v, ok := flags["s"]; ok {
+		attr.Size, _ = strconv.Atoi(v)
+	}
+	if v, ok := flags["k"]; ok {
+		b := isTrue(v)
+		attr.Keyable = &b
+	}
+	if v, ok := flags["l"]; ok {
+		b := isTrue(v)
+		attr.Locked = &b
+	}
+
+	value, err := decode(attr.Type, attr.Raw)
+	if err != nil {
+		return attr, &Error{Line: cmd.Line, Column: cmd.Column, Message: fmt.Sprintf("setAttr %s: %s", attr.Name, err)}
+	}
+	attr.Value, attr.Decoded, attr.Raw = value, true, nil
+	return attr, nil
+}
+
+func (s *Scene) addAttr(cmd *Command) {
+	flags, _ := parseFlags(cmd, flagArity{
+		"ln": 1, "longName": 1, "sn": 1, "shortName": 1, "nn": 1, "niceName": 1,
+		"at": 1, "attributeType": 1, "dt": 1, "dataType": 1, "p": 1, "parent": 1,
+		"nc": 1, "numberOfChildren": 1, "min": 1, "max": 1, "smn": 1, "smx": 1,
+		"dv": 1, "defaultValue": 1, "en": 1, "enumName": 1, "uac": 0, "usedAsColor": 0,
+		"ci": 1, "cachedInternally": 1, "m": 0, "multi": 0, "im": 1, "indexMatters": 1,
+		"h": 1, "hidden": 1, "k": 1, "keyable": 1, "r": 1, "readable": 1, "w": 1, "writable": 1,
+		"s": 1, "storable": 1, "uaf": 0, "usedAsFilename": 0, "hnv": 1, "hasMinValue": 1,
+		"hxv": 1, "hasMaxValue": 1, "hsn": 1, "hasSoftMinValue": 1, "hsx": 1, "hasSoftMaxValue": 1,
+	})
+	if s.current == nil {
+		s.errorf(cmd.Line, cmd.Column, "addAttr without node")
+		return
+	}
+	typ := first(flags, "at", "attributeType", "")
+	if typ == "" {
+		typ = first(flags, "dt", "dataType", "")
+	}
+	s.current.AddedAttributes = append(s.current.AddedAttributes, &AddedAttribute{
+		LongName:  first(flags, "ln", "longName", ""),
+		ShortName: first(flags, "sn", "shortName", ""),
+		Type:      typ,
+	})
+}
+
+func (s *Scene) connectAttr(cmd *Command) {
+	flags, args := parseFlags(cmd, flagArity{"na": 0, "nextAvailable": 0, "f": 0, "force": 0, "l": 1, "lock": 1})
+	if len(args) != 2 {
+		s.errorf(cmd.Line, cmd.Column, "connectAttr needs 2 attributes, got %d", len(args))
+		return
+	}
+	c := &Connection{Source: args[0].Text, Destination: args[1].Text}
+	_, c.NextAvailable = flags["na"]
+	if _, ok := flags["nextAvailable"]; ok {
+		c.NextAvailable = true
+	}
+	c.Locked = isTrue(first(flags, "l", "lock", "off"))
+	s.Connections = append(s.Connections, c)
+}
+
+// parent は parent -s -nc -r -add "child" "parent"; を読む
+func (s *Scene) parent(cmd *Command) {
+	flags, args := parseFlags(cmd, flagArity{
+		"s": 0, "shape": 0, "nc": 0, "noConnections": 0, "r": 0, "relative": 0,
+		"a": 0, "absolute": 0, "add": 0, "addObject": 0, "w": 0, "world": 0,
+	})
+	if len(args) < 2 {
+		return
+	}
+	parent := args[len(args)-1].Text
+	_, add := flags["add"]
+	if _, ok := flags["addObject"]; ok {
+		add = true
+	}
+	for _, arg := range args[:len(args)-1] {
+		n := s.nodes[arg.Text]
+		if n == nil {
+			continue
+		}
+		if add {
+			n.Instances = append(n.Instances, parent)
+		} else {
+			n.Parent = parent
+		}
+	}
+}
+
+func (s *Scene) errorf(line, column int, format string, a ...interface{}) {
+	s.Errors = append(s.Errors, &Error{Line: line, Column: column, Message: fmt.Sprintf(format, a...)})
+}
+
+// flagArity は flag の名前 ("-" 無し) と引数の数
+type flagArity map[string]int
+
+// parseFlags は flag と残りの引数に分ける. 知らない flag は引数を取らないものとする
+func parseFlags(cmd *Command, arity flagArity) (map[string]string, []Arg) {
+	flags := map[string]string{}
+	var args []Arg
+	for i := 0; i < len(cmd.Args); i++ {
+		arg := cmd.Args[i]
+		if arg.Kind != FlagArg {
+			args = append(args, arg)
+			continue
+		}
+		name := strings.TrimPrefix(arg.Text, "-")
+		flags[name] = ""
+		if arity[name] == 1 && i+1 < len(cmd.Args) {
+			i++
+			flags[name] = cmd.Args[i].Text
+		}
+	}
+	return flags, args
+}
+
+// first は短い名前と長い名前のどちらかの flag の値を返す
+func first(flags map[string]string, short, long, def string) string {
+	if v, ok := flags[short]; ok {
+		return v
+	}
+	if v, ok := flags[long]; ok {
+		return v
+	}
+	return def
+}
+
+func isTrue(s string) bool {
+	switch s {
+	case "1", "on", "yes", "true":
+		return true
+	}
+	return false
+}
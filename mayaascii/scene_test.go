@@ -0,0 +1,243 @@
+package mayaascii
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testScene = `//Maya ASCII 2018 scene
+//Name: cube.ma
+//Last modified: Mon, Jan 01, 2018 12:00:00 PM
+//Codeset: UTF-8
+file -rdi 1 -ns "chara" -rfn "charaRN" -typ "mayaAscii" "/proj/chara.ma";
+requires maya "2018";
+requires -nodeType "aiOptions" -dataType "aiData" "mtoa" "3.0.0.2";
+currentUnit -l centimeter -a degree -t film;
+fileInfo "application" "maya";
+fileInfo "product" "Maya 2018";
+createNode transform -n "group1";
+	rename -uid "4A1B-01";
+createNode transform -n "pCube1" -p "group1";
+	setAttr ".t" -type "double3" 1 -2 0.5 ;
+	setAttr -k off ".v" no;
+	setAttr -l on ".tx";
+createNode mesh -n "pCubeShape1" -p "pCube1";
+	addAttr -ci true -sn "mso" -ln "miShadingSamplesOverride" -min 0 -max 1 -at "bool";
+	setAttr -k off ".v";
+	setAttr -s 4 ".iog[0].og";
+	setAttr ".uvst[0].uvsn" -type "string" "map1";
+	setAttr -s 2 ".uvst[0].uvsp[0:1]" -type "float2" 0.375 0 0.625 0;
+	setAttr ".pt" -type "Int32Array" 3 1 2 3 ;
+	setAttr ".names" -type "stringArray" 2 "a" "b"  ;
+	setAttr ".ics" -type "componentList" 1 "f[0:5]";
+	setAttr ".fc[0:1]" -type "polyFaces" f 4 0 1 3 -3 mu 0 4 0 1 3 2;
+	setAttr ".bad" -type "double3" 1 2;
+createNode mesh -n "pCubeShape2" -p "pCube1";
+	setAttr -k off ".v";
+lockNode -l 1 ;
+select -ne :time1;
+	setAttr ".o" 1;
+connectAttr "pCubeShape1.iog" ":initialShadingGroup.dsm" -na;
+connectAttr "pCube1.t" "group1.t" -l on;
+parent -s -nc -r -add "pCubeShape1" "group1";
+parent "pCubeShape2" "group1";
+// End of cube.ma
+`
+
+func TestParse(t *testing.T) {
+	scene, err := Parse(strings.NewReader(testScene))
+	if err != nil {
+		t.Fatalf("Parse returned error: %s", err)
+	}
+
+	header := Header{
+		Version:      "2018",
+		Name:         "cube.ma",
+		LastModified: "Mon, Jan 01, 2018 12:00:00 PM",
+		Codeset:      "UTF-8",
+		Requires: []*Requirement{
+			{Plugin: "maya", Version: "2018"},
+			{Plugin: "mtoa", Version: "3.0.0.2", NodeTypes: []string{"aiOptions"}, DataTypes: []string{"aiData"}},
+		},
+		FileInfo: []*FileInfo{
+			{Key: "application", Value: "maya"},
+			{Key: "product", Value: "Maya 2018"},
+		},
+		Units: Units{Linear: "centimeter", Angle: "degree", Time: "film"},
+	}
+	if !reflect.DeepEqual(scene.Header, header) {
+		t.Errorf("wrong header.\nwant=%+v\ngot=%+v", header, scene.Header)
+	}
+
+	ref := &Reference{Path: "/proj/chara.ma", Namespace: "chara", ReferenceNode: "charaRN", Type: "mayaAscii"}
+	if len(scene.References) != 1 || !reflect.DeepEqual(scene.References[0], ref) {
+		t.Errorf("wrong references: %+v", scene.References)
+	}
+
+	nodes := []struct {
+		typ, name, parent string
+		attributes        int
+	}{
+		{"transform", "group1", "", 0},
+		{"transform", "pCube1", "group1", 3},
+		{"mesh", "pCubeShape1", "pCube1", 9},
+		{"mesh", "pCubeShape2", "group1", 1},
+		{"", ":time1", "", 1},
+	}
+	if len(scene.Nodes) != len(nodes) {
+		t.Fatalf("wrong number of nodes. want=%d, got=%d", len(nodes), len(scene.Nodes))
+	}
+	for i, tt := range nodes {
+		n := scene.Nodes[i]
+		if n.Type != tt.typ || n.Name != tt.name || n.Parent != tt.parent || len(n.Attributes) != tt.attributes {
+			t.Errorf("nodes[%d] wrong. want=%s %s %s %d, got=%s %s %s %d", i,
+				tt.typ, tt.name, tt.parent, tt.attributes, n.Type, n.Name, n.Parent, len(n.Attributes))
+		}
+	}
+	if n := scene.Node("group1"); n == nil || n.UUID != "4A1B-01" {
+		t.Errorf("group1 should have uuid: %+v", n)
+	}
+	shape := scene.Node("pCubeShape1")
+	if shape == nil {
+		t.Fatalf("pCubeShape1 not found")
+	}
+	if !reflect.DeepEqual(shape.Instances, []string{"group1"}) {
+		t.Errorf("wrong instances: %v", shape.Instances)
+	}
+	added := &AddedAttribute{LongName: "miShadingSamplesOverride", ShortName: "mso", Type: "bool"}
+	if len(shape.AddedAttributes) != 1 || !reflect.DeepEqual(shape.AddedAttributes[0], added) {
+		t.Errorf("wrong added attributes: %+v", shape.AddedAttributes)
+	}
+	if n := scene.Node("pCubeShape2"); n == nil || !n.Locked {
+		t.Errorf("pCubeShape2 should be locked")
+	}
+
+	cube := scene.Node("pCube1")
+	if v := cube.Attributes[1]; v.Keyable == nil || *v.Keyable || !reflect.DeepEqual(v.Value, []float64{0}) {
+		t.Errorf("wrong .v attribute: %+v", v)
+	}
+	if tx := cube.Attributes[2]; tx.Locked == nil || !*tx.Locked || tx.Value != nil {
+		t.Errorf("wrong .tx attribute: %+v", tx)
+	}
+	if s := shape.Attributes[1]; s.Size != 4 || s.Name != ".iog[0].og" {
+		t.Errorf("wrong .iog attribute: %+v", s)
+	}
+
+	connections := []*Connection{
+		{Source: "pCubeShape1.iog", Destination: ":initialShadingGroup.dsm", NextAvailable: true},
+		{Source: "pCube1.t", Destination: "group1.t", Locked: true},
+	}
+	if !reflect.DeepEqual(scene.Connections, connections) {
+		t.Errorf("wrong connections: %+v", scene.Connections)
+	}
+
+	var errs []string
+	for _, err := range scene.Errors {
+		errs = append(errs, err.Error())
+	}
+	if want := []string{"line:27.2 setAttr .bad: double3 needs multiple of 3 values, got 2"}; !reflect.DeepEqual(errs, want) {
+		t.Errorf("wrong errors. want=%q, got=%q", want, errs)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`setAttr ".t" -type "double3" 1 -2 0.5;`, []float64{1, -2, 0.5}},
+		{`setAttr ".v" yes;`, []float64{1}},
+		{`setAttr ".a" 1 2 3;`, []float64{1, 2, 3}},
+		{`setAttr ".s" -type "string" "a\"b\\c\n";`, "a\"b\\c\n"},
+		{`setAttr ".e" -type "string" "";`, ""},
+		{`setAttr ".u" -type "float2" 0.375 0 0.625 0;`, []float64{0.375, 0, 0.625, 0}},
+		{`setAttr ".l" -type "long3" 1 2 -3;`, []int64{1, 2, -3}},
+		{`setAttr ".p" -type "Int32Array" 3 1 2 3;`, []int64{1, 2, 3}},
+		{`setAttr ".d" -type "doubleArray" 0;`, []float64{}},
+		{`setAttr ".v" -type "vectorArray" 1 1 2 3;`, []float64{1, 2, 3}},
+		{`setAttr ".n" -type "stringArray" 2 "a" "b";`, []string{"a", "b"}},
+		{`setAttr ".c" -type "componentList" 1 "vtx[0:7]";`, []string{"vtx[0:7]"}},
+		{`setAttr ".m" -type "matrix" 1 0 0 0 0 1 0 0 0 0 1 0 0 0 0 1;`,
+			[]float64{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}},
+		{`setAttr ".x" -type "matrix" "xform" 1 1 1;`, []string{"xform", "1", "1", "1"}},
+		{`setAttr ".f" -type "polyFaces" f 3 0 1 -2;`, []string{"f", "3", "0", "1", "-2"}},
+	}
+
+	for _, tt := range tests {
+		scene, err := Parse(strings.NewReader("createNode transform;\n" + tt.input))
+		if err != nil {
+			t.Errorf("input %q returned error: %s", tt.input, err)
+			continue
+		}
+		if len(scene.Errors) != 0 {
+			t.Errorf("input %q has errors: %v", tt.input, scene.Errors)
+			continue
+		}
+		attr := scene.Nodes[0].Attributes[0]
+		if !attr.Decoded || !reflect.DeepEqual(attr.Value, tt.expected) {
+			t.Errorf("input %q wrong value. want=%#v, got=%#v", tt.input, tt.expected, attr.Value)
+		}
+		if attr.Raw != nil {
+			t.Errorf("input %q should drop the raw value: %+v", tt.input, attr.Raw)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`setAttr ".t" -type "double3" 1 2;`, "line:2.1 setAttr .t: double3 needs multiple of 3 values, got 2"},
+		{`setAttr ".s" -type "string" 1;`, "line:2.1 setAttr .s: string needs 1 string, got 1 arguments"},
+		{`setAttr ".n" -type "stringArray" 3 "a";`, "line:2.1 setAttr .n: stringArray needs 3 values, got 1"},
+		{`setAttr ".p" -type "Int32Array" 1 1.5;`, "line:2.1 setAttr .p: expected integer, got \"1.5\""},
+		{`setAttr ".a" "x";`, "line:2.1 setAttr .a: expected number, got \"x\""},
+	}
+
+	for _, tt := range tests {
+		scene, err := Parse(strings.NewReader("createNode transform;\n" + tt.input))
+		if err != nil {
+			t.Errorf("input %q returned error: %s", tt.input, err)
+			continue
+		}
+		if len(scene.Errors) != 1 || scene.Errors[0].Error() != tt.expected {
+			t.Errorf("input %q wrong errors. want=%q, got=%v", tt.input, tt.expected, scene.Errors)
+			continue
+		}
+		// 解釈できなかった値も引数は残す
+		if attr := scene.Nodes[0].Attributes[0]; attr.Decoded || len(attr.Raw) == 0 {
+			t.Errorf("input %q should keep the raw value: %+v", tt.input, attr)
+		}
+	}
+}
+
+// sceneReader は createNode と setAttr を n 回繰り返す .ma を少しずつ作る
+type sceneReader struct {
+	n, i int
+	buf  []byte
+}
+
+func (r *sceneReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.i == r.n {
+			return 0, io.EOF
+		}
+		r.buf = []byte(fmt.Sprintf("createNode transform -n \"n%d\";\n\tsetAttr \".t\" -type \"double3\" %d 0 -1;\n", r.i, r.i))
+		r.i++
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func BenchmarkParse(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(&sceneReader{n: 10000}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package mayaascii
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// 要素の数が決まっている数値の型
+var tupleSizes = map[string]int{
+	"short2": 2, "short3": 3, "long2": 2, "long3": 3,
+	"float2": 2, "float3": 3, "double2": 2, "double3": 3, "double4": 4,
+	"reflectanceRGB": 3, "spectrumRGB": 3,
+}
+
+// 最初に要素の数が書かれている配列の型と, 一つの要素の数値の数
+var arraySizes = map[string]int{
+	"Int32Array": 1, "Int64Array": 1, "doubleArray": 1, "floatArray": 1,
+	"vectorArray": 3, "pointArray": 4,
+}
+
+// decode は setAttr の値を -type に合わせて Go の値にする.
+//
+//	""                     []float64 (yes/no, on/off, true/false は 1 と 0)
+//	"string"               string
+//	"stringArray"          []string
+//	"componentList"        []string
+//	"short2", "long3", ... []int64
+//	"float2", "double3"... []float64
+//	"matrix"               []float64 (16 個)
+//	"Int32Array"...        []int64
+//	"doubleArray"...       []float64
+//
+// それ以外の型 (polyFaces, nurbsCurve, ...) は引数の文字列を []string で返す.
+func decode(typ string, args []Arg) (interface{}, error) {
+	switch typ {
+	case "":
+		if len(args) == 0 {
+			return nil, nil
+		}
+		return floats(args)
+	case "string":
+		if len(args) != 1 || args[0].Kind != StringArg {
+			return nil, fmt.Errorf("string needs 1 string, got %d arguments", len(args))
+		}
+		return args[0].Text, nil
+	case "stringArray", "componentList":
+		n, rest, err := count(typ, args, 1)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]string, 0, n)
+		for _, arg := range rest {
+			values = append(values, arg.Text)
+		}
+		return values, nil
+	case "matrix":
+		if len(args) != 16 {
+			// "xform" 形式の matrix はそのまま残す
+			return texts(args), nil
+		}
+		return floats(args)
+	}
+
+	if n, ok := tupleSizes[typ]; ok {
+		if len(args) == 0 || len(args)%n != 0 {
+			return nil, fmt.Errorf("%s needs multiple of %d values, got %d", typ, n, len(args))
+		}
+		if isInteger(typ) {
+			return ints(args)
+		}
+		return floats(args)
+	}
+	if n, ok := arraySizes[typ]; ok {
+		_, rest, err := count(typ, args, n)
+		if err != nil {
+			return nil, err
+		}
+		if isInteger(typ) {
+			return ints(rest)
+		}
+		return floats(rest)
+	}
+	return texts(args), nil
+}
+
+func isInteger(typ string) bool {
+	switch typ {
+	case "short2", "short3", "long2", "long3", "Int32Array", "Int64Array":
+		return true
+	}
+	return false
+}
+
+// count は最初の引数の要素の数を読んで, 残りの引数の数を確かめる
+func count(typ string, args []Arg, size int) (int, []Arg, error) {
+	if len(args) == 0 || args[0].Kind != NumberArg {
+		return 0, nil, fmt.Errorf("%s needs the number of elements", typ)
+	}
+	n, err := strconv.Atoi(args[0].Text)
+	if err != nil || n < 0 {
+		return 0, nil, fmt.Errorf("%s has invalid number of elements %q", typ, args[0].Text)
+	}
+	if len(args)-1 != n*size {
+		return 0, nil, fmt.Errorf("%s needs %d values, got %d", typ, n*size, len(args)-1)
+	}
+	return n, args[1:], nil
+}
+
+func floats(args []Arg) ([]float64, error) {
+	values := make([]float64, 0, len(args))
+	for _, arg := range args {
+		v, err := number(arg)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func ints(args []Arg) ([]int64, error) {
+	values := make([]int64, 0, len(args))
+	for _, arg := range args {
+		v, err := strconv.ParseInt(arg.Text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected integer, got %q", arg.Text)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func number(arg Arg) (float64, error) {
+	switch arg.Text {
+	case "yes", "on", "true":
+		return 1, nil
+	case "no", "off", "false":
+		return 0, nil
+	}
+	if arg.Kind != NumberArg {
+		return 0, fmt.Errorf("expected number, got %q", arg.Text)
+	}
+	v, err := strconv.ParseFloat(arg.Text, 64)
+	if err != nil && err.(*strconv.NumError).Err != strconv.ErrRange {
+		return 0, fmt.Errorf("expected number, got %q", arg.Text)
+	}
+	return v, nil
+}
+
+func texts(args []Arg) []string {
+	values := make([]string, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Text)
+	}
+	return values
+}
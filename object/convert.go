@@ -0,0 +1,359 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// IsArray reports whether typ is an array type. ex) "int[]"
+func (t Type) IsArray() bool {
+	return strings.HasSuffix(string(t), "[]")
+}
+
+// ElementType returns the element type of an array type. ex) "int[]" -> "int"
+func (t Type) ElementType() Type {
+	return Type(strings.TrimSuffix(string(t), "[]"))
+}
+
+// ArrayOf returns the array type of typ. ex) "int" -> "int[]"
+func (t Type) ArrayOf() Type {
+	return t + "[]"
+}
+
+// IsTypeName reports whether name is a type that values can be cast to.
+// A call of the name such as int(1.1) is a cast too.
+func IsTypeName(name string) bool {
+	switch Type(name) {
+	case IntType, FloatType, StringType, VectorType, MatrixType:
+		return true
+	}
+	return false
+}
+
+// WrongArgumentCount is the message of a call with the wrong number of arguments.
+func WrongArgumentCount(name string) string {
+	return fmt.Sprintf("Wrong number of arguments on call to %s.", name)
+}
+
+// Bool converts b to MEL int. true is 1, false is 0.
+func Bool(b bool) *Integer {
+	if b {
+		return &Integer{Value: 1}
+	}
+	return &Integer{Value: 0}
+}
+
+// Zero returns the value of a variable declared without value.
+// Arrays are empty and matrices are 0x0.
+func Zero(typ Type) Object {
+	switch typ {
+	case IntType:
+		return &Integer{}
+	case FloatType:
+		return &Float{}
+	case StringType:
+		return &String{}
+	case VectorType:
+		return &Vector{}
+	case MatrixType:
+		return &Matrix{}
+	}
+	if typ.IsArray() {
+		return &Array{ElementType: typ.ElementType()}
+	}
+	return &Void{}
+}
+
+// Convert converts val to typ with MEL's implicit conversion rules.
+//
+//	int    <- float (truncated), string ("12abc" is 12), vector (magnitude)
+//	float  <- int, string, vector (magnitude)
+//	string <- int, float, vector ("1 2 3"), matrix
+//	vector <- int, float (all components), string ("1 2 3"), matrix[1][3]
+//	matrix <- vector (1x3)
+//	T[]    <- U[] converting each element
+//
+// Arrays and matrices are always copied.
+func Convert(val Object, typ Type) (Object, error) {
+	switch typ {
+	case IntType:
+		switch val := val.(type) {
+		case *Integer:
+			return val, nil
+		case *Float:
+			return &Integer{Value: Int32(int64(val.Value))}, nil
+		case *String:
+			return &Integer{Value: Int32(int64(parseLeadingFloat(val.Value)))}, nil
+		case *Vector:
+			return &Integer{Value: Int32(int64(val.Magnitude()))}, nil
+		}
+	case FloatType:
+		switch val := val.(type) {
+		case *Integer:
+			return &Float{Value: float64(val.Value)}, nil
+		case *Float:
+			return val, nil
+		case *String:
+			return &Float{Value: parseLeadingFloat(val.Value)}, nil
+		case *Vector:
+			return &Float{Value: val.Magnitude()}, nil
+		}
+	case StringType:
+		switch val := val.(type) {
+		case *Integer, *Float, *String, *Vector, *Matrix:
+			return &String{Value: val.Inspect()}, nil
+		}
+	case VectorType:
+		switch val := val.(type) {
+		case *Integer:
+			f := float64(val.Value)
+			return &Vector{X: f, Y: f, Z: f}, nil
+		case *Float:
+			return &Vector{X: val.Value, Y: val.Value, Z: val.Value}, nil
+		case *Vector:
+			return val, nil
+		case *String:
+			var xyz [3]float64
+			for i, f := range strings.Fields(val.Value) {
+				if i == 3 {
+					break
+				}
+				xyz[i] = parseLeadingFloat(f)
+			}
+			return &Vector{X: xyz[0], Y: xyz[1], Z: xyz[2]}, nil
+		case *Matrix:
+			if val.Rows == 1 && val.Cols == 3 {
+				return &Vector{X: val.Values[0], Y: val.Values[1], Z: val.Values[2]}, nil
+			}
+		}
+	case MatrixType:
+		switch val := val.(type) {
+		case *Matrix:
+			return val.Copy(), nil
+		case *Vector:
+			return &Matrix{Rows: 1, Cols: 3, Values: []float64{val.X, val.Y, val.Z}}, nil
+		}
+	default:
+		arr, ok := val.(*Array)
+		if !ok || !typ.IsArray() {
+			break
+		}
+		converted := &Array{ElementType: typ.ElementType()}
+		for _, e := range arr.Elements {
+			ce, err := Convert(e, converted.ElementType)
+			if err != nil {
+				return nil, err
+			}
+			converted.Elements = append(converted.Elements, ce)
+		}
+		return converted, nil
+	}
+
+	return nil, fmt.Errorf("can not convert %s to %s", val.Type(), typ)
+}
+
+// WiderType returns the type that both a and b are converted to
+// in an array literal. ex) {1, 2.5} is float[]
+func WiderType(a, b Type) Type {
+	if a == b {
+		return a
+	}
+	rank := map[Type]int{
+		IntType:    1,
+		FloatType:  2,
+		VectorType: 3,
+		StringType: 4,
+	}
+	if rank[a] > rank[b] {
+		return a
+	}
+	return b
+}
+
+// NewArray makes an array from the values of an array literal.
+// The element type is the widest type of the values.
+func NewArray(values []Object) (*Array, error) {
+	elemType := IntType
+	for i, v := range values {
+		if v.Type().IsArray() {
+			return nil, fmt.Errorf("array can not have %s", v.Type())
+		}
+		if i == 0 {
+			elemType = v.Type()
+			continue
+		}
+		elemType = WiderType(elemType, v.Type())
+	}
+
+	arr := &Array{ElementType: elemType}
+	for _, v := range values {
+		cv, err := Convert(v, elemType)
+		if err != nil {
+			return nil, err
+		}
+		arr.Elements = append(arr.Elements, cv)
+	}
+	return arr, nil
+}
+
+// Get returns the element at index. Reading out of range returns
+// the zero value of the element type like MEL.
+func (a *Array) Get(index int) (Object, error) {
+	if index < 0 {
+		return nil, fmt.Errorf("negative index %d", index)
+	}
+	if index >= len(a.Elements) {
+		return Zero(a.ElementType), nil
+	}
+	return a.Elements[index], nil
+}
+
+// Set converts val to the element type and stores it at index.
+// The array grows with zero values when index is out of range.
+func (a *Array) Set(index int, val Object) (Object, error) {
+	if index < 0 {
+		return nil, fmt.Errorf("negative index %d", index)
+	}
+	elem, err := Convert(val, a.ElementType)
+	if err != nil {
+		return nil, err
+	}
+	for len(a.Elements) <= index {
+		a.Elements = append(a.Elements, Zero(a.ElementType))
+	}
+	a.Elements[index] = elem
+	return elem, nil
+}
+
+// Resize makes the array size elements long.
+func (a *Array) Resize(size int) {
+	if size < len(a.Elements) {
+		a.Elements = a.Elements[:size]
+		return
+	}
+	for len(a.Elements) < size {
+		a.Elements = append(a.Elements, Zero(a.ElementType))
+	}
+}
+
+// Magnitude returns the length of the vector.
+func (v *Vector) Magnitude() float64 {
+	return math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z)
+}
+
+// Component returns $v.x, $v.y or $v.z.
+func (v *Vector) Component(name string) (*Float, error) {
+	switch name {
+	case "x":
+		return &Float{Value: v.X}, nil
+	case "y":
+		return &Float{Value: v.Y}, nil
+	case "z":
+		return &Float{Value: v.Z}, nil
+	}
+	return nil, fmt.Errorf("invalid vector component %s", name)
+}
+
+// NewMatrix makes a rows x cols matrix filled with 0.
+func NewMatrix(rows, cols int) *Matrix {
+	return &Matrix{Rows: rows, Cols: cols, Values: make([]float64, rows*cols)}
+}
+
+// Copy returns a copy of the matrix.
+func (m *Matrix) Copy() *Matrix {
+	return &Matrix{Rows: m.Rows, Cols: m.Cols, Values: append([]float64(nil), m.Values...)}
+}
+
+// At returns $m[row][col]. Matrices never grow, so out of range is an error.
+func (m *Matrix) At(row, col int) (float64, error) {
+	if row < 0 || col < 0 || row >= m.Rows || col >= m.Cols {
+		return 0, fmt.Errorf("matrix index [%d][%d] out of range %dx%d", row, col, m.Rows, m.Cols)
+	}
+	return m.Values[row*m.Cols+col], nil
+}
+
+// Set stores $m[row][col].
+func (m *Matrix) Set(row, col int, val Object) (*Float, error) {
+	if row < 0 || col < 0 || row >= m.Rows || col >= m.Cols {
+		return nil, fmt.Errorf("matrix index [%d][%d] out of range %dx%d", row, col, m.Rows, m.Cols)
+	}
+	f, err := Convert(val, FloatType)
+	if err != nil {
+		return nil, err
+	}
+	m.Values[row*m.Cols+col] = f.(*Float).Value
+	return f.(*Float), nil
+}
+
+// Assign converts val to the type of the variable and stores it.
+// A matrix variable declared with a size keeps its size.
+func (v *Variable) Assign(val Object) (Object, error) {
+	converted, err := Convert(val, v.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	if m, ok := v.Value.(*Matrix); ok && m.Rows*m.Cols != 0 {
+		cm := converted.(*Matrix)
+		if cm.Rows != m.Rows || cm.Cols != m.Cols {
+			return nil, fmt.Errorf("matrix size mismatch. expected=%dx%d, got=%dx%d",
+				m.Rows, m.Cols, cm.Rows, cm.Cols)
+		}
+	}
+
+	v.Value = converted
+	return converted, nil
+}
+
+// Truthy reports whether obj is true in if, while and so on.
+func Truthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value != 0
+	case *Float:
+		return obj.Value != 0
+	case *String:
+		return obj.Value != ""
+	case *Vector:
+		return obj.X != 0 || obj.Y != 0 || obj.Z != 0
+	case *Array:
+		return len(obj.Elements) != 0
+	}
+	return false
+}
+
+// Equal reports whether a == b in MEL. Numbers are compared after
+// int to float promotion, arrays are compared element by element.
+func Equal(a, b Object) bool {
+	if aa, ok := a.(*Array); ok {
+		ba, ok := b.(*Array)
+		if !ok || len(aa.Elements) != len(ba.Elements) {
+			return false
+		}
+		for i := range aa.Elements {
+			if !Equal(aa.Elements[i], ba.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	eq, err := BinaryOp("==", a, b)
+	if err != nil {
+		return false
+	}
+	return Truthy(eq)
+}
+
+// parseLeadingFloat は "12abc" のような文字列の先頭の数値を返す
+func parseLeadingFloat(s string) float64 {
+	s = strings.TrimSpace(s)
+	for end := len(s); end > 0; end-- {
+		if f, err := strconv.ParseFloat(s[:end], 64); err == nil {
+			return f
+		}
+	}
+	return 0
+}
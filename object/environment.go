@@ -0,0 +1,110 @@
+package object
+
+import (
+	"io"
+	"os"
+)
+
+// Variable is a declared MEL variable
+type Variable struct {
+	Type  Type
+	Value Object
+}
+
+// shared is the state shared by all environments of one interpreter.
+type shared struct {
+	globals map[string]*Variable
+	procs   map[string]Object
+	out     io.Writer
+}
+
+// Environment is a lexical scope of MEL variables
+type Environment struct {
+	store  map[string]*Variable
+	outer  *Environment
+	shared *shared
+}
+
+// NewEnvironment makes the top level environment. print writes to out.
+func NewEnvironment(out io.Writer) *Environment {
+	if out == nil {
+		out = os.Stdout
+	}
+	return &Environment{
+		store: make(map[string]*Variable),
+		shared: &shared{
+			globals: make(map[string]*Variable),
+			procs:   make(map[string]Object),
+			out:     out,
+		},
+	}
+}
+
+// NewEnclosedEnvironment makes the environment of a block.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	return &Environment{
+		store:  make(map[string]*Variable),
+		outer:  outer,
+		shared: outer.shared,
+	}
+}
+
+// NewProcEnvironment makes the environment of a proc call.
+// Local variables of the caller are not visible from it.
+func NewProcEnvironment(caller *Environment) *Environment {
+	return &Environment{
+		store:  make(map[string]*Variable),
+		shared: caller.shared,
+	}
+}
+
+// Out returns the writer of print.
+func (e *Environment) Out() io.Writer {
+	return e.shared.out
+}
+
+// Get finds the variable from this scope to the outermost scope.
+func (e *Environment) Get(name string) (*Variable, bool) {
+	for env := e; env != nil; env = env.outer {
+		if v, ok := env.store[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Declare makes the variable in this scope.
+func (e *Environment) Declare(name string, typ Type, value Object) *Variable {
+	v := &Variable{Type: typ, Value: value}
+	e.store[name] = v
+	return v
+}
+
+// DeclareGlobal makes the global variable and binds it to this scope.
+// If the global variable already exists, the existing one is bound.
+func (e *Environment) DeclareGlobal(name string, typ Type, value Object) *Variable {
+	v, ok := e.shared.globals[name]
+	if !ok {
+		v = &Variable{Type: typ, Value: value}
+		e.shared.globals[name] = v
+	}
+	e.store[name] = v
+	return v
+}
+
+// IsDeclaredHere reports whether the variable is declared in this scope.
+func (e *Environment) IsDeclaredHere(name string) bool {
+	_, ok := e.store[name]
+	return ok
+}
+
+// GetProc finds the proc or builtin.
+func (e *Environment) GetProc(name string) (Object, bool) {
+	p, ok := e.shared.procs[name]
+	return p, ok
+}
+
+// SetProc registers the proc or builtin.
+func (e *Environment) SetProc(name string, proc Object) {
+	e.shared.procs[name] = proc
+}
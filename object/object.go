@@ -0,0 +1,238 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Type is the MEL type name of an Object. ex) "int", "string[]"
+type Type string
+
+// MEL types.
+const (
+	IntType         Type = "int"
+	FloatType       Type = "float"
+	StringType      Type = "string"
+	VectorType      Type = "vector"
+	MatrixType      Type = "matrix"
+	IntArrayType    Type = "int[]"
+	FloatArrayType  Type = "float[]"
+	StringArrayType Type = "string[]"
+	VectorArrayType Type = "vector[]"
+
+	// internal types
+	VoidType     Type = "void"
+	ReturnType   Type = "return"
+	BreakType    Type = "break"
+	ContinueType Type = "continue"
+	ErrorType    Type = "error"
+	ProcType     Type = "proc"
+	BuiltinType  Type = "builtin"
+)
+
+// Object is the value of MEL
+type Object interface {
+	Type() Type
+	Inspect() string
+}
+
+// Integer is MEL int. Value is in the range of int32
+type Integer struct {
+	Value int64
+}
+
+// Type ...
+func (i *Integer) Type() Type { return IntType }
+
+// Inspect ...
+func (i *Integer) Inspect() string { return strconv.FormatInt(i.Value, 10) }
+
+// Float is MEL float
+type Float struct {
+	Value float64
+}
+
+// Type ...
+func (f *Float) Type() Type { return FloatType }
+
+// Inspect ...
+func (f *Float) Inspect() string { return formatFloat(f.Value) }
+
+// String is MEL string
+type String struct {
+	Value string
+}
+
+// Type ...
+func (s *String) Type() Type { return StringType }
+
+// Inspect ...
+func (s *String) Inspect() string { return s.Value }
+
+// Vector is MEL vector
+type Vector struct {
+	X, Y, Z float64
+}
+
+// Type ...
+func (v *Vector) Type() Type { return VectorType }
+
+// Inspect ...
+func (v *Vector) Inspect() string {
+	return formatFloat(v.X) + " " + formatFloat(v.Y) + " " + formatFloat(v.Z)
+}
+
+// Matrix is MEL matrix. Values is row major.
+type Matrix struct {
+	Rows   int
+	Cols   int
+	Values []float64
+}
+
+// Type ...
+func (m *Matrix) Type() Type { return MatrixType }
+
+// Inspect ...
+func (m *Matrix) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString("<<")
+	for r := 0; r < m.Rows; r++ {
+		if r != 0 {
+			out.WriteString("; ")
+		}
+		var cols []string
+		for c := 0; c < m.Cols; c++ {
+			cols = append(cols, formatFloat(m.Values[r*m.Cols+c]))
+		}
+		out.WriteString(strings.Join(cols, ", "))
+	}
+	out.WriteString(">>")
+
+	return out.String()
+}
+
+// Array is MEL int[], float[], string[] and vector[]
+type Array struct {
+	ElementType Type
+	Elements    []Object
+}
+
+// Type ...
+func (a *Array) Type() Type { return a.ElementType + "[]" }
+
+// Inspect ...
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	var elements []string
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+	out.WriteString(strings.Join(elements, " "))
+
+	return out.String()
+}
+
+// Void is the result of statements and procs without return value
+type Void struct{}
+
+// Type ...
+func (v *Void) Type() Type { return VoidType }
+
+// Inspect ...
+func (v *Void) Inspect() string { return "" }
+
+// ReturnValue wraps the value of a return statement
+type ReturnValue struct {
+	Value Object
+}
+
+// Type ...
+func (rv *ReturnValue) Type() Type { return ReturnType }
+
+// Inspect ...
+func (rv *ReturnValue) Inspect() string {
+	if rv.Value == nil {
+		return ""
+	}
+	return rv.Value.Inspect()
+}
+
+// Break is the result of a break statement
+type Break struct{}
+
+// Type ...
+func (b *Break) Type() Type { return BreakType }
+
+// Inspect ...
+func (b *Break) Inspect() string { return "break" }
+
+// Continue is the result of a continue statement
+type Continue struct{}
+
+// Type ...
+func (c *Continue) Type() Type { return ContinueType }
+
+// Inspect ...
+func (c *Continue) Inspect() string { return "continue" }
+
+// Error is a MEL runtime error
+type Error struct {
+	Token   token.Token // where the error occurred
+	Message string
+}
+
+// Type ...
+func (e *Error) Type() Type { return ErrorType }
+
+// Inspect ...
+func (e *Error) Inspect() string {
+	return fmt.Sprintf("line:%d.%d %s", e.Token.Row, e.Token.Column, e.Message)
+}
+
+// Error implements error interface.
+func (e *Error) Error() string { return e.Inspect() }
+
+// Proc is a user defined proc
+type Proc struct {
+	Statement *ast.ProcStatement
+	Global    bool
+}
+
+// Type ...
+func (p *Proc) Type() Type { return ProcType }
+
+// Inspect ...
+func (p *Proc) Inspect() string { return "proc " + p.Statement.Name.Literal }
+
+// BuiltinFunction is the implementation of a builtin proc.
+// env is the environment of the caller.
+type BuiltinFunction func(env *Environment, args ...Object) Object
+
+// Builtin is a proc implemented in Go
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+// Type ...
+func (b *Builtin) Type() Type { return BuiltinType }
+
+// Inspect ...
+func (b *Builtin) Inspect() string { return "builtin " + b.Name }
+
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 6, 64)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,229 @@
+package object
+
+import (
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		input    Object
+		typ      Type
+		expected string
+	}{
+		{&Integer{Value: 3}, FloatType, "3"},
+		{&Float{Value: 2.9}, IntType, "2"},
+		{&Float{Value: -2.9}, IntType, "-2"},
+		{&String{Value: "12abc"}, IntType, "12"},
+		{&String{Value: "abc"}, FloatType, "0"},
+		{&Float{Value: 1.0 / 3}, StringType, "0.333333"},
+		{&Vector{X: 3, Y: 4}, FloatType, "5"},
+		{&Vector{X: 1, Y: 2.5, Z: 3}, StringType, "1 2.5 3"},
+		{&Integer{Value: 2}, VectorType, "2 2 2"},
+		{&String{Value: "1 2 3"}, VectorType, "1 2 3"},
+		{&Matrix{Rows: 1, Cols: 3, Values: []float64{1, 2, 3}}, VectorType, "1 2 3"},
+		{&Array{ElementType: IntType, Elements: []Object{&Integer{Value: 1}}}, StringArrayType, "1"},
+	}
+
+	for _, tt := range tests {
+		converted, err := Convert(tt.input, tt.typ)
+		if err != nil {
+			t.Errorf("Convert(%s, %s) returned error: %s", tt.input.Inspect(), tt.typ, err)
+			continue
+		}
+		if converted.Type() != tt.typ {
+			t.Errorf("type wrong. got=%s, want=%s", converted.Type(), tt.typ)
+		}
+		if converted.Inspect() != tt.expected {
+			t.Errorf("Convert(%s, %s) wrong. got=%q, want=%q",
+				tt.input.Inspect(), tt.typ, converted.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestConvertError(t *testing.T) {
+	tests := []struct {
+		input Object
+		typ   Type
+	}{
+		{&Matrix{Rows: 2, Cols: 2, Values: make([]float64, 4)}, VectorType},
+		{&Integer{Value: 1}, IntArrayType},
+		{&Array{ElementType: IntType}, IntType},
+		{&Vector{}, MatrixType + "[]"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Convert(tt.input, tt.typ); err == nil {
+			t.Errorf("Convert(%s, %s) should be error", tt.input.Type(), tt.typ)
+		}
+	}
+}
+
+func TestBinaryOp(t *testing.T) {
+	tests := []struct {
+		op       string
+		left     Object
+		right    Object
+		expected string
+		typ      Type
+	}{
+		{"/", &Integer{Value: 7}, &Integer{Value: 2}, "3", IntType},
+		{"+", &Integer{Value: 1}, &Float{Value: 0.5}, "1.5", FloatType},
+		{"%", &Float{Value: 5.5}, &Integer{Value: 2}, "1.5", FloatType},
+		{"+", &String{Value: "a"}, &Integer{Value: 1}, "a1", StringType},
+		{"+", &Float{Value: 1.5}, &String{Value: "a"}, "1.5a", StringType},
+		{"==", &Integer{Value: 1}, &Float{Value: 1}, "1", IntType},
+		{"<", &String{Value: "a"}, &String{Value: "b"}, "", ""},
+		{"*", &Vector{X: 1, Y: 2, Z: 3}, &Vector{X: 4, Y: 5, Z: 6}, "32", FloatType},
+		{"^", &Vector{X: 1}, &Vector{Y: 1}, "0 0 1", VectorType},
+		{"*", &Vector{X: 1, Y: 2, Z: 3}, &Integer{Value: 2}, "2 4 6", VectorType},
+		{"*", &Matrix{Rows: 1, Cols: 2, Values: []float64{1, 2}},
+			&Matrix{Rows: 2, Cols: 1, Values: []float64{3, 4}}, "<<11>>", MatrixType},
+		{"/", &Integer{Value: 1}, &Integer{Value: 0}, "", ""},
+		// int は 32 bit で回り込む
+		{"+", &Integer{Value: 2147483647}, &Integer{Value: 1}, "-2147483648", IntType},
+		{"*", &Integer{Value: 65536}, &Integer{Value: 65536}, "0", IntType},
+		{"-", &Integer{Value: -2147483648}, &Integer{Value: 1}, "2147483647", IntType},
+		// 文字列と数の比較は文字列を数にする
+		{"==", &String{Value: "1"}, &Integer{Value: 1}, "1", IntType},
+		{"<", &Float{Value: 1.5}, &String{Value: "2abc"}, "1", IntType},
+		{"!=", &String{Value: "a"}, &Integer{Value: 0}, "0", IntType},
+		{"-", &String{Value: "1"}, &Integer{Value: 1}, "", ""},
+	}
+
+	for _, tt := range tests {
+		result, err := BinaryOp(tt.op, tt.left, tt.right)
+		if tt.typ == "" {
+			if err == nil {
+				t.Errorf("%s %s %s should be error. got=%s",
+					tt.left.Inspect(), tt.op, tt.right.Inspect(), result.Inspect())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s %s returned error: %s", tt.left.Inspect(), tt.op, tt.right.Inspect(), err)
+			continue
+		}
+		if result.Type() != tt.typ {
+			t.Errorf("type wrong. got=%s, want=%s", result.Type(), tt.typ)
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s %s %s wrong. got=%q, want=%q",
+				tt.left.Inspect(), tt.op, tt.right.Inspect(), result.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestArrayGrow(t *testing.T) {
+	arr := &Array{ElementType: IntType}
+
+	elem, err := arr.Set(3, &Float{Value: 7.5})
+	if err != nil {
+		t.Fatalf("Set returned error: %s", err)
+	}
+	if elem.Inspect() != "7" {
+		t.Errorf("element not converted. got=%s", elem.Inspect())
+	}
+	if arr.Inspect() != "0 0 0 7" {
+		t.Errorf("array not grown. got=%q", arr.Inspect())
+	}
+
+	got, err := arr.Get(10)
+	if err != nil {
+		t.Fatalf("Get returned error: %s", err)
+	}
+	if got.Inspect() != "0" {
+		t.Errorf("out of range read should be zero. got=%s", got.Inspect())
+	}
+	if len(arr.Elements) != 4 {
+		t.Errorf("out of range read should not grow. got=%d", len(arr.Elements))
+	}
+
+	if _, err := arr.Set(-1, &Integer{}); err == nil {
+		t.Errorf("negative index should be error")
+	}
+}
+
+func TestMatrixFixedSize(t *testing.T) {
+	m := NewMatrix(2, 2)
+
+	if _, err := m.Set(1, 0, &Integer{Value: 3}); err != nil {
+		t.Fatalf("Set returned error: %s", err)
+	}
+	f, err := m.At(1, 0)
+	if err != nil {
+		t.Fatalf("At returned error: %s", err)
+	}
+	if f != 3 {
+		t.Errorf("m[1][0] wrong. got=%g", f)
+	}
+
+	if _, err := m.Set(2, 0, &Integer{Value: 1}); err == nil {
+		t.Errorf("matrix should not grow")
+	}
+	if _, err := m.At(0, 2); err == nil {
+		t.Errorf("out of range read should be error")
+	}
+
+	v := &Variable{Type: MatrixType, Value: m}
+	if _, err := v.Assign(NewMatrix(3, 3)); err == nil {
+		t.Errorf("assigning different size should be error")
+	}
+}
+
+func TestVectorComponent(t *testing.T) {
+	v := &Vector{X: 1, Y: 2, Z: 3}
+	for name, expected := range map[string]float64{"x": 1, "y": 2, "z": 3} {
+		f, err := v.Component(name)
+		if err != nil {
+			t.Errorf("Component(%q) returned error: %s", name, err)
+			continue
+		}
+		if f.Value != expected {
+			t.Errorf("Component(%q) wrong. got=%g, want=%g", name, f.Value, expected)
+		}
+	}
+	if _, err := v.Component("w"); err == nil {
+		t.Errorf("Component(\"w\") should be error")
+	}
+}
+
+func TestEqualAndTruthy(t *testing.T) {
+	ints := &Array{ElementType: IntType, Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}
+	floats := &Array{ElementType: FloatType, Elements: []Object{&Float{Value: 1}, &Float{Value: 2}}}
+
+	equalTests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Float{Value: 1}, true},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&String{Value: "1"}, &Integer{Value: 1}, true},
+		{&String{Value: "1x"}, &Integer{Value: 2}, false},
+		{&Vector{X: 1}, &Vector{X: 1}, true},
+		{ints, floats, true},
+		{ints, &Array{ElementType: IntType}, false},
+	}
+	for _, tt := range equalTests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("Equal(%s, %s) wrong. got=%t", tt.a.Inspect(), tt.b.Inspect(), got)
+		}
+	}
+
+	truthyTests := []struct {
+		obj      Object
+		expected bool
+	}{
+		{&Integer{Value: 0}, false},
+		{&Integer{Value: -1}, true},
+		{&Float{Value: 0.1}, true},
+		{&String{}, false},
+		{&String{Value: "0"}, true},
+		{&Vector{}, false},
+		{ints, true},
+	}
+	for _, tt := range truthyTests {
+		if got := Truthy(tt.obj); got != tt.expected {
+			t.Errorf("Truthy(%s) wrong. got=%t", tt.obj.Inspect(), got)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// errDivideByZero is returned by / and % with 0.
+var errDivideByZero = errors.New("divide by zero")
+
+// Negate evaluates -val.
+func Negate(val Object) (Object, error) {
+	switch val := val.(type) {
+	case *Integer:
+		return &Integer{Value: Int32(-val.Value)}, nil
+	case *Float:
+		return &Float{Value: -val.Value}, nil
+	case *Vector:
+		return &Vector{X: -val.X, Y: -val.Y, Z: -val.Z}, nil
+	case *Matrix:
+		m := &Matrix{Rows: val.Rows, Cols: val.Cols}
+		for _, f := range val.Values {
+			m.Values = append(m.Values, -f)
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown operator: -%s", val.Type())
+}
+
+// BinaryOp evaluates left op right.
+//
+// int op int is int (7 / 2 is 3) and wraps around like MEL's 32 bit int,
+// int and float are promoted to float, string + anything concatenates.
+// A string compared with a number is converted to the number ("12abc" == 12).
+// vector * vector is the dot product and vector ^ vector is the cross
+// product. Comparisons return int 1 or 0.
+func BinaryOp(op string, left, right Object) (Object, error) {
+	lt, rt := left.Type(), right.Type()
+
+	switch {
+	case lt == IntType && rt == IntType:
+		return integerOp(op, left.(*Integer).Value, right.(*Integer).Value)
+	case op == "+" && (lt == StringType || rt == StringType):
+		if lt.IsArray() || rt.IsArray() {
+			break
+		}
+		return &String{Value: left.Inspect() + right.Inspect()}, nil
+	case lt == StringType && rt == StringType:
+		return stringOp(op, left.(*String).Value, right.(*String).Value)
+	case isComparison(op) && (lt == StringType && isNumber(right) || isNumber(left) && rt == StringType):
+		l, _ := Convert(left, FloatType)
+		r, _ := Convert(right, FloatType)
+		return compareFloat(op, l.(*Float).Value, r.(*Float).Value)
+	case isNumber(left) && isNumber(right):
+		return floatOp(op, toFloat(left), toFloat(right))
+	case lt == VectorType || rt == VectorType:
+		return vectorOp(op, left, right)
+	case lt == MatrixType || rt == MatrixType:
+		return matrixOp(op, left, right)
+	}
+
+	return nil, fmt.Errorf("unknown operator: %s %s %s", lt, op, rt)
+}
+
+func isComparison(op string) bool {
+	switch op {
+	case "==", "!=", "<", ">", "<=", ">=":
+		return true
+	}
+	return false
+}
+
+// Int32 wraps v around to the range of MEL's 32 bit int
+func Int32(v int64) int64 {
+	return int64(int32(v))
+}
+
+func isNumber(obj Object) bool {
+	switch obj.(type) {
+	case *Integer, *Float:
+		return true
+	}
+	return false
+}
+
+func toFloat(obj Object) float64 {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value)
+	case *Float:
+		return obj.Value
+	}
+	return 0
+}
+
+func integerOp(op string, l, r int64) (Object, error) {
+	switch op {
+	case "+":
+		return &Integer{Value: Int32(l + r)}, nil
+	case "-":
+		return &Integer{Value: Int32(l - r)}, nil
+	case "*":
+		return &Integer{Value: Int32(l * r)}, nil
+	case "/":
+		if r == 0 {
+			return nil, errDivideByZero
+		}
+		return &Integer{Value: Int32(l / r)}, nil
+	case "%":
+		if r == 0 {
+			return nil, errDivideByZero
+		}
+		return &Integer{Value: Int32(l % r)}, nil
+	}
+	return compareFloat(op, float64(l), float64(r))
+}
+
+func floatOp(op string, l, r float64) (Object, error) {
+	switch op {
+	case "+":
+		return &Float{Value: l + r}, nil
+	case "-":
+		return &Float{Value: l - r}, nil
+	case "*":
+		return &Float{Value: l * r}, nil
+	case "/":
+		if r == 0 {
+			return nil, errDivideByZero
+		}
+		return &Float{Value: l / r}, nil
+	case "%":
+		if r == 0 {
+			return nil, errDivideByZero
+		}
+		return &Float{Value: math.Mod(l, r)}, nil
+	}
+	return compareFloat(op, l, r)
+}
+
+func compareFloat(op string, l, r float64) (Object, error) {
+	switch op {
+	case "==":
+		return Bool(l == r), nil
+	case "!=":
+		return Bool(l != r), nil
+	case "<":
+		return Bool(l < r), nil
+	case ">":
+		return Bool(l > r), nil
+	case "<=":
+		return Bool(l <= r), nil
+	case ">=":
+		return Bool(l >= r), nil
+	}
+	return nil, fmt.Errorf("unknown operator: number %s number", op)
+}
+
+func stringOp(op string, l, r string) (Object, error) {
+	switch op {
+	case "==":
+		return Bool(l == r), nil
+	case "!=":
+		return Bool(l != r), nil
+	}
+	return nil, fmt.Errorf("unknown operator: string %s string", op)
+}
+
+func vectorOp(op string, left, right Object) (Object, error) {
+	l, lok := left.(*Vector)
+	r, rok := right.(*Vector)
+
+	if lok && rok {
+		switch op {
+		case "+":
+			return &Vector{X: l.X + r.X, Y: l.Y + r.Y, Z: l.Z + r.Z}, nil
+		case "-":
+			return &Vector{X: l.X - r.X, Y: l.Y - r.Y, Z: l.Z - r.Z}, nil
+		case "*":
+			// dot product
+			return &Float{Value: l.X*r.X + l.Y*r.Y + l.Z*r.Z}, nil
+		case "^":
+			// cross product
+			return &Vector{
+				X: l.Y*r.Z - l.Z*r.Y,
+				Y: l.Z*r.X - l.X*r.Z,
+				Z: l.X*r.Y - l.Y*r.X,
+			}, nil
+		case "==":
+			return Bool(*l == *r), nil
+		case "!=":
+			return Bool(*l != *r), nil
+		}
+		return nil, fmt.Errorf("unknown operator: vector %s vector", op)
+	}
+
+	// vector と数値の演算は要素ごとに行う
+	if lok && isNumber(right) {
+		f := toFloat(right)
+		return componentwise(op, l, &Vector{X: f, Y: f, Z: f})
+	}
+	if rok && isNumber(left) {
+		f := toFloat(left)
+		return componentwise(op, &Vector{X: f, Y: f, Z: f}, r)
+	}
+
+	return nil, fmt.Errorf("unknown operator: %s %s %s", left.Type(), op, right.Type())
+}
+
+func componentwise(op string, l, r *Vector) (Object, error) {
+	switch op {
+	case "+":
+		return &Vector{X: l.X + r.X, Y: l.Y + r.Y, Z: l.Z + r.Z}, nil
+	case "-":
+		return &Vector{X: l.X - r.X, Y: l.Y - r.Y, Z: l.Z - r.Z}, nil
+	case "*":
+		return &Vector{X: l.X * r.X, Y: l.Y * r.Y, Z: l.Z * r.Z}, nil
+	case "/":
+		if r.X == 0 || r.Y == 0 || r.Z == 0 {
+			return nil, errDivideByZero
+		}
+		return &Vector{X: l.X / r.X, Y: l.Y / r.Y, Z: l.Z / r.Z}, nil
+	}
+	return nil, fmt.Errorf("unknown operator: vector %s number", op)
+}
+
+func matrixOp(op string, left, right Object) (Object, error) {
+	l, lok := left.(*Matrix)
+	r, rok := right.(*Matrix)
+
+	if lok && isNumber(right) && op == "*" {
+		m := &Matrix{Rows: l.Rows, Cols: l.Cols}
+		for _, f := range l.Values {
+			m.Values = append(m.Values, f*toFloat(right))
+		}
+		return m, nil
+	}
+	if !lok || !rok {
+		return nil, fmt.Errorf("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+
+	switch op {
+	case "+", "-":
+		if l.Rows != r.Rows || l.Cols != r.Cols {
+			return nil, fmt.Errorf("matrix size mismatch. %dx%d %s %dx%d",
+				l.Rows, l.Cols, op, r.Rows, r.Cols)
+		}
+		m := &Matrix{Rows: l.Rows, Cols: l.Cols}
+		for i := range l.Values {
+			if op == "+" {
+				m.Values = append(m.Values, l.Values[i]+r.Values[i])
+			} else {
+				m.Values = append(m.Values, l.Values[i]-r.Values[i])
+			}
+		}
+		return m, nil
+	case "*":
+		if l.Cols != r.Rows {
+			return nil, fmt.Errorf("matrix size mismatch. %dx%d * %dx%d",
+				l.Rows, l.Cols, r.Rows, r.Cols)
+		}
+		m := NewMatrix(l.Rows, r.Cols)
+		for i := 0; i < l.Rows; i++ {
+			for j := 0; j < r.Cols; j++ {
+				for k := 0; k < l.Cols; k++ {
+					m.Values[i*m.Cols+j] += l.Values[i*l.Cols+k] * r.Values[k*r.Cols+j]
+				}
+			}
+		}
+		return m, nil
+	case "==", "!=":
+		eq := l.Rows == r.Rows && l.Cols == r.Cols
+		for i := 0; eq && i < len(l.Values); i++ {
+			eq = l.Values[i] == r.Values[i]
+		}
+		return Bool(eq == (op == "==")), nil
+	}
+	return nil, fmt.Errorf("unknown operator: matrix %s matrix", op)
+}
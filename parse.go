@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+)
+
+// runParse は parse サブコマンドを実行して終了コードを返す.
+// 構文エラーがあれば 1, ファイルを読めなければ 2 を返す.
+func runParse(args []string) int {
+	fs := flag.NewFlagSet("parse", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the AST as JSON, one line per file")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-MEL parse [-json] [path ...]")
+		fmt.Fprintln(os.Stderr, "Parse MEL files and print the AST. The JSON schema is in ast/json.md.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		return parseSource("<standard input>", src, *asJSON)
+	}
+
+	code := 0
+	parsePath := func(path string) error {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 2
+			return nil
+		}
+		if c := parseSource(path, src, *asJSON); c > code {
+			code = c
+		}
+		return nil
+	}
+	for _, path := range fs.Args() {
+		stat, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 2
+			continue
+		}
+		if stat.IsDir() {
+			if err := readDir(path, parsePath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = 2
+			}
+			continue
+		}
+		parsePath(path)
+	}
+	return code
+}
+
+// parseSource は src の AST を表示する. 構文エラーがあっても AST は表示する
+func parseSource(name string, src []byte, asJSON bool) int {
+	p := parser.New(lexer.NewWithMode(string(src), lexer.ScanComments))
+	program := p.ParseProgram()
+
+	if asJSON {
+		out, err := ast.EncodeJSON(program)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			return 2
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Println(program.String())
+	}
+
+	for _, err := range p.Errors() {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+	}
+	if len(p.Errors()) != 0 {
+		return 1
+	}
+	return 0
+}
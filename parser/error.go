@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// ErrorCode is a kind of syntax error
+type ErrorCode string
+
+// ErrorCode strings.
+const (
+	InvalidToken        ErrorCode = "invalid-token"        // lexer が読めなかった token
+	UnexpectedToken     ErrorCode = "unexpected-token"     // 期待した token と違う
+	NoPrefixParseFn     ErrorCode = "no-prefix-parse-fn"   // 式を始められない token
+	InvalidNumber       ErrorCode = "invalid-number"       // 数値に変換できない
+	InvalidString       ErrorCode = "invalid-string"       // 閉じていない文字列や知らないエスケープ
+	InvalidCall         ErrorCode = "invalid-call"         // 呼び出せない式の呼び出し
+	InvalidCaseLabel    ErrorCode = "invalid-case-label"   // case がリテラルでない
+	InvalidForIn        ErrorCode = "invalid-for-in"       // for-in の要素が変数でない
+	UnexpectedStatement ErrorCode = "unexpected-statement" // 文を始められない token
+)
+
+// Error is a syntax error found by the parser.
+type Error struct {
+	Filename string      // ファイル名 (無い時は "")
+	Token    token.Token // エラーの位置の token
+	Row      int         // エラーの位置の行 1行はじまり. token の途中のこともある
+	Column   int         // エラーの位置の列 1列はじまり
+	Start    token.Pos   // エラーの範囲の始まり
+	End      token.Pos   // エラーの範囲の終わり
+	Code     ErrorCode
+	Expected token.Type // UnexpectedToken の時に期待した token (無い時は "")
+	Actual   token.Type // 実際の token
+	Message  string     // 位置を含まないメッセージ
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line:%d.%d %s", e.Row, e.Column, e.Message)
+}
+
+// ErrorList is a list of *Error. It implements error.
+type ErrorList []*Error
+
+func (l ErrorList) Len() int      { return len(l) }
+func (l ErrorList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+func (l ErrorList) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Row != b.Row {
+		return a.Row < b.Row
+	}
+	if a.Column != b.Column {
+		return a.Column < b.Column
+	}
+	return a.Message < b.Message
+}
+
+// Sort sorts the list by file, position and message
+func (l ErrorList) Sort() {
+	sort.Stable(l)
+}
+
+// RemoveMultiples sorts the list and removes errors with the same position and message
+func (l *ErrorList) RemoveMultiples() {
+	l.Sort()
+	var list ErrorList
+	for i, e := range *l {
+		if i > 0 {
+			prev := (*l)[i-1]
+			if prev.Filename == e.Filename && prev.Row == e.Row &&
+				prev.Column == e.Column && prev.Message == e.Message {
+				continue
+			}
+		}
+		list = append(list, e)
+	}
+	*l = list
+}
+
+// Error return the errors separated by newline
+func (l ErrorList) Error() string {
+	var msgs []string
+	for _, e := range l {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Err return nil if the list is empty, or the list as error
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
@@ -59,7 +59,7 @@ type (
 // Parser use Lexer and Token
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors ErrorList
 
 	curToken  token.Token
 	peekToken token.Token
@@ -71,10 +71,41 @@ type Parser struct {
 	ternaryParseFns map[token.Type]ternaryParseFn
 
 	commandStyleMode bool
+	flagArity        FlagArity
+
+	depth  int // curToken までに開いている '{' の数
+	parens int // curToken までに開いている '(' の数
+	synced int // 読み飛ばして回復した errors の数
+
+	lexed     int                // errors に加えた lexer のエラーの数
+	badTokens map[token.Pos]bool // lexer がエラーにした token の位置
+
+	// lexer.ScanComments の時に読んだコメント
+	comments        []*ast.CommentGroup
+	leadComment     *ast.CommentGroup // curToken の直前の行にあるコメント
+	peekLeadComment *ast.CommentGroup // peekToken の直前の行にあるコメント
+	lineComment     *ast.CommentGroup // curToken の後ろの同じ行にあるコメント
 }
 
-// Errors return parsing error strings..
-func (p *Parser) Errors() []string {
+// FlagArity return the number of values that the flag of the command takes.
+// query is true when the call has -q or -query. ok is false if it is not known.
+type FlagArity func(command, flag string, query bool) (n int, ok bool)
+
+// SetFlagArity sets the signatures used to group the flags of command style
+// calls with their values. ex) commands.DB.FlagArity
+//
+// A flag whose arity is not known takes the arguments up to the next flag,
+// and the last flag takes none of the arguments after it.
+func (p *Parser) SetFlagArity(arity FlagArity) {
+	p.flagArity = arity
+}
+
+// Errors return parsing errors sorted by position. The same error reported
+// twice by the error recovery is removed.
+func (p *Parser) Errors() ErrorList {
+	if len(p.errors) > 1 {
+		p.errors.RemoveMultiples()
+	}
 	return p.errors
 }
 
@@ -82,7 +113,7 @@ func (p *Parser) Errors() []string {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: ErrorList{},
 	}
 
 	// set prefix parse func.
@@ -165,11 +196,78 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
 
 	return program
 }
 
+// parseStatement は文を読み, 文の前後にあるコメントを文に付ける.
+// 構文エラーがあれば次の文の始まりまで読み飛ばす
 func (p *Parser) parseStatement() ast.Statement {
+	lead := p.leadComment
+	p.leadComment = nil
+
+	start := p.curToken
+	depth, parens := p.depth, p.parens
+	if p.curTokenIs(token.Lbrace) {
+		depth--
+	}
+
+	stmt := p.parseStatementNode()
+	if stmt == nil || len(p.errors) > p.synced {
+		if stmt == nil && len(p.errors) == p.synced {
+			p.errorf(UnexpectedStatement, start, "unexpected %s", start.Type)
+		}
+		p.synchronize(start, depth, parens)
+		p.synced = len(p.errors)
+		if stmt == nil {
+			return &ast.BadStatement{From: start, To: p.curToken}
+		}
+	}
+
+	// global の文ではコメントは中の文に付ける
+	target := stmt
+	if gs, ok := stmt.(*ast.GlobalStatement); ok {
+		target = gs.Statement
+	}
+	cs, ok := target.(ast.CommentedStatement)
+	if !ok {
+		return stmt
+	}
+	if lead != nil {
+		cs.Attached().Leading = lead
+	}
+	if p.lineComment != nil {
+		cs.Attached().Trailing = p.lineComment
+		p.lineComment = nil
+	}
+
+	return stmt
+}
+
+// synchronize は構文エラーの後で文の終わりまで token を読み飛ばす.
+// depth の深さの ';' と '}' で止まり, 外側の '}' と proc と global の前で止まる.
+// for の () の中の ';' では止まらないが, 行末の ';' では止まる
+func (p *Parser) synchronize(start token.Token, depth, parens int) {
+	for !p.curTokenIs(token.EOF) && p.depth >= depth {
+		if p.depth == depth && p.curTokenIs(token.Semicolon) &&
+			(start.Type != token.For || p.parens <= parens || p.peekToken.Row > p.curToken.Row) {
+			return
+		}
+		if p.depth == depth && p.curTokenIs(token.Rbrace) {
+			return
+		}
+		if p.peekTokenIs(token.EOF) || p.peekTokenIs(token.Proc) {
+			return
+		}
+		if p.depth == depth && (p.peekTokenIs(token.Rbrace) || p.peekTokenIs(token.Global)) {
+			return
+		}
+		p.nextToken()
+	}
+}
+
+func (p *Parser) parseStatementNode() ast.Statement {
 	switch p.curToken.Type {
 	case token.Global:
 		return p.parseGlobalStatement()
@@ -211,34 +309,49 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
 	}
+	// command style の呼び出しは ';' まで読んでいる
+	if p.curTokenIs(token.Semicolon) {
+		stmt.Semicolon = p.curToken
+	}
 
 	return stmt
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	start := p.curToken
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken)
-		return nil
+		return &ast.BadExpression{From: start, To: start}
+	}
+	// 構文エラーで式が作れない時は BadExpression にする
+	bad := func(exp ast.Expression) ast.Expression {
+		if exp == nil {
+			return &ast.BadExpression{From: start, To: p.curToken}
+		}
+		return exp
 	}
 	leftExp := prefix()
+	if leftExp == nil {
+		return bad(leftExp)
+	}
 
 	if p.curTokenIs(token.ProcIdent) &&
 		!p.peekTokenIs(token.Lparen) &&
 		nil != p.prefixParseFns[p.peekToken.Type] &&
 		p.commandStyleMode == false {
-		leftExp = p.parseCommandCallExpression(leftExp)
+		leftExp = bad(p.parseCommandCallExpression(leftExp))
 	}
 
 	for !p.peekTokenIs(token.Semicolon) && precedence < p.peekPrecedence() {
 		ternary := p.ternaryParseFns[p.peekToken.Type]
 		if ternary != nil {
-			leftExp = ternary(leftExp)
+			leftExp = bad(ternary(leftExp))
 		}
 
 		postfix := p.postfixParseFns[p.peekToken.Type]
 		if postfix != nil {
-			leftExp = postfix(leftExp)
+			leftExp = bad(postfix(leftExp))
 		}
 		if p.commandStyleMode && p.peekTokenIs(token.Lparen) {
 			return leftExp
@@ -250,7 +363,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 		p.nextToken()
 
-		leftExp = infix(leftExp)
+		leftExp = bad(infix(leftExp))
 	}
 
 	return leftExp
@@ -322,19 +435,73 @@ func (p *Parser) parseTernaryExpression(conditional ast.Expression) ast.Expressi
 func (p *Parser) parseCommandCallExpression(function ast.Expression) ast.Expression {
 	ident, ok := function.(*ast.Identifier)
 	if !ok {
+		p.errorf(InvalidCall, p.curToken, "can not call %s", function.String())
 		return nil
 	}
-	exp := &ast.CallExpression{Token: p.curToken, Function: ident}
+	exp := &ast.CallExpression{Token: p.curToken, Function: ident, Syntax: ast.CommandCall}
 
 	preCommandMode := p.commandStyleMode
 	p.commandStyleMode = true
 	defer func() { p.commandStyleMode = preCommandMode }()
 
-	exp.Arguments = p.parseCommandCallArguments()
+	exp.Arguments = p.groupFlags(ident.Value, p.parseCommandCallArguments())
 
 	return exp
 }
 
+// groupFlags は command style の引数の flag とその値を ast.FlagArgument にまとめる.
+// flag の値より後ろの引数は object なのでそのまま残す. ex) select -r a b;
+func (p *Parser) groupFlags(command string, args []ast.Expression) []ast.Expression {
+	query := false
+	for _, arg := range args {
+		if isFlag(arg, "-q") || isFlag(arg, "-query") {
+			query = true
+		}
+	}
+
+	var grouped []ast.Expression
+	for i := 0; i < len(args); i++ {
+		ident, ok := args[i].(*ast.Identifier)
+		if !ok || ident.Token.Type != token.Flag {
+			grouped = append(grouped, args[i])
+			continue
+		}
+		flag := &ast.FlagArgument{Token: ident.Token, Flag: ident.Value}
+		grouped = append(grouped, flag)
+
+		// 次の flag までの引数
+		next := i + 1
+		for next < len(args) && !isFlag(args[next], "") {
+			next++
+		}
+		n, known := 0, false
+		if p.flagArity != nil {
+			n, known = p.flagArity(command, ident.Value, query)
+		}
+		if !known {
+			// 次の flag までの引数は flag の値. 最後の flag の後ろの引数は object かもしれないので残す
+			n = 0
+			if next < len(args) {
+				n = next - i - 1
+			}
+		}
+		if n > next-i-1 {
+			n = next - i - 1
+		}
+		if n > 0 {
+			flag.Values = args[i+1 : i+1+n : i+1+n]
+			i += n
+		}
+	}
+	return grouped
+}
+
+// isFlag は arg が flag かどうかを返す. name が "" でなければ flag の名前も比べる
+func isFlag(arg ast.Expression, name string) bool {
+	ident, ok := arg.(*ast.Identifier)
+	return ok && ident.Token.Type == token.Flag && (name == "" || ident.Value == name)
+}
+
 func (p *Parser) parseCommandCallArguments() []ast.Expression {
 	var args []ast.Expression
 
@@ -352,7 +519,8 @@ func (p *Parser) parseCommandCallArguments() []ast.Expression {
 	}
 
 	if !p.peekTokenIs(token.Semicolon) {
-		return nil
+		p.peekError(token.Semicolon)
+		return args
 	}
 	p.nextToken()
 
@@ -362,7 +530,7 @@ func (p *Parser) parseCommandCallArguments() []ast.Expression {
 // like this:
 //   `add 1 (2 + 3) a "b"`;
 func (p *Parser) parseBackQuotesCallExpression() ast.Expression {
-	exp := &ast.CallExpression{Token: p.curToken}
+	exp := &ast.CallExpression{Token: p.curToken, Syntax: ast.BackQuoteCall}
 	if p.peekTokenIs(token.ProcIdent) {
 		p.nextToken()
 		identExp := p.parseIdentifier()
@@ -372,6 +540,7 @@ func (p *Parser) parseBackQuotesCallExpression() ast.Expression {
 		}
 		exp.Function = ident
 	} else {
+		p.peekError(token.ProcIdent)
 		return nil
 	}
 
@@ -379,7 +548,10 @@ func (p *Parser) parseBackQuotesCallExpression() ast.Expression {
 	p.commandStyleMode = true
 	defer func() { p.commandStyleMode = preCommandMode }()
 
-	exp.Arguments = p.parseBackQuotesCallArguments()
+	exp.Arguments = p.groupFlags(exp.Function.Value, p.parseBackQuotesCallArguments())
+	if p.curTokenIs(token.BackQuotes) {
+		exp.Close = p.curToken
+	}
 
 	return exp
 }
@@ -395,12 +567,13 @@ func (p *Parser) parseBackQuotesCallArguments() []ast.Expression {
 	p.nextToken()
 	args = append(args, p.parseExpression(LOWEST))
 
-	for !p.peekTokenIs(token.BackQuotes) && !p.peekTokenIs(token.EOF) {
+	for !p.peekTokenIs(token.BackQuotes) && !p.peekTokenIs(token.Semicolon) && !p.peekTokenIs(token.EOF) {
 		p.nextToken()
 		args = append(args, p.parseExpression(LOWEST))
 	}
 
 	if !p.peekTokenIs(token.BackQuotes) {
+		p.peekError(token.BackQuotes)
 		return nil
 	}
 	p.nextToken()
@@ -413,6 +586,7 @@ func (p *Parser) parseBackQuotesCallArguments() []ast.Expression {
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	ident, ok := function.(*ast.Identifier)
 	if !ok {
+		p.errorf(InvalidCall, p.curToken, "can not call %s", function.String())
 		return nil
 	}
 	exp := &ast.CallExpression{Token: p.curToken, Function: ident}
@@ -420,6 +594,7 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	if p.peekTokenIs(token.Rparen) {
 		// no arguments call expression.
 		p.nextToken()
+		exp.Close = p.curToken
 		return exp
 	}
 
@@ -442,11 +617,13 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 		if !p.expectPeek(token.Rparen) {
 			return nil
 		}
+		exp.Close = p.curToken
 		return exp
 	}
 
 	if p.peekTokenIs(token.Rparen) {
 		p.nextToken()
+		exp.Close = p.curToken
 
 		preCommandMode := p.commandStyleMode
 		p.commandStyleMode = true
@@ -456,6 +633,11 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 			p.nextToken()
 			exp.Arguments = append(exp.Arguments, p.parseExpression(LOWEST))
 		}
+		if len(exp.Arguments) > 1 {
+			// setAttr ($n + ".t") -type double3 1 2 3; は command style
+			exp.Syntax = ast.CommandCall
+			exp.Arguments = p.groupFlags(ident.Value, exp.Arguments)
+		}
 		return exp
 	}
 
@@ -468,6 +650,7 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	p.nextToken()
 	if p.curTokenIs(token.Rbracket) {
 		exp.Index = nil
+		exp.Rbracket = p.curToken
 		return exp
 	}
 
@@ -480,6 +663,7 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	if !p.expectPeek(token.Rbracket) {
 		return nil
 	}
+	exp.Rbracket = p.curToken
 
 	return exp
 }
@@ -491,6 +675,7 @@ func (p *Parser) parseVariableStatement() ast.Statement {
 
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
+		stmt.Semicolon = p.curToken
 	}
 
 	return stmt
@@ -507,6 +692,7 @@ func (p *Parser) parseMatrixStatement() ast.Statement {
 
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
+		stmt.Semicolon = p.curToken
 	}
 
 	return stmt
@@ -523,6 +709,7 @@ func (p *Parser) parseVectorStatement() ast.Statement {
 
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
+		stmt.Semicolon = p.curToken
 	}
 
 	return stmt
@@ -539,6 +726,7 @@ func (p *Parser) parseIntegerStatement() ast.Statement {
 
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
+		stmt.Semicolon = p.curToken
 	}
 
 	return stmt
@@ -555,6 +743,7 @@ func (p *Parser) parseFloatStatement() ast.Statement {
 
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
+		stmt.Semicolon = p.curToken
 	}
 
 	return stmt
@@ -571,6 +760,7 @@ func (p *Parser) parseStringStatement() ast.Statement {
 
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
+		stmt.Semicolon = p.curToken
 	}
 
 	return stmt
@@ -647,6 +837,7 @@ func (p *Parser) parseBreakStatement() ast.Statement {
 
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
+		stmt.Semicolon = p.curToken
 	}
 
 	return stmt
@@ -657,6 +848,7 @@ func (p *Parser) parseContinueStatement() ast.Statement {
 
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
+		stmt.Semicolon = p.curToken
 	}
 
 	return stmt
@@ -665,23 +857,32 @@ func (p *Parser) parseContinueStatement() ast.Statement {
 func (p *Parser) parseReturnStatement() ast.Statement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
+	// return; は値を返さない
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+		stmt.Semicolon = p.curToken
+		return stmt
+	}
+
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
+		stmt.Semicolon = p.curToken
 	}
 
 	return stmt
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	lparen := p.curToken
 	p.nextToken()
 
 	if p.curTokenIsDec() && !p.peekTokenIs(token.Lparen) {
 		// CastExpression
-		cast := &ast.CastExpression{Token: p.curToken}
+		cast := &ast.CastExpression{Token: p.curToken, Lparen: lparen}
 		if !p.expectPeek(token.Rparen) {
 			return nil
 		}
@@ -718,12 +919,10 @@ func (p *Parser) parseProcStatement() ast.Statement {
 
 	lit.ReturnType = p.parseTypeDeclaration()
 
-	if p.peekTokenIs(token.ProcIdent) {
-		p.nextToken()
-		lit.Name = p.curToken
-	} else {
+	if !p.expectPeek(token.ProcIdent) {
 		return nil
 	}
+	lit.Name = p.curToken
 
 	if !p.expectPeek(token.Lparen) {
 		return nil
@@ -750,6 +949,10 @@ func (p *Parser) parseProcParameters() ([]*ast.TypeDeclaration, []ast.Expression
 	}
 
 	typeDeclaration := p.parseTypeDeclaration()
+	if typeDeclaration == nil {
+		p.typeError()
+		return nil, nil
+	}
 	typeDeclarations = append(typeDeclarations, typeDeclaration)
 	p.nextToken()
 	identifier := p.parseExpression(LOWEST)
@@ -758,6 +961,10 @@ func (p *Parser) parseProcParameters() ([]*ast.TypeDeclaration, []ast.Expression
 	for p.peekTokenIs(token.Comma) {
 		p.nextToken()
 		typeDeclaration := p.parseTypeDeclaration()
+		if typeDeclaration == nil {
+			p.typeError()
+			return nil, nil
+		}
 		typeDeclarations = append(typeDeclarations, typeDeclaration)
 		p.nextToken()
 		identifier := p.parseExpression(LOWEST)
@@ -789,7 +996,9 @@ func (p *Parser) parseTypeDeclaration() *ast.TypeDeclaration {
 			if p.peekTokenIs(token.Rbracket) {
 				p.nextToken()
 				td.IsArray = true
+				td.Rbracket = p.curToken
 			} else {
+				p.peekError(token.Rbracket)
 				return nil
 			}
 		}
@@ -827,6 +1036,7 @@ func (p *Parser) parseForExpression() ast.Expression {
 		}
 
 		if !p.curTokenIs(token.Semicolon) {
+			p.curError(token.Semicolon)
 			return nil
 		}
 		p.nextToken()
@@ -844,6 +1054,7 @@ func (p *Parser) parseForExpression() ast.Expression {
 		}
 
 		if !p.curTokenIs(token.Rparen) {
+			p.curError(token.Rparen)
 			return nil
 		}
 		p.nextToken()
@@ -860,6 +1071,7 @@ func (p *Parser) parseForExpression() ast.Expression {
 		p.nextToken()
 		ident, ok := names[0].(*ast.Identifier)
 		if !ok {
+			p.errorf(InvalidForIn, forToken, "for-in element must be a variable. got=%s", names[0].String())
 			return nil
 		}
 
@@ -883,6 +1095,7 @@ func (p *Parser) parseForExpression() ast.Expression {
 		return exp
 
 	} else {
+		p.errorf(UnexpectedToken, p.curToken, "expected ';' or 'in' in for got '%s' instead", p.curToken.Type)
 		return nil
 	}
 }
@@ -911,6 +1124,7 @@ func (p *Parser) parseDoWhileExpression() ast.Expression {
 	if !p.expectPeek(token.Rparen) {
 		return nil
 	}
+	expression.Rparen = p.curToken
 
 	return expression
 }
@@ -980,6 +1194,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	depth := p.depth
 
 	p.nextToken()
 
@@ -988,8 +1203,19 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
+		if p.depth < depth {
+			// 構文エラーの文がこのブロックの '}' まで読んだ
+			break
+		}
 		p.nextToken()
 	}
+	if p.curTokenIs(token.Rbrace) {
+		block.Rbrace = p.curToken
+	} else if p.curTokenIs(token.EOF) {
+		// 閉じていない '{' の位置で報告する
+		p.addError(UnexpectedToken, block.Token, token.Rbrace, "expected '}' to close '{' got 'EOF' instead")
+		p.errors[len(p.errors)-1].Actual = token.EOF
+	}
 
 	return block
 }
@@ -1000,6 +1226,8 @@ func (p *Parser) parseSingleBlockStatement() *ast.BlockStatement {
 		Literal: "{",
 		Row:     p.curToken.Row,
 		Column:  p.curToken.Column,
+		Offset:  p.curToken.Offset,
+		Pos:     p.curToken.Pos,
 	}}
 	block.Statements = []ast.Statement{}
 
@@ -1030,11 +1258,15 @@ func (p *Parser) parseSwitchExpression() ast.Expression {
 
 	for p.peekTokenIs(token.Case) {
 		p.nextToken()
+		keyword := p.curToken
 		p.nextToken()
 
 		litExp := p.parseExpression(LOWEST)
 		literal, ok := litExp.(ast.Literal)
 		if !ok {
+			if _, bad := litExp.(*ast.BadExpression); !bad {
+				p.errorf(InvalidCaseLabel, p.curToken, "case label must be a literal. got=%s", litExp.String())
+			}
 			return nil
 		}
 		exp.Cases = append(exp.Cases, literal)
@@ -1042,27 +1274,29 @@ func (p *Parser) parseSwitchExpression() ast.Expression {
 		if !p.expectPeek(token.Coron) {
 			return nil
 		}
-		exp.CaseStatements = append(exp.CaseStatements, p.parseCaseStatement())
+		exp.CaseStatements = append(exp.CaseStatements, p.parseCaseStatement(keyword))
 	}
 
 	if p.peekTokenIs(token.Default) {
 		p.nextToken()
+		keyword := p.curToken
 		exp.Cases = append(exp.Cases, nil)
 		if !p.expectPeek(token.Coron) {
 			return nil
 		}
-		exp.CaseStatements = append(exp.CaseStatements, p.parseCaseStatement())
+		exp.CaseStatements = append(exp.CaseStatements, p.parseCaseStatement(keyword))
 	}
 
 	if !p.expectPeek(token.Rbrace) {
 		return nil
 	}
+	exp.Rbrace = p.curToken
 
 	return exp
 }
 
-func (p *Parser) parseCaseStatement() *ast.CaseStatement {
-	stmt := &ast.CaseStatement{Token: p.curToken}
+func (p *Parser) parseCaseStatement(keyword token.Token) *ast.CaseStatement {
+	stmt := &ast.CaseStatement{Token: p.curToken, Keyword: keyword}
 
 	for !p.peekTokenIs(token.Case) &&
 		!p.peekTokenIs(token.Default) &&
@@ -1089,8 +1323,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorf(InvalidNumber, p.curToken, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -1104,8 +1337,7 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 
 	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorf(InvalidNumber, p.curToken, "could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -1115,7 +1347,11 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	value, err := lexer.Unquote(p.curToken.Literal)
+	if err != nil {
+		p.errorf(InvalidString, p.curToken, "%s", err)
+	}
+	return &ast.StringLiteral{Token: p.curToken, Value: value}
 }
 
 func (p *Parser) parseTensorLiteral() ast.Expression {
@@ -1133,6 +1369,7 @@ func (p *Parser) parseTensorLiteral() ast.Expression {
 	value = nil
 
 	for p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
 		p.nextToken()
 		value = append(value, p.parseExpression(LOWEST))
 		for p.peekTokenIs(token.Comma) {
@@ -1147,6 +1384,7 @@ func (p *Parser) parseTensorLiteral() ast.Expression {
 	if !p.expectPeek(token.Rtensor) {
 		return nil
 	}
+	tl.Rtensor = p.curToken
 
 	return tl
 }
@@ -1155,6 +1393,12 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
 	array.Elements = p.parseExpressionList(token.Rbrace)
+	if p.curTokenIs(token.Rbrace) {
+		array.Rbrace = p.curToken
+	} else {
+		// 閉じていない '{' をブロックとして数えない
+		p.depth--
+	}
 
 	return array
 }
@@ -1181,9 +1425,8 @@ func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(end) {
-		return nil
-	}
+	// 閉じていなくても, 部分的な AST のために読めた要素は返す
+	p.expectPeek(end)
 
 	return list
 }
@@ -1202,7 +1445,101 @@ func (p *Parser) curTokenIsDec() bool {
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	switch p.curToken.Type {
+	case token.Lbrace:
+		p.depth++
+	case token.Rbrace:
+		p.depth--
+	case token.Lparen:
+		p.parens++
+	case token.Rparen:
+		p.parens--
+	}
+	p.leadComment = p.peekLeadComment
+	p.peekLeadComment = nil
+	p.lineComment = nil
+	p.peekToken = p.readToken()
+	if p.peekTokenIs(token.Comment) {
+		p.readComments()
+	}
+}
+
+// readComments は curToken と peekToken の間にあるコメントを CommentGroup にまとめる
+func (p *Parser) readComments() {
+	var group *ast.CommentGroup
+	if p.peekToken.Row == endRow(p.curToken) {
+		// curToken と同じ行のコメントだけをまとめる
+		group = p.readCommentGroup(0)
+		if p.peekTokenIs(token.EOF) || p.peekToken.Row > endRow(lastComment(group)) {
+			p.lineComment = group
+		}
+	}
+
+	for p.peekTokenIs(token.Comment) {
+		group = p.readCommentGroup(1)
+	}
+
+	if group != nil && group != p.lineComment && endRow(lastComment(group))+1 >= p.peekToken.Row {
+		p.peekLeadComment = group
+	}
+}
+
+// readCommentGroup は n 行以内に続くコメントを一つの CommentGroup にする
+func (p *Parser) readCommentGroup(n int) *ast.CommentGroup {
+	group := &ast.CommentGroup{}
+	row := p.peekToken.Row
+	for p.peekTokenIs(token.Comment) && p.peekToken.Row <= row+n {
+		group.List = append(group.List, &ast.Comment{Token: p.peekToken})
+		row = endRow(p.peekToken)
+		p.peekToken = p.readToken()
+	}
+	p.comments = append(p.comments, group)
+	return group
+}
+
+// readToken は lexer から次の token を読み, lexer のエラーを errors に加える.
+// lexer.ScanWhitespace の空白は読み飛ばす
+func (p *Parser) readToken() token.Token {
+	tok := p.l.NextToken()
+	for tok.Type == token.Whitespace {
+		tok = p.l.NextToken()
+	}
+	errs := p.l.Errors()
+	for _, err := range errs[p.lexed:] {
+		p.errors = append(p.errors, &Error{
+			Filename: p.l.File().Name(),
+			Token:    err.Token,
+			Row:      err.Row,
+			Column:   err.Column,
+			Start:    err.Pos,
+			End:      err.Token.End(),
+			Code:     InvalidToken,
+			Actual:   err.Token.Type,
+			Message:  err.Message,
+		})
+		if p.badTokens == nil {
+			p.badTokens = map[token.Pos]bool{}
+		}
+		p.badTokens[err.Token.Pos] = true
+	}
+	p.lexed = len(errs)
+	return tok
+}
+
+func lastComment(group *ast.CommentGroup) token.Token {
+	return group.List[len(group.List)-1].Token
+}
+
+// endRow は tok の最後の文字がある行を返す
+func endRow(tok token.Token) int {
+	row := tok.Row
+	runes := []rune(tok.Literal)
+	for i, r := range runes {
+		if r == '\n' || r == '\r' && (i+1 == len(runes) || runes[i+1] != '\n') {
+			row++
+		}
+	}
+	return row
 }
 
 func (p *Parser) curTokenIs(t token.Type) bool {
@@ -1223,9 +1560,41 @@ func (p *Parser) expectPeek(t token.Type) bool {
 }
 
 func (p *Parser) peekError(t token.Type) {
-	msg := fmt.Sprintf("line:%d.%d expected next token to be '%s' got '%s' instead",
-		p.peekToken.Row, p.peekToken.Column, t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	msg := fmt.Sprintf("expected next token to be '%s' got '%s' instead", t, p.peekToken.Type)
+	p.addError(UnexpectedToken, p.peekToken, t, msg)
+}
+
+func (p *Parser) curError(t token.Type) {
+	msg := fmt.Sprintf("expected token to be '%s' got '%s' instead", t, p.curToken.Type)
+	p.addError(UnexpectedToken, p.curToken, t, msg)
+}
+
+func (p *Parser) typeError() {
+	p.errorf(UnexpectedToken, p.peekToken, "expected type of parameter got '%s' instead", p.peekToken.Type)
+}
+
+func (p *Parser) errorf(code ErrorCode, t token.Token, format string, a ...interface{}) {
+	p.addError(code, t, "", fmt.Sprintf(format, a...))
+}
+
+// addError は t の位置のエラーを記録する.
+// lexer がエラーにした token のエラーは重ねて報告しない
+func (p *Parser) addError(code ErrorCode, t token.Token, expected token.Type, msg string) {
+	if p.badTokens[t.Pos] && t.Pos.IsValid() {
+		return
+	}
+	p.errors = append(p.errors, &Error{
+		Filename: p.l.File().Name(),
+		Token:    t,
+		Row:      t.Row,
+		Column:   t.Column,
+		Start:    t.Pos,
+		End:      t.End(),
+		Code:     code,
+		Expected: expected,
+		Actual:   t.Type,
+		Message:  msg,
+	})
 }
 
 func (p *Parser) registerPrefix(tokenType token.Type, fn prefixParseFn) {
@@ -1245,9 +1614,8 @@ func (p *Parser) registerTernary(tokenType token.Type, fn ternaryParseFn) {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.Token) {
-	msg := fmt.Sprintf("line:%d.%d no prefix parse function for %s found.",
-		t.Row, t.Column, t.Type)
-	p.errors = append(p.errors, msg)
+	msg := fmt.Sprintf("no prefix parse function for %s found.", t.Type)
+	p.addError(NoPrefixParseFn, t, "", msg)
 }
 
 func (p *Parser) peekPrecedence() int {
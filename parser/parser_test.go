@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/nrtkbb/go-MEL/ast"
@@ -315,7 +316,7 @@ func TestCallExpressionParsing3(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[1], 2, "+", 3)
 	testIdentifier(t, exp.Arguments[3], "a")
 	testLiteralExpression(t, exp.Arguments[4], `"b"`)
-	testIdentifier(t, exp.Arguments[5], "-flag")
+	testFlagArgument(t, exp.Arguments[5], "-flag", 0)
 
 	call, ok := exp.Arguments[2].(*ast.CallExpression)
 	if !ok {
@@ -354,6 +355,127 @@ func TestCallExpressionParsing3(t *testing.T) {
 	testLiteralExpression(t, call2.Arguments[0], 1)
 }
 
+func TestFlagArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		syntax   ast.CallSyntax
+		flags    []string
+		values   []int
+		expected string
+	}{
+		{`setAttr -type "string" $n $v;`, ast.CommandCall,
+			[]string{"-type"}, []int{0}, `setAttr(-type, "string", $n, $v)`},
+		{`polyCube -w 2 -h 3 -ch off;`, ast.CommandCall,
+			[]string{"-w", "-h", "-ch"}, []int{1, 1, 0}, `polyCube(-w, 2, -h, 3, -ch, off)`},
+		{`select -r -add pCube1;`, ast.CommandCall,
+			[]string{"-r", "-add"}, []int{0, 0}, `select(-r, -add, pCube1)`},
+		// signature が無い時は最後の flag の後ろは object
+		{`select -r pCube1 pCube2;`, ast.CommandCall,
+			[]string{"-r"}, []int{0}, `select(-r, pCube1, pCube2)`},
+		{"`ls -sl`;", ast.BackQuoteCall, []string{"-sl"}, []int{0}, `ls(-sl)`},
+		{`setAttr($n, 1);`, ast.FunctionCall, nil, nil, `setAttr($n, 1)`},
+		{`setAttr ($n) -k on;`, ast.CommandCall, []string{"-k"}, []int{0}, `setAttr($n, -k, on)`},
+		{`window -wh 100 200 -t "x" myWindow;`, ast.CommandCall,
+			[]string{"-wh", "-t"}, []int{2, 0}, `window(-wh, 100, 200, -t, "x", myWindow)`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		call, ok := stmt.Expression.(*ast.CallExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+		}
+		if call.Syntax != tt.syntax {
+			t.Errorf("input %q: call.Syntax wrong. want=%s, got=%s", tt.input, tt.syntax, call.Syntax)
+		}
+		if call.String() != tt.expected {
+			t.Errorf("input %q: call.String() wrong. want=%s, got=%s", tt.input, tt.expected, call.String())
+		}
+
+		var flags []ast.Expression
+		for _, arg := range call.Arguments {
+			if _, ok := arg.(*ast.FlagArgument); ok {
+				flags = append(flags, arg)
+			}
+		}
+		if len(flags) != len(tt.flags) {
+			t.Errorf("input %q: wrong number of flags. want=%d, got=%d", tt.input, len(tt.flags), len(flags))
+			continue
+		}
+		for i, flag := range flags {
+			testFlagArgument(t, flag, tt.flags[i], tt.values[i])
+		}
+	}
+}
+
+func TestFlagArity(t *testing.T) {
+	arity := func(command, flag string, query bool) (int, bool) {
+		if query {
+			return 0, true
+		}
+		switch command + " " + flag {
+		case "setAttr -type":
+			return 1, true
+		case "select -r":
+			return 0, true
+		case "move -r":
+			return 0, true
+		case "polyCube -ax":
+			return 3, true
+		}
+		return 0, false
+	}
+
+	tests := []struct {
+		input   string
+		flags   []string
+		values  []int
+		objects int // FlagArgument でない引数の数
+	}{
+		{`setAttr -type "string" $n $v;`, []string{"-type"}, []int{1}, 2},
+		{`setAttr $n -type "double3" 1 2 3;`, []string{"-type"}, []int{1}, 4},
+		{`select -r a b;`, []string{"-r"}, []int{0}, 2},
+		{"`select -r a b`;", []string{"-r"}, []int{0}, 2},
+		{`move -r 1 2 3 pCube1;`, []string{"-r"}, []int{0}, 4},
+		{`polyCube -ax 0 1 -w 2;`, []string{"-ax", "-w"}, []int{2, 0}, 1},
+		{`polyCube -q -ax pCube1;`, []string{"-q", "-ax"}, []int{0, 0}, 1},
+		{`myCommand -a 1 2 -b 3 4;`, []string{"-a", "-b"}, []int{2, 0}, 2},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.SetFlagArity(arity)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+		var flags []ast.Expression
+		objects := 0
+		for _, arg := range call.Arguments {
+			if _, ok := arg.(*ast.FlagArgument); ok {
+				flags = append(flags, arg)
+			} else {
+				objects++
+			}
+		}
+		if len(flags) != len(tt.flags) {
+			t.Errorf("input %q: wrong number of flags. want=%d, got=%d", tt.input, len(tt.flags), len(flags))
+			continue
+		}
+		for i, flag := range flags {
+			testFlagArgument(t, flag, tt.flags[i], tt.values[i])
+		}
+		if objects != tt.objects {
+			t.Errorf("input %q: wrong number of objects. want=%d, got=%d", tt.input, tt.objects, objects)
+		}
+	}
+}
+
 func TestCallExpressionParsing2(t *testing.T) {
 	input := "`add 1 (2 + 3) x $y`;"
 
@@ -624,7 +746,7 @@ func TestForExpressionPrefix(t *testing.T) {
 	}
 
 	if len(exp.ChangeOfs) != 2 {
-		t.Fatalf("len(exp.ChangeOfs) is not 2. got=%q", len(exp.ChangeOfs))
+		t.Fatalf("len(exp.ChangeOfs) is not 2. got=%d", len(exp.ChangeOfs))
 	}
 
 	consequence, ok := exp.Consequence.Statements[0].(*ast.StringStatement)
@@ -1260,6 +1382,27 @@ func TestReturnStatement(t *testing.T) {
 	}
 }
 
+func TestEmptyReturnStatement(t *testing.T) {
+	l := lexer.New(`return; print "a";`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ReturnStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ReturnStatement. got=%T",
+			program.Statements[0])
+	}
+	if stmt.ReturnValue != nil {
+		t.Errorf("stmt.ReturnValue is not nil. got=%s", stmt.ReturnValue.String())
+	}
+}
+
 func TestMatrixStatement(t *testing.T) {
 	tests := []struct {
 		input              string
@@ -1271,11 +1414,13 @@ func TestMatrixStatement(t *testing.T) {
 		{`matrix $x[2][3] = <<1, 2>>;`, "$x", 3, 2, nil},
 		{`matrix $y[1][1] = <<1>>;`, "$y", 1, 1, nil},
 		{`matrix $foobar[1][1] = <<123123>>`, "$foobar", 1, 1, nil},
+		{`matrix $z[2][2] = <<1, 2; 3, 4>>;`, "$z", 2, 2, nil},
 	}
 
 	tests[0].expectedValue = append(tests[0].expectedValue, []float64{1, 2})
 	tests[1].expectedValue = append(tests[1].expectedValue, []float64{1})
 	tests[2].expectedValue = append(tests[2].expectedValue, []float64{123123})
+	tests[3].expectedValue = append(tests[3].expectedValue, []float64{1, 2}, []float64{3, 4})
 
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -1605,6 +1750,402 @@ func TestStringStatement(t *testing.T) {
 	}
 }
 
+func TestComments(t *testing.T) {
+	input := `// header
+
+// doc of foo
+// second line
+global proc foo() {
+	/* first */ int $a = 1; // trailing
+	// dangling
+}
+int $b; /* same line */ int $c;
+global int $g; // global
+// eof`
+
+	l := lexer.NewWithMode(input, lexer.ScanComments)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Comments) != 8 {
+		t.Fatalf("program.Comments does not contain 8 groups. got=%d", len(program.Comments))
+	}
+
+	tests := []struct {
+		stmt     ast.Statement
+		leading  string
+		trailing string
+	}{
+		{program.Statements[0].(*ast.GlobalStatement).Statement, "doc of foo\nsecond line", ""},
+		{program.Statements[0].(*ast.GlobalStatement).Statement.(*ast.ProcStatement).Body.Statements[0], "first", "trailing"},
+		{program.Statements[1], "", ""},
+		{program.Statements[2], "same line", ""},
+		{program.Statements[3].(*ast.GlobalStatement).Statement, "", "global"},
+	}
+
+	for i, tt := range tests {
+		cs, ok := tt.stmt.(ast.CommentedStatement)
+		if !ok {
+			t.Fatalf("tests[%d] - stmt is not ast.CommentedStatement. got=%T", i, tt.stmt)
+		}
+		if got := cs.Attached().Leading.Text(); got != tt.leading {
+			t.Errorf("tests[%d] - leading wrong. expected=%q, got=%q", i, tt.leading, got)
+		}
+		if got := cs.Attached().Trailing.Text(); got != tt.trailing {
+			t.Errorf("tests[%d] - trailing wrong. expected=%q, got=%q", i, tt.trailing, got)
+		}
+	}
+
+	// コメントを読まない時はコメントは付かない
+	program = New(lexer.New(input)).ParseProgram()
+	if len(program.Comments) != 0 {
+		t.Errorf("program.Comments should be empty. got=%d", len(program.Comments))
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`int $a = 1;`, `int $a = 1;`},
+		{`$a = 1 + 2`, `$a = 1 + 2`},
+		{`print ("あ" + $b);`, `print ("あ" + $b);`},
+		{`setAttr ".tx" 1;`, `setAttr ".tx" 1;`},
+		{"string $s = `ls -sl`;", "string $s = `ls -sl`;"},
+		{`vector $v = <<1, 2, 3>>;`, `vector $v = <<1, 2, 3>>;`},
+		{`int $a[] = {1, 2};`, `int $a[] = {1, 2};`},
+		{`$a[0] = (int) $f;`, `$a[0] = (int) $f;`},
+		{`if ($a) { print 1; } else print 2;`, `if ($a) { print 1; } else print 2;`},
+		{`do { $i++; } while ($i < 3);`, `do { $i++; } while ($i < 3);`},
+		{`switch ($a) { case 1: break; }`, `switch ($a) { case 1: break; }`},
+		{`global proc string[] foo(int $a) { return; }`, `global proc string[] foo(int $a) { return; }`},
+		{`for ($e in $a) print $e;`, `for ($e in $a) print $e;`},
+		{`return $c ? 1 : 2;`, `return $c ? 1 : 2;`},
+	}
+
+	for _, tt := range tests {
+		input := "  " + tt.input + "  "
+		l := lexer.New(input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0]
+		file := l.File()
+		got := input[file.Offset(stmt.Pos()):file.Offset(stmt.End())]
+		if got != tt.expected {
+			t.Errorf("input %q: wrong range. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCaseStatementPositions(t *testing.T) {
+	input := "switch ($a) {\n\tcase \"x\":\n\t\tprint 1;\n\t\tbreak;\n\tdefault :\n\t\tprint 2;\n}"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	se := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SwitchExpression)
+	expected := []string{
+		"case \"x\":\n\t\tprint 1;\n\t\tbreak;",
+		"default :\n\t\tprint 2;",
+	}
+	if len(se.CaseStatements) != len(expected) {
+		t.Fatalf("len(se.CaseStatements) is not %d. got=%d", len(expected), len(se.CaseStatements))
+	}
+	file := l.File()
+	for i, cs := range se.CaseStatements {
+		got := input[file.Offset(cs.Pos()):file.Offset(cs.End())]
+		if got != expected[i] {
+			t.Errorf("CaseStatements[%d]: wrong range. expected=%q, got=%q", i, expected[i], got)
+		}
+	}
+}
+
+func TestFileSetPositions(t *testing.T) {
+	fset := token.NewFileSet()
+	sources := []struct {
+		name  string
+		input string
+	}{
+		{"a.mel", "int $a;\nprint $a;"},
+		{"b.mel", "proc foo() {\n\tprint \"b\";\n}"},
+	}
+
+	var programs []*ast.Program
+	for _, src := range sources {
+		file := fset.AddFile(src.name, len(src.input))
+		p := New(lexer.NewFile(file, src.input, 0))
+		programs = append(programs, p.ParseProgram())
+		checkParserErrors(t, p)
+	}
+
+	print := programs[0].Statements[1]
+	if pos := fset.Position(print.Pos()); pos.String() != "a.mel:2:1" {
+		t.Errorf("wrong position. expected=%q, got=%q", "a.mel:2:1", pos)
+	}
+	body := programs[1].Statements[0].(*ast.ProcStatement).Body
+	if pos := fset.Position(body.Statements[0].Pos()); pos.String() != "b.mel:2:2" {
+		t.Errorf("wrong position. expected=%q, got=%q", "b.mel:2:2", pos)
+	}
+	if pos := fset.Position(body.End()); pos.String() != "b.mel:3:2" || pos.Offset != 26 {
+		t.Errorf("wrong position. expected=%q, got=%q (offset %d)", "b.mel:3:2", pos, pos.Offset)
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		errors     []string
+		statements []string
+	}{
+		{
+			"int $a = ;\nprint \"ok\";",
+			[]string{"line:1.10 no prefix parse function for ; found."},
+			[]string{"int $a = <bad expression>;", `print("ok")`},
+		},
+		{
+			"proc () { int $a; }\nproc bar() { print 1; }",
+			[]string{"line:1.6 expected next token to be 'ProcIdent' got '(' instead"},
+			[]string{"<bad statement>", "proc (){ print(1) }"},
+		},
+		{
+			"proc foo() { print( ; print 2; }\nglobal proc bar() {}",
+			[]string{"line:1.21 no prefix parse function for ; found."},
+			[]string{"proc (){ print(<bad expression>)print(2) }", "global proc (){  }"},
+		},
+		{
+			"switch ($a) { case $b: print 1; break; }\nprint 3;",
+			[]string{"line:1.20 case label must be a literal. got=$b"},
+			[]string{"<bad expression>", "print(3)"},
+		},
+		{
+			"for ($i = 0 $i < 3; $i++) print 1;\nprint 4;",
+			[]string{"line:1.13 expected ';' or 'in' in for got 'Ident' instead"},
+			[]string{"<bad expression>", "print(4)"},
+		},
+		{
+			"proc foo() { if ($a { print 1; } print 2; }\nprint 5;",
+			[]string{"line:1.21 expected next token to be ')' got '{' instead"},
+			[]string{"proc (){ <bad expression>print(2) }", "print(5)"},
+		},
+		{
+			"proc foo() { foo( }\nprint 6;",
+			[]string{"line:1.19 no prefix parse function for } found."},
+			[]string{"proc (){ foo(<bad expression>) }", "print(6)"},
+		},
+		{
+			"int $a[] = {1, 2;\nprint 7;",
+			[]string{"line:1.17 expected next token to be '}' got ';' instead"},
+			[]string{"int ($a[]) = {1, 2};", "print(7)"},
+		},
+		{
+			"string $s = `ls -sl;\nprint 8;",
+			[]string{"line:1.20 expected next token to be '`' got ';' instead"},
+			[]string{"string $s = ls();", "print(8)"},
+		},
+		{
+			"print 1 2",
+			[]string{"line:1.10 expected next token to be ';' got 'EOF' instead"},
+			[]string{"print(1, 2)"},
+		},
+		{
+			// lexer のエラーは "no prefix parse function" と重ねて報告しない
+			"$a = $b & $c;\nprint 11;",
+			[]string{"line:1.9 illegal character '&', did you mean '&&'?"},
+			[]string{"$a = $b;", "print(11)"},
+		},
+		{
+			"int $a = 0x;\nfloat $b = 1e;\nprint 12;",
+			[]string{"line:1.10 hexadecimal literal has no digits", "line:2.13 exponent has no digits"},
+			[]string{"int $a = <bad expression>;", "float $b = <bad expression>;", "print(12)"},
+		},
+		{
+			// 閉じていない '{' は '{' の位置で報告する
+			"proc f() { print 1;",
+			[]string{"line:1.10 expected '}' to close '{' got 'EOF' instead"},
+			[]string{"proc (){ print(1) }"},
+		},
+		{
+			"if (1) { print 1;\nprint 2;",
+			[]string{"line:1.8 expected '}' to close '{' got 'EOF' instead"},
+			[]string{"if 1 { print(1)print(2) }"},
+		},
+		{
+			"proc f() {\n\tif (1) {\n\t\tls;\n",
+			[]string{"line:1.10 expected '}' to close '{' got 'EOF' instead", "line:2.9 expected '}' to close '{' got 'EOF' instead"},
+			[]string{"proc (){ if 1 { ls } }"},
+		},
+		{
+			"proc int[ foo() {}\nprint 10;",
+			[]string{"line:1.11 expected next token to be ']' got 'ProcIdent' instead"},
+			[]string{"proc (){  }", "print(10)"},
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		var errors []string
+		for _, err := range p.Errors() {
+			errors = append(errors, err.Error())
+		}
+		if fmt.Sprint(errors) != fmt.Sprint(tt.errors) {
+			t.Errorf("input %q: wrong errors.\nexpected=%q\ngot=%q", tt.input, tt.errors, errors)
+		}
+
+		var statements []string
+		for _, stmt := range program.Statements {
+			statements = append(statements, stmt.String())
+		}
+		if fmt.Sprint(statements) != fmt.Sprint(tt.statements) {
+			t.Errorf("input %q: wrong statements.\nexpected=%q\ngot=%q", tt.input, tt.statements, statements)
+		}
+	}
+}
+
+func TestStringLiteralValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		errors   []string
+	}{
+		{`"a\tb\nc\r";`, "a\tb\nc\r", nil},
+		{`"say \"hi\" \\ bye";`, `say "hi" \ bye`, nil},
+		{`"日本語\n";`, "日本語\n", nil},
+		{`"";`, "", nil},
+		{`"a\db";`, "adb", []string{"line:1.1 unknown escape sequence \\d"}},
+		{`"abc`, "abc", []string{"line:1.1 string literal not terminated"}},
+		{`"abc\`, "abc", []string{"line:1.1 string literal not terminated"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		var errors []string
+		for _, err := range p.Errors() {
+			if err.Code == InvalidString || err.Code == InvalidToken {
+				errors = append(errors, err.Error())
+			}
+		}
+		if fmt.Sprint(errors) != fmt.Sprint(tt.errors) {
+			t.Errorf("input %q: wrong errors.\nexpected=%q\ngot=%q", tt.input, tt.errors, errors)
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		sl, ok := stmt.Expression.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("input %q: not *ast.StringLiteral. got=%T", tt.input, stmt.Expression)
+		}
+		if sl.Value != tt.expected {
+			t.Errorf("input %q: wrong value. expected=%q, got=%q", tt.input, tt.expected, sl.Value)
+		}
+		if src := strings.TrimSuffix(tt.input, ";"); sl.TokenLiteral() != src {
+			t.Errorf("input %q: literal should keep the source. got=%q", tt.input, sl.TokenLiteral())
+		}
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	input := "global proc string f(int $a) {\r\n\treturn \"a\" + $a; // ok\r\n}\nprint (f(1)) & 2;\n"
+
+	want := New(lexer.NewWithMode(input, lexer.ScanComments))
+	wantProgram := want.ParseProgram()
+	got := New(lexer.NewReaderWithMode(strings.NewReader(input), lexer.ScanComments))
+	gotProgram := got.ParseProgram()
+
+	if gotProgram.String() != wantProgram.String() {
+		t.Errorf("wrong program.\nwant=%s\ngot=%s", wantProgram, gotProgram)
+	}
+	if got.Errors().Error() != want.Errors().Error() {
+		t.Errorf("wrong errors.\nwant=%v\ngot=%v", want.Errors(), got.Errors())
+	}
+	if len(gotProgram.Comments) != 1 || gotProgram.End() != wantProgram.End() {
+		t.Errorf("wrong comments or end. got=%d comments, end %d", len(gotProgram.Comments), gotProgram.End())
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	fset := token.NewFileSet()
+	input := "int $a = ;\nproc () {}\nprint 1 2"
+	file := fset.AddFile("a.mel", len(input))
+	p := New(lexer.NewFile(file, input, 0))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 3 {
+		t.Fatalf("wrong number of errors. want=3, got=%d: %v", len(errors), errors)
+	}
+
+	e := errors[1]
+	if e.Filename != "a.mel" || e.Code != UnexpectedToken || e.Expected != token.ProcIdent || e.Actual != token.Lparen {
+		t.Errorf("wrong error fields. got=%+v", e)
+	}
+	if pos := fset.Position(e.Start); pos.String() != "a.mel:2:6" {
+		t.Errorf("wrong start position. got=%s", pos)
+	}
+	if e.End-e.Start != 1 {
+		t.Errorf("wrong error range. got=%d-%d", e.Start, e.End)
+	}
+
+	list := ErrorList{errors[2], errors[0], errors[1], errors[0]}
+	list.RemoveMultiples()
+	expected := "line:1.10 no prefix parse function for ; found.\n" +
+		"line:2.6 expected next token to be 'ProcIdent' got '(' instead\n" +
+		"line:3.10 expected next token to be ';' got 'EOF' instead"
+	if list.Error() != expected {
+		t.Errorf("wrong error list.\nexpected=%q\ngot=%q", expected, list.Error())
+	}
+
+	if err := (ErrorList{}).Err(); err != nil {
+		t.Errorf("empty ErrorList.Err() should be nil. got=%v", err)
+	}
+}
+
+func TestErrorsSorted(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			// 閉じていない '{' は最後に報告される
+			"{ { (",
+			[]string{
+				"line:1.1 expected '}' to close '{' got 'EOF' instead",
+				"line:1.3 expected '}' to close '{' got 'EOF' instead",
+				"line:1.6 no prefix parse function for EOF found.",
+				"line:1.7 expected next token to be ')' got 'EOF' instead",
+			},
+		},
+		{
+			// 入れ子の ')' が同じ位置で二度報告される
+			"$a = ((;",
+			[]string{
+				"line:1.8 no prefix parse function for ; found.",
+				"line:1.9 expected next token to be ')' got 'EOF' instead",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		var errors []string
+		for _, err := range p.Errors() {
+			errors = append(errors, err.Error())
+		}
+		if fmt.Sprint(errors) != fmt.Sprint(tt.expected) {
+			t.Errorf("input %q: wrong errors.\nexpected=%q\ngot=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -2032,7 +2573,8 @@ func testStringLiteral(t *testing.T, sl ast.Expression, value string) bool {
 		return false
 	}
 
-	if st.Value != value {
+	// value はソースのままの Literal なので引用符を外して比べる
+	if st.Value != value[1:len(value)-1] {
 		t.Errorf("st.Value not %s, got=%s", value, st.Value)
 		return false
 	}
@@ -2067,6 +2609,26 @@ func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
 	return true
 }
 
+func testFlagArgument(t *testing.T, exp ast.Expression, flag string, values int) bool {
+	fa, ok := exp.(*ast.FlagArgument)
+	if !ok {
+		t.Errorf("exp not *ast.FlagArgument. got=%T", exp)
+		return false
+	}
+
+	if fa.Flag != flag || fa.TokenLiteral() != flag {
+		t.Errorf("fa.Flag not %s. got=%s", flag, fa.Flag)
+		return false
+	}
+
+	if len(fa.Values) != values {
+		t.Errorf("flag %s has wrong number of values. want=%d, got=%d", flag, values, len(fa.Values))
+		return false
+	}
+
+	return true
+}
+
 func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 	bo, ok := exp.(*ast.BooleanLiteral)
 	if !ok {
@@ -0,0 +1,418 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Error is a scope error found by the resolver.
+type Error struct {
+	Token   token.Token
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line:%d.%d %s", e.Token.Row, e.Token.Column, e.Message)
+}
+
+// variable は宣言された変数
+type variable struct {
+	token  token.Token // 宣言した位置
+	read   bool
+	global bool
+	param  bool
+}
+
+// scope は BlockStatement や proc ごとに作られる
+type scope struct {
+	vars  map[string]*variable
+	order []string // 未使用の変数を宣言順に報告するため
+	outer *scope
+	proc  bool // proc の一番外側のスコープ. これより外の変数は見えない
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{vars: map[string]*variable{}, outer: outer}
+}
+
+// lookup は name を proc の境界まで探す
+func (s *scope) lookup(name string) (*variable, *scope) {
+	for ; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v, s
+		}
+		if s.proc {
+			break
+		}
+	}
+	return nil, nil
+}
+
+// Reference is a variable identifier bound to its declaration.
+type Reference struct {
+	Ident  *ast.Identifier
+	Decl   token.Token // 宣言した位置. global 宣言せずに使った global 変数では空
+	Global bool
+}
+
+// Resolver builds lexical scopes and reports undeclared, redeclared,
+// shadowed and unused variables.
+type Resolver struct {
+	errors     []*Error
+	references []Reference
+
+	globals map[string]bool // ファイル内で global 宣言された変数
+	scope   *scope
+	proc    *ast.ProcStatement // 検査中の proc
+}
+
+// New make Resolver instance.
+func New() *Resolver {
+	return &Resolver{
+		errors:  []*Error{},
+		globals: map[string]bool{},
+	}
+}
+
+// Errors return scope errors sorted by position.
+func (r *Resolver) Errors() []*Error {
+	return r.errors
+}
+
+// References return the resolved variable identifiers in the order they were visited.
+// Undeclared variables are not included.
+func (r *Resolver) References() []Reference {
+	return r.references
+}
+
+// Resolve resolves program and returns the errors.
+func Resolve(program *ast.Program) []*Error {
+	r := New()
+	r.Resolve(program)
+	return r.Errors()
+}
+
+// Resolve resolves every statement of program.
+func (r *Resolver) Resolve(program *ast.Program) {
+	for _, stmt := range program.Statements {
+		r.collectGlobals(stmt)
+	}
+
+	r.scope = newScope(nil)
+	for _, stmt := range program.Statements {
+		r.resolveStatement(stmt)
+	}
+	r.closeScope()
+
+	sort.SliceStable(r.errors, func(i, j int) bool {
+		a, b := r.errors[i].Token, r.errors[j].Token
+		if a.Row != b.Row {
+			return a.Row < b.Row
+		}
+		return a.Column < b.Column
+	})
+}
+
+// collectGlobals はファイル内のすべての global 変数を集める
+func (r *Resolver) collectGlobals(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.GlobalStatement:
+		if ps, ok := stmt.Statement.(*ast.ProcStatement); ok {
+			r.collectGlobals(ps)
+			return
+		}
+		names, _ := ast.Declaration(stmt.Statement)
+		for _, name := range names {
+			if ident := ast.DeclaredVariable(name); ident != nil {
+				r.globals[ident.Value] = true
+			}
+		}
+	case *ast.ProcStatement:
+		if stmt.Body != nil {
+			for _, s := range stmt.Body.Statements {
+				r.collectGlobals(s)
+			}
+		}
+	case *ast.BlockStatement:
+		for _, s := range stmt.Statements {
+			r.collectGlobals(s)
+		}
+	}
+}
+
+func (r *Resolver) errorf(tok token.Token, format string, a ...interface{}) {
+	r.errors = append(r.errors, &Error{Token: tok, Message: fmt.Sprintf(format, a...)})
+}
+
+func (r *Resolver) refer(ident *ast.Identifier, v *variable) {
+	r.references = append(r.references, Reference{Ident: ident, Decl: v.token, Global: v.global})
+}
+
+func (r *Resolver) openScope() {
+	r.scope = newScope(r.scope)
+}
+
+// closeScope は一度も読まれなかった変数を報告してスコープを閉じる
+func (r *Resolver) closeScope() {
+	for _, name := range r.scope.order {
+		v := r.scope.vars[name]
+		if !v.read && !v.global && !v.param {
+			r.errorf(v.token, "variable %s is declared but never read", name)
+		}
+	}
+	r.scope = r.scope.outer
+}
+
+// declare は name を現在のスコープに宣言する
+func (r *Resolver) declare(ident *ast.Identifier, global bool) *variable {
+	if old, ok := r.scope.vars[ident.Value]; ok {
+		if !(global && old.global) {
+			r.errorf(ident.Token, "variable %s redeclared in the same scope", ident.Value)
+		}
+		r.refer(ident, old)
+		return old
+	}
+	if !global && !r.scope.proc {
+		if old, _ := r.scope.outer.lookup(ident.Value); old != nil {
+			r.errorf(ident.Token, "variable %s shadows the declaration at line:%d.%d",
+				ident.Value, old.token.Row, old.token.Column)
+		}
+	}
+
+	v := &variable{token: ident.Token, global: global}
+	r.scope.vars[ident.Value] = v
+	r.scope.order = append(r.scope.order, ident.Value)
+	r.refer(ident, v)
+	return v
+}
+
+func (r *Resolver) resolveStatement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		r.resolveExpression(stmt.Expression)
+	case *ast.BlockStatement:
+		r.resolveBlock(stmt)
+	case *ast.GlobalStatement:
+		if ps, ok := stmt.Statement.(*ast.ProcStatement); ok {
+			r.resolveProc(ps)
+			return
+		}
+		r.resolveDeclaration(stmt.Statement, true)
+	case *ast.ProcStatement:
+		r.resolveProc(stmt)
+	case *ast.VariableStatement:
+		r.resolveAssignments(stmt.Names, stmt.Assigns, stmt.Values)
+	case *ast.IntegerStatement, *ast.FloatStatement, *ast.StringStatement,
+		*ast.VectorStatement, *ast.MatrixStatement:
+		r.resolveDeclaration(stmt, false)
+	case *ast.ReturnStatement:
+		r.resolveExpression(stmt.ReturnValue)
+	}
+}
+
+func (r *Resolver) resolveBlock(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	r.openScope()
+	for _, stmt := range block.Statements {
+		r.resolveStatement(stmt)
+	}
+	r.closeScope()
+}
+
+func (r *Resolver) resolveProc(ps *ast.ProcStatement) {
+	if ps == nil {
+		return
+	}
+
+	outer := r.proc
+	r.proc = ps
+	defer func() { r.proc = outer }()
+
+	// proc の中からは外側の変数は見えない
+	r.openScope()
+	r.scope.proc = true
+	for _, param := range ps.Parameters {
+		if ident := ast.DeclaredVariable(param); ident != nil {
+			r.declare(ident, false).param = true
+		}
+	}
+	if ps.Body != nil {
+		for _, stmt := range ps.Body.Statements {
+			r.resolveStatement(stmt)
+		}
+	}
+	r.closeScope()
+}
+
+// resolveDeclaration は int $a = $b, $c[$n]; のような宣言を解決する
+func (r *Resolver) resolveDeclaration(stmt ast.Statement, global bool) {
+	names, values := ast.Declaration(stmt)
+	for i, name := range names {
+		ident := ast.DeclaredVariable(name)
+		if ident == nil {
+			continue
+		}
+		// 配列のサイズと初期値は宣言前に評価される
+		for n := name; n != ident; {
+			ie := n.(*ast.IndexExpression)
+			r.resolveExpression(ie.Index)
+			n = ie.Left
+		}
+		if i < len(values) {
+			r.resolveExpression(values[i])
+		}
+		r.declare(ident, global)
+	}
+}
+
+// resolveAssignments は $a = 1, $b[$i] += 2; のような代入を解決する
+func (r *Resolver) resolveAssignments(names []ast.Expression, assigns []token.Token, values []ast.Expression) {
+	for i, name := range names {
+		if i >= len(values) || values[i] == nil {
+			r.resolveExpression(name)
+			continue
+		}
+
+		r.resolveExpression(values[i])
+		if assigns[i].Type != token.Assign {
+			// $a += 1 は $a を読んでから書く
+			r.resolveExpression(name)
+			continue
+		}
+		r.assign(name)
+	}
+}
+
+// assign は代入先を解決する. 宣言されていない変数は暗黙に宣言される
+func (r *Resolver) assign(target ast.Expression) {
+	ident, ok := target.(*ast.Identifier)
+	if !ok || ident.Token.Type != token.Ident {
+		// $a[0] = 1; は $a を変更するので読み出しとして扱う
+		r.resolveExpression(target)
+		return
+	}
+
+	if v, _ := r.scope.lookup(ident.Value); v != nil {
+		r.refer(ident, v)
+		return
+	}
+	if r.globalWithoutDeclaration(ident) {
+		return
+	}
+	r.declare(ident, false)
+}
+
+// globalWithoutDeclaration は proc の中で global 宣言せずに global 変数を使っていれば報告する
+func (r *Resolver) globalWithoutDeclaration(ident *ast.Identifier) bool {
+	if r.proc == nil || !r.globals[ident.Value] {
+		return false
+	}
+	r.errorf(ident.Token, "global variable %s is used in proc %s without global declaration",
+		ident.Value, r.proc.Name.Literal)
+	r.references = append(r.references, Reference{Ident: ident, Global: true})
+	return true
+}
+
+// use は変数の読み出しを解決する
+func (r *Resolver) use(ident *ast.Identifier) {
+	if ident.Token.Type != token.Ident {
+		return
+	}
+	if v, _ := r.scope.lookup(ident.Value); v != nil {
+		v.read = true
+		r.refer(ident, v)
+		return
+	}
+	if r.globalWithoutDeclaration(ident) {
+		return
+	}
+	r.errorf(ident.Token, "undeclared variable %s", ident.Value)
+}
+
+func (r *Resolver) resolveExpression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		r.use(exp)
+	case *ast.PrefixExpression:
+		r.resolveExpression(exp.Right)
+	case *ast.PostfixExpression:
+		r.resolveExpression(exp.Left)
+	case *ast.InfixExpression:
+		r.resolveExpression(exp.Left)
+		if exp.Operator != "." {
+			// $v.x の x は変数ではない
+			r.resolveExpression(exp.Right)
+		}
+	case *ast.TernaryExpression:
+		r.resolveExpression(exp.Conditional)
+		r.resolveExpression(exp.TrueExp)
+		r.resolveExpression(exp.FalseExp)
+	case *ast.CastExpression:
+		r.resolveExpression(exp.Right)
+	case *ast.IndexExpression:
+		r.resolveExpression(exp.Left)
+		r.resolveExpression(exp.Index)
+	case *ast.CallExpression:
+		for _, a := range exp.Arguments {
+			r.resolveExpression(a)
+		}
+	case *ast.FlagArgument:
+		for _, v := range exp.Values {
+			r.resolveExpression(v)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			r.resolveExpression(el)
+		}
+	case *ast.TensorLiteral:
+		for _, row := range exp.Values {
+			for _, v := range row {
+				r.resolveExpression(v)
+			}
+		}
+
+	// 式として使われる制御構文
+	case *ast.IfExpression:
+		r.resolveExpression(exp.Condition)
+		r.resolveBlock(exp.Consequence)
+		r.resolveBlock(exp.Alternative)
+	case *ast.WhileExpression:
+		r.resolveExpression(exp.Condition)
+		r.resolveBlock(exp.Consequence)
+	case *ast.DoWhileExpression:
+		r.resolveBlock(exp.Consequence)
+		r.resolveExpression(exp.Condition)
+	case *ast.ForExpression:
+		r.resolveAssignments(exp.InitNames, exp.InitAssigns, exp.InitValues)
+		r.resolveExpression(exp.Condition)
+		for _, changeOf := range exp.ChangeOfs {
+			r.resolveStatement(changeOf)
+		}
+		r.resolveBlock(exp.Consequence)
+	case *ast.ForInExpression:
+		r.resolveExpression(exp.ArrayElement)
+		if exp.Element != nil {
+			r.assign(exp.Element)
+		}
+		r.resolveBlock(exp.Consequence)
+	case *ast.SwitchExpression:
+		r.resolveExpression(exp.Condition)
+		for _, cas := range exp.Cases {
+			if cas != nil {
+				r.resolveExpression(cas)
+			}
+		}
+		r.openScope()
+		for _, cs := range exp.CaseStatements {
+			for _, stmt := range cs.Statements {
+				r.resolveStatement(stmt)
+			}
+		}
+		r.closeScope()
+	}
+}
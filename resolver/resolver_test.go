@@ -0,0 +1,164 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+)
+
+func TestResolveNoErrors(t *testing.T) {
+	inputs := []string{
+		`int $i = 1; print $i;`,
+		`$nodes = {"a"}; print $nodes;`,
+		`int $a[]; $a[0] = 1;`,
+		`int $n = 3; int $a[$n]; $a[0] = 1;`,
+		`int $i = 0; $i++;`,
+		`vector $v; print $v.x;`,
+		`global int $g; proc foo() { global int $g; print $g; }`,
+		`proc foo() { global int $g; $g = 1; } global int $g = 0;`,
+		`proc int add(int $a, int $b) { return $a; }`,
+		`string $a[] = {"x"}; for ($s in $a) print $s;`,
+		`for ($i = 0; $i < 3; $i++) print "a";`,
+		`int $x = 1; switch ($x) { case 1: int $y = $x; print $y; break; }`,
+		`select -r pCube1;`,
+	}
+
+	for _, input := range inputs {
+		for _, err := range testResolve(t, input) {
+			t.Errorf("input %q has error: %s", input, err)
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			`string $nodes[] = {"a"}; print $nodse;`,
+			[]string{
+				"line:1.8 variable $nodes is declared but never read",
+				"line:1.32 undeclared variable $nodse",
+			},
+		},
+		{
+			`print $x; int $x = 1; print $x;`,
+			[]string{"line:1.7 undeclared variable $x"},
+		},
+		{
+			`int $x = $x + 1; print $x;`,
+			[]string{"line:1.10 undeclared variable $x"},
+		},
+		{
+			`int $a = 1; int $a = 2; print $a;`,
+			[]string{"line:1.17 variable $a redeclared in the same scope"},
+		},
+		{
+			`int $a = 1; { int $a = 2; print $a; } print $a;`,
+			[]string{"line:1.19 variable $a shadows the declaration at line:1.5"},
+		},
+		{
+			"int $unused = 1;\n$typo = 2;",
+			[]string{
+				"line:1.5 variable $unused is declared but never read",
+				"line:2.1 variable $typo is declared but never read",
+			},
+		},
+		{
+			`global int $g; proc foo() { print $g; }`,
+			[]string{"line:1.35 global variable $g is used in proc foo without global declaration"},
+		},
+		{
+			`proc foo() { $g = 1; } global string $g;`,
+			[]string{"line:1.14 global variable $g is used in proc foo without global declaration"},
+		},
+		{
+			`int $top = 1; proc foo() { print $top; } print $top;`,
+			[]string{"line:1.34 undeclared variable $top"},
+		},
+		{
+			`proc foo(int $a) { int $a = 1; print $a; }`,
+			[]string{"line:1.24 variable $a redeclared in the same scope"},
+		},
+		{
+			`if (1) { int $b = 1; } print $b;`,
+			[]string{
+				"line:1.14 variable $b is declared but never read",
+				"line:1.30 undeclared variable $b",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		errs := testResolve(t, tt.input)
+		if len(errs) != len(tt.expected) {
+			t.Errorf("input %q: wrong number of errors. want=%d, got=%d", tt.input, len(tt.expected), len(errs))
+			for _, err := range errs {
+				t.Errorf("  %s", err)
+			}
+			continue
+		}
+		for i, err := range errs {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("input %q: wrong error. want=%q, got=%q", tt.input, tt.expected[i], err.Error())
+			}
+		}
+	}
+}
+
+func testResolve(t *testing.T, input string) []*Error {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("input %q has parser errors: %v", input, p.Errors())
+	}
+	return Resolve(program)
+}
+
+func TestReferences(t *testing.T) {
+	input := `global int $g; int $a = 1; proc foo(int $a) { global int $g; print ($a + $g); } print $a;`
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("input %q has parser errors: %v", input, p.Errors())
+	}
+	r := New()
+	r.Resolve(program)
+
+	tests := []struct {
+		name   string
+		column int
+		decl   int
+		global bool
+	}{
+		{"$g", 12, 12, true},
+		{"$a", 20, 20, false},
+		{"$a", 41, 41, false},
+		{"$g", 58, 58, true},
+		{"$a", 69, 41, false},
+		{"$g", 74, 58, true},
+		{"$a", 87, 20, false},
+	}
+
+	refs := r.References()
+	if len(refs) != len(tests) {
+		t.Fatalf("wrong number of references. want=%d, got=%d", len(tests), len(refs))
+	}
+	for i, tt := range tests {
+		ref := refs[i]
+		if ref.Ident.Value != tt.name || ref.Ident.Token.Column != tt.column {
+			t.Errorf("refs[%d] wrong identifier. want=%s at %d, got=%s at %d",
+				i, tt.name, tt.column, ref.Ident.Value, ref.Ident.Token.Column)
+		}
+		if ref.Decl.Column != tt.decl {
+			t.Errorf("refs[%d] wrong declaration. want=%d, got=%d", i, tt.decl, ref.Decl.Column)
+		}
+		if ref.Global != tt.global {
+			t.Errorf("refs[%d] wrong global. want=%t, got=%t", i, tt.global, ref.Global)
+		}
+	}
+}
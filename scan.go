@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nrtkbb/go-MEL/scriptnode"
+	"github.com/nrtkbb/go-MEL/workspace"
+)
+
+// runScan は scan サブコマンドを実行して終了コードを返す.
+// 何か見つかれば 1, ファイルを読めなければ 2 を返す.
+func runScan(args []string) int {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-MEL scan path ...")
+		fmt.Fprintln(os.Stderr, "Scan the scriptNodes of .ma files and print the findings as JSON.")
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	code := 0
+	findings := []*scriptnode.Finding{}
+	scanPath := func(path string) error {
+		found, err := scanFile(path)
+		if err != nil {
+			// 一つのファイルが失敗しても残りのファイルは続けて調べる
+			fmt.Fprintln(os.Stderr, err)
+			code = 2
+		}
+		findings = append(findings, found...)
+		return nil
+	}
+	for _, path := range fs.Args() {
+		stat, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 2
+			continue
+		}
+		if stat.IsDir() {
+			if err := workspace.WalkFiles(path, ma, scanPath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = 2
+			}
+			continue
+		}
+		scanPath(path)
+	}
+
+	out, err := json.MarshalIndent(findings, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Println(string(out))
+
+	if code == 0 && len(findings) != 0 {
+		code = 1
+	}
+	return code
+}
+
+func scanFile(path string) ([]*scriptnode.Finding, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// 読めたところまでの scriptNode は調べる
+	findings, err := scriptnode.Scan(path, f)
+	if err != nil {
+		return findings, fmt.Errorf("%s: %s", path, err)
+	}
+	return findings, nil
+}
@@ -0,0 +1,397 @@
+// Package scriptnode finds dangerous scripts in the scriptNodes of Maya ASCII files.
+//
+// A scriptNode is created with "createNode script" and keeps its scripts in
+// the ".b" (before) and ".a" (after) string attributes. Maya runs them when
+// the scene is opened or closed, so viruses hide in them. MEL scripts are
+// parsed with the MEL parser and checked for python calls that exec or
+// base64-decode code, writes to userSetup.mel or userSetup.py, scriptJob
+// creation and eval of constructed strings. Python scripts are checked by text.
+package scriptnode
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/mayaascii"
+	"github.com/nrtkbb/go-MEL/parser"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Rules of Finding
+const (
+	PythonExec     = "python-exec"     // python("exec(...)") や base64 の decode
+	UserSetupWrite = "usersetup-write" // userSetup.mel, userSetup.py への書き込み
+	ScriptJob      = "scriptjob"       // scriptJob の作成
+	EvalConstruct  = "eval-construct"  // 組み立てた文字列の eval
+	SyntaxError    = "syntax-error"    // 解析できないスクリプト
+)
+
+// Finding is a dangerous pattern in a script of a scriptNode
+type Finding struct {
+	File       string `json:"file"`
+	Node       string `json:"node"`
+	Attribute  string `json:"attribute"`  // ".b" or ".a"
+	Line       int    `json:"line"`       // .ma ファイルの行
+	ScriptLine int    `json:"scriptLine"` // スクリプトの中の行
+	Rule       string `json:"rule"`
+	Message    string `json:"message"`
+}
+
+// Script is a script of a scriptNode
+type Script struct {
+	Node      string
+	Attribute string // ".b" or ".a"
+	Line      int    // setAttr の値が書かれた .ma ファイルの行
+	Python    bool   // ".stp" が 1 の時
+	Source    string
+}
+
+// Scripts reads a .ma file and extracts the before and after scripts of
+// every scriptNode. Only the scriptNodes are kept in memory, so r can be a
+// large scene. On an error, it returns the scripts read so far.
+func Scripts(r io.Reader) ([]*Script, error) {
+	var scripts []*Script
+	python := map[string]bool{}    // .stp が 1 の scriptNode
+	nodes := map[string]bool{}     // scriptNode の名前
+	current, isScript := "", false // setAttr の対象
+
+	reader := mayaascii.NewReader(r)
+	for {
+		cmd, err := reader.Next()
+		if err != nil {
+			// .stp は .b より後に書かれることがある
+			for _, s := range scripts {
+				s.Python = python[s.Node]
+			}
+			if err == io.EOF {
+				err = nil
+			}
+			return scripts, err
+		}
+
+		switch cmd.Name {
+		case "createNode":
+			typ, name := createdNode(cmd)
+			current, isScript = name, typ == "script"
+			if isScript {
+				nodes[name] = true
+			}
+		case "select":
+			current, isScript = "", false
+			for _, arg := range cmd.Args {
+				if arg.Kind != mayaascii.FlagArg {
+					current, isScript = arg.Text, nodes[arg.Text]
+					break
+				}
+			}
+		case "setAttr":
+			if !isScript {
+				continue
+			}
+			attr, err := mayaascii.ParseAttribute(cmd)
+			if attr == nil || err != nil {
+				continue
+			}
+			switch attr.Name {
+			case ".stp", ".sourceType":
+				v, ok := attr.Value.([]float64)
+				python[current] = ok && len(v) == 1 && v[0] == 1
+			case ".b", ".before", ".a", ".after":
+				src, ok := attr.Value.(string)
+				if !ok {
+					continue
+				}
+				scripts = append(scripts, &Script{
+					Node:      current,
+					Attribute: "." + attr.Name[1:2],
+					Line:      valueLine(cmd, src),
+					Source:    src,
+				})
+			}
+		}
+	}
+}
+
+// createdNode は createNode の node の型と名前を返す
+func createdNode(cmd *mayaascii.Command) (typ, name string) {
+	for i := 0; i < len(cmd.Args); i++ {
+		arg := cmd.Args[i]
+		if arg.Kind != mayaascii.FlagArg {
+			if typ == "" {
+				typ = arg.Text
+			}
+			continue
+		}
+		switch arg.Text {
+		case "-n", "-name", "-p", "-parent":
+			if i+1 < len(cmd.Args) {
+				i++
+				if arg.Text == "-n" || arg.Text == "-name" {
+					name = cmd.Args[i].Text
+				}
+			}
+		}
+	}
+	return typ, name
+}
+
+// valueLine は setAttr の値 src が書かれた行を返す
+func valueLine(cmd *mayaascii.Command, src string) int {
+	for _, arg := range cmd.Args {
+		if arg.Kind == mayaascii.StringArg && arg.Text == src {
+			return arg.Line
+		}
+	}
+	return cmd.Line
+}
+
+// Scan reads a .ma file and checks every scriptNode. file is used in the
+// findings. On an error, it returns the findings of the scripts read so far.
+func Scan(file string, r io.Reader) ([]*Finding, error) {
+	scripts, err := Scripts(r)
+	var findings []*Finding
+	for _, s := range scripts {
+		for _, f := range Check(s.Source, s.Python) {
+			f.File = file
+			f.Node = s.Node
+			f.Attribute = s.Attribute
+			f.Line = s.Line
+			findings = append(findings, f)
+		}
+	}
+	return findings, err
+}
+
+// Check checks a MEL or Python script. File, Node, Attribute and Line of
+// the findings are not set.
+func Check(src string, python bool) []*Finding {
+	c := &checker{assigned: map[string]string{}}
+	if python {
+		c.checkPython(src, 1)
+	} else {
+		c.checkMEL(src, 0)
+	}
+	return c.findings
+}
+
+// eval の中の文字列をさらに解析する深さの上限
+const maxDepth = 8
+
+type checker struct {
+	findings []*Finding
+	line     int               // eval の中のスクリプトを解析している時の外側の行
+	assigned map[string]string // 変数に代入した文字列リテラル. ex) $p = $d + "userSetup.mel";
+}
+
+func (c *checker) report(line int, rule, format string, a ...interface{}) {
+	c.findings = append(c.findings, &Finding{
+		ScriptLine: line,
+		Rule:       rule,
+		Message:    fmt.Sprintf(format, a...),
+	})
+}
+
+func (c *checker) checkMEL(src string, depth int) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	// eval の引数などの文字列はスクリプトとは限らないので構文エラーは報告しない
+	if errs := p.Errors(); len(errs) != 0 && depth == 0 {
+		c.report(c.scriptLine(errs[0].Token), SyntaxError, "script has syntax error: %s", errs[0].Message)
+	}
+	// fopen($p, "w") の $p に代入した文字列も調べられるように先に集める
+	for _, stmt := range program.Statements {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.StringStatement:
+				c.assign(n.Names, n.Values)
+			case *ast.VariableStatement:
+				c.assign(n.Names, n.Values)
+			}
+			return true
+		})
+	}
+	for _, stmt := range program.Statements {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpression); ok {
+				c.checkCall(call, depth)
+			}
+			return true
+		})
+	}
+}
+
+// assign は変数に代入した式の文字列を覚える. 代入のたびに足していく
+func (c *checker) assign(names, values []ast.Expression) {
+	for i, name := range names {
+		if i >= len(values) || values[i] == nil {
+			continue
+		}
+		if ie, ok := name.(*ast.IndexExpression); ok {
+			name = ie.Left
+		}
+		if ident, ok := name.(*ast.Identifier); ok {
+			c.assigned[ident.Value] += c.text(values[i])
+		}
+	}
+}
+
+// text は式の中の文字列リテラルと, 変数に代入した文字列をつなげて返す
+func (c *checker) text(exp ast.Expression) string {
+	var b strings.Builder
+	ast.Inspect(exp, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.StringLiteral:
+			b.WriteString(n.Value)
+		case *ast.Identifier:
+			b.WriteString(c.assigned[n.Value])
+		}
+		return true
+	})
+	return b.String()
+}
+
+func (c *checker) scriptLine(tok token.Token) int {
+	if c.line != 0 {
+		return c.line
+	}
+	return tok.Row
+}
+
+func (c *checker) checkCall(call *ast.CallExpression, depth int) {
+	if call.Function == nil {
+		return
+	}
+	name := call.Function.Value
+	line := c.scriptLine(call.Function.Token)
+
+	switch name {
+	case "python":
+		text := strings.Join(literals(call.Arguments), " ")
+		if strings.Contains(text, "exec") || strings.Contains(text, "base64") || strings.Contains(text, "b64decode") {
+			c.report(line, PythonExec, "python call executes encoded or generated code")
+		}
+		if mentionsUserSetup(text) && writesFile(text) {
+			c.report(line, UserSetupWrite, "python call writes userSetup")
+		}
+		if len(call.Arguments) == 1 {
+			if _, ok := call.Arguments[0].(*ast.StringLiteral); !ok {
+				c.report(line, PythonExec, "python call executes a constructed string")
+			}
+		}
+	case "fopen":
+		// fopen の mode は省略すると "w"
+		mode := "w"
+		if len(call.Arguments) > 1 {
+			mode = strings.Join(literals(call.Arguments[1:2]), "")
+		}
+		if len(call.Arguments) > 0 && mentionsUserSetup(c.text(call.Arguments[0])) &&
+			strings.ContainsAny(mode, "wa") {
+			c.report(line, UserSetupWrite, "fopen opens userSetup for writing")
+		}
+	case "sysFile":
+		if hasFlag(call, "-copy", "-cp", "-move", "-mov", "-rename", "-ren") &&
+			mentionsUserSetup(strings.Join(literals(call.Arguments), " ")) {
+			c.report(line, UserSetupWrite, "sysFile writes userSetup")
+		}
+	case "scriptJob":
+		if !hasFlag(call, "-kill", "-k", "-killAll", "-ka", "-exists", "-ex", "-listJobs", "-lj", "-listEvents", "-le", "-listConditions", "-lc") {
+			c.report(line, ScriptJob, "scriptJob is created")
+		}
+		// scriptJob が実行する MEL も調べる
+		for _, arg := range ast.FlattenArguments(call.Arguments) {
+			if lit, ok := arg.(*ast.StringLiteral); ok {
+				c.checkNested(lit, line, depth)
+			}
+		}
+	case "eval", "evalDeferred", "evalEcho":
+		for _, arg := range ast.FlattenArguments(call.Arguments) {
+			if ident, ok := arg.(*ast.Identifier); ok && ident.Token.Type == token.Flag {
+				continue
+			}
+			lit, ok := arg.(*ast.StringLiteral)
+			if !ok {
+				c.report(line, EvalConstruct, "%s of a constructed string", name)
+				continue
+			}
+			c.checkNested(lit, line, depth)
+		}
+	}
+}
+
+// checkNested は文字列の中の MEL を調べる. 見つけたものは line で報告する
+func (c *checker) checkNested(lit *ast.StringLiteral, line, depth int) {
+	if depth >= maxDepth {
+		return
+	}
+	src := strings.TrimSpace(lit.Value)
+	if !strings.HasSuffix(src, ";") && !strings.HasSuffix(src, "}") {
+		// eval "print 1" のように最後の ';' は省略できる
+		src += ";"
+	}
+	outer := c.line
+	c.line = line
+	c.checkMEL(src, depth+1)
+	c.line = outer
+}
+
+// checkPython は python のスクリプトを文字列として調べる
+func (c *checker) checkPython(src string, line int) {
+	for i, text := range strings.Split(src, "\n") {
+		if strings.Contains(text, "exec(") || strings.Contains(text, "b64decode") {
+			c.report(line+i, PythonExec, "python script executes encoded or generated code")
+		}
+		if mentionsUserSetup(text) && writesFile(src) {
+			c.report(line+i, UserSetupWrite, "python script writes userSetup")
+		}
+		if strings.Contains(text, "scriptJob(") {
+			c.report(line+i, ScriptJob, "scriptJob is created")
+		}
+	}
+}
+
+func mentionsUserSetup(text string) bool {
+	text = strings.ToLower(text)
+	return strings.Contains(text, "usersetup.mel") || strings.Contains(text, "usersetup.py")
+}
+
+// writesFile は python のコードがファイルに書き込みそうか調べる
+func writesFile(text string) bool {
+	for _, s := range []string{"'w'", "\"w\"", "'a'", "\"a\"", ".write(", "copy", "move", "rename"} {
+		if strings.Contains(text, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFlag(call *ast.CallExpression, names ...string) bool {
+	for _, arg := range call.Arguments {
+		flag, ok := arg.(*ast.FlagArgument)
+		if !ok {
+			continue
+		}
+		for _, name := range names {
+			if flag.Flag == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// literals は式の中のすべての文字列リテラルの値を集める
+func literals(exps []ast.Expression) []string {
+	var values []string
+	for _, exp := range exps {
+		ast.Inspect(exp, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.StringLiteral); ok {
+				values = append(values, lit.Value)
+			}
+			return true
+		})
+	}
+	return values
+}
@@ -0,0 +1,104 @@
+package scriptnode
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		python   bool
+		expected []string
+	}{
+		{`print "hello";`, false, nil},
+		{`python("import base64; exec(base64.b64decode('aGk='))");`, false,
+			[]string{"1 python-exec python call executes encoded or generated code"}},
+		{"string $c = \"import os\";\npython($c);", false,
+			[]string{"2 python-exec python call executes a constructed string"}},
+		{`$f = fopen(` + "`internalVar -uad`" + ` + "scripts/userSetup.mel", "a");`, false,
+			[]string{"1 usersetup-write fopen opens userSetup for writing"}},
+		{`$f = fopen("userSetup.mel", "r");`, false, nil},
+		{"string $p = `internalVar -uad` + \"scripts/\";\n$p += \"userSetup.mel\";\nstring $q = $p;\n$f = fopen($q, \"w\");", false,
+			[]string{"4 usersetup-write fopen opens userSetup for writing"}},
+		{"string $p = \"userSetup.mel\";\n$f = fopen($p, \"r\");", false, nil},
+		{`sysFile -copy ($dir + "/userSetup.py") $src;`, false,
+			[]string{"1 usersetup-write sysFile writes userSetup"}},
+		{`scriptJob -e "SelectionChanged" "leukocyte.antivirus()";`, false,
+			[]string{"1 scriptjob scriptJob is created"}},
+		{`scriptJob -kill $job;`, false, nil},
+		{"proc f() {\n\teval (\"print \" + $x);\n}", false,
+			[]string{"2 eval-construct eval of a constructed string"}},
+		{`eval "print 1";`, false, nil},
+		{"eval \"scriptJob -e \\\"idle\\\" \\\"print 1\\\"\";", false,
+			[]string{"1 scriptjob scriptJob is created"}},
+		{`print (;`, false, []string{"1 syntax-error script has syntax error: no prefix parse function for ; found."}},
+		{"import base64\nexec(base64.b64decode('aGk='))\n", true,
+			[]string{"2 python-exec python script executes encoded or generated code"}},
+		{"with open(os.path.join(d, 'userSetup.py'), 'a') as f:\n\tf.write(s)", true,
+			[]string{"1 usersetup-write python script writes userSetup"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, f := range Check(tt.input, tt.python) {
+			got = append(got, strings.Join([]string{strconv.Itoa(f.ScriptLine), f.Rule, f.Message}, " "))
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("input %q wrong findings.\nwant=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+const testScene = `//Maya ASCII 2018 scene
+requires maya "2018";
+createNode transform -n "pCube1";
+createNode script -n "uiConfigurationScriptNode";
+	setAttr ".b" -type "string" "// Maya Mel UI Configuration File.\n";
+	setAttr ".st" 3;
+createNode script -n "vaccine_gene";
+	setAttr ".b" -type "string" (
+		"petri_dish_path = cmds.internalVar(userAppDir=True) + 'scripts/userSetup.py'\n"
+		+ "leukocyte = vaccine.phage()\nleukocyte.occupation()");
+	setAttr ".stp" 1;
+createNode script -n "breed_gene";
+	setAttr ".b" -type "string" "python(\"import base64; exec(base64.urlsafe_b64decode('aW1wb3J0'))\");\nscriptJob -e \"SceneSaved\" \"vaccine_gene\";";
+	setAttr ".a" -type "string" "eval (\"source \" + $x);";
+`
+
+func TestScan(t *testing.T) {
+	scripts, err := Scripts(strings.NewReader(testScene))
+	if err != nil {
+		t.Fatalf("Scripts returned error: %s", err)
+	}
+	if n := len(scripts); n != 4 {
+		t.Errorf("wrong number of scripts. want=4, got=%d", n)
+	}
+	for _, s := range scripts {
+		if s.Python != (s.Node == "vaccine_gene") {
+			t.Errorf("script of %s wrong Python. got=%t", s.Node, s.Python)
+		}
+	}
+
+	expected := []Finding{
+		{File: "virus.ma", Node: "breed_gene", Attribute: ".b", Line: 13, ScriptLine: 1, Rule: PythonExec,
+			Message: "python call executes encoded or generated code"},
+		{File: "virus.ma", Node: "breed_gene", Attribute: ".b", Line: 13, ScriptLine: 2, Rule: ScriptJob,
+			Message: "scriptJob is created"},
+		{File: "virus.ma", Node: "breed_gene", Attribute: ".a", Line: 14, ScriptLine: 1, Rule: EvalConstruct,
+			Message: "eval of a constructed string"},
+	}
+	findings, err := Scan("virus.ma", strings.NewReader(testScene))
+	if err != nil {
+		t.Fatalf("Scan returned error: %s", err)
+	}
+	var got []Finding
+	for _, f := range findings {
+		got = append(got, *f)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong findings.\nwant=%+v\ngot=%+v", expected, got)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nrtkbb/go-MEL/lsp"
+)
+
+// runServe は serve サブコマンドを実行して終了コードを返す
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-MEL serve")
+		fmt.Fprintln(os.Stderr, "Run the language server over stdin and stdout.")
+	}
+	fs.Parse(args)
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
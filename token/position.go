@@ -0,0 +1,189 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Pos is a position in a FileSet. It is the base of the file plus the byte offset.
+type Pos int
+
+// NoPos is the zero value of Pos. It means no position.
+const NoPos Pos = 0
+
+// IsValid reports whether the position is valid
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
+// Position is a readable form of Pos
+type Position struct {
+	Filename string // ファイル名 (無い時は "")
+	Offset   int    // byte offset 0 はじまり
+	Line     int    // 行数 1行はじまり
+	Column   int    // 列数 1列はじまり (byte 単位)
+}
+
+// IsValid reports whether the position is valid
+func (pos Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+// String return "file:line:column" or "line:column"
+func (pos Position) String() string {
+	s := pos.Filename
+	if !pos.IsValid() {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	if s != "" {
+		s += ":"
+	}
+	return s + fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+// File has the line offsets of a file in a FileSet
+type File struct {
+	name  string
+	base  int
+	size  int   // stream の時は -1
+	lines []int // 各行の先頭の byte offset. stream の時は記録しない
+}
+
+// Name return the file name
+func (f *File) Name() string {
+	return f.name
+}
+
+// Base return the base of the file
+func (f *File) Base() int {
+	return f.base
+}
+
+// Size return the byte size of the file, or -1 if the file is a stream
+func (f *File) Size() int {
+	return f.size
+}
+
+// LineCount return the number of lines
+func (f *File) LineCount() int {
+	return len(f.lines)
+}
+
+// AddLine add the offset of a new line.
+// It is ignored if the offset is not after the last line.
+func (f *File) AddLine(offset int) {
+	if f.isStream() || offset <= f.lines[len(f.lines)-1] || offset > f.size {
+		return
+	}
+	f.lines = append(f.lines, offset)
+}
+
+// Pos return the Pos of the byte offset in the file
+func (f *File) Pos(offset int) Pos {
+	if offset < 0 || offset > f.size && !f.isStream() {
+		panic(fmt.Sprintf("token: offset %d out of range [0, %d]", offset, f.size))
+	}
+	return Pos(f.base + offset)
+}
+
+// Offset return the byte offset of the Pos in the file
+func (f *File) Offset(p Pos) int {
+	if int(p) < f.base || int(p) > f.base+f.size && !f.isStream() {
+		panic(fmt.Sprintf("token: pos %d out of range [%d, %d]", p, f.base, f.base+f.size))
+	}
+	return int(p) - f.base
+}
+
+// Line return the line number of the Pos
+func (f *File) Line(p Pos) int {
+	return f.Position(p).Line
+}
+
+// Position return the Position of the Pos.
+// The Position of a stream has the offset only, because it has no lines.
+func (f *File) Position(p Pos) Position {
+	if !p.IsValid() {
+		return Position{}
+	}
+	offset := f.Offset(p)
+	if f.isStream() {
+		return Position{Filename: f.name, Offset: offset}
+	}
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	return Position{
+		Filename: f.name,
+		Offset:   offset,
+		Line:     i + 1,
+		Column:   offset - f.lines[i] + 1,
+	}
+}
+
+func (f *File) isStream() bool {
+	return f.size < 0
+}
+
+// FileSet is a set of files. Each file has its own range of Pos.
+type FileSet struct {
+	base  int
+	files []*File
+}
+
+// NewFileSet make FileSet instance.
+func NewFileSet() *FileSet {
+	return &FileSet{base: 1} // 0 は NoPos
+}
+
+// AddFile add a file with the name and the byte size
+func (s *FileSet) AddFile(filename string, size int) *File {
+	s.checkStream()
+	if size < 0 {
+		panic(fmt.Sprintf("token: negative file size %d", size))
+	}
+	f := &File{name: filename, base: s.base, size: size, lines: []int{0}}
+	s.base += size + 1 // EOF の位置も含める
+	s.files = append(s.files, f)
+	return f
+}
+
+// AddStream add a file of unknown size that is read from a stream.
+// It must be the last file of the FileSet, and it does not record lines.
+func (s *FileSet) AddStream(filename string) *File {
+	s.checkStream()
+	f := &File{name: filename, base: s.base, size: -1, lines: []int{0}}
+	s.files = append(s.files, f)
+	return f
+}
+
+// checkStream は stream の後にファイルを追加させない
+func (s *FileSet) checkStream() {
+	if n := len(s.files); n != 0 && s.files[n-1].isStream() {
+		panic("token: can not add a file after a stream")
+	}
+}
+
+// File return the file that has the Pos, or nil
+func (s *FileSet) File(p Pos) *File {
+	if !p.IsValid() {
+		return nil
+	}
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) }) - 1
+	if i < 0 {
+		return nil
+	}
+	f := s.files[i]
+	if int(p) > f.base+f.size && !f.isStream() {
+		return nil
+	}
+	return f
+}
+
+// Position return the Position of the Pos
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+	return Position{}
+}
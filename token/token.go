@@ -9,6 +9,16 @@ type Token struct {
 	Literal string
 	Row     int // 行数 1行はじまり
 	Column  int // 列数 1列はじまり
+	Offset  int // byte offset 0 はじまり
+	Pos     Pos // FileSet の中での位置
+}
+
+// End return the Pos just after the token
+func (t Token) End() Pos {
+	if !t.Pos.IsValid() {
+		return NoPos
+	}
+	return t.Pos + Pos(len(t.Literal))
 }
 
 // Type strings.
@@ -17,17 +27,19 @@ const (
 	EOF     = "EOF"
 
 	// 識別子 + リテラル
-	Ident     = "Ident"     // $add, $foobar, $x, $y, ...
-	ProcIdent = "ProcIdent" // add, FuncName, ...
-	Int       = "Int"       // 1343456
-	Int16     = "Int16"     // 0xA0, 0xfff, ...
-	Float     = "Float"     // 1.1, 1e-3, 1e+3, ...
-	String    = "String"    // "node.attr", ...
-	Flag      = "Flag"      // -size, -s, ...
-	True      = "True"
-	On        = "On"
-	False     = "False"
-	Off       = "Off"
+	Ident      = "Ident"      // $add, $foobar, $x, $y, ...
+	ProcIdent  = "ProcIdent"  // add, FuncName, ...
+	Int        = "Int"        // 1343456
+	Int16      = "Int16"      // 0xA0, 0xfff, ...
+	Float      = "Float"      // 1.1, 1e-3, 1e+3, ...
+	String     = "String"     // "node.attr", ...
+	Flag       = "Flag"       // -size, -s, ...
+	Comment    = "Comment"    // // comment, /* comment */
+	Whitespace = "Whitespace" // spaces, tabs and newlines
+	True       = "True"
+	On         = "On"
+	False      = "False"
+	Off        = "Off"
 
 	// 演算子
 	Assign   = "="
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// jsonToken は tokens -format json の一行. フィールドは ast/json.md の token と同じ
+type jsonToken struct {
+	Type    token.Type `json:"type"`
+	Literal string     `json:"literal"`
+	Line    int        `json:"line"`
+	Column  int        `json:"column"`
+	Offset  int        `json:"offset"`
+}
+
+// runTokens は tokens サブコマンドを実行して終了コードを返す.
+// 字句のエラーがあれば 1, ファイルを読めなければ 2 を返す.
+func runTokens(args []string) int {
+	fs := flag.NewFlagSet("tokens", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text, json or table")
+	trivia := fs.Bool("trivia", false, "include comments and whitespace")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-MEL tokens [-format text|json|table] [-trivia] [path]")
+		fmt.Fprintln(os.Stderr, "Print the tokens of a MEL file, or of the standard input without path.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 || (*format != "text" && *format != "json" && *format != "table") {
+		fs.Usage()
+		return 2
+	}
+
+	name, r := "<standard input>", io.Reader(os.Stdin)
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		defer f.Close()
+		name, r = fs.Arg(0), f
+	}
+
+	var mode lexer.Mode
+	if *trivia {
+		mode = lexer.ScanComments | lexer.ScanWhitespace
+	}
+	l := lexer.NewReaderWithMode(r, mode)
+
+	out := bufio.NewWriter(os.Stdout)
+	if err := printTokens(out, l, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := out.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	for _, err := range l.Errors() {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+	}
+	if len(l.Errors()) != 0 {
+		return 1
+	}
+	return 0
+}
+
+// printTokens は EOF までの token を format の形で w に書く
+func printTokens(w io.Writer, l *lexer.Lexer, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		// "<<" などをそのまま書く
+		enc.SetEscapeHTML(false)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if err := enc.Encode(jsonToken{tok.Type, tok.Literal, tok.Row, tok.Column, tok.Offset}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "table":
+		// 全体を溜めずに流すため幅は固定. TYPE の幅は一番長い Whitespace に合わせる
+		if _, err := fmt.Fprintf(w, "%-6s  %-6s  %-10s  %s\n", "LINE", "COLUMN", "TYPE", "LITERAL"); err != nil {
+			return err
+		}
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if _, err := fmt.Fprintf(w, "%-6d  %-6d  %-10s  %q\n", tok.Row, tok.Column, tok.Type, tok.Literal); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if _, err := fmt.Fprintf(w, "%d:%d %s %q\n", tok.Row, tok.Column, tok.Type, tok.Literal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,498 @@
+package python
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Python の演算子の優先順位. 大きいほど強く結合する
+const (
+	_ int = iota
+	precTernary
+	precOr
+	precAnd
+	precNot
+	precCompare
+	precBitOr
+	precBitXor
+	precBitAnd
+	precSum
+	precProduct
+	precUnary
+	precPostfix // a.b, a[0], f()
+	precAtom
+)
+
+var infixes = map[string]struct {
+	op   string
+	prec int
+}{
+	"||": {"or", precOr},
+	"&&": {"and", precAnd},
+	"==": {"==", precCompare},
+	"!=": {"!=", precCompare},
+	"<":  {"<", precCompare},
+	">":  {">", precCompare},
+	"<=": {"<=", precCompare},
+	">=": {">=", precCompare},
+	"^":  {"^", precBitXor}, // MVector の外積
+	"+":  {"+", precSum},
+	"-":  {"-", precSum},
+	"*":  {"*", precProduct}, // MVector の内積
+	"/":  {"/", precProduct},
+	"%":  {"%", precProduct},
+}
+
+var keywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true,
+	"assert": true, "async": true, "await": true, "break": true, "class": true,
+	"continue": true, "def": true, "del": true, "elif": true, "else": true,
+	"except": true, "finally": true, "for": true, "from": true, "global": true,
+	"if": true, "import": true, "in": true, "is": true, "lambda": true,
+	"nonlocal": true, "not": true, "or": true, "pass": true, "raise": true,
+	"return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+// 変換した Python で使う名前
+var builtins = map[string]bool{
+	"abs": true, "exec": true, "float": true, "int": true, "len": true, "max": true,
+	"min": true, "print": true, "range": true, "str": true,
+	"cmds": true, "math": true, "mel": true, "MVector": true,
+}
+
+// math module にある関数
+var mathFunctions = map[string]string{
+	"sqrt": "sqrt", "pow": "pow", "exp": "exp", "log": "log", "log10": "log10",
+	"sin": "sin", "cos": "cos", "tan": "tan", "asin": "asin", "acos": "acos",
+	"atan": "atan", "atan2": "atan2", "hypot": "hypot", "floor": "floor",
+	"ceil": "ceil", "trunc": "trunc", "deg_to_rad": "radians", "rad_to_deg": "degrees",
+}
+
+// Python に同じものが無い MEL の関数
+var unsupported = map[string]bool{
+	"catch": true, "catchQuiet": true, "tokenize": true, "tokenizeList": true,
+	"match": true, "gmatch": true, "substitute": true, "substituteAllString": true,
+	"rand": true, "seed": true,
+}
+
+// varName は $foo を foo にする
+func varName(name string) string {
+	return procName(strings.TrimPrefix(name, "$"))
+}
+
+// procName は Python の予約語や使っている名前に _ を付ける
+func procName(name string) string {
+	if keywords[name] || builtins[name] {
+		return name + "_"
+	}
+	return name
+}
+
+func (t *transpiler) expr(exp ast.Expression) string {
+	s, _ := t.exprPrec(exp)
+	return s
+}
+
+// condition は if や while の条件を書く
+func (t *transpiler) condition(exp ast.Expression) string {
+	return t.expr(exp)
+}
+
+// operand は prec より弱く結合する式を括弧で囲む
+func (t *transpiler) operand(exp ast.Expression, prec int) string {
+	s, p := t.exprPrec(exp)
+	if p < prec {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func (t *transpiler) exprPrec(exp ast.Expression) (string, int) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if exp.Token.Type == token.ProcIdent {
+			// コマンドの引数の pCube1 などは文字列
+			return strconv.Quote(exp.Value), precAtom
+		}
+		return varName(exp.Value), precAtom
+	case *ast.IntegerLiteral:
+		return exp.Token.Literal, precAtom
+	case *ast.FloatLiteral:
+		return exp.Token.Literal, precAtom
+	case *ast.StringLiteral:
+		// MEL だけのエスケープがあるので値から書き直す. Go の quote は Python 3 でも同じ文字列になる
+		return strconv.Quote(exp.Value), precAtom
+	case *ast.BooleanLiteral:
+		switch exp.Token.Literal {
+		case "true", "on", "yes":
+			return "True", precAtom
+		}
+		return "False", precAtom
+	case *ast.ArrayLiteral:
+		return "[" + t.list(exp.Elements) + "]", precAtom
+	case *ast.TensorLiteral:
+		if len(exp.Values) == 1 && len(exp.Values[0]) == 3 {
+			t.imports["MVector"] = true
+			return "MVector(" + t.list(exp.Values[0]) + ")", precPostfix
+		}
+		var rows []string
+		for _, row := range exp.Values {
+			rows = append(rows, "["+t.list(row)+"]")
+		}
+		return "[" + strings.Join(rows, ", ") + "]", precAtom
+	case *ast.PrefixExpression:
+		switch exp.Operator {
+		case "!":
+			return "not " + t.operand(exp.Right, precNot), precNot
+		case "-", "+":
+			return exp.Operator + t.operand(exp.Right, precUnary), precUnary
+		}
+		t.fail(exp.Token, "%s in an expression", exp.Operator)
+	case *ast.PostfixExpression:
+		t.fail(exp.Token, "%s in an expression", exp.Operator)
+	case *ast.InfixExpression:
+		return t.infix(exp)
+	case *ast.TernaryExpression:
+		return fmt.Sprintf("%s if %s else %s",
+			t.operand(exp.TrueExp, precTernary+1),
+			t.operand(exp.Conditional, precTernary+1),
+			t.operand(exp.FalseExp, precTernary)), precTernary
+	case *ast.IndexExpression:
+		if exp.Index == nil {
+			t.fail(exp.Token, "array without an index")
+		}
+		return t.operand(exp.Left, precPostfix) + "[" + t.expr(exp.Index) + "]", precPostfix
+	case *ast.CastExpression:
+		switch exp.Token.Literal {
+		case "int", "float":
+			return exp.Token.Literal + "(" + t.expr(exp.Right) + ")", precPostfix
+		case "string":
+			return "str(" + t.expr(exp.Right) + ")", precPostfix
+		case "vector":
+			t.imports["MVector"] = true
+			return "MVector(" + t.expr(exp.Right) + ")", precPostfix
+		}
+		t.fail(exp.Token, "%s cast", exp.Token.Literal)
+	case *ast.CallExpression:
+		return t.callPrec(exp)
+	case *ast.FlagArgument:
+		t.fail(exp.Token, "flag %s outside a command", exp.Flag)
+	}
+	t.fail(token.Token{}, "unknown expression %T", exp)
+	return "", 0
+}
+
+func (t *transpiler) list(exps []ast.Expression) string {
+	var s []string
+	for _, e := range exps {
+		s = append(s, t.expr(e))
+	}
+	return strings.Join(s, ", ")
+}
+
+func (t *transpiler) infix(ie *ast.InfixExpression) (string, int) {
+	if ie.Operator == "." {
+		// $v.x は MVector の成分
+		right, ok := ie.Right.(*ast.Identifier)
+		if !ok {
+			t.fail(ie.Token, "unknown member %s", ie.Right)
+		}
+		return t.operand(ie.Left, precPostfix) + "." + right.Value, precPostfix
+	}
+
+	in, ok := infixes[ie.Operator]
+	if !ok {
+		t.fail(ie.Token, "operator %s", ie.Operator)
+	}
+	op, prec := in.op, in.prec
+
+	lprec, rprec := prec, prec+1
+	if prec == precCompare {
+		// Python は a < b < c を続けて比べるので括弧が要る
+		lprec = prec + 1
+	}
+	left, right := t.operand(ie.Left, lprec), t.operand(ie.Right, rprec)
+
+	ltype, rtype := t.typeOf(ie.Left), t.typeOf(ie.Right)
+	switch ie.Operator {
+	case "/":
+		if ltype == "int" && rtype == "int" {
+			// MEL の int の割り算は 0 の方へ切り捨てる. Python の // は負の方へ切り捨てる
+			return "int(" + left + " / " + right + ")", precAtom
+		}
+	case "%":
+		// MEL の余りは C と同じく左の符号になる. Python の % は右の符号になる
+		if ltype == "int" && rtype == "int" {
+			t.imports["math"] = true
+			return "int(math.fmod(" + t.expr(ie.Left) + ", " + t.expr(ie.Right) + "))", precAtom
+		}
+		if (ltype == "int" || ltype == "float") && (rtype == "int" || rtype == "float") {
+			t.imports["math"] = true
+			return "math.fmod(" + t.expr(ie.Left) + ", " + t.expr(ie.Right) + ")", precPostfix
+		}
+	case "+":
+		// 文字列に数を足す時は str() にする
+		if ltype == "string" && rtype != "string" && rtype != "" {
+			right = "str(" + t.expr(ie.Right) + ")"
+		}
+		if rtype == "string" && ltype != "string" && ltype != "" {
+			left = "str(" + t.expr(ie.Left) + ")"
+		}
+	}
+	return left + " " + op + " " + right, prec
+}
+
+// typeOf は式の MEL の型を返す. 分からない時は ""
+func (t *transpiler) typeOf(exp ast.Expression) string {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if exp.Token.Type == token.ProcIdent {
+			return "string"
+		}
+		return t.types[exp.Value]
+	case *ast.IntegerLiteral, *ast.BooleanLiteral:
+		return "int"
+	case *ast.FloatLiteral:
+		return "float"
+	case *ast.StringLiteral:
+		return "string"
+	case *ast.TensorLiteral:
+		if len(exp.Values) == 1 && len(exp.Values[0]) == 3 {
+			return "vector"
+		}
+		return "matrix"
+	case *ast.CastExpression:
+		return exp.Token.Literal
+	case *ast.IndexExpression:
+		return strings.TrimSuffix(t.typeOf(exp.Left), "[]")
+	case *ast.PrefixExpression:
+		if exp.Operator == "!" {
+			return "int"
+		}
+		return t.typeOf(exp.Right)
+	case *ast.TernaryExpression:
+		if typ := t.typeOf(exp.TrueExp); typ == t.typeOf(exp.FalseExp) {
+			return typ
+		}
+	case *ast.InfixExpression:
+		switch exp.Operator {
+		case "||", "&&", "==", "!=", "<", ">", "<=", ">=":
+			return "int"
+		case ".":
+			return "float"
+		}
+		ltype, rtype := t.typeOf(exp.Left), t.typeOf(exp.Right)
+		switch {
+		case exp.Operator == "+" && (ltype == "string" || rtype == "string"):
+			return "string"
+		case ltype == "vector" || rtype == "vector":
+			if exp.Operator == "*" && ltype == rtype {
+				return "float"
+			}
+			return "vector"
+		case ltype == "int" && rtype == "int":
+			return "int"
+		case (ltype == "int" || ltype == "float") && (rtype == "int" || rtype == "float"):
+			return "float"
+		}
+	case *ast.CallExpression:
+		if exp.Function == nil {
+			return ""
+		}
+		if typ, ok := t.procs[exp.Function.Value]; ok {
+			return typ
+		}
+		switch exp.Function.Value {
+		case "size":
+			return "int"
+		case "tolower", "toupper", "strip", "substring":
+			return "string"
+		case "unit", "cross":
+			return "vector"
+		case "mag", "dot":
+			return "float"
+		}
+		if _, ok := mathFunctions[exp.Function.Value]; ok {
+			return "float"
+		}
+	}
+	return ""
+}
+
+// call は文としての呼び出しを書く
+func (t *transpiler) call(ce *ast.CallExpression) string {
+	s, _ := t.callPrec(ce)
+	return s
+}
+
+func (t *transpiler) callPrec(ce *ast.CallExpression) (string, int) {
+	if ce.Function == nil {
+		t.fail(ce.Token, "call without a name")
+	}
+	name := ce.Function.Value
+	if _, ok := t.procs[name]; ok {
+		return procName(name) + "(" + t.list(ast.FlattenArguments(ce.Arguments)) + ")", precPostfix
+	}
+	if unsupported[name] {
+		t.fail(ce.Function.Token, "%s has no Python equivalent", name)
+	}
+
+	args := ast.FlattenArguments(ce.Arguments)
+	arg := func(i int) string {
+		if i >= len(args) {
+			t.fail(ce.Function.Token, "%s needs %d arguments", name, i+1)
+		}
+		return t.expr(args[i])
+	}
+	if f, ok := mathFunctions[name]; ok {
+		t.imports["math"] = true
+		return "math." + f + "(" + t.list(args) + ")", precPostfix
+	}
+
+	switch name {
+	case "size":
+		return "len(" + arg(0) + ")", precPostfix
+	case "abs", "min", "max":
+		return name + "(" + t.list(args) + ")", precPostfix
+	case "print":
+		return "print(" + t.list(args) + `, end="")`, precPostfix
+	case "tolower":
+		return t.operand(args[0], precPostfix) + ".lower()", precPostfix
+	case "toupper":
+		return t.operand(args[0], precPostfix) + ".upper()", precPostfix
+	case "strip":
+		return t.operand(args[0], precPostfix) + ".strip()", precPostfix
+	case "substring":
+		// MEL は 1 から数えて end を含む
+		arg(2)
+		start := t.operand(&ast.InfixExpression{Left: args[1], Operator: "-", Right: one()}, precSum)
+		if lit, ok := args[1].(*ast.IntegerLiteral); ok {
+			start = strconv.FormatInt(lit.Value-1, 10)
+		}
+		return t.operand(args[0], precPostfix) + "[" + start + ":" + arg(2) + "]", precPostfix
+	case "clamp":
+		return fmt.Sprintf("max(%s, min(%s, %s))", arg(0), arg(1), arg(2)), precPostfix
+	case "stringArrayContains":
+		return t.operand(args[0], precCompare+1) + " in " + t.operand(args[1], precCompare+1), precCompare
+	case "mag":
+		return t.operand(args[0], precPostfix) + ".length()", precPostfix
+	case "unit":
+		return t.operand(args[0], precPostfix) + ".normal()", precPostfix
+	case "cross":
+		return t.operand(args[0], precBitXor) + " ^ " + t.operand(args[1], precBitXor+1), precBitXor
+	case "dot":
+		return t.operand(args[0], precProduct) + " * " + t.operand(args[1], precProduct+1), precProduct
+	case "eval":
+		if len(args) != 1 {
+			t.fail(ce.Function.Token, "eval with %d arguments", len(args))
+		}
+		t.imports["mel"] = true
+		return "mel.eval(" + arg(0) + ")", precPostfix
+	case "python":
+		return "exec(" + arg(0) + ")", precPostfix
+	case "source":
+		if len(args) != 1 {
+			t.fail(ce.Function.Token, "source with %d arguments", len(args))
+		}
+		var file string
+		switch a := args[0].(type) {
+		case *ast.Identifier:
+			file = a.Value
+		case *ast.StringLiteral:
+			file = a.Token.Literal
+		default:
+			t.fail(ce.Function.Token, "source of an expression")
+		}
+		t.imports["mel"] = true
+		return "mel.eval(" + strconv.Quote("source "+file) + ")", precPostfix
+	}
+
+	return t.command(ce), precPostfix
+}
+
+func one() *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{Token: token.Token{Type: token.Int, Literal: "1"}, Value: 1}
+}
+
+// keyword は cmds の関数の keyword 引数
+type keyword struct {
+	name   string
+	values []string
+	list   bool // 何度も使った flag
+}
+
+// command は cmds.name(objects, flag=value) を書く.
+// flag の値は parser がコマンドのデータベースの引数の数で分けている
+func (t *transpiler) command(ce *ast.CallExpression) string {
+	name := ce.Function.Value
+	t.imports["cmds"] = true
+
+	query := false
+	for _, arg := range ce.Arguments {
+		if fa, ok := arg.(*ast.FlagArgument); ok && (fa.Flag == "-q" || fa.Flag == "-query") {
+			query = true
+		}
+	}
+
+	var positional []string
+	var kwargs []*keyword
+	index := map[string]*keyword{}
+	for _, arg := range ce.Arguments {
+		fa, ok := arg.(*ast.FlagArgument)
+		if !ok {
+			positional = append(positional, t.expr(arg))
+			continue
+		}
+
+		flag := strings.TrimPrefix(fa.Flag, "-")
+		arity := len(fa.Values)
+		if flag == "q" || flag == "query" || flag == "e" || flag == "edit" || query {
+			// データベースに無いコマンドでも query の時 flag は値を取らない
+			arity = 0
+		}
+
+		value := "True"
+		switch arity {
+		case 0:
+		case 1:
+			value = t.expr(fa.Values[0])
+		default:
+			value = "(" + t.list(fa.Values[:arity]) + ")"
+		}
+		for _, v := range fa.Values[arity:] {
+			positional = append(positional, t.expr(v))
+		}
+
+		if k, ok := index[flag]; ok {
+			k.values = append(k.values, value)
+			k.list = true
+			continue
+		}
+		k := &keyword{name: flag, values: []string{value}}
+		index[flag] = k
+		kwargs = append(kwargs, k)
+	}
+
+	args := positional
+	var reserved []string
+	for _, k := range kwargs {
+		value := k.values[0]
+		if k.list {
+			value = "[" + strings.Join(k.values, ", ") + "]"
+		}
+		if keywords[k.name] {
+			// in=... は書けないので dict で渡す
+			reserved = append(reserved, strconv.Quote(k.name)+": "+value)
+			continue
+		}
+		args = append(args, k.name+"="+value)
+	}
+	if len(reserved) != 0 {
+		args = append(args, "**{"+strings.Join(reserved, ", ")+"}")
+	}
+	return "cmds." + name + "(" + strings.Join(args, ", ") + ")"
+}
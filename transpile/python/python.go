@@ -0,0 +1,913 @@
+// Package python converts MEL to Python that uses maya.cmds.
+//
+// Command calls become cmds.foo(objects, flag=value), procs become def and
+// vectors become maya.api.OpenMaya.MVector. Statements that can not be
+// converted are kept as TODO comments with the MEL source line.
+package python
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/commands"
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// TODO is the marker of the comments of untranslatable statements.
+const TODO = "# TODO(mel2py):"
+
+const indentString = "    "
+
+// Transpile converts MEL source to Python. The flags of the commands in db
+// are split from the objects by their number of arguments. If db is nil,
+// commands.Default is used. It returns an error if src has syntax errors.
+func Transpile(src []byte, db *commands.DB) ([]byte, error) {
+	input := string(src)
+	if db == nil {
+		db = commands.Default()
+	}
+
+	p := parser.New(lexer.NewWithMode(input, lexer.ScanComments))
+	p.SetFlagArity(db.FlagArity)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, p.Errors()
+	}
+	t := &transpiler{
+		lines:   strings.Split(input, "\n"),
+		procs:   map[string]string{},
+		imports: map[string]bool{},
+		helpers: map[string]bool{},
+		types:   map[string]string{},
+	}
+	return t.program(program), nil
+}
+
+// untranslatable は変換できない式や文の場所. panic で文の変換を止める
+type untranslatable struct {
+	tok    token.Token
+	reason string
+}
+
+type transpiler struct {
+	out    bytes.Buffer
+	indent int
+	code   int // 書いたコメントでない行の数
+
+	lines   []string          // MEL の各行
+	procs   map[string]string // program で定義された proc の戻り値の型
+	imports map[string]bool   // 使った module
+	helpers map[string]bool   // 使った helper 関数
+	types   map[string]string // 宣言された変数の型. ex) "$a" -> "int[]"
+
+	comments []*ast.CommentGroup        // まだ書いていない文に付かないコメント
+	attached map[*ast.CommentGroup]bool // 文に付いたコメント
+
+	after    [][]ast.Statement // 今の文の後に実行される文. 外のブロックの分も持つ
+	inProc   bool
+	switches int // 入れ子の switch の数
+	afterDef bool
+}
+
+func (t *transpiler) program(program *ast.Program) []byte {
+	t.attached = map[*ast.CommentGroup]bool{}
+	for _, stmt := range program.Statements {
+		if ps := procOf(stmt); ps != nil {
+			t.procs[ps.Name.Literal] = typeName(ps.ReturnType)
+		}
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			if c := commentsOf(node); c != nil {
+				t.attached[c.Leading] = true
+				t.attached[c.Trailing] = true
+			}
+			return true
+		})
+	}
+	t.comments = program.Comments
+
+	for i, stmt := range program.Statements {
+		isDef := procOf(stmt) != nil
+		if t.out.Len() != 0 && (isDef || t.afterDef) {
+			// 関数の前後は 2 行空ける
+			t.out.WriteString("\n\n")
+		}
+		t.after = [][]ast.Statement{program.Statements[i+1:]}
+		t.statement(stmt)
+		t.afterDef = isDef
+	}
+	t.freeComments(token.Pos(-1))
+
+	// 標準ライブラリと maya の import の間は 1 行空ける
+	var out bytes.Buffer
+	if t.imports["math"] {
+		out.WriteString("import math\n")
+	}
+	maya := false
+	for _, imp := range []struct{ name, line string }{
+		{"cmds", "import maya.cmds as cmds"},
+		{"mel", "import maya.mel as mel"},
+		{"MVector", "from maya.api.OpenMaya import MVector"},
+	} {
+		if !t.imports[imp.name] {
+			continue
+		}
+		if !maya && out.Len() != 0 {
+			out.WriteString("\n")
+		}
+		maya = true
+		out.WriteString(imp.line + "\n")
+	}
+	for _, h := range helpers {
+		if !t.helpers[h.name] {
+			continue
+		}
+		if out.Len() != 0 {
+			out.WriteString("\n\n")
+		}
+		out.WriteString(h.def)
+	}
+	if out.Len() != 0 && t.out.Len() != 0 {
+		out.WriteString("\n\n")
+	}
+	out.Write(t.out.Bytes())
+	return out.Bytes()
+}
+
+// helpers は Python に同じものが無い MEL の動きを書いた関数
+var helpers = []struct{ name, def string }{
+	// MEL の配列は範囲の外に代入すると 0 や "" で伸びる
+	{"_set", `def _set(array, index, value):
+    if index >= len(array):
+        array.extend([type(value)()] * (index + 1 - len(array)))
+    array[index] = value
+`},
+}
+
+// commentsOf は文に付いたコメントを返す. global の文では中の文のコメント
+func commentsOf(node ast.Node) *ast.Comments {
+	if gs, ok := node.(*ast.GlobalStatement); ok {
+		node = gs.Statement
+	}
+	if cs, ok := node.(ast.CommentedStatement); ok {
+		return cs.Attached()
+	}
+	return nil
+}
+
+func procOf(stmt ast.Statement) *ast.ProcStatement {
+	if gs, ok := stmt.(*ast.GlobalStatement); ok {
+		stmt = gs.Statement
+	}
+	ps, _ := stmt.(*ast.ProcStatement)
+	return ps
+}
+
+// line は今の indent で一行書く
+func (t *transpiler) line(format string, a ...interface{}) {
+	t.out.WriteString(strings.Repeat(indentString, t.indent))
+	fmt.Fprintf(&t.out, format, a...)
+	t.out.WriteString("\n")
+	t.code++
+}
+
+// freeComments は pos より前にある文に付かないコメントを書く. pos が負の時は全部書く
+func (t *transpiler) freeComments(pos token.Pos) {
+	for len(t.comments) != 0 && (pos < 0 || t.comments[0].End() <= pos) {
+		if g := t.comments[0]; !t.attached[g] {
+			t.comment(g.Text())
+		}
+		t.comments = t.comments[1:]
+	}
+}
+
+func (t *transpiler) comment(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(strings.TrimPrefix(strings.TrimSpace(line), "*"), " ")
+		if line == "" {
+			t.out.WriteString(strings.Repeat(indentString, t.indent) + "#\n")
+			continue
+		}
+		t.out.WriteString(strings.Repeat(indentString, t.indent) + "# " + strings.TrimSpace(line) + "\n")
+	}
+}
+
+// todo は変換できなかった MEL の行をコメントとして残す
+func (t *transpiler) todo(u untranslatable) {
+	src := ""
+	if u.tok.Row > 0 && u.tok.Row <= len(t.lines) {
+		src = strings.TrimSpace(t.lines[u.tok.Row-1])
+	}
+	t.out.WriteString(strings.Repeat(indentString, t.indent))
+	fmt.Fprintf(&t.out, "%s line %d: %s: %s\n", TODO, u.tok.Row, u.reason, src)
+}
+
+func (t *transpiler) fail(tok token.Token, format string, a ...interface{}) {
+	panic(untranslatable{tok: tok, reason: fmt.Sprintf(format, a...)})
+}
+
+// statement は文を一つ書く. 変換できない時は書いた分を捨てて TODO を書く
+func (t *transpiler) statement(stmt ast.Statement) {
+	t.freeComments(stmt.Pos())
+	c := commentsOf(stmt)
+	if c != nil && c.Leading != nil {
+		t.comment(c.Leading.Text())
+	}
+
+	start, code, indent, switches := t.out.Len(), t.code, t.indent, t.switches
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				u, ok := r.(untranslatable)
+				if !ok {
+					panic(r)
+				}
+				t.out.Truncate(start)
+				t.code, t.indent, t.switches = code, indent, switches
+				t.todo(u)
+			}
+		}()
+		t.convert(stmt)
+	}()
+
+	if c != nil && c.Trailing != nil && t.out.Len() > start {
+		// 最後の行の後ろに書く
+		t.out.Truncate(t.out.Len() - 1)
+		t.out.WriteString("  # " + c.Trailing.Text() + "\n")
+	}
+}
+
+func (t *transpiler) statements(stmts []ast.Statement) {
+	for i, stmt := range stmts {
+		t.after = append(t.after, stmts[i+1:])
+		t.statement(stmt)
+		t.after = t.after[:len(t.after)-1]
+	}
+}
+
+// loopBody はループの中身を書く. 次の周回でループ全体がもう一度実行される
+func (t *transpiler) loopBody(loop ast.Expression, stmts []ast.Statement) {
+	t.after = append(t.after, []ast.Statement{&ast.ExpressionStatement{Expression: loop}})
+	t.body(stmts)
+	t.after = t.after[:len(t.after)-1]
+}
+
+// body は indent したブロックを書く. 中身が無ければ pass を書く
+func (t *transpiler) body(stmts []ast.Statement) {
+	t.indent++
+	code := t.code
+	t.statements(stmts)
+	if t.code == code {
+		t.line("pass")
+	}
+	t.indent--
+}
+
+func blockStatements(block *ast.BlockStatement) []ast.Statement {
+	if block == nil {
+		return nil
+	}
+	return block.Statements
+}
+
+func (t *transpiler) convert(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		t.expressionStatement(stmt.Expression)
+	case *ast.VariableStatement:
+		t.assignments(stmt.Names, stmt.Assigns, stmt.Values)
+	case *ast.IntegerStatement:
+		t.declaration("int", stmt.Names, stmt.Assigns, stmt.Values, false)
+	case *ast.FloatStatement:
+		t.declaration("float", stmt.Names, stmt.Assigns, stmt.Values, false)
+	case *ast.StringStatement:
+		t.declaration("string", stmt.Names, stmt.Assigns, stmt.Values, false)
+	case *ast.VectorStatement:
+		t.declaration("vector", stmt.Names, stmt.Assigns, stmt.Values, false)
+	case *ast.MatrixStatement:
+		t.declaration("matrix", stmt.Names, stmt.Assigns, stmt.Values, false)
+	case *ast.GlobalStatement:
+		t.global(stmt)
+	case *ast.ProcStatement:
+		t.proc(stmt)
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue == nil {
+			t.line("return")
+			return
+		}
+		t.line("return %s", t.expr(stmt.ReturnValue))
+	case *ast.BreakStatement:
+		t.line("break")
+	case *ast.ContinueStatement:
+		t.line("continue")
+	case *ast.BlockStatement:
+		// Python にはブロックのスコープが無い
+		t.statements(stmt.Statements)
+	case *ast.BadStatement:
+		t.fail(stmt.From, "syntax error")
+	default:
+		t.fail(token.Token{}, "unknown statement %T", stmt)
+	}
+}
+
+func (t *transpiler) global(gs *ast.GlobalStatement) {
+	switch stmt := gs.Statement.(type) {
+	case *ast.ProcStatement:
+		t.proc(stmt)
+	case *ast.IntegerStatement:
+		t.declaration("int", stmt.Names, stmt.Assigns, stmt.Values, true)
+	case *ast.FloatStatement:
+		t.declaration("float", stmt.Names, stmt.Assigns, stmt.Values, true)
+	case *ast.StringStatement:
+		t.declaration("string", stmt.Names, stmt.Assigns, stmt.Values, true)
+	case *ast.VectorStatement:
+		t.declaration("vector", stmt.Names, stmt.Assigns, stmt.Values, true)
+	case *ast.MatrixStatement:
+		t.declaration("matrix", stmt.Names, stmt.Assigns, stmt.Values, true)
+	default:
+		t.fail(gs.Token, "unknown global statement")
+	}
+}
+
+func (t *transpiler) proc(ps *ast.ProcStatement) {
+	outer := t.types
+	t.types = map[string]string{}
+	for name, typ := range outer {
+		t.types[name] = typ
+	}
+
+	var params []string
+	for i, p := range ps.Parameters {
+		ident := target(p)
+		if ident == nil {
+			t.fail(ps.Name, "unknown parameter %s", p)
+		}
+		if i < len(ps.ParamTypes) {
+			t.types[ident.Value] = typeName(ps.ParamTypes[i])
+		}
+		params = append(params, varName(ident.Value))
+	}
+	t.line("def %s(%s):", procName(ps.Name.Literal), strings.Join(params, ", "))
+
+	after := t.after
+	t.after = nil
+	t.inProc = true
+	t.body(blockStatements(ps.Body))
+	t.inProc = false
+	t.after = after
+	t.types = outer
+}
+
+// declaration は型のある変数の宣言を書く
+func (t *transpiler) declaration(typ string, names []ast.Expression, assigns []token.Token, values []ast.Expression, global bool) {
+	for i, name := range names {
+		ident := target(name)
+		if ident == nil {
+			t.fail(token.Token{}, "unknown variable %s", name)
+		}
+		declType := typ
+		if _, ok := name.(*ast.IndexExpression); ok && typ != "matrix" {
+			declType += "[]"
+		}
+		t.types[ident.Value] = declType
+
+		if global && t.inProc {
+			// 関数の中では global 変数を宣言し直す
+			t.line("global %s", varName(ident.Value))
+		}
+
+		var value ast.Expression
+		if i < len(values) {
+			value = values[i]
+		}
+		if value == nil {
+			if global && t.inProc {
+				continue
+			}
+			t.line("%s = %s", varName(ident.Value), t.zero(typ, name))
+			continue
+		}
+
+		op := "="
+		if i < len(assigns) && assigns[i].Literal != "" {
+			op = assigns[i].Literal
+		}
+		v := t.expr(value)
+		if declType == "vector" && (t.typeOf(value) != "vector" || target(value) != nil) {
+			// コマンドが返す float[] も MVector にする. 変数は copy する
+			t.imports["MVector"] = true
+			v = "MVector(" + v + ")"
+		}
+		t.line("%s %s %s", varName(ident.Value), op, v)
+	}
+}
+
+// zero は初期値の無い変数の値
+func (t *transpiler) zero(typ string, name ast.Expression) string {
+	ie, isArray := name.(*ast.IndexExpression)
+	if typ == "matrix" {
+		rows, cols := "1", "1"
+		if inner, ok := ie.Left.(*ast.IndexExpression); ok && ie.Index != nil && inner.Index != nil {
+			rows, cols = t.expr(inner.Index), t.expr(ie.Index)
+		}
+		return fmt.Sprintf("[[0.0] * %s for _ in range(%s)]", cols, rows)
+	}
+
+	zero := map[string]string{"int": "0", "float": "0.0", "string": `""`, "vector": "MVector()"}[typ]
+	if typ == "vector" {
+		t.imports["MVector"] = true
+	}
+	if !isArray {
+		return zero
+	}
+	if ie.Index == nil {
+		return "[]"
+	}
+	return fmt.Sprintf("[%s] * %s", zero, t.expr(ie.Index))
+}
+
+// assignments は $a = 1; $a[0] += 2; を書く
+func (t *transpiler) assignments(names []ast.Expression, assigns []token.Token, values []ast.Expression) {
+	for i, name := range names {
+		if i >= len(values) || values[i] == nil {
+			t.line("%s", t.expr(name))
+			continue
+		}
+		op := "="
+		if i < len(assigns) && assigns[i].Literal != "" {
+			op = assigns[i].Literal
+		}
+
+		// $a[size($a)] = $v; は配列の最後に追加する
+		if ie, ok := name.(*ast.IndexExpression); ok && op == "=" {
+			if ce, ok := ie.Index.(*ast.CallExpression); ok && ce.Function != nil && ce.Function.Value == "size" &&
+				len(ce.Arguments) == 1 && ce.Arguments[0].String() == ie.Left.String() {
+				t.line("%s.append(%s)", t.expr(ie.Left), t.expr(values[i]))
+				continue
+			}
+		}
+
+		// $a[$i] = $v; は配列を伸ばすので helper で代入する
+		if ie, ok := name.(*ast.IndexExpression); ok && op == "=" && ie.Index != nil {
+			if _, ok := ie.Left.(*ast.Identifier); ok {
+				t.helpers["_set"] = true
+				t.line("_set(%s, %s, %s)", t.expr(ie.Left), t.expr(ie.Index), t.expr(values[i]))
+				continue
+			}
+		}
+
+		if op == "/=" && t.typeOf(name) == "int" && t.typeOf(values[i]) == "int" {
+			// int の割り算は 0 の方へ切り捨てる
+			target := t.expr(name)
+			t.line("%s = int(%s / %s)", target, target, t.operand(values[i], precProduct+1))
+			continue
+		}
+
+		if ident, ok := name.(*ast.Identifier); ok && t.types[ident.Value] == "" {
+			// 宣言していない変数は値の型になる
+			t.types[ident.Value] = t.typeOf(values[i])
+		}
+		t.line("%s %s %s", t.expr(name), op, t.expr(values[i]))
+	}
+}
+
+func (t *transpiler) expressionStatement(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.IfExpression:
+		t.ifStatement(exp, "if")
+	case *ast.WhileExpression:
+		t.line("while %s:", t.condition(exp.Condition))
+		t.loopBody(exp, blockStatements(exp.Consequence))
+	case *ast.DoWhileExpression:
+		if hasContinue(blockStatements(exp.Consequence)) {
+			t.fail(exp.Token, "continue in do-while")
+		}
+		t.line("while True:")
+		t.loopBody(exp, blockStatements(exp.Consequence))
+		t.indent++
+		t.line("if not %s:", t.operand(exp.Condition, precNot))
+		t.indent++
+		t.line("break")
+		t.indent -= 2
+	case *ast.ForExpression:
+		t.forStatement(exp)
+	case *ast.ForInExpression:
+		if exp.Element == nil {
+			t.fail(exp.Token, "for-in without a variable")
+		}
+		t.line("for %s in %s:", varName(exp.Element.Value), t.expr(exp.ArrayElement))
+		t.loopBody(exp, blockStatements(exp.Consequence))
+	case *ast.SwitchExpression:
+		t.switchStatement(exp)
+	case *ast.PostfixExpression:
+		t.increment(exp.Left, exp.Operator)
+	case *ast.PrefixExpression:
+		if exp.Operator == "++" || exp.Operator == "--" {
+			t.increment(exp.Right, exp.Operator)
+			return
+		}
+		t.line("%s", t.expr(exp))
+	case *ast.Identifier:
+		if exp.Token.Type == token.ProcIdent {
+			// "foo;" は引数の無い呼び出し
+			t.line("%s", t.call(&ast.CallExpression{Token: exp.Token, Function: exp, Syntax: ast.CommandCall}))
+			return
+		}
+		t.line("%s", t.expr(exp))
+	default:
+		t.line("%s", t.expr(exp))
+	}
+}
+
+func (t *transpiler) increment(exp ast.Expression, op string) {
+	if op == "++" {
+		t.line("%s += 1", t.expr(exp))
+		return
+	}
+	t.line("%s -= 1", t.expr(exp))
+}
+
+func (t *transpiler) ifStatement(ie *ast.IfExpression, keyword string) {
+	t.line("%s %s:", keyword, t.condition(ie.Condition))
+	t.body(blockStatements(ie.Consequence))
+
+	alt := blockStatements(ie.Alternative)
+	if len(alt) == 0 {
+		return
+	}
+	if len(alt) == 1 {
+		if es, ok := alt[0].(*ast.ExpressionStatement); ok {
+			if elif, ok := es.Expression.(*ast.IfExpression); ok && es.Leading == nil {
+				t.ifStatement(elif, "elif")
+				return
+			}
+		}
+	}
+	t.line("else:")
+	t.body(alt)
+}
+
+// forStatement は for ($i = 0; $i < $n; $i++) を range に, それ以外を while にする
+func (t *transpiler) forStatement(fe *ast.ForExpression) {
+	if r, ok := t.forRange(fe); ok {
+		t.line("for %s in %s:", varName(fe.InitNames[0].(*ast.Identifier).Value), r)
+		t.loopBody(fe, blockStatements(fe.Consequence))
+		return
+	}
+
+	if hasContinue(blockStatements(fe.Consequence)) {
+		t.fail(fe.Token, "continue in for loop")
+	}
+	t.assignments(fe.InitNames, fe.InitAssigns, fe.InitValues)
+	cond := "True"
+	if fe.Condition != nil {
+		cond = t.condition(fe.Condition)
+	}
+	t.line("while %s:", cond)
+	t.loopBody(fe, append(append([]ast.Statement{}, blockStatements(fe.Consequence)...), fe.ChangeOfs...))
+}
+
+// forRange は range で書ける for の range(...) を返す.
+// range は終わりを一度しか評価せず, ループの後の変数は最後の値で止まるので,
+// 終わりが数か中で代入しない変数で, ループの後で変数を読まない時だけ使う
+func (t *transpiler) forRange(fe *ast.ForExpression) (string, bool) {
+	if len(fe.InitNames) != 1 || len(fe.InitValues) != 1 || len(fe.ChangeOfs) != 1 {
+		return "", false
+	}
+	ident, ok := fe.InitNames[0].(*ast.Identifier)
+	if !ok || fe.InitValues[0] == nil || (len(fe.InitAssigns) > 0 && fe.InitAssigns[0].Literal != "=") {
+		return "", false
+	}
+	cond, ok := fe.Condition.(*ast.InfixExpression)
+	if !ok || cond.Left.String() != ident.Value {
+		return "", false
+	}
+
+	step := ""
+	switch change := fe.ChangeOfs[0].(type) {
+	case *ast.ExpressionStatement:
+		switch exp := change.Expression.(type) {
+		case *ast.PostfixExpression:
+			if exp.Left.String() == ident.Value {
+				step = map[string]string{"++": "1", "--": "-1"}[exp.Operator]
+			}
+		case *ast.PrefixExpression:
+			if exp.Right.String() == ident.Value {
+				step = map[string]string{"++": "1", "--": "-1"}[exp.Operator]
+			}
+		}
+	case *ast.VariableStatement:
+		if len(change.Names) == 1 && len(change.Values) == 1 && change.Names[0].String() == ident.Value {
+			if lit, ok := change.Values[0].(*ast.IntegerLiteral); ok && len(change.Assigns) == 1 {
+				switch change.Assigns[0].Literal {
+				case "+=":
+					step = lit.Token.Literal
+				case "-=":
+					step = "-" + lit.Token.Literal
+				}
+			}
+		}
+	}
+	if step == "" || step == "0" || assigns(blockStatements(fe.Consequence), ident.Value) {
+		return "", false
+	}
+	switch end := cond.Right.(type) {
+	case *ast.IntegerLiteral:
+	case *ast.Identifier:
+		if end.Token.Type != token.Ident || assigns(blockStatements(fe.Consequence), end.Value) {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	for _, stmts := range t.after {
+		if reads(stmts, ident.Value) {
+			return "", false
+		}
+	}
+
+	end := t.expr(cond.Right)
+	down := strings.HasPrefix(step, "-")
+	switch {
+	case cond.Operator == "<" && !down, cond.Operator == ">" && down:
+	case cond.Operator == "<=" && !down:
+		end = t.inclusive(cond.Right, 1)
+	case cond.Operator == ">=" && down:
+		end = t.inclusive(cond.Right, -1)
+	default:
+		return "", false
+	}
+
+	t.types[ident.Value] = "int"
+	start := t.expr(fe.InitValues[0])
+	if step == "1" {
+		if start == "0" {
+			return fmt.Sprintf("range(%s)", end), true
+		}
+		return fmt.Sprintf("range(%s, %s)", start, end), true
+	}
+	return fmt.Sprintf("range(%s, %s, %s)", start, end, step), true
+}
+
+// inclusive は $i <= end の end を range の終わりにする
+func (t *transpiler) inclusive(end ast.Expression, d int64) string {
+	if lit, ok := end.(*ast.IntegerLiteral); ok {
+		return strconv.FormatInt(lit.Value+d, 10)
+	}
+	if d > 0 {
+		return t.expr(&ast.InfixExpression{Left: end, Operator: "+", Right: one()})
+	}
+	return t.expr(&ast.InfixExpression{Left: end, Operator: "-", Right: one()})
+}
+
+// switchStatement は switch を if/elif/else にする.
+// break の無い case は次の case の文も続けて書く
+func (t *transpiler) switchStatement(se *ast.SwitchExpression) {
+	value := t.expr(se.Condition)
+	switch se.Condition.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.StringLiteral, *ast.FloatLiteral:
+	default:
+		name := "_switch"
+		if t.switches > 0 {
+			name = fmt.Sprintf("_switch%d", t.switches+1)
+		}
+		t.line("%s = %s", name, value)
+		value = name
+	}
+	t.switches++
+	defer func() { t.switches-- }()
+
+	n := len(se.CaseStatements)
+	for i, cs := range se.CaseStatements {
+		if hasSwitchBreak(cs.Statements, false) {
+			t.fail(se.Token, "break inside a block of a case")
+		}
+		if i >= len(se.Cases) {
+			t.fail(cs.Token, "case without a label")
+		}
+	}
+
+	var defaultBody []ast.Statement
+	hasDefault := false
+	keyword := "if"
+	for i := 0; i < n; {
+		// 文の無い case は次の case と同じ条件にする
+		var labels []ast.Literal
+		j := i
+		for j < n && len(se.CaseStatements[j].Statements) == 0 {
+			labels = append(labels, se.Cases[j])
+			j++
+		}
+		if j == n {
+			break
+		}
+		labels = append(labels, se.Cases[j])
+
+		// break まで後ろの case の文も実行する
+		var body []ast.Statement
+	collect:
+		for k := j; k < n; k++ {
+			for _, stmt := range se.CaseStatements[k].Statements {
+				if _, ok := stmt.(*ast.BreakStatement); ok {
+					break collect
+				}
+				body = append(body, stmt)
+			}
+		}
+		i = j + 1
+
+		var values []string
+		isDefault := false
+		for _, label := range labels {
+			if label == nil {
+				isDefault = true
+				continue
+			}
+			values = append(values, t.expr(label))
+		}
+		if isDefault {
+			hasDefault = true
+			defaultBody = body
+			continue
+		}
+
+		if len(values) == 1 {
+			t.line("%s %s == %s:", keyword, value, values[0])
+		} else {
+			t.line("%s %s in (%s):", keyword, value, strings.Join(values, ", "))
+		}
+		t.body(body)
+		keyword = "elif"
+	}
+
+	if !hasDefault {
+		return
+	}
+	if keyword == "if" {
+		// default しか無い
+		t.statements(defaultBody)
+		return
+	}
+	t.line("else:")
+	t.body(defaultBody)
+}
+
+// hasSwitchBreak は case の中の if などのブロックに switch を抜ける break があるか調べる
+func hasSwitchBreak(stmts []ast.Statement, nested bool) bool {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.BreakStatement:
+			if nested {
+				return true
+			}
+		case *ast.BlockStatement:
+			if hasSwitchBreak(stmt.Statements, true) {
+				return true
+			}
+		case *ast.ExpressionStatement:
+			if ie, ok := stmt.Expression.(*ast.IfExpression); ok {
+				if hasSwitchBreak(blockStatements(ie.Consequence), true) ||
+					hasSwitchBreak(blockStatements(ie.Alternative), true) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hasContinue はループの中の (入れ子のループでない) continue を探す
+func hasContinue(stmts []ast.Statement) bool {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.ContinueStatement:
+			return true
+		case *ast.BlockStatement:
+			if hasContinue(stmt.Statements) {
+				return true
+			}
+		case *ast.ExpressionStatement:
+			switch exp := stmt.Expression.(type) {
+			case *ast.IfExpression:
+				if hasContinue(blockStatements(exp.Consequence)) || hasContinue(blockStatements(exp.Alternative)) {
+					return true
+				}
+			case *ast.SwitchExpression:
+				for _, cs := range exp.CaseStatements {
+					if hasContinue(cs.Statements) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// assigns は文の中で変数 name に代入しているか調べる
+func assigns(stmts []ast.Statement, name string) bool {
+	found := false
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.VariableStatement:
+				for _, v := range n.Names {
+					if ident := target(v); ident != nil && ident.Value == name {
+						found = true
+					}
+				}
+			case *ast.PostfixExpression:
+				if n.Left.String() == name {
+					found = true
+				}
+			case *ast.PrefixExpression:
+				if (n.Operator == "++" || n.Operator == "--") && n.Right.String() == name {
+					found = true
+				}
+			case *ast.ForInExpression:
+				if n.Element != nil && n.Element.Value == name {
+					found = true
+				}
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// reads は続けて実行する文が変数 name の今の値を読むか調べる.
+// 先に for の初期化で代入し直す時は読まない
+func reads(stmts []ast.Statement, name string) bool {
+	for _, stmt := range stmts {
+		if es, ok := stmt.(*ast.ExpressionStatement); ok {
+			if fe, ok := es.Expression.(*ast.ForExpression); ok && initializes(fe, name) {
+				return false
+			}
+		}
+		found := false
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.ProcStatement:
+				// proc の変数は別
+				return false
+			case *ast.ForExpression:
+				if initializes(n, name) {
+					return false
+				}
+			case *ast.Identifier:
+				if n.Value == name {
+					found = true
+				}
+			}
+			return !found
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// initializes は for ($i = 0; ...) が値に name を使わずに name へ代入するか調べる
+func initializes(fe *ast.ForExpression, name string) bool {
+	if len(fe.InitNames) == 0 || len(fe.InitValues) == 0 || fe.InitValues[0] == nil {
+		return false
+	}
+	ident, ok := fe.InitNames[0].(*ast.Identifier)
+	if !ok || ident.Value != name || (len(fe.InitAssigns) > 0 && fe.InitAssigns[0].Literal != "=") {
+		return false
+	}
+	return !reads([]ast.Statement{&ast.ExpressionStatement{Expression: fe.InitValues[0]}}, name)
+}
+
+// typeName は int[] のような型の名前を返す
+func typeName(td *ast.TypeDeclaration) string {
+	if td == nil {
+		return ""
+	}
+	if td.IsArray {
+		return td.Token.Literal + "[]"
+	}
+	return td.Token.Literal
+}
+
+// target は $a, $a[], $a[0] から変数を取り出す
+func target(exp ast.Expression) *ast.Identifier {
+	for {
+		switch e := exp.(type) {
+		case *ast.Identifier:
+			if e.Token.Type != token.Ident {
+				return nil
+			}
+			return e
+		case *ast.IndexExpression:
+			exp = e.Left
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package python
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranspile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`int $a = 1; float $f; string $s[]; int $n[3];`,
+			"a = 1\nf = 0.0\ns = []\nn = [0] * 3\n",
+		},
+		{
+			`int $a = 7 / 2; float $f = 7 / 2.0; string $s = "n" + $a;`,
+			"a = int(7 / 2)\nf = 7 / 2.0\ns = \"n\" + str(a)\n",
+		},
+		{
+			// 文字列は値を Python のエスケープで書き直す. MEL の文字列は改行をそのまま含める
+			"string $s = \"tab\\t \\\"q\\\" \\\\ é\\n\";\n$s = \"a\nb\";",
+			"s = \"tab\\t \\\"q\\\" \\\\ é\\n\"\ns = \"a\\nb\"\n",
+		},
+		{
+			// int の割り算は 0 の方へ切り捨てる
+			`int $a = -7 / 2 * 3; int $b = $a / ($a - 1); $a /= $b + 1; float $f = 1; $f /= 2;`,
+			"a = int(-7 / 2) * 3\nb = int(a / (a - 1))\na = int(a / (b + 1))\nf = 1\nf /= 2\n",
+		},
+		{
+			// int の余りは左の符号になる
+			`int $m = -7 % 2; int $n = -7 / 2 % 3; float $f = -7.5 % 2;`,
+			"import math\n\n\nm = int(math.fmod(-7, 2))\nn = int(math.fmod(int(-7 / 2), 3))\nf = math.fmod(-7.5, 2)\n",
+		},
+		{
+			`$a = !$b && ($c || $d); $e = ($a < $b) == $c; $f = -($a - 1) * 2;`,
+			"a = not b and (c or d)\ne = (a < b) == c\nf = -(a - 1) * 2\n",
+		},
+		{
+			`$x = $a > 1 ? "big" : "small"; $on = on; $off = false;`,
+			"x = \"big\" if a > 1 else \"small\"\non = True\noff = False\n",
+		},
+		{
+			`$i++; $j -= 2; $a[size($a)] = "x"; $a[0] += 1;`,
+			"i += 1\nj -= 2\na.append(\"x\")\na[0] += 1\n",
+		},
+		{
+			`string $s[]; $s[2] = "c"; $m[$i][0] = 1;`,
+			"def _set(array, index, value):\n    if index >= len(array):\n        array.extend([type(value)()] * (index + 1 - len(array)))\n    array[index] = value\n\n\ns = []\n_set(s, 2, \"c\")\nm[i][0] = 1\n",
+		},
+		{
+			`vector $v = <<1, 2, 3>>; vector $w; vector $u = $w; float $x = $v.x + mag($u);`,
+			"from maya.api.OpenMaya import MVector\n\n\nv = MVector(1, 2, 3)\nw = MVector()\nu = MVector(w)\nx = v.x + u.length()\n",
+		},
+		{
+			`string $s = substring($name, 2, 4) + tolower("A"); int $n = (int)$s;`,
+			"s = name[1:4] + \"A\".lower()\nn = int(s)\n",
+		},
+		{
+			`float $r = sqrt(2) + clamp(0, 1, $x);`,
+			"import math\n\n\nr = math.sqrt(2) + max(0, min(1, x))\n",
+		},
+		{
+			`vector $v = unit(<<1, 0, 0>>); print (cos($v.x));`,
+			"import math\n\nfrom maya.api.OpenMaya import MVector\n\n\nv = MVector(1, 0, 0).normal()\nprint(math.cos(v.x), end=\"\")\n",
+		},
+		{
+			// 変数の名前が Python の予約語や関数の時は _ を付ける
+			`string $in = "a"; int $len = size($in);`,
+			"in_ = \"a\"\nlen_ = len(in_)\n",
+		},
+		{
+			"if ($a) {\n\tprint \"a\";\n} else if ($b) {\n} else {\n\t$c = 1;\n}",
+			"if a:\n    print(\"a\", end=\"\")\nelif b:\n    pass\nelse:\n    c = 1\n",
+		},
+		{
+			`while ($i < 3) $i++; do { $i--; } while ($i > 0);`,
+			"while i < 3:\n    i += 1\nwhile True:\n    i -= 1\n    if not i > 0:\n        break\n",
+		},
+		{
+			`for ($e in $list) print $e;`,
+			"for e in list:\n    print(e, end=\"\")\n",
+		},
+		{
+			`for ($i = 0; $i < 5; $i++) {} for ($i = 1; $i <= $n; $i += 2) {} for ($i = 9; $i >= 0; $i--) {}`,
+			"for i in range(5):\n    pass\nfor i in range(1, n + 1, 2):\n    pass\nfor i in range(9, -1, -1):\n    pass\n",
+		},
+		{
+			// ループの中で変数を変える時は while にする
+			`for ($i = 0; $i < 5; $i++) { $i += 1; }`,
+			"i = 0\nwhile i < 5:\n    i += 1\n    i += 1\n",
+		},
+		{
+			// ループの後で変数を読む時は while にする. range の後の i は 1 少ない
+			`for ($i = 0; $i < 3; $i++) {} print $i; if ($a) { for ($j = 0; $j < 3; $j++) {} } print $j;`,
+			"i = 0\nwhile i < 3:\n    i += 1\nprint(i, end=\"\")\nif a:\n    j = 0\n    while j < 3:\n        j += 1\nprint(j, end=\"\")\n",
+		},
+		{
+			// 次の周回で読む時も while にする
+			`while ($k) { print $i; for ($i = 0; $i < 3; $i++) {} }`,
+			"while k:\n    print(i, end=\"\")\n    i = 0\n    while i < 3:\n        i += 1\n",
+		},
+		{
+			// range は終わりを一度しか評価しないので, 変わるかもしれない終わりは while にする
+			`for ($i = 0; $i < size($a); $i++) { $a[$i + 1] = 1; } for ($i = 0; $i < $n; $i++) { $n--; }`,
+			"def _set(array, index, value):\n    if index >= len(array):\n        array.extend([type(value)()] * (index + 1 - len(array)))\n    array[index] = value\n\n\ni = 0\nwhile i < len(a):\n    _set(a, i + 1, 1)\n    i += 1\ni = 0\nwhile i < n:\n    n -= 1\n    i += 1\n",
+		},
+		{
+			"switch ($x) {\n\tcase 1:\n\tcase 2:\n\t\tprint \"a\";\n\t\tbreak;\n\tcase 3:\n\t\tprint \"b\";\n\tdefault:\n\t\tprint \"c\";\n}",
+			"if x in (1, 2):\n    print(\"a\", end=\"\")\nelif x == 3:\n    print(\"b\", end=\"\")\n    print(\"c\", end=\"\")\nelse:\n    print(\"c\", end=\"\")\n",
+		},
+		{
+			"switch (`getAttr a.v`) {\n\tcase 0:\n\t\tbreak;\n\tdefault:\n\t\t$v = 1;\n}",
+			"import maya.cmds as cmds\n\n\n_switch = cmds.getAttr(\"a.v\")\nif _switch == 0:\n    pass\nelse:\n    v = 1\n",
+		},
+		{
+			"// make a cube\nglobal proc string make(float $w) {\n\tglobal int $count;\n\t$count++;\n\tstring $r[] = `polyCube -w $w`; // create\n\treturn $r[0];\n}\nmake 1.0;",
+			"import maya.cmds as cmds\n\n\n# make a cube\ndef make(w):\n    global count\n    count += 1\n    r = cmds.polyCube(w=w)  # create\n    return r[0]\n\n\nmake(1.0)\n",
+		},
+		{
+			`source "lib.mel"; eval "ls"; python("import os");`,
+			"import maya.mel as mel\n\n\nmel.eval(\"source \\\"lib.mel\\\"\")\nmel.eval(\"ls\")\nexec(\"import os\")\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := Transpile([]byte(tt.input), nil)
+		if err != nil {
+			t.Errorf("input %q: %s", tt.input, err)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("input %q wrong.\nwant=\n%s\ngot=\n%s", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestTranspileCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`polyCube -w 2 -sx 3 -n "box";`, `cmds.polyCube(w=2, sx=3, n="box")`},
+		{`polyCube -ax 0 1 0 -ch off;`, `cmds.polyCube(ax=(0, 1, 0), ch=False)`},
+		{`select -r pCube1 pCube2;`, `cmds.select("pCube1", "pCube2", r=True)`},
+		{`setAttr ($obj + ".tx") 1;`, `cmds.setAttr(obj + ".tx", 1)`},
+		{`setAttr -type "string" $n $v;`, `cmds.setAttr(n, v, type="string")`},
+		// query の flag は値を取らない
+		{`polyCube -q -w $obj;`, `cmds.polyCube(obj, q=True, w=True)`},
+		{`xform -q -ws -t pCube1;`, `cmds.xform("pCube1", q=True, ws=True, t=True)`},
+		// 知らない flag は次の flag までが値で, 最後の flag の後ろは object
+		{`myCommand -a 1 2 -b 3 obj1 obj2;`, `cmds.myCommand(3, "obj1", "obj2", a=(1, 2), b=True)`},
+		{`delete -at tx -at ty pCube1;`, `cmds.delete("pCube1", at=["tx", "ty"])`},
+		{`sets -in set1 -e obj1;`, `cmds.sets("obj1", e=True, **{"in": "set1"})`},
+		{"ls;", `cmds.ls()`},
+		{"$s = `ls -sl`;", `s = cmds.ls(sl=True)`},
+	}
+
+	for _, tt := range tests {
+		out, err := Transpile([]byte(tt.input), nil)
+		if err != nil {
+			t.Errorf("input %q: %s", tt.input, err)
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if got := lines[len(lines)-1]; got != tt.expected {
+			t.Errorf("input %q wrong. want=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestTranspileTODO(t *testing.T) {
+	input := `int $a = 1;
+if (catch(` + "`ls`" + `)) print "error";
+do {
+	continue;
+} while ($a);
+$b = $a++;
+print $a;`
+
+	expected := `a = 1
+# TODO(mel2py): line 2: catch has no Python equivalent: if (catch(` + "`ls`" + `)) print "error";
+# TODO(mel2py): line 3: continue in do-while: do {
+# TODO(mel2py): line 6: ++ in an expression: $b = $a++;
+print(a, end="")
+`
+	out, err := Transpile([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expected {
+		t.Errorf("wrong output.\nwant=\n%s\ngot=\n%s", expected, out)
+	}
+}
+
+func TestTranspileSyntaxError(t *testing.T) {
+	_, err := Transpile([]byte("int $a = ;"), nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
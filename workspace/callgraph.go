@@ -0,0 +1,324 @@
+package workspace
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/parser"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// 文字列の中の MEL を読む深さの上限
+const maxDepth = 8
+
+// 文字列を組み立てている時は先頭の proc の名前だけを読む
+var leadingName = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)`)
+
+// CallNode is a proc or the top level code of a file in the call graph.
+type CallNode struct {
+	ID     string `json:"id"`   // "file:name". top level の時は file
+	Name   string `json:"name"` // top level の時は ""
+	File   string `json:"file"`
+	Global bool   `json:"global"`
+	Line   int    `json:"line"` // top level の時は 0
+	Proc   *Proc  `json:"-"`    // top level の時は nil
+}
+
+// CallEdge is a call from a proc to another proc.
+type CallEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	String bool   `json:"string,omitempty"` // -command や eval の文字列の中の呼び出し
+}
+
+// CallGraph is the graph of the procs in the workspace and the procs they call.
+// The top level code of a file runs when the file is sourced, so it is a
+// node that is always reachable.
+type CallGraph struct {
+	Nodes []*CallNode `json:"nodes"`
+	Edges []*CallEdge `json:"edges"`
+
+	nodes map[string]*CallNode
+}
+
+// call は proc の中の呼び出し
+type call struct {
+	name   string
+	tok    token.Token // 報告する位置
+	string bool
+}
+
+// CallGraph builds the call graph of every proc in the workspace.
+//
+// Strings passed to eval, evalDeferred, evalEcho and to -command, -c and
+// other -...Command flags of UI commands are parsed as MEL, and the calls in
+// them are edges too. Calls of procs that are not in the workspace are
+// dropped.
+func (w *Workspace) CallGraph() *CallGraph {
+	g := &CallGraph{Nodes: []*CallNode{}, Edges: []*CallEdge{}, nodes: map[string]*CallNode{}}
+
+	type body struct {
+		node  *CallNode
+		file  *File
+		calls []call
+	}
+	var bodies []body
+
+	for _, f := range w.Files {
+		procs := map[*ast.ProcStatement]*Proc{}
+		for _, proc := range f.Procs {
+			procs[proc.Statement] = proc
+		}
+
+		var top *CallNode
+		for _, stmt := range f.Program.Statements {
+			ps, ok := stmt.(*ast.ProcStatement)
+			if gs, global := stmt.(*ast.GlobalStatement); global {
+				ps, ok = gs.Statement.(*ast.ProcStatement)
+			}
+			if ok && procs[ps] != nil {
+				proc := procs[ps]
+				node := g.add(&CallNode{
+					ID:     f.Path + ":" + proc.Name,
+					Name:   proc.Name,
+					File:   f.Path,
+					Global: proc.Global,
+					Line:   ps.Token.Row,
+					Proc:   proc,
+				})
+				bodies = append(bodies, body{node, f, collectCalls(ps.Body, 0)})
+				continue
+			}
+
+			if top == nil {
+				top = g.add(&CallNode{ID: f.Path, File: f.Path})
+			}
+			bodies = append(bodies, body{top, f, collectCalls(stmt, 0)})
+		}
+	}
+
+	for _, b := range bodies {
+		for _, c := range b.calls {
+			procs := w.Lookup(b.file, c.name)
+			if c.string && len(procs) == 1 && !procs[0].Global {
+				// 文字列は global scope で実行されるので local proc は呼べない
+				procs = w.Lookup(nil, c.name)
+			}
+			for _, proc := range procs {
+				g.Edges = append(g.Edges, &CallEdge{
+					From:   b.node.ID,
+					To:     proc.File.Path + ":" + proc.Name,
+					Line:   c.tok.Row,
+					Column: c.tok.Column,
+					String: c.string,
+				})
+			}
+		}
+	}
+
+	return g
+}
+
+func (g *CallGraph) add(node *CallNode) *CallNode {
+	if n, ok := g.nodes[node.ID]; ok {
+		// 同じファイルで定義し直した proc は一つの node にする
+		return n
+	}
+	g.Nodes = append(g.Nodes, node)
+	g.nodes[node.ID] = node
+	return node
+}
+
+// Node returns the node with id or nil.
+func (g *CallGraph) Node(id string) *CallNode {
+	return g.nodes[id]
+}
+
+func (g *CallGraph) next() map[string][]string {
+	next := map[string][]string{}
+	for _, e := range g.Edges {
+		next[e.From] = append(next[e.From], e.To)
+	}
+	return next
+}
+
+// Unreachable returns the procs that can not be reached from the top level
+// code of the files nor from the global procs named in entries.
+func (g *CallGraph) Unreachable(entries ...string) []*CallNode {
+	entry := map[string]bool{}
+	for _, name := range entries {
+		entry[name] = true
+	}
+
+	var queue []string
+	reached := map[string]bool{}
+	for _, n := range g.Nodes {
+		if n.Proc == nil || n.Global && entry[n.Name] {
+			reached[n.ID] = true
+			queue = append(queue, n.ID)
+		}
+	}
+
+	next := g.next()
+	for len(queue) != 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, to := range next[id] {
+			if !reached[to] {
+				reached[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+
+	var unreachable []*CallNode
+	for _, n := range g.Nodes {
+		if !reached[n.ID] {
+			unreachable = append(unreachable, n)
+		}
+	}
+	return unreachable
+}
+
+// Cycles returns the recursions in the graph. Each cycle lists the IDs of
+// the nodes in call order and ends with the first one.
+func (g *CallGraph) Cycles() [][]string {
+	var ids []string
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+	return cycles(ids, g.next())
+}
+
+// DOT returns the graph in the Graphviz DOT language.
+// Calls in strings are drawn with dashed edges.
+func (g *CallGraph) DOT() string {
+	var out bytes.Buffer
+
+	out.WriteString("digraph calls {\n")
+	for _, n := range g.Nodes {
+		label := n.Name
+		if n.Proc == nil {
+			label = n.File
+		}
+		attrs := fmt.Sprintf("label=%q", label)
+		if n.Proc == nil {
+			attrs += ", shape=box"
+		}
+		fmt.Fprintf(&out, "\t%q [%s];\n", n.ID, attrs)
+	}
+	for _, e := range g.Edges {
+		if e.String {
+			fmt.Fprintf(&out, "\t%q -> %q [style=dashed];\n", e.From, e.To)
+			continue
+		}
+		fmt.Fprintf(&out, "\t%q -> %q;\n", e.From, e.To)
+	}
+	out.WriteString("}\n")
+
+	return out.String()
+}
+
+// collectCalls は node の中の呼び出しを, 文字列の中の MEL も含めて集める
+func collectCalls(node ast.Node, depth int) []call {
+	var found []call
+	for _, ident := range calls(node) {
+		found = append(found, call{name: ident.Value, tok: ident.Token})
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ce, ok := n.(*ast.CallExpression); ok && ce.Function != nil {
+			for _, exp := range commandStrings(ce) {
+				found = append(found, stringCalls(exp, depth)...)
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// commandStrings は呼び出しの引数のうち MEL として実行される式を返す
+func commandStrings(ce *ast.CallExpression) []ast.Expression {
+	var exps []ast.Expression
+	switch ce.Function.Value {
+	case "eval", "evalDeferred", "evalEcho":
+		for _, arg := range ce.Arguments {
+			if _, ok := arg.(*ast.FlagArgument); !ok {
+				exps = append(exps, arg)
+			}
+		}
+		return exps
+	}
+	for i, arg := range ce.Arguments {
+		fa, ok := arg.(*ast.FlagArgument)
+		if !ok || !isCommandFlag(fa.Flag) {
+			continue
+		}
+		if len(fa.Values) != 0 {
+			// 後ろの値は object なので最初の値だけ
+			exps = append(exps, fa.Values[0])
+			continue
+		}
+		// signature の無い最後の flag は値を持たないので次の引数を値にする
+		if i+1 < len(ce.Arguments) {
+			if _, ok := ce.Arguments[i+1].(*ast.FlagArgument); !ok {
+				exps = append(exps, ce.Arguments[i+1])
+			}
+		}
+	}
+	return exps
+}
+
+// isCommandFlag は -command, -c, -changeCommand のような MEL を受け取る flag か調べる
+func isCommandFlag(flag string) bool {
+	return flag == "-c" || flag == "-command" || strings.HasSuffix(flag, "Command")
+}
+
+// stringCalls は MEL の文字列の中の呼び出しを文字列の位置で返す
+func stringCalls(exp ast.Expression, depth int) []call {
+	if depth >= maxDepth {
+		return nil
+	}
+
+	switch exp := exp.(type) {
+	case *ast.StringLiteral:
+		src := strings.TrimSpace(exp.Value)
+		if !strings.HasSuffix(src, ";") && !strings.HasSuffix(src, "}") {
+			// "myProc" のように最後の ';' は省略できる
+			src += ";"
+		}
+		program := parser.New(lexer.New(src)).ParseProgram()
+		var found []call
+		for _, stmt := range program.Statements {
+			for _, c := range collectCalls(stmt, depth+1) {
+				found = append(found, call{name: c.name, tok: exp.Token, string: true})
+			}
+		}
+		return found
+	case *ast.InfixExpression:
+		// ("myProc \"" + $name + "\"") は先頭の文字列から proc の名前を読む
+		left := exp
+		for {
+			l, ok := left.Left.(*ast.InfixExpression)
+			if !ok {
+				break
+			}
+			left = l
+		}
+		lit, ok := left.Left.(*ast.StringLiteral)
+		if !ok || left.Operator != "+" {
+			return nil
+		}
+		m := leadingName.FindStringSubmatch(lit.Value)
+		if m == nil {
+			return nil
+		}
+		return []call{{name: m[1], tok: lit.Token, string: true}}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package workspace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newCallGraphWorkspace() *Workspace {
+	w := New()
+	w.Add("shelf.mel", `
+global proc shelf_Tools() {
+	shelfButton -label "Rig" -command "rigTool" -image "rig.png";
+	menuItem -c ("exportTool \"" + $path + "\"") item1;
+}`)
+	w.Add("rigTool.mel", `
+global proc rigTool() {
+	button -label "Build" -c "buildRig 1; evalDeferred \"cleanup\"" b1;
+	rigHelper;
+}
+proc rigHelper() { rigHelper; }
+global proc buildRig(int $n) { local; }
+proc local() {}
+`)
+	w.Add("exportTool.mel", `global proc exportTool(string $p) { eval "exportTool $p"; }`)
+	w.Add("cleanup.mel", `global proc cleanup() {}`)
+	w.Add("legacy.mel", `
+global proc oldTool() { oldHelper; }
+global proc oldHelper() { oldTool(); }
+global proc unusedProc() { textField -changeCommand "local" t1; }
+`)
+	w.Add("startup.mel", `startupProc; global proc startupProc() {}`)
+	return w
+}
+
+func TestCallGraph(t *testing.T) {
+	g := newCallGraphWorkspace().CallGraph()
+
+	ids := []string{
+		"shelf.mel:shelf_Tools",
+		"rigTool.mel:rigTool", "rigTool.mel:rigHelper", "rigTool.mel:buildRig", "rigTool.mel:local",
+		"exportTool.mel:exportTool",
+		"cleanup.mel:cleanup",
+		"legacy.mel:oldTool", "legacy.mel:oldHelper", "legacy.mel:unusedProc",
+		"startup.mel", "startup.mel:startupProc",
+	}
+	var got []string
+	for _, n := range g.Nodes {
+		got = append(got, n.ID)
+	}
+	if !reflect.DeepEqual(got, ids) {
+		t.Errorf("wrong nodes.\nwant=%v\ngot=%v", ids, got)
+	}
+
+	edges := []CallEdge{
+		{From: "shelf.mel:shelf_Tools", To: "rigTool.mel:rigTool", Line: 3, Column: 36, String: true},
+		{From: "shelf.mel:shelf_Tools", To: "exportTool.mel:exportTool", Line: 4, Column: 15, String: true},
+		{From: "rigTool.mel:rigTool", To: "rigTool.mel:rigHelper", Line: 4, Column: 2},
+		{From: "rigTool.mel:rigTool", To: "rigTool.mel:buildRig", Line: 3, Column: 27, String: true},
+		{From: "rigTool.mel:rigTool", To: "cleanup.mel:cleanup", Line: 3, Column: 27, String: true},
+		{From: "rigTool.mel:rigHelper", To: "rigTool.mel:rigHelper", Line: 6, Column: 20},
+		{From: "rigTool.mel:buildRig", To: "rigTool.mel:local", Line: 7, Column: 32},
+		{From: "exportTool.mel:exportTool", To: "exportTool.mel:exportTool", Line: 1, Column: 42, String: true},
+		{From: "legacy.mel:oldTool", To: "legacy.mel:oldHelper", Line: 2, Column: 25},
+		{From: "legacy.mel:oldHelper", To: "legacy.mel:oldTool", Line: 3, Column: 27},
+		{From: "startup.mel", To: "startup.mel:startupProc", Line: 1, Column: 1},
+	}
+	if len(g.Edges) != len(edges) {
+		t.Errorf("wrong number of edges. want=%d, got=%d", len(edges), len(g.Edges))
+		for _, e := range g.Edges {
+			t.Errorf("  %+v", *e)
+		}
+		return
+	}
+	for i, e := range g.Edges {
+		if *e != edges[i] {
+			t.Errorf("g.Edges[%d] wrong.\nwant=%+v\ngot=%+v", i, edges[i], *e)
+		}
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	g := newCallGraphWorkspace().CallGraph()
+
+	tests := []struct {
+		entries  []string
+		expected []string
+	}{
+		{
+			[]string{"shelf_Tools"},
+			[]string{"legacy.mel:oldTool", "legacy.mel:oldHelper", "legacy.mel:unusedProc"},
+		},
+		{
+			// local proc は entry にならない
+			[]string{"shelf_Tools", "oldTool", "local"},
+			[]string{"legacy.mel:unusedProc"},
+		},
+		{
+			nil,
+			[]string{
+				"shelf.mel:shelf_Tools",
+				"rigTool.mel:rigTool", "rigTool.mel:rigHelper", "rigTool.mel:buildRig", "rigTool.mel:local",
+				"exportTool.mel:exportTool",
+				"cleanup.mel:cleanup",
+				"legacy.mel:oldTool", "legacy.mel:oldHelper", "legacy.mel:unusedProc",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, n := range g.Unreachable(tt.entries...) {
+			got = append(got, n.ID)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Unreachable(%v) wrong.\nwant=%v\ngot=%v", tt.entries, tt.expected, got)
+		}
+	}
+}
+
+func TestCallGraphCycles(t *testing.T) {
+	g := newCallGraphWorkspace().CallGraph()
+
+	expected := [][]string{
+		{"rigTool.mel:rigHelper", "rigTool.mel:rigHelper"},
+		{"exportTool.mel:exportTool", "exportTool.mel:exportTool"},
+		{"legacy.mel:oldTool", "legacy.mel:oldHelper", "legacy.mel:oldTool"},
+	}
+	if got := g.Cycles(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Cycles wrong.\nwant=%v\ngot=%v", expected, got)
+	}
+}
+
+func TestCallGraphDOT(t *testing.T) {
+	w := New()
+	w.Add("a.mel", `a; global proc a() { button -c "b" x; } global proc b() { a; }`)
+
+	expected := `digraph calls {
+	"a.mel" [label="a.mel", shape=box];
+	"a.mel:a" [label="a"];
+	"a.mel:b" [label="b"];
+	"a.mel" -> "a.mel:a";
+	"a.mel:a" -> "a.mel:b" [style=dashed];
+	"a.mel:b" -> "a.mel:a";
+}
+`
+	if got := w.CallGraph().DOT(); got != expected {
+		t.Errorf("DOT wrong.\nwant=\n%s\ngot=\n%s", expected, got)
+	}
+}
@@ -0,0 +1,260 @@
+package workspace
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+// Source is a source statement in a file.
+// ex) source "myLib.mel"; source myLib;
+type Source struct {
+	Call *ast.CallExpression
+	Name string // 読み込むファイルの名前. 式で作られる時は ""
+}
+
+// Token returns the token of the source command.
+func (s *Source) Token() token.Token {
+	return s.Call.Function.Token
+}
+
+// newSource は source の呼び出しから読み込むファイルの名前を取り出す
+func newSource(call *ast.CallExpression) *Source {
+	s := &Source{Call: call}
+	args := ast.FlattenArguments(call.Arguments)
+	if len(args) != 1 {
+		return s
+	}
+	switch arg := args[0].(type) {
+	case *ast.StringLiteral:
+		s.Name = arg.Value
+	case *ast.Identifier:
+		if arg.Token.Type == token.ProcIdent {
+			s.Name = arg.Value
+		}
+	}
+	return s
+}
+
+// Find returns the path of the file that source name reads, or "" if it
+// is not found. Like Maya, ".mel" is added to a name without an extension
+// and a relative name is searched in the script path.
+func (w *Workspace) Find(name string) string {
+	if name == "" {
+		return ""
+	}
+	if filepath.Ext(name) == "" {
+		name += ".mel"
+	}
+	if filepath.IsAbs(name) {
+		if w.exists(name) {
+			return filepath.Clean(name)
+		}
+		return ""
+	}
+
+	dirs := w.ScriptPath
+	if len(dirs) == 0 {
+		dirs = w.dirs
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if w.exists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+func (w *Workspace) exists(path string) bool {
+	if _, ok := w.files[filepath.Clean(path)]; ok {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// sourced は f が直接または間接に source しているワークスペースのファイル
+func (w *Workspace) sourced(f *File) []*File {
+	var files []*File
+	seen := map[*File]bool{f: true}
+	queue := []*File{f}
+	for len(queue) != 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, s := range cur.Sources {
+			dep := w.File(w.Find(s.Name))
+			if dep == nil || seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			files = append(files, dep)
+			queue = append(queue, dep)
+		}
+	}
+	return files
+}
+
+// Dependency is an edge of the source graph.
+type Dependency struct {
+	From   string `json:"from"`
+	To     string `json:"to,omitempty"` // 見つからない時は ""
+	Name   string `json:"name"`         // source に書かれた名前か式
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// Graph is the graph of the files and their source statements.
+type Graph struct {
+	Files   []string      `json:"files"`
+	Edges   []*Dependency `json:"edges"`
+	Missing []*Dependency `json:"missing"`
+	Cycles  [][]string    `json:"cycles"`
+}
+
+// LoadSources adds the sourced files found in the script path to the
+// workspace, and the files that they source in turn.
+func (w *Workspace) LoadSources() {
+	// 見つけたファイルを追加するので w.Files は途中で増える
+	for i := 0; i < len(w.Files); i++ {
+		for _, s := range w.Files[i].Sources {
+			if path := w.Find(s.Name); path != "" {
+				w.load(path)
+			}
+		}
+	}
+}
+
+// Graph resolves the source statements of every file and returns the graph.
+// It does not change the workspace. The source statements of a sourced file
+// that is not in the workspace are not followed, so call LoadSources first.
+func (w *Workspace) Graph() *Graph {
+	g := &Graph{Files: []string{}, Edges: []*Dependency{}, Missing: []*Dependency{}, Cycles: [][]string{}}
+
+	for _, f := range w.Files {
+		g.Files = append(g.Files, f.Path)
+		for _, s := range f.Sources {
+			tok := s.Token()
+			dep := &Dependency{From: f.Path, Name: s.Name, Line: tok.Row, Column: tok.Column}
+			if s.Name == "" {
+				dep.Name = sourceArguments(s.Call)
+			}
+			path := w.Find(s.Name)
+			if path == "" {
+				g.Missing = append(g.Missing, dep)
+				continue
+			}
+			dep.To = path
+			if to := w.File(path); to != nil {
+				dep.To = to.Path
+			}
+			g.Edges = append(g.Edges, dep)
+		}
+	}
+
+	next := map[string][]string{}
+	for _, e := range g.Edges {
+		next[e.From] = append(next[e.From], e.To)
+	}
+	g.Cycles = cycles(g.Files, next)
+	return g
+}
+
+// load は path のファイルがワークスペースに無ければ読み込む
+func (w *Workspace) load(path string) *File {
+	if f := w.File(path); f != nil {
+		return f
+	}
+	if err := w.AddFile(path); err != nil {
+		return nil
+	}
+	return w.File(path)
+}
+
+func sourceArguments(call *ast.CallExpression) string {
+	var args []string
+	for _, a := range ast.FlattenArguments(call.Arguments) {
+		args = append(args, a.String())
+	}
+	return strings.Join(args, " ")
+}
+
+// cycles は next を辺とするグラフの循環を見つけて, 循環する node を順に並べて返す.
+// 最後の要素は最初の要素と同じ node
+func cycles(nodes []string, next map[string][]string) [][]string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var stack []string
+	var found [][]string
+
+	var visit func(node string)
+	visit = func(node string) {
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, to := range next[node] {
+			switch state[to] {
+			case unvisited:
+				visit(to)
+			case visiting:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == to {
+						cycle := append([]string{}, stack[i:]...)
+						found = append(found, append(cycle, to))
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = done
+	}
+
+	for _, node := range nodes {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+	return found
+}
+
+// DOT returns the graph in the Graphviz DOT language.
+// Missing files are drawn with dashed red edges.
+func (g *Graph) DOT() string {
+	var out bytes.Buffer
+
+	out.WriteString("digraph deps {\n")
+	for _, f := range g.Files {
+		fmt.Fprintf(&out, "\t%q;\n", f)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&out, "\t%q -> %q;\n", e.From, e.To)
+	}
+
+	// 見つからないファイルは同じ名前を一つの node にする
+	var missing []string
+	seen := map[string]bool{}
+	for _, e := range g.Missing {
+		fmt.Fprintf(&out, "\t%q -> %q [style=dashed, color=red];\n", e.From, e.Name)
+		if !seen[e.Name] {
+			seen[e.Name] = true
+			missing = append(missing, e.Name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		fmt.Fprintf(&out, "\t%q [color=red];\n", name)
+	}
+	out.WriteString("}\n")
+
+	return out.String()
+}
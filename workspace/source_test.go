@@ -0,0 +1,179 @@
+package workspace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSources(t *testing.T) {
+	w := New()
+	f := w.Add("a.mel", "source \"lib.mel\";\nsource lib2;\nproc p() { source(\"C:\\\\x.mel\"); }\nsource ($dir + \"c.mel\");")
+
+	expected := []string{"lib.mel", "lib2", `C:\x.mel`, ""}
+	if len(f.Sources) != len(expected) {
+		t.Fatalf("wrong number of sources. want=%d, got=%d", len(expected), len(f.Sources))
+	}
+	for i, s := range f.Sources {
+		if s.Name != expected[i] {
+			t.Errorf("Sources[%d].Name wrong. want=%q, got=%q", i, expected[i], s.Name)
+		}
+	}
+	if tok := f.Sources[1].Token(); tok.Row != 2 || tok.Column != 1 {
+		t.Errorf("Sources[1] wrong position. got=line:%d.%d", tok.Row, tok.Column)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"first/lib.mel":   ``,
+		"second/lib.mel":  ``,
+		"second/util.mel": ``,
+		"second/x.txt":    ``,
+	})
+	defer os.RemoveAll(dir)
+
+	w := New()
+	w.ScriptPath = []string{filepath.Join(dir, "first"), filepath.Join(dir, "second")}
+	w.Add("mem/only.mel", ``)
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"lib", filepath.Join(dir, "first", "lib.mel")},
+		{"lib.mel", filepath.Join(dir, "first", "lib.mel")},
+		{"util", filepath.Join(dir, "second", "util.mel")},
+		{"x.txt", filepath.Join(dir, "second", "x.txt")},
+		{filepath.Join(dir, "second", "lib.mel"), filepath.Join(dir, "second", "lib.mel")},
+		{"missing", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := w.Find(tt.name); got != tt.expected {
+			t.Errorf("Find(%q) wrong. want=%q, got=%q", tt.name, tt.expected, got)
+		}
+	}
+
+	// ワークスペースにあるファイルはディスクに無くても見つかる
+	w.ScriptPath = []string{"mem"}
+	if got := w.Find("only"); got != filepath.Join("mem", "only.mel") {
+		t.Errorf("Find(only) wrong. got=%q", got)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"scripts/a.mel":  "source b;\nsource \"missing.mel\";\nglobal proc a() { helper; }",
+		"scripts/b.mel":  "source \"sub/c.mel\";",
+		"scripts/d.mel":  "source ($dir + \"e.mel\");",
+		"lib/sub/c.mel":  "source a;\nglobal proc helper() {}",
+		"lib/unused.mel": "",
+	})
+	defer os.RemoveAll(dir)
+
+	scripts := filepath.Join(dir, "scripts")
+	lib := filepath.Join(dir, "lib")
+	a := filepath.Join(scripts, "a.mel")
+	b := filepath.Join(scripts, "b.mel")
+	c := filepath.Join(lib, "sub", "c.mel")
+	d := filepath.Join(scripts, "d.mel")
+
+	w := New()
+	w.ScriptPath = []string{scripts, lib}
+	if err := w.AddDir(scripts); err != nil {
+		t.Fatal(err)
+	}
+
+	// Graph はワークスペースを変えない
+	g := w.Graph()
+	if !reflect.DeepEqual(g.Files, []string{a, b, d}) || len(w.Files) != 3 {
+		t.Errorf("Graph changed the workspace. g.Files=%v", g.Files)
+	}
+	if len(g.Edges) != 2 || len(g.Cycles) != 0 {
+		t.Errorf("wrong graph before LoadSources. edges=%d, cycles=%v", len(g.Edges), g.Cycles)
+	}
+
+	// lib/sub/c.mel はスクリプトパスから見つけて追加される
+	w.LoadSources()
+	g = w.Graph()
+	if !reflect.DeepEqual(g.Files, []string{a, b, d, c}) {
+		t.Errorf("g.Files wrong. got=%v", g.Files)
+	}
+
+	edges := []Dependency{
+		{From: a, To: b, Name: "b", Line: 1, Column: 1},
+		{From: b, To: c, Name: "sub/c.mel", Line: 1, Column: 1},
+		{From: c, To: a, Name: "a", Line: 1, Column: 1},
+	}
+	if len(g.Edges) != len(edges) {
+		t.Fatalf("wrong number of edges. want=%d, got=%d", len(edges), len(g.Edges))
+	}
+	for i, e := range g.Edges {
+		if *e != edges[i] {
+			t.Errorf("g.Edges[%d] wrong. want=%+v, got=%+v", i, edges[i], *e)
+		}
+	}
+
+	missing := []Dependency{
+		{From: a, Name: "missing.mel", Line: 2, Column: 1},
+		{From: d, Name: `($dir + "e.mel")`, Line: 1, Column: 1},
+	}
+	if len(g.Missing) != len(missing) {
+		t.Fatalf("wrong number of missing. want=%d, got=%d", len(missing), len(g.Missing))
+	}
+	for i, e := range g.Missing {
+		if *e != missing[i] {
+			t.Errorf("g.Missing[%d] wrong. want=%+v, got=%+v", i, missing[i], *e)
+		}
+	}
+
+	if !reflect.DeepEqual(g.Cycles, [][]string{{a, b, c, a}}) {
+		t.Errorf("g.Cycles wrong. got=%v", g.Cycles)
+	}
+
+	// source したファイルの global proc は名前が違っても呼べる
+	procs := w.Lookup(w.File(a), "helper")
+	if len(procs) != 1 || procs[0].File.Path != c {
+		t.Errorf("Lookup(a, helper) should find helper in c.mel. got=%v", procs)
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	w := New()
+	w.ScriptPath = []string{"."}
+	w.Add("a.mel", `source b; source x;`)
+	w.Add("b.mel", ``)
+
+	expected := `digraph deps {
+	"a.mel";
+	"b.mel";
+	"a.mel" -> "b.mel";
+	"a.mel" -> "x" [style=dashed, color=red];
+	"x" [color=red];
+}
+`
+	if got := w.Graph().DOT(); got != expected {
+		t.Errorf("DOT wrong.\nwant=\n%s\ngot=\n%s", expected, got)
+	}
+}
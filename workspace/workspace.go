@@ -0,0 +1,345 @@
+// Package workspace indexes the procs and global variables of MEL files and
+// resolves proc calls across the files the way Maya does.
+//
+// Maya finds an unknown proc foo by searching MAYA_SCRIPT_PATH for foo.mel
+// and sourcing it. Procs declared without global are visible only in the
+// file that defines them.
+package workspace
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nrtkbb/go-MEL/ast"
+	"github.com/nrtkbb/go-MEL/lexer"
+	"github.com/nrtkbb/go-MEL/object"
+	"github.com/nrtkbb/go-MEL/parser"
+	"github.com/nrtkbb/go-MEL/token"
+)
+
+var melFile = regexp.MustCompile(`\.mel$`)
+
+// Error is an unresolved or ambiguous call found in the workspace.
+type Error struct {
+	Filename string
+	Token    token.Token
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: line:%d.%d %s", e.Filename, e.Token.Row, e.Token.Column, e.Message)
+}
+
+// Proc is a proc defined at the top level of a file.
+type Proc struct {
+	Name      string
+	Global    bool
+	File      *File
+	Statement *ast.ProcStatement
+}
+
+// Variable is a global variable declared at the top level of a file.
+type Variable struct {
+	Name  string
+	File  *File
+	Ident *ast.Identifier
+}
+
+// File is a parsed MEL file and its symbols.
+type File struct {
+	Path    string
+	Program *ast.Program
+	Errors  parser.ErrorList
+
+	Procs   []*Proc
+	Globals []*Variable
+	Calls   []*ast.Identifier // 呼び出している proc の名前
+	Sources []*Source
+}
+
+// Workspace is the index of the procs and global variables of MEL files.
+// Files should be added in the order of MAYA_SCRIPT_PATH.
+type Workspace struct {
+	// Builtin reports whether name is a command or a proc provided by Maya.
+	// Calls of builtin names are not reported as unresolved. When it is nil,
+	// calls of names that no file in the workspace defines are not reported,
+	// because Maya has thousands of commands.
+	Builtin func(name string) bool
+
+	// ScriptPath is the directories searched for sourced files.
+	// When it is empty, the directories added with AddDir are used.
+	ScriptPath []string
+
+	Files []*File
+
+	dirs    []string
+	files   map[string]*File
+	procs   map[string][]*Proc // global proc
+	globals map[string][]*Variable
+}
+
+// New make Workspace instance.
+func New() *Workspace {
+	return &Workspace{
+		files:   map[string]*File{},
+		procs:   map[string][]*Proc{},
+		globals: map[string][]*Variable{},
+	}
+}
+
+// AddDir adds every .mel file under dir.
+func (w *Workspace) AddDir(dir string) error {
+	w.dirs = append(w.dirs, dir)
+	return WalkFiles(dir, melFile, w.AddFile)
+}
+
+// AddFile reads and adds the file at path.
+// Files with syntax errors are added with the procs that could be parsed.
+func (w *Workspace) AddFile(path string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	w.Add(path, string(src))
+	return nil
+}
+
+// calls は node の中で呼び出している proc の名前を順に集める.
+// "foo;" のように引数の無い呼び出しは文の Identifier になる
+func calls(node ast.Node) []*ast.Identifier {
+	var names []*ast.Identifier
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpression:
+			if n.Function != nil {
+				names = append(names, n.Function)
+			}
+		case *ast.ExpressionStatement:
+			if ident, ok := n.Expression.(*ast.Identifier); ok && ident.Token.Type == token.ProcIdent {
+				names = append(names, ident)
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// sourceCalls は node の中の source の呼び出しを集める
+func sourceCalls(node ast.Node) []*ast.CallExpression {
+	var sources []*ast.CallExpression
+	ast.Inspect(node, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpression); ok && call.Function != nil && call.Function.Value == "source" {
+			sources = append(sources, call)
+		}
+		return true
+	})
+	return sources
+}
+
+// Add parses src as the file at path and adds it.
+// A file that was already added with the same path is not added again.
+func (w *Workspace) Add(path, src string) *File {
+	if f := w.File(path); f != nil {
+		return f
+	}
+
+	p := parser.New(lexer.New(src))
+	f := &File{Path: path, Program: p.ParseProgram(), Errors: p.Errors()}
+
+	for _, stmt := range f.Program.Statements {
+		f.Calls = append(f.Calls, calls(stmt)...)
+		for _, call := range sourceCalls(stmt) {
+			f.Sources = append(f.Sources, newSource(call))
+		}
+
+		switch stmt := stmt.(type) {
+		case *ast.ProcStatement:
+			f.Procs = append(f.Procs, &Proc{Name: stmt.Name.Literal, File: f, Statement: stmt})
+		case *ast.GlobalStatement:
+			if ps, ok := stmt.Statement.(*ast.ProcStatement); ok {
+				proc := &Proc{Name: ps.Name.Literal, Global: true, File: f, Statement: ps}
+				f.Procs = append(f.Procs, proc)
+				w.procs[proc.Name] = append(w.procs[proc.Name], proc)
+				continue
+			}
+			names, _ := ast.Declaration(stmt.Statement)
+			for _, name := range names {
+				if ident := ast.DeclaredVariable(name); ident != nil {
+					v := &Variable{Name: ident.Value, File: f, Ident: ident}
+					f.Globals = append(f.Globals, v)
+					w.globals[v.Name] = append(w.globals[v.Name], v)
+				}
+			}
+		}
+	}
+
+	w.Files = append(w.Files, f)
+	w.files[filepath.Clean(path)] = f
+	return f
+}
+
+// File returns the file added with path or nil.
+func (w *Workspace) File(path string) *File {
+	return w.files[filepath.Clean(path)]
+}
+
+// Procs returns the global procs named name in the order they were added.
+func (w *Workspace) Procs(name string) []*Proc {
+	return w.procs[name]
+}
+
+// Globals returns the declarations of the global variable name in the order
+// they were added.
+func (w *Workspace) Globals(name string) []*Variable {
+	return w.globals[name]
+}
+
+// Lookup returns the procs that a call of name in f may reach, the one Maya
+// calls first.
+//
+// A proc defined in f is always used, then a global proc in the files that
+// f sources. Otherwise Maya sources name.mel, so the global procs in files
+// named name.mel are candidates. When no file matches the name, the global
+// procs in the other files are returned, because any of them may have been
+// sourced before at run time; the source order is not checked.
+func (w *Workspace) Lookup(f *File, name string) []*Proc {
+	if f != nil {
+		// 同じファイルで定義し直した時は後の定義が使われる
+		for i := len(f.Procs) - 1; i >= 0; i-- {
+			if f.Procs[i].Name == name {
+				return []*Proc{f.Procs[i]}
+			}
+		}
+		for _, dep := range w.sourced(f) {
+			for _, proc := range dep.Procs {
+				if proc.Name == name && proc.Global {
+					return []*Proc{proc}
+				}
+			}
+		}
+	}
+
+	var matched, others []*Proc
+	for _, proc := range w.procs[name] {
+		if filepath.Base(proc.File.Path) == name+".mel" {
+			matched = append(matched, proc)
+		} else {
+			others = append(others, proc)
+		}
+	}
+	if len(matched) != 0 {
+		return matched
+	}
+	return others
+}
+
+// Resolution is a call and the proc it calls.
+type Resolution struct {
+	File       *File
+	Call       *ast.Identifier
+	Proc       *Proc   // 呼び出される proc. 見つからない時は nil
+	Candidates []*Proc // 呼び出される可能性のある全ての proc
+}
+
+// Ambiguous reports whether more than one proc may be called.
+func (r *Resolution) Ambiguous() bool {
+	return len(r.Candidates) > 1
+}
+
+// Resolve resolves every call in the workspace in the order of the files.
+func (w *Workspace) Resolve() []*Resolution {
+	var resolutions []*Resolution
+	for _, f := range w.Files {
+		for _, call := range f.Calls {
+			r := &Resolution{File: f, Call: call, Candidates: w.Lookup(f, call.Value)}
+			if len(r.Candidates) != 0 {
+				r.Proc = r.Candidates[0]
+			}
+			resolutions = append(resolutions, r)
+		}
+	}
+	return resolutions
+}
+
+// Check returns the unresolved and ambiguous calls in the workspace.
+func (w *Workspace) Check() []*Error {
+	errors := []*Error{}
+	for _, r := range w.Resolve() {
+		name := r.Call.Value
+		switch {
+		case r.Ambiguous():
+			var paths []string
+			for _, proc := range r.Candidates {
+				paths = append(paths, proc.File.Path)
+			}
+			errors = append(errors, &Error{
+				Filename: r.File.Path,
+				Token:    r.Call.Token,
+				Message:  fmt.Sprintf("proc %s is defined in %d files: %s", name, len(paths), strings.Join(paths, ", ")),
+			})
+		case r.Proc == nil:
+			if w.builtin(name) {
+				continue
+			}
+			local := w.local(name)
+			if local == nil && w.Builtin == nil {
+				// どのファイルにも無い名前は Maya のコマンドとみなす
+				continue
+			}
+			msg := fmt.Sprintf("proc %s is not found", name)
+			if local != nil {
+				msg = fmt.Sprintf("proc %s is local to %s", name, local.File.Path)
+			}
+			errors = append(errors, &Error{Filename: r.File.Path, Token: r.Call.Token, Message: msg})
+		}
+	}
+	return errors
+}
+
+func (w *Workspace) builtin(name string) bool {
+	if object.IsTypeName(name) || name == "source" {
+		return true
+	}
+	return w.Builtin != nil && w.Builtin(name)
+}
+
+// local は他のファイルで定義された global でない proc name を探す
+func (w *Workspace) local(name string) *Proc {
+	for _, f := range w.Files {
+		for _, proc := range f.Procs {
+			if proc.Name == name && !proc.Global {
+				return proc
+			}
+		}
+	}
+	return nil
+}
+
+// WalkFiles passes every file under dir whose name matches re to fn in
+// lexical order. It stops at the first error returned by fn.
+func WalkFiles(dir string, re *regexp.Regexp, fn func(path string) error) error {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		if !re.MatchString(path) {
+			return nil
+		}
+
+		err = fn(path)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,194 @@
+package workspace
+
+import (
+	"os"
+	"testing"
+)
+
+func newTestWorkspace() *Workspace {
+	w := New()
+	w.Builtin = func(name string) bool { return name == "print" }
+	w.Add("scripts/main.mel", `
+global proc main() {
+	helper;
+	foo(1);
+	util "a";
+	print "done";
+	bar;
+	missing;
+}
+proc helper() { print "main"; }
+`)
+	w.Add("scripts/foo.mel", `
+global proc foo(int $a) { fooHelper; }
+proc fooHelper() {}
+global proc util(string $s) {}
+global string $gFoo;
+`)
+	w.Add("scripts/util.mel", `global proc util(string $s) {}`)
+	w.Add("scripts/bar.mel", `global proc bar() {}`)
+	w.Add("other/bar.mel", `global proc bar() {} global string $gFoo, $gBar[];`)
+	w.Add("scripts/local.mel", `proc missing() {}`)
+	return w
+}
+
+func TestLookup(t *testing.T) {
+	w := newTestWorkspace()
+	main := w.File("scripts/main.mel")
+
+	tests := []struct {
+		file     string
+		name     string
+		expected []string
+	}{
+		// 同じファイルの local proc
+		{"scripts/main.mel", "helper", []string{"scripts/main.mel"}},
+		{"scripts/foo.mel", "fooHelper", []string{"scripts/foo.mel"}},
+		{"scripts/main.mel", "fooHelper", nil},
+		// ファイル名が合う global proc
+		{"scripts/main.mel", "foo", []string{"scripts/foo.mel"}},
+		{"scripts/main.mel", "util", []string{"scripts/util.mel"}},
+		{"scripts/main.mel", "bar", []string{"scripts/bar.mel", "other/bar.mel"}},
+		// 同じファイルの定義はファイル名より優先する
+		{"scripts/foo.mel", "util", []string{"scripts/foo.mel"}},
+		{"scripts/main.mel", "missing", nil},
+	}
+
+	for _, tt := range tests {
+		f := w.File(tt.file)
+		procs := w.Lookup(f, tt.name)
+		if len(procs) != len(tt.expected) {
+			t.Errorf("Lookup(%s, %s) wrong number of procs. want=%d, got=%d",
+				tt.file, tt.name, len(tt.expected), len(procs))
+			continue
+		}
+		for i, proc := range procs {
+			if proc.Name != tt.name || proc.File.Path != tt.expected[i] {
+				t.Errorf("Lookup(%s, %s)[%d] wrong. want=%s, got=%s in %s",
+					tt.file, tt.name, i, tt.expected[i], proc.Name, proc.File.Path)
+			}
+		}
+	}
+
+	if len(main.Calls) != 7 {
+		t.Fatalf("main.mel has wrong number of calls. want=7, got=%d", len(main.Calls))
+	}
+}
+
+func TestLookupOtherFile(t *testing.T) {
+	w := New()
+	w.Add("a.mel", `global proc a() { shared; }`)
+	w.Add("lib.mel", `global proc shared() {}`)
+
+	procs := w.Lookup(w.File("a.mel"), "shared")
+	if len(procs) != 1 || procs[0].File.Path != "lib.mel" {
+		t.Errorf("Lookup should find shared in lib.mel. got=%v", procs)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	w := newTestWorkspace()
+
+	tests := []struct {
+		name     string
+		expected []string
+	}{
+		{"$gFoo", []string{"scripts/foo.mel", "other/bar.mel"}},
+		{"$gBar", []string{"other/bar.mel"}},
+		{"$none", nil},
+	}
+
+	for _, tt := range tests {
+		vars := w.Globals(tt.name)
+		if len(vars) != len(tt.expected) {
+			t.Errorf("Globals(%s) wrong number. want=%d, got=%d", tt.name, len(tt.expected), len(vars))
+			continue
+		}
+		for i, v := range vars {
+			if v.File.Path != tt.expected[i] || v.Ident.Value != tt.name {
+				t.Errorf("Globals(%s)[%d] wrong. want=%s, got=%s", tt.name, i, tt.expected[i], v.File.Path)
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	w := newTestWorkspace()
+
+	expected := []string{
+		"scripts/main.mel: line:7.2 proc bar is defined in 2 files: scripts/bar.mel, other/bar.mel",
+		"scripts/main.mel: line:8.2 proc missing is local to scripts/local.mel",
+	}
+
+	errs := w.Check()
+	if len(errs) != len(expected) {
+		t.Errorf("wrong number of errors. want=%d, got=%d", len(expected), len(errs))
+		for _, err := range errs {
+			t.Errorf("  %s", err)
+		}
+		return
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("wrong error. want=%q, got=%q", expected[i], err.Error())
+		}
+	}
+}
+
+func TestCheckNotFound(t *testing.T) {
+	// Builtin が nil の時はどのファイルにも無い名前を Maya のコマンドとみなす
+	w := New()
+	w.Add("a.mel", "int $i = int(1.5);\nnothing 1;\n`select -r a`;\nprint (`ls -sl`);\n"+
+		"button -c (substring($s, 1, 2)) b; xform -q -ws -t a; hidden;")
+	w.Add("b.mel", "proc hidden() {}")
+
+	expected := []string{
+		"a.mel: line:5.55 proc hidden is local to b.mel",
+	}
+	testErrors(t, w.Check(), expected)
+
+	w = New()
+	w.Add("a.mel", "int $i = int(1.5);\nnothing 1;\n`select -r a`;\nprint (`ls -sl`);")
+	w.Builtin = func(name string) bool { return name == "print" }
+	expected = []string{
+		"a.mel: line:2.1 proc nothing is not found",
+		"a.mel: line:3.2 proc select is not found",
+		"a.mel: line:4.9 proc ls is not found",
+	}
+	testErrors(t, w.Check(), expected)
+}
+
+func testErrors(t *testing.T, errs []*Error, expected []string) {
+	if len(errs) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d: %v", len(expected), len(errs), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("wrong error. want=%q, got=%q", expected[i], err.Error())
+		}
+	}
+}
+
+func TestAddDir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.mel":        `global proc a() { b; }`,
+		"sub/b.mel":    `global proc b() {}`,
+		"sub/note.txt": `global proc c() {}`,
+		"caramel":      `global proc c() {}`,
+	})
+	defer os.RemoveAll(dir)
+
+	w := New()
+	if err := w.AddDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Files) != 2 {
+		t.Fatalf("wrong number of files. want=2, got=%d", len(w.Files))
+	}
+	if errs := w.Check(); len(errs) != 0 {
+		t.Errorf("workspace has errors: %v", errs)
+	}
+	if len(w.Procs("c")) != 0 {
+		t.Errorf("proc c in note.txt and caramel should not be indexed")
+	}
+}